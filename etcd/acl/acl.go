@@ -0,0 +1,163 @@
+package acl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrForbidden = errors.New("acl: forbidden")
+
+// Guard is a KV that only lets a role reach the keys under its prefixes. A
+// request touching any key outside them, or a range not contained in a
+// single one of them, fails with ErrForbidden before it is sent. Txns are
+// checked as a whole, conditions and nested txns included.
+//
+// It does not replace etcd auth: it guards the tools built on this package
+// against mistakes, not the cluster against clients that bypass it.
+type Guard struct {
+	kv       etcdx.KV
+	role     string
+	prefixes []string
+}
+
+// NewGuard lets role reach the keys under prefixes, which are taken as they
+// are: "/tenant/a" also covers "/tenant/ab", "/tenant/a/" does not.
+func NewGuard(kv etcdx.KV, role string, prefixes ...string) *Guard {
+	return &Guard{kv: kv, role: role, prefixes: prefixes}
+}
+
+// Allowed reports whether [key, end) lies within one of the prefixes. An
+// empty end is the single key, and "\x00" the keys from key on.
+func (g *Guard) Allowed(key, end []byte) bool {
+	for _, p := range g.prefixes {
+		if !strings.HasPrefix(string(key), p) {
+			continue
+		}
+		if len(end) == 0 {
+			return true
+		}
+		pend := clientv3.GetPrefixRangeEnd(p)
+		if pend == "\x00" || string(end) != "\x00" && string(end) <= pend {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Guard) check(key, end []byte) error {
+	if g.Allowed(key, end) {
+		return nil
+	}
+	if len(end) == 0 {
+		return fmt.Errorf("%w: role %s: %s", ErrForbidden, g.role, key)
+	}
+	return fmt.Errorf("%w: role %s: %s to %s", ErrForbidden, g.role, key, end)
+}
+
+func (g *Guard) checkOp(op clientv3.Op) error {
+	if !op.IsTxn() {
+		return g.check(op.KeyBytes(), op.RangeBytes())
+	}
+	cmps, thens, elses := op.Txn()
+	if err := g.checkCmps(cmps); err != nil {
+		return err
+	}
+	if err := g.checkOps(thens); err != nil {
+		return err
+	}
+	return g.checkOps(elses)
+}
+
+func (g *Guard) checkOps(ops []clientv3.Op) error {
+	for _, op := range ops {
+		if err := g.checkOp(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Guard) checkCmps(cmps []clientv3.Cmp) error {
+	for _, cmp := range cmps {
+		if err := g.check(cmp.Key, cmp.RangeEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *Guard) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if err := g.checkOp(clientv3.OpPut(key, val, opts...)); err != nil {
+		return nil, err
+	}
+	return g.kv.Put(ctx, key, val, opts...)
+}
+
+func (g *Guard) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if err := g.checkOp(clientv3.OpGet(key, opts...)); err != nil {
+		return nil, err
+	}
+	return g.kv.Get(ctx, key, opts...)
+}
+
+func (g *Guard) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	if err := g.checkOp(clientv3.OpDelete(key, opts...)); err != nil {
+		return nil, err
+	}
+	return g.kv.Delete(ctx, key, opts...)
+}
+
+func (g *Guard) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	if err := g.checkOp(op); err != nil {
+		return clientv3.OpResponse{}, err
+	}
+	return g.kv.Do(ctx, op)
+}
+
+// Txn returns a txn whose Commit fails without running anything if a
+// condition or op is forbidden.
+func (g *Guard) Txn(ctx context.Context) clientv3.Txn {
+	return &guardTxn{Txn: g.kv.Txn(ctx), g: g}
+}
+
+type guardTxn struct {
+	clientv3.Txn
+	g   *Guard
+	err error
+}
+
+func (t *guardTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	if t.err == nil {
+		t.err = t.g.checkCmps(cs)
+	}
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *guardTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	if t.err == nil {
+		t.err = t.g.checkOps(ops)
+	}
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *guardTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	if t.err == nil {
+		t.err = t.g.checkOps(ops)
+	}
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *guardTxn) Commit() (*clientv3.TxnResponse, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.Txn.Commit()
+}
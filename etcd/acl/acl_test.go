@@ -0,0 +1,83 @@
+package acl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gojustforfun/learn-by-test/etcd/acl"
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type ACLTestSuite struct {
+	suite.Suite
+	kv *fake.KV
+	g  *acl.Guard
+}
+
+func TestACLTestSuite(t *testing.T) {
+	suite.Run(t, new(ACLTestSuite))
+}
+
+func (s *ACLTestSuite) SetupTest() {
+	s.kv = fake.NewKV()
+	s.g = acl.NewGuard(s.kv, "tenant-a", "/tenant/a/", "/shared/")
+}
+
+func (s *ACLTestSuite) TestInBounds() {
+	ctx := context.Background()
+	_, err := s.g.Put(ctx, "/tenant/a/x", "1")
+	s.NoError(err)
+	_, err = s.g.Put(ctx, "/shared/y", "2")
+	s.NoError(err)
+
+	resp, err := s.g.Get(ctx, "/tenant/a/", clientv3.WithPrefix())
+	s.Require().NoError(err)
+	s.Len(resp.Kvs, 1)
+	_, err = s.g.Get(ctx, "/tenant/a/a", clientv3.WithRange("/tenant/a/z"))
+	s.NoError(err)
+
+	txnResp, err := s.g.Txn(ctx).
+		If(clientv3.Compare(clientv3.Version("/tenant/a/x"), "=", 1)).
+		Then(clientv3.OpDelete("/shared/", clientv3.WithPrefix())).
+		Commit()
+	s.Require().NoError(err)
+	s.True(txnResp.Succeeded)
+
+	_, err = s.g.Delete(ctx, "/tenant/a/x")
+	s.NoError(err)
+	s.Equal(int64(5), s.kv.Rev())
+}
+
+func (s *ACLTestSuite) TestOutOfBounds() {
+	ctx := context.Background()
+	_, err := s.kv.Put(ctx, "/tenant/b/x", "1")
+	s.Require().NoError(err)
+
+	_, err = s.g.Put(ctx, "/tenant/b/x", "2")
+	s.ErrorIs(err, acl.ErrForbidden)
+	_, err = s.g.Delete(ctx, "/tenant/b/x")
+	s.ErrorIs(err, acl.ErrForbidden)
+
+	// ranges must stay inside one prefix as a whole
+	_, err = s.g.Get(ctx, "/tenant/", clientv3.WithPrefix())
+	s.ErrorIs(err, acl.ErrForbidden)
+	_, err = s.g.Get(ctx, "/tenant/a/", clientv3.WithRange("/tenant/b/"))
+	s.ErrorIs(err, acl.ErrForbidden)
+	_, err = s.g.Get(ctx, "/tenant/a/", clientv3.WithFromKey())
+	s.ErrorIs(err, acl.ErrForbidden)
+	_, err = s.g.Delete(ctx, "/tenant/a", clientv3.WithPrefix())
+	s.ErrorIs(err, acl.ErrForbidden)
+	_, err = s.g.Do(ctx, clientv3.OpGet("/shared/", clientv3.WithRange("/tenant/a/x")))
+	s.ErrorIs(err, acl.ErrForbidden)
+
+	// a forbidden key anywhere in a txn stops the whole of it
+	_, err = s.g.Txn(ctx).
+		If(clientv3.Compare(clientv3.Version("/tenant/a/x"), "=", 0)).
+		Then(clientv3.OpPut("/tenant/a/x", "1"), clientv3.OpTxn(nil, nil, []clientv3.Op{clientv3.OpDelete("/tenant/b/x")})).
+		Commit()
+	s.ErrorIs(err, acl.ErrForbidden)
+
+	s.Equal(int64(2), s.kv.Rev())
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrKeyExists = errors.New("backup: key exists")
+
+// Record is one key as exported, a line of JSON.
+type Record struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	Lease int64  `json:"lease,omitempty"`
+}
+
+// Export writes every key under prefix to w as Records, one per line, and
+// returns how many it wrote. The prefix is read page by page, all pages at
+// the revision of the first one, so the export is a consistent snapshot.
+func Export(ctx context.Context, cli *clientv3.Client, prefix string, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	it := kv.NewRangeIterator(cli, prefix)
+	var n int
+	for {
+		kvs, more, err := it.Next(ctx)
+		if err != nil {
+			return n, err
+		}
+		for _, pair := range kvs {
+			if err := enc.Encode(Record{Key: string(pair.Key), Value: pair.Value, Lease: pair.Lease}); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if !more {
+			return n, nil
+		}
+	}
+}
+
+type options struct {
+	keepLeases   bool
+	failIfExists bool
+}
+
+type Option func(*options)
+
+// WithLeases attaches imported keys to the leases they were exported with,
+// which must exist. By default keys are imported without a lease.
+func WithLeases() Option {
+	return func(o *options) {
+		o.keepLeases = true
+	}
+}
+
+// WithFailIfExists stops the import with ErrKeyExists at the first key that
+// already exists, instead of overwriting it.
+func WithFailIfExists() Option {
+	return func(o *options) {
+		o.failIfExists = true
+	}
+}
+
+// Import writes the Records read from r and returns how many it wrote. Each
+// key is written on its own, so a failed import leaves the keys before the
+// failure written.
+func Import(ctx context.Context, cli *clientv3.Client, r io.Reader, opts ...Option) (int, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dec := json.NewDecoder(r)
+	var n int
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("backup: decode record %d: %w", n+1, err)
+		}
+
+		var putOpts []clientv3.OpOption
+		if o.keepLeases && rec.Lease != 0 {
+			putOpts = append(putOpts, clientv3.WithLease(clientv3.LeaseID(rec.Lease)))
+		}
+		txn := cli.Txn(ctx)
+		if o.failIfExists {
+			txn = txn.If(clientv3.Compare(clientv3.CreateRevision(rec.Key), "=", 0))
+		}
+		resp, err := txn.Then(clientv3.OpPut(rec.Key, string(rec.Value), putOpts...)).Commit()
+		if err != nil {
+			return n, err
+		}
+		if !resp.Succeeded {
+			return n, fmt.Errorf("%w: %s", ErrKeyExists, rec.Key)
+		}
+		n++
+	}
+}
@@ -0,0 +1,115 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/backup"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type BackupTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestBackupTestSuite(t *testing.T) {
+	suite.Run(t, new(BackupTestSuite))
+}
+
+func (s *BackupTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *BackupTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *BackupTestSuite) dump(prefix string) map[string]string {
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	m := make(map[string]string, len(getResp.Kvs))
+	for _, kv := range getResp.Kvs {
+		m[string(kv.Key)] = string(kv.Value)
+	}
+	return m
+}
+
+func (s *BackupTestSuite) TestRoundTrip() {
+	prefix := "/test/backup/roundtrip/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	for i := 0; i < 100; i++ {
+		_, err := s.cli.Put(context.Background(), fmt.Sprintf("%s%03d", prefix, i), fmt.Sprintf("val\n\x00%d", i))
+		s.NoError(err)
+	}
+	want := s.dump(prefix)
+
+	var buf bytes.Buffer
+	n, err := backup.Export(context.Background(), s.cli, prefix, &buf)
+	s.NoError(err)
+	s.Equal(100, n)
+	s.Equal(100, strings.Count(buf.String(), "\n"))
+
+	_, err = s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Empty(s.dump(prefix))
+
+	n, err = backup.Import(context.Background(), s.cli, &buf)
+	s.NoError(err)
+	s.Equal(100, n)
+	s.Equal(want, s.dump(prefix))
+}
+
+func (s *BackupTestSuite) TestLeases() {
+	prefix := "/test/backup/leases/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	leaseResp, err := s.cli.Grant(context.Background(), 10)
+	s.NoError(err)
+	defer s.cli.Revoke(context.Background(), leaseResp.ID)
+	_, err = s.cli.Put(context.Background(), prefix+"a", "1", clientv3.WithLease(leaseResp.ID))
+	s.NoError(err)
+
+	var buf bytes.Buffer
+	_, err = backup.Export(context.Background(), s.cli, prefix, &buf)
+	s.NoError(err)
+	exported := buf.String()
+
+	// leases are dropped by default
+	_, err = backup.Import(context.Background(), s.cli, strings.NewReader(exported))
+	s.NoError(err)
+	getResp, err := s.cli.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Zero(getResp.Kvs[0].Lease)
+
+	_, err = backup.Import(context.Background(), s.cli, strings.NewReader(exported), backup.WithLeases())
+	s.NoError(err)
+	getResp, err = s.cli.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(int64(leaseResp.ID), getResp.Kvs[0].Lease)
+}
+
+func (s *BackupTestSuite) TestFailIfExists() {
+	prefix := "/test/backup/exists/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"b", "old")
+	s.NoError(err)
+
+	records := `{"key":"` + prefix + `a","value":"MQ=="}
+{"key":"` + prefix + `b","value":"Mg=="}
+{"key":"` + prefix + `c","value":"Mw=="}
+`
+	n, err := backup.Import(context.Background(), s.cli, strings.NewReader(records), backup.WithFailIfExists())
+	s.ErrorIs(err, backup.ErrKeyExists)
+	s.Equal(1, n)
+	s.Equal(map[string]string{prefix + "a": "1", prefix + "b": "old"}, s.dump(prefix))
+}
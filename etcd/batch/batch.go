@@ -0,0 +1,159 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/cluster"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/gojustforfun/learn-by-test/etcd/txnresult"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultMaxTxnOps matches the default of etcd's --max-txn-ops, and is the
+// limit used when it cannot be discovered.
+const DefaultMaxTxnOps = cluster.DefaultMaxTxnOps
+
+var (
+	ErrTooManyOps    = errors.New("batch: too many operations in txn")
+	ErrCompareFailed = errors.New("batch: txn compare failed")
+)
+
+type Result struct {
+	Op       clientv3.Op
+	Response clientv3.OpResponse
+	Err      error
+}
+
+// Results line up with the order the ops were added in.
+type Results []Result
+
+// Err returns the first error among the results.
+func (rs Results) Err() error {
+	for _, r := range rs {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+type Option func(*Batch)
+
+// WithMaxTxnOps sets the limit CommitTxn checks against and CommitChunked
+// splits by. By default it is discovered with cluster.MaxTxnOps on the first
+// commit. It should match the server's --max-txn-ops.
+func WithMaxTxnOps(n int) Option {
+	return func(b *Batch) {
+		b.maxTxnOps = n
+	}
+}
+
+// Batch accumulates ops to commit either atomically in a single txn or as
+// independent best-effort requests.
+type Batch struct {
+	cli etcdx.KV
+	// maxTxnOps is discovered by limit when not set
+	maxTxnOps int
+	cmps      []clientv3.Cmp
+	ops       []clientv3.Op
+}
+
+func New(cli etcdx.KV, opts ...Option) *Batch {
+	b := &Batch{cli: cli}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// If adds compares that must all hold for CommitTxn to apply the ops. They
+// are ignored by CommitAll.
+func (b *Batch) If(cmps ...clientv3.Cmp) *Batch {
+	b.cmps = append(b.cmps, cmps...)
+	return b
+}
+
+func (b *Batch) Put(key, val string, opts ...clientv3.OpOption) *Batch {
+	return b.Op(clientv3.OpPut(key, val, opts...))
+}
+
+func (b *Batch) Get(key string, opts ...clientv3.OpOption) *Batch {
+	return b.Op(clientv3.OpGet(key, opts...))
+}
+
+func (b *Batch) Delete(key string, opts ...clientv3.OpOption) *Batch {
+	return b.Op(clientv3.OpDelete(key, opts...))
+}
+
+func (b *Batch) Op(ops ...clientv3.Op) *Batch {
+	b.ops = append(b.ops, ops...)
+	return b
+}
+
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// CommitTxn applies all ops in one txn: either all of them take effect or,
+// if any compare fails, none does and ErrCompareFailed is returned.
+func (b *Batch) CommitTxn(ctx context.Context) (Results, error) {
+	if max := b.limit(ctx); len(b.ops) > max {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTooManyOps, len(b.ops), max)
+	}
+	resp, err := b.cli.Txn(ctx).If(b.cmps...).Then(b.ops...).Commit()
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Succeeded {
+		return nil, ErrCompareFailed
+	}
+
+	results := make(Results, len(b.ops))
+	for i, op := range b.ops {
+		results[i] = Result{Op: op}
+		results[i].Response, results[i].Err = txnresult.At(resp, i)
+	}
+	return results, nil
+}
+
+// CommitChunked applies the ops in txns of as many as the limit allows, in
+// order. Each txn is atomic but the batch as a whole is not: the ops of a
+// txn that fails get its error, and the txns after it are still sent.
+// Compares are ignored.
+func (b *Batch) CommitChunked(ctx context.Context) Results {
+	results := make(Results, len(b.ops))
+	max := b.limit(ctx)
+	for start := 0; start < len(b.ops); start += max {
+		end := min(start+max, len(b.ops))
+		resp, err := b.cli.Txn(ctx).Then(b.ops[start:end]...).Commit()
+		for i := start; i < end; i++ {
+			results[i] = Result{Op: b.ops[i], Err: err}
+			if err == nil {
+				results[i].Response, results[i].Err = txnresult.At(resp, i-start)
+			}
+		}
+	}
+	return results
+}
+
+// CommitAll sends every op on its own and reports each outcome; a failing op
+// does not stop the rest.
+func (b *Batch) CommitAll(ctx context.Context) Results {
+	results := make(Results, len(b.ops))
+	for i, op := range b.ops {
+		resp, err := b.cli.Do(ctx, op)
+		results[i] = Result{Op: op, Response: resp, Err: err}
+	}
+	return results
+}
+
+// limit returns the max ops per txn, discovering it the first time unless it
+// was set; failing that it falls back to DefaultMaxTxnOps.
+func (b *Batch) limit(ctx context.Context) int {
+	if b.maxTxnOps == 0 {
+		b.maxTxnOps, _ = cluster.MaxTxnOps(ctx, b.cli)
+	}
+	return b.maxTxnOps
+}
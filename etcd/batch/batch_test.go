@@ -0,0 +1,156 @@
+package batch_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/batch"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestBatchTestSuite(t *testing.T) {
+	suite.Run(t, new(BatchTestSuite))
+}
+
+func (s *BatchTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *BatchTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *BatchTestSuite) count(prefix string) int64 {
+	resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	return resp.Count
+}
+
+func (s *BatchTestSuite) TestCommitTxn() {
+	prefix := "/test/batch/txn/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	results, err := batch.New(s.cli).
+		Put(prefix+"a", "A").
+		Put(prefix+"b", "B").
+		Get(prefix + "a").
+		CommitTxn(context.Background())
+	s.NoError(err)
+	s.Len(results, 3)
+	s.NotNil(results[0].Response.Put())
+	s.Equal("A", string(results[2].Response.Get().Kvs[0].Value))
+
+	s.Run("Failed compare rolls back all ops", func() {
+		_, err := batch.New(s.cli).
+			If(clientv3.Compare(clientv3.Value(prefix+"a"), "=", "not A")).
+			Put(prefix+"c", "C").
+			Delete(prefix + "a").
+			CommitTxn(context.Background())
+		s.Equal(batch.ErrCompareFailed, err)
+		s.Equal(int64(2), s.count(prefix))
+	})
+
+	s.Run("Too many ops", func() {
+		b := batch.New(s.cli, batch.WithMaxTxnOps(3))
+		for i := 0; i < 4; i++ {
+			b.Put(prefix+"many/"+strconv.Itoa(i), "val")
+		}
+		_, err := b.CommitTxn(context.Background())
+		s.ErrorIs(err, batch.ErrTooManyOps)
+		s.Zero(s.count(prefix + "many/"))
+	})
+}
+
+func (s *BatchTestSuite) TestCommitAll() {
+	prefix := "/test/batch/all/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	results := batch.New(s.cli).
+		Put(prefix+"a", "A").
+		Put(prefix+"b", "B", clientv3.WithLease(clientv3.LeaseID(12345))).
+		Put(prefix+"c", "C").
+		CommitAll(context.Background())
+
+	s.Len(results, 3)
+	s.NoError(results[0].Err)
+	s.Error(results[1].Err)
+	s.NoError(results[2].Err)
+	s.Equal(results[1].Err, results.Err())
+	s.Equal(int64(2), s.count(prefix))
+}
+
+// limitedKV refuses txns of more than max ops, like a server with
+// --max-txn-ops=max, and records the size of the txns that put.
+type limitedKV struct {
+	etcdx.KV
+	max  int
+	puts []int
+}
+
+func (kv *limitedKV) Txn(ctx context.Context) clientv3.Txn {
+	return &limitedTxn{Txn: kv.KV.Txn(ctx), kv: kv}
+}
+
+type limitedTxn struct {
+	clientv3.Txn
+	kv  *limitedKV
+	ops []clientv3.Op
+}
+
+func (t *limitedTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *limitedTxn) Commit() (*clientv3.TxnResponse, error) {
+	if len(t.ops) > t.kv.max {
+		return nil, rpctypes.ErrTooManyOps
+	}
+	if len(t.ops) > 0 && t.ops[0].IsPut() {
+		t.kv.puts = append(t.kv.puts, len(t.ops))
+	}
+	return t.Txn.Commit()
+}
+
+func (s *BatchTestSuite) TestCommitChunked() {
+	prefix := "/test/batch/chunked/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	kv := &limitedKV{KV: s.cli, max: 5}
+	b := batch.New(kv)
+	for i := 0; i < 12; i++ {
+		b.Put(prefix+strconv.Itoa(i), "val")
+	}
+	results := b.CommitChunked(context.Background())
+	s.Require().NoError(results.Err())
+	s.Len(results, 12)
+	s.NotNil(results[11].Response.Put())
+	s.Equal([]int{5, 5, 2}, kv.puts)
+	s.Equal(int64(12), s.count(prefix))
+
+	s.Run("CommitTxn checks the discovered limit", func() {
+		b := batch.New(&limitedKV{KV: s.cli, max: 5})
+		for i := 0; i < 6; i++ {
+			b.Put(prefix+"many/"+strconv.Itoa(i), "val")
+		}
+		_, err := b.CommitTxn(context.Background())
+		s.ErrorIs(err, batch.ErrTooManyOps)
+		s.Zero(s.count(prefix + "many/"))
+	})
+}
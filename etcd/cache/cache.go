@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = time.Minute
+
+var ErrNotFound = errors.New("cache: key not found")
+
+type options struct {
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+type Option func(*options)
+
+// WithTTL bounds how long an entry is served from the cache, as a safety
+// net in case an invalidation is missed.
+func WithTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.ttl = d
+	}
+}
+
+// WithClock sets the clock the TTL is measured by.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+type entry struct {
+	val     []byte
+	found   bool
+	rev     int64
+	expires time.Time
+}
+
+// Store serves reads of the keys under a prefix from a local cache, loading
+// missing entries with a Get. A background watch on the prefix evicts an
+// entry as soon as its key changes anywhere, so reads only lag writes by
+// the watch latency. Absent keys are cached as well.
+type Store struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   options
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	entries map[string]entry
+	// rev is the revision up to which evictions have been applied
+	rev int64
+}
+
+// New starts watching prefix until Close is called.
+func New(ctx context.Context, cli *clientv3.Client, prefix string, opts ...Option) (*Store, error) {
+	o := options{ttl: defaultTTL, clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	getResp, err := cli.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	s := &Store{
+		cli:     cli,
+		prefix:  prefix,
+		opts:    o,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		entries: make(map[string]entry),
+		rev:     getResp.Header.Revision,
+	}
+	go s.run(watchCtx)
+	return s, nil
+}
+
+// Get returns the value of key, or ErrNotFound. Keys outside the prefix are
+// read through without being cached.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	cacheable := strings.HasPrefix(key, s.prefix)
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	if ok && s.opts.clock.Now().After(e.expires) {
+		delete(s.entries, key)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		getResp, err := s.cli.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		e = entry{rev: getResp.Header.Revision, expires: s.opts.clock.Now().Add(s.opts.ttl)}
+		if len(getResp.Kvs) > 0 {
+			e.val, e.found = getResp.Kvs[0].Value, true
+		}
+
+		s.mu.Lock()
+		// a change newer than the read may already have been seen, and its
+		// eviction would be lost; serve the value without caching it
+		if cacheable && s.rev <= e.rev {
+			s.entries[key] = e
+		}
+		s.mu.Unlock()
+	}
+
+	if !e.found {
+		return nil, ErrNotFound
+	}
+	return e.val, nil
+}
+
+// Close stops watching. Get keeps working but without the cache.
+func (s *Store) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *Store) run(ctx context.Context) {
+	defer close(s.done)
+	// nothing can be cached once evictions stop
+	defer s.purge(math.MaxInt64)
+
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		rev := s.rev
+		s.mu.Unlock()
+
+		watchChan := s.cli.Watch(clientv3.WithRequireLeader(ctx), s.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+		for watchResp := range watchChan {
+			if watchResp.Err() != nil {
+				if watchResp.Err() == rpctypes.ErrCompacted {
+					// the changes we missed are gone, so drop everything and
+					// watch from what is still there
+					s.purge(watchResp.CompactRevision - 1)
+				}
+				break
+			}
+			s.mu.Lock()
+			for _, ev := range watchResp.Events {
+				if e, ok := s.entries[string(ev.Kv.Key)]; ok && e.rev < ev.Kv.ModRevision {
+					delete(s.entries, string(ev.Kv.Key))
+				}
+				if ev.Kv.ModRevision > s.rev {
+					s.rev = ev.Kv.ModRevision
+				}
+			}
+			s.mu.Unlock()
+		}
+
+		// the watch closed, re-establish it from the last seen revision
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// purge drops every entry and moves the revision forward to rev.
+func (s *Store) purge(rev int64) {
+	s.mu.Lock()
+	s.entries = make(map[string]entry)
+	if rev > s.rev {
+		s.rev = rev
+	}
+	s.mu.Unlock()
+}
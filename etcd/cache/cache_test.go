@@ -0,0 +1,172 @@
+package cache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/cache"
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// countingKV counts the Gets reaching etcd.
+type countingKV struct {
+	clientv3.KV
+	gets int64
+}
+
+func (kv *countingKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	atomic.AddInt64(&kv.gets, 1)
+	return kv.KV.Get(ctx, key, opts...)
+}
+
+func (kv *countingKV) Gets() int64 {
+	return atomic.LoadInt64(&kv.gets)
+}
+
+type CacheTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
+
+func (s *CacheTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *CacheTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+// countingClient returns a client sharing the suite connection whose Gets
+// are counted.
+func (s *CacheTestSuite) countingClient() (*clientv3.Client, *countingKV) {
+	kv := &countingKV{KV: s.cli.KV}
+	cli := clientv3.NewCtxClient(context.Background())
+	cli.KV = kv
+	cli.Watcher = s.cli.Watcher
+	return cli, kv
+}
+
+func (s *CacheTestSuite) TestInvalidate() {
+	prefix := "/test/cache/invalidate/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+
+	cli, kv := s.countingClient()
+	store, err := cache.New(context.Background(), cli, prefix)
+	s.NoError(err)
+	defer store.Close()
+	gets := kv.Gets()
+
+	for i := 0; i < 3; i++ {
+		val, err := store.Get(context.Background(), prefix+"a")
+		s.NoError(err)
+		s.Equal("1", string(val))
+	}
+	s.Equal(gets+1, kv.Gets())
+
+	// another writer updates the key
+	_, err = s.cli.Put(context.Background(), prefix+"a", "2")
+	s.NoError(err)
+	s.Eventually(func() bool {
+		val, err := store.Get(context.Background(), prefix+"a")
+		return err == nil && string(val) == "2"
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = s.cli.Delete(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Eventually(func() bool {
+		_, err := store.Get(context.Background(), prefix+"a")
+		return err == cache.ErrNotFound
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (s *CacheTestSuite) TestNotFound() {
+	prefix := "/test/cache/notfound/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	cli, kv := s.countingClient()
+	store, err := cache.New(context.Background(), cli, prefix)
+	s.NoError(err)
+	defer store.Close()
+	gets := kv.Gets()
+
+	// absent keys are cached too
+	for i := 0; i < 3; i++ {
+		_, err = store.Get(context.Background(), prefix+"a")
+		s.Equal(cache.ErrNotFound, err)
+	}
+	s.Equal(gets+1, kv.Gets())
+
+	_, err = s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+	s.Eventually(func() bool {
+		val, err := store.Get(context.Background(), prefix+"a")
+		return err == nil && string(val) == "1"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func (s *CacheTestSuite) TestTTL() {
+	prefix := "/test/cache/ttl/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+
+	cli, kv := s.countingClient()
+	clock := fake.NewClock()
+	store, err := cache.New(context.Background(), cli, prefix, cache.WithTTL(time.Minute), cache.WithClock(clock))
+	s.NoError(err)
+	defer store.Close()
+	gets := kv.Gets()
+
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(gets+1, kv.Gets())
+
+	clock.Advance(59 * time.Second)
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(gets+1, kv.Gets())
+
+	clock.Advance(2 * time.Second)
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(gets+2, kv.Gets())
+}
+
+func (s *CacheTestSuite) TestClosed() {
+	prefix := "/test/cache/closed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+
+	cli, kv := s.countingClient()
+	store, err := cache.New(context.Background(), cli, prefix)
+	s.NoError(err)
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	store.Close()
+
+	// without the watch every read goes to etcd
+	gets := kv.Gets()
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	_, err = store.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(gets+2, kv.Gets())
+}
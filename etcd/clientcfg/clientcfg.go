@@ -0,0 +1,182 @@
+package clientcfg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+const defaultDialTimeout = 5 * time.Second
+
+var (
+	ErrNoEndpoints = errors.New("clientcfg: no endpoints")
+	ErrConflict    = errors.New("clientcfg: conflicting options")
+)
+
+// Balancer selects which endpoint serves a call.
+type Balancer int
+
+const (
+	// RoundRobin spreads calls over all endpoints, the clientv3 default.
+	RoundRobin Balancer = iota
+	// FirstHealthy sends every call to the first endpoint that can be
+	// reached, moving on to the next only when it cannot.
+	FirstHealthy
+)
+
+// Builder assembles a clientv3.Config. Its methods only record the settings;
+// files are loaded and the settings checked against each other by Build.
+type Builder struct {
+	endpoints []string
+
+	certFile, keyFile, caFile string
+	tlsFiles                  bool
+	tlsConfig                 *tls.Config
+
+	username, password string
+
+	dialTimeout      time.Duration
+	keepAliveTime    time.Duration
+	keepAliveTimeout time.Duration
+
+	balancer Balancer
+}
+
+func NewBuilder(endpoints ...string) *Builder {
+	return &Builder{endpoints: endpoints, dialTimeout: defaultDialTimeout}
+}
+
+// WithTLSFiles secures the connection with the PEM files at the given paths.
+// cert and key present a client certificate and must be given together; ca
+// replaces the system roots used to verify the server. Any of them may be
+// empty.
+func (b *Builder) WithTLSFiles(cert, key, ca string) *Builder {
+	b.certFile, b.keyFile, b.caFile = cert, key, ca
+	b.tlsFiles = true
+	return b
+}
+
+// WithTLSConfig secures the connection with cfg as is. It excludes
+// WithTLSFiles.
+func (b *Builder) WithTLSConfig(cfg *tls.Config) *Builder {
+	b.tlsConfig = cfg
+	return b
+}
+
+// WithAuth authenticates as username with password.
+func (b *Builder) WithAuth(username, password string) *Builder {
+	b.username, b.password = username, password
+	return b
+}
+
+// WithDialTimeout bounds how long creating the client waits for a connection,
+// 5 seconds by default.
+func (b *Builder) WithDialTimeout(d time.Duration) *Builder {
+	b.dialTimeout = d
+	return b
+}
+
+// WithKeepAlive pings the server after interval without activity and closes
+// the connection if no answer arrives within timeout.
+func (b *Builder) WithKeepAlive(interval, timeout time.Duration) *Builder {
+	b.keepAliveTime, b.keepAliveTimeout = interval, timeout
+	return b
+}
+
+// WithBalancer sets how the client picks among the endpoints, RoundRobin by
+// default.
+func (b *Builder) WithBalancer(balancer Balancer) *Builder {
+	b.balancer = balancer
+	return b
+}
+
+// Build validates the settings and returns the config.
+func (b *Builder) Build() (clientv3.Config, error) {
+	if len(b.endpoints) == 0 {
+		return clientv3.Config{}, ErrNoEndpoints
+	}
+	if b.tlsFiles && b.tlsConfig != nil {
+		return clientv3.Config{}, fmt.Errorf("%w: WithTLSFiles and WithTLSConfig are mutually exclusive", ErrConflict)
+	}
+	if b.dialTimeout < 0 || b.keepAliveTime < 0 || b.keepAliveTimeout < 0 {
+		return clientv3.Config{}, errors.New("clientcfg: negative timeout")
+	}
+	if b.keepAliveTimeout > 0 && b.keepAliveTime == 0 {
+		return clientv3.Config{}, fmt.Errorf("%w: keepalive timeout without interval", ErrConflict)
+	}
+	if b.password != "" && b.username == "" {
+		return clientv3.Config{}, errors.New("clientcfg: password without username")
+	}
+
+	tlsConfig := b.tlsConfig
+	if b.tlsFiles {
+		var err error
+		if tlsConfig, err = b.loadTLS(); err != nil {
+			return clientv3.Config{}, err
+		}
+	}
+	if tlsConfig != nil {
+		// the client would talk TLS to plain endpoints
+		for _, ep := range b.endpoints {
+			if strings.HasPrefix(ep, "http://") {
+				return clientv3.Config{}, fmt.Errorf("%w: TLS configured for plain endpoint %s", ErrConflict, ep)
+			}
+		}
+	}
+
+	var dialOpts []grpc.DialOption
+	switch b.balancer {
+	case RoundRobin:
+	case FirstHealthy:
+		// the clientv3 resolver asks for round_robin in the service config
+		// it hands out, so ignore that one in favour of the default
+		dialOpts = append(dialOpts, grpc.WithDisableServiceConfig(), grpc.WithDefaultServiceConfig(`{"loadBalancingPolicy":"pick_first"}`))
+	default:
+		return clientv3.Config{}, fmt.Errorf("clientcfg: unknown balancer %d", b.balancer)
+	}
+
+	return clientv3.Config{
+		Endpoints:            b.endpoints,
+		TLS:                  tlsConfig,
+		Username:             b.username,
+		Password:             b.password,
+		DialTimeout:          b.dialTimeout,
+		DialKeepAliveTime:    b.keepAliveTime,
+		DialKeepAliveTimeout: b.keepAliveTimeout,
+		DialOptions:          dialOpts,
+	}, nil
+}
+
+func (b *Builder) loadTLS() (*tls.Config, error) {
+	if (b.certFile == "") != (b.keyFile == "") {
+		return nil, fmt.Errorf("%w: cert and key must be given together", ErrConflict)
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if b.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(b.certFile, b.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("clientcfg: load key pair %s, %s: %w", b.certFile, b.keyFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if b.caFile != "" {
+		pem, err := os.ReadFile(b.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("clientcfg: load CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("clientcfg: no certificates in CA file %s", b.caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
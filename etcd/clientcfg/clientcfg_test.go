@@ -0,0 +1,180 @@
+package clientcfg_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clientcfg"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var endpoints = []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"}
+
+type ClientcfgTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestClientcfgTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientcfgTestSuite))
+}
+
+func (s *ClientcfgTestSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+// writePEM writes a PEM block of type typ to name in the test dir.
+func (s *ClientcfgTestSuite) writePEM(name, typ string, der []byte) string {
+	path := filepath.Join(s.dir, name)
+	s.NoError(os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	return path
+}
+
+// certs writes a self-signed CA and a client certificate signed by it,
+// returning their paths and the parsed CA and client certificate.
+func (s *ClientcfgTestSuite) certs() (cert, key, ca string, caCert, clientCert *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	s.Require().NoError(err)
+	caCert, err = x509.ParseCertificate(caDER)
+	s.Require().NoError(err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, caCert, &clientKey.PublicKey, caKey)
+	s.Require().NoError(err)
+	clientCert, err = x509.ParseCertificate(clientDER)
+	s.Require().NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	s.Require().NoError(err)
+
+	ca = s.writePEM("ca.pem", "CERTIFICATE", caDER)
+	cert = s.writePEM("client.pem", "CERTIFICATE", clientDER)
+	key = s.writePEM("client-key.pem", "EC PRIVATE KEY", keyDER)
+	return cert, key, ca, caCert, clientCert
+}
+
+func (s *ClientcfgTestSuite) TestTLSFiles() {
+	cert, key, ca, caCert, clientCert := s.certs()
+
+	cfg, err := clientcfg.NewBuilder("https://localhost:2379").
+		WithTLSFiles(cert, key, ca).
+		WithAuth("root", "secret").
+		WithKeepAlive(10*time.Second, 3*time.Second).
+		Build()
+	s.Require().NoError(err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	s.True(pool.Equal(cfg.TLS.RootCAs))
+	s.Require().Len(cfg.TLS.Certificates, 1)
+	s.Equal(clientCert.Raw, cfg.TLS.Certificates[0].Certificate[0])
+
+	s.Equal("root", cfg.Username)
+	s.Equal("secret", cfg.Password)
+	s.Equal(5*time.Second, cfg.DialTimeout)
+	s.Equal(10*time.Second, cfg.DialKeepAliveTime)
+	s.Equal(3*time.Second, cfg.DialKeepAliveTimeout)
+}
+
+func (s *ClientcfgTestSuite) TestCAOnly() {
+	_, _, ca, caCert, _ := s.certs()
+
+	cfg, err := clientcfg.NewBuilder("https://localhost:2379").WithTLSFiles("", "", ca).Build()
+	s.Require().NoError(err)
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	s.True(pool.Equal(cfg.TLS.RootCAs))
+	s.Empty(cfg.TLS.Certificates)
+}
+
+func (s *ClientcfgTestSuite) TestInvalid() {
+	cert, key, ca, _, _ := s.certs()
+
+	_, err := clientcfg.NewBuilder().Build()
+	s.ErrorIs(err, clientcfg.ErrNoEndpoints)
+
+	_, err = clientcfg.NewBuilder("https://localhost:2379").WithTLSFiles(filepath.Join(s.dir, "missing.pem"), key, ca).Build()
+	s.ErrorIs(err, fs.ErrNotExist)
+	_, err = clientcfg.NewBuilder("https://localhost:2379").WithTLSFiles(cert, key, filepath.Join(s.dir, "missing.pem")).Build()
+	s.ErrorIs(err, fs.ErrNotExist)
+	_, err = clientcfg.NewBuilder("https://localhost:2379").WithTLSFiles(cert, key, key).Build()
+	s.Require().Error(err)
+	s.Contains(err.Error(), "no certificates")
+
+	_, err = clientcfg.NewBuilder("https://localhost:2379").WithTLSFiles(cert, "", ca).Build()
+	s.ErrorIs(err, clientcfg.ErrConflict)
+	_, err = clientcfg.NewBuilder(endpoints...).WithTLSFiles(cert, key, ca).Build()
+	s.ErrorIs(err, clientcfg.ErrConflict)
+	_, err = clientcfg.NewBuilder("https://localhost:2379").WithTLSFiles(cert, key, ca).WithTLSConfig(&tls.Config{}).Build()
+	s.ErrorIs(err, clientcfg.ErrConflict)
+
+	_, err = clientcfg.NewBuilder(endpoints...).WithAuth("", "secret").Build()
+	s.Error(err)
+	_, err = clientcfg.NewBuilder(endpoints...).WithKeepAlive(0, time.Second).Build()
+	s.ErrorIs(err, clientcfg.ErrConflict)
+	_, err = clientcfg.NewBuilder(endpoints...).WithDialTimeout(-time.Second).Build()
+	s.Error(err)
+}
+
+func (s *ClientcfgTestSuite) TestPlain() {
+	cfg, err := clientcfg.NewBuilder(endpoints...).Build()
+	s.Require().NoError(err)
+	s.Nil(cfg.TLS)
+
+	cli, err := clientv3.New(cfg)
+	s.Require().NoError(err)
+	defer cli.Close()
+	_, err = cli.Get(context.Background(), "/test/clientcfg")
+	s.NoError(err)
+}
+
+func (s *ClientcfgTestSuite) TestBalancer() {
+	cfg, err := clientcfg.NewBuilder(endpoints...).Build()
+	s.Require().NoError(err)
+	s.Empty(cfg.DialOptions)
+
+	cfg, err = clientcfg.NewBuilder(endpoints...).WithBalancer(clientcfg.FirstHealthy).Build()
+	s.Require().NoError(err)
+	s.Len(cfg.DialOptions, 2)
+
+	cli, err := clientv3.New(cfg)
+	s.Require().NoError(err)
+	defer cli.Close()
+	_, err = cli.Get(context.Background(), "/test/clientcfg")
+	s.NoError(err)
+
+	_, err = clientcfg.NewBuilder(endpoints...).WithBalancer(clientcfg.Balancer(9)).Build()
+	s.Error(err)
+}
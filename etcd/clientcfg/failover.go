@@ -0,0 +1,238 @@
+package clientcfg
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultCooldown = 30 * time.Second
+
+// Dialer connects to a single endpoint.
+type Dialer func(endpoint string) (etcdx.KV, error)
+
+// DialConfig returns a Dialer creating a client with cfg for each endpoint.
+func DialConfig(cfg clientv3.Config) Dialer {
+	return func(endpoint string) (etcdx.KV, error) {
+		cfg := cfg
+		cfg.Endpoints = []string{endpoint}
+		return clientv3.New(cfg)
+	}
+}
+
+type failoverOptions struct {
+	cooldown time.Duration
+	clock    clock.Clock
+}
+
+type FailoverOption func(*failoverOptions)
+
+// WithCooldown sets how long an endpoint that answered Unavailable is passed
+// over, 30 seconds by default.
+func WithCooldown(d time.Duration) FailoverOption {
+	return func(o *failoverOptions) {
+		o.cooldown = d
+	}
+}
+
+// WithClock sets the clock cooldowns are measured by.
+func WithClock(c clock.Clock) FailoverOption {
+	return func(o *failoverOptions) {
+		o.clock = c
+	}
+}
+
+// Failover is a KV over one connection per endpoint, picking the endpoint of
+// each call by its balancer. An endpoint whose call fails with Unavailable is
+// skipped for a cooldown; when every endpoint is cooling down, calls go to
+// them all the same. The failed call itself is not retried.
+type Failover struct {
+	endpoints []string
+	dial      Dialer
+	balancer  Balancer
+	opts      failoverOptions
+
+	mu        sync.Mutex
+	kvs       map[string]etcdx.KV
+	downUntil map[string]time.Time
+	next      int
+	last      string
+}
+
+// NewFailover connects to the endpoints lazily, on their first call.
+func NewFailover(endpoints []string, dial Dialer, balancer Balancer, opts ...FailoverOption) (*Failover, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	o := failoverOptions{cooldown: defaultCooldown, clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Failover{
+		endpoints: endpoints,
+		dial:      dial,
+		balancer:  balancer,
+		opts:      o,
+		kvs:       make(map[string]etcdx.KV),
+		downUntil: make(map[string]time.Time),
+	}, nil
+}
+
+// LastEndpoint returns the endpoint the last call went to, or "" before any.
+func (f *Failover) LastEndpoint() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last
+}
+
+// Close closes the connections that were dialed.
+func (f *Failover) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	for ep, kv := range f.kvs {
+		if c, ok := kv.(io.Closer); ok {
+			if cerr := c.Close(); err == nil {
+				err = cerr
+			}
+		}
+		delete(f.kvs, ep)
+	}
+	return err
+}
+
+// pick returns the endpoint for the next call and its connection.
+func (f *Failover) pick() (string, etcdx.KV, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.opts.clock.Now()
+	start := 0
+	if f.balancer == RoundRobin {
+		start = f.next
+	}
+	i := start
+	for n := 0; n < len(f.endpoints); n++ {
+		j := (start + n) % len(f.endpoints)
+		if !now.Before(f.downUntil[f.endpoints[j]]) {
+			i = j
+			break
+		}
+	}
+	f.next = (i + 1) % len(f.endpoints)
+
+	ep := f.endpoints[i]
+	kv, ok := f.kvs[ep]
+	if !ok {
+		var err error
+		if kv, err = f.dial(ep); err != nil {
+			return ep, nil, err
+		}
+		f.kvs[ep] = kv
+	}
+	f.last = ep
+	return ep, kv, nil
+}
+
+// report puts the endpoint on cooldown if err says it is unavailable.
+func (f *Failover) report(ep string, err error) {
+	if status.Code(err) != codes.Unavailable {
+		return
+	}
+	f.mu.Lock()
+	f.downUntil[ep] = f.opts.clock.Now().Add(f.opts.cooldown)
+	f.mu.Unlock()
+}
+
+func (f *Failover) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	ep, kv, err := f.pick()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := kv.Put(ctx, key, val, opts...)
+	f.report(ep, err)
+	return resp, err
+}
+
+func (f *Failover) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ep, kv, err := f.pick()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := kv.Get(ctx, key, opts...)
+	f.report(ep, err)
+	return resp, err
+}
+
+func (f *Failover) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	ep, kv, err := f.pick()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := kv.Delete(ctx, key, opts...)
+	f.report(ep, err)
+	return resp, err
+}
+
+func (f *Failover) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	ep, kv, err := f.pick()
+	if err != nil {
+		return clientv3.OpResponse{}, err
+	}
+	resp, err := kv.Do(ctx, op)
+	f.report(ep, err)
+	return resp, err
+}
+
+// Txn picks the endpoint when called, and the txn commits there.
+func (f *Failover) Txn(ctx context.Context) clientv3.Txn {
+	ep, kv, err := f.pick()
+	if err != nil {
+		return &failoverTxn{err: err}
+	}
+	return &failoverTxn{Txn: kv.Txn(ctx), f: f, ep: ep}
+}
+
+type failoverTxn struct {
+	clientv3.Txn
+	f   *Failover
+	ep  string
+	err error
+}
+
+func (t *failoverTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	if t.err == nil {
+		t.Txn = t.Txn.If(cs...)
+	}
+	return t
+}
+
+func (t *failoverTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	if t.err == nil {
+		t.Txn = t.Txn.Then(ops...)
+	}
+	return t
+}
+
+func (t *failoverTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	if t.err == nil {
+		t.Txn = t.Txn.Else(ops...)
+	}
+	return t
+}
+
+func (t *failoverTxn) Commit() (*clientv3.TxnResponse, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	resp, err := t.Txn.Commit()
+	t.f.report(t.ep, err)
+	return resp, err
+}
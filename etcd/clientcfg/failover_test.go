@@ -0,0 +1,116 @@
+package clientcfg_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clientcfg"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// flakyKV fails every call with Unavailable while down is set.
+type flakyKV struct {
+	etcdx.KV
+	down bool
+}
+
+func (kv *flakyKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if kv.down {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return kv.KV.Get(ctx, key, opts...)
+}
+
+// fakeDialer dials one flakyKV per endpoint, all sharing a store.
+func fakeDialer(endpoints ...string) (map[string]*flakyKV, clientcfg.Dialer) {
+	store := fake.NewKV()
+	kvs := make(map[string]*flakyKV)
+	for _, ep := range endpoints {
+		kvs[ep] = &flakyKV{KV: store}
+	}
+	return kvs, func(endpoint string) (etcdx.KV, error) {
+		return kvs[endpoint], nil
+	}
+}
+
+// served makes n Gets and returns the endpoints that served them.
+func (s *ClientcfgTestSuite) served(f *clientcfg.Failover, n int) []string {
+	var eps []string
+	for i := 0; i < n; i++ {
+		f.Get(context.Background(), "/test/clientcfg")
+		eps = append(eps, f.LastEndpoint())
+	}
+	return eps
+}
+
+func (s *ClientcfgTestSuite) TestFailoverRoundRobin() {
+	kvs, dial := fakeDialer("a", "b", "c")
+	clock := fake.NewClock()
+	f, err := clientcfg.NewFailover([]string{"a", "b", "c"}, dial, clientcfg.RoundRobin,
+		clientcfg.WithCooldown(10*time.Second), clientcfg.WithClock(clock))
+	s.Require().NoError(err)
+	defer f.Close()
+	s.Empty(f.LastEndpoint())
+
+	s.Equal([]string{"a", "b", "c", "a"}, s.served(f, 4))
+
+	kvs["b"].down = true
+	_, err = f.Get(context.Background(), "/test/clientcfg")
+	s.Equal(codes.Unavailable, status.Code(err))
+	s.Equal("b", f.LastEndpoint())
+	s.Equal([]string{"c", "a", "c", "a"}, s.served(f, 4))
+
+	// back in rotation once the cooldown is over
+	kvs["b"].down = false
+	clock.Advance(10 * time.Second)
+	s.Equal([]string{"b", "c", "a"}, s.served(f, 3))
+}
+
+func (s *ClientcfgTestSuite) TestFailoverFirstHealthy() {
+	kvs, dial := fakeDialer("a", "b", "c")
+	clock := fake.NewClock()
+	f, err := clientcfg.NewFailover([]string{"a", "b", "c"}, dial, clientcfg.FirstHealthy,
+		clientcfg.WithCooldown(10*time.Second), clientcfg.WithClock(clock))
+	s.Require().NoError(err)
+	defer f.Close()
+
+	s.Equal([]string{"a", "a"}, s.served(f, 2))
+
+	kvs["a"].down = true
+	s.Equal([]string{"a", "b", "b"}, s.served(f, 3))
+
+	clock.Advance(5 * time.Second)
+	s.Equal([]string{"b"}, s.served(f, 1))
+	kvs["a"].down = false
+	clock.Advance(5 * time.Second)
+	s.Equal([]string{"a", "a"}, s.served(f, 2))
+
+	// with every endpoint cooling down, calls still go out
+	kvs["a"].down, kvs["b"].down, kvs["c"].down = true, true, true
+	s.Equal([]string{"a", "b", "c", "a"}, s.served(f, 4))
+}
+
+func (s *ClientcfgTestSuite) TestFailoverOtherErrors() {
+	_, dial := fakeDialer("b")
+	f, err := clientcfg.NewFailover([]string{"a", "b"}, func(endpoint string) (etcdx.KV, error) {
+		if endpoint == "a" {
+			return nil, errors.New("dial a")
+		}
+		return dial(endpoint)
+	}, clientcfg.FirstHealthy)
+	s.Require().NoError(err)
+
+	// only Unavailable moves calls away
+	_, err = f.Get(context.Background(), "/test/clientcfg")
+	s.EqualError(err, "dial a")
+	_, err = f.Get(context.Background(), "/test/clientcfg")
+	s.EqualError(err, "dial a")
+
+	_, err = clientcfg.NewFailover(nil, dial, clientcfg.RoundRobin)
+	s.ErrorIs(err, clientcfg.ErrNoEndpoints)
+}
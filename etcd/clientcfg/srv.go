@@ -0,0 +1,98 @@
+package clientcfg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Resolver looks up SRV records; *net.Resolver implements it.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// NoRecordsError reports that a domain publishes no SRV records for the
+// service.
+type NoRecordsError struct {
+	Service, Proto, Domain string
+	// Err is the resolver error, if the lookup failed as not found.
+	Err error
+}
+
+func (e *NoRecordsError) Error() string {
+	return fmt.Sprintf("clientcfg: no SRV records for _%s._%s.%s", e.Service, e.Proto, e.Domain)
+}
+
+func (e *NoRecordsError) Unwrap() error { return e.Err }
+
+type srvOptions struct {
+	resolver Resolver
+	https    bool
+}
+
+type SRVOption func(*srvOptions)
+
+// WithResolver looks the records up with r instead of net.DefaultResolver.
+func WithResolver(r Resolver) SRVOption {
+	return func(o *srvOptions) {
+		o.resolver = r
+	}
+}
+
+// WithHTTPS returns https:// endpoints instead of http:// ones.
+func WithHTTPS() SRVOption {
+	return func(o *srvOptions) {
+		o.https = true
+	}
+}
+
+// EndpointsFromSRV resolves the endpoints of a cluster from the SRV records
+// _service._proto.domain, e.g. _etcd-client._tcp.example.com. Endpoints are
+// ordered by ascending priority and, within a priority, by descending weight;
+// a target and port listed more than once are returned once, at their best
+// position.
+func EndpointsFromSRV(ctx context.Context, service, proto, domain string, opts ...SRVOption) ([]string, error) {
+	o := srvOptions{resolver: net.DefaultResolver}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	_, addrs, err := o.resolver.LookupSRV(ctx, service, proto, domain)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, &NoRecordsError{Service: service, Proto: proto, Domain: domain, Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &NoRecordsError{Service: service, Proto: proto, Domain: domain}
+	}
+
+	sort.SliceStable(addrs, func(i, j int) bool {
+		if addrs[i].Priority != addrs[j].Priority {
+			return addrs[i].Priority < addrs[j].Priority
+		}
+		return addrs[i].Weight > addrs[j].Weight
+	})
+
+	scheme := "http://"
+	if o.https {
+		scheme = "https://"
+	}
+	seen := make(map[string]bool, len(addrs))
+	endpoints := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		host := net.JoinHostPort(strings.TrimSuffix(addr.Target, "."), strconv.Itoa(int(addr.Port)))
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+		endpoints = append(endpoints, scheme+host)
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,68 @@
+package clientcfg_test
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clientcfg"
+)
+
+type stubResolver struct {
+	addrs []*net.SRV
+	err   error
+
+	name string
+}
+
+func (r *stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.name = "_" + service + "._" + proto + "." + name
+	return r.name, r.addrs, r.err
+}
+
+func (s *ClientcfgTestSuite) TestEndpointsFromSRV() {
+	r := &stubResolver{addrs: []*net.SRV{
+		{Target: "c.example.com.", Port: 2379, Priority: 20, Weight: 10},
+		{Target: "a.example.com.", Port: 2379, Priority: 10, Weight: 10},
+		{Target: "b.example.com.", Port: 2379, Priority: 10, Weight: 50},
+		{Target: "a.example.com.", Port: 2379, Priority: 30, Weight: 10},
+		{Target: "a.example.com.", Port: 22379, Priority: 30, Weight: 10},
+	}}
+
+	endpoints, err := clientcfg.EndpointsFromSRV(context.Background(), "etcd-client", "tcp", "example.com", clientcfg.WithResolver(r))
+	s.NoError(err)
+	s.Equal("_etcd-client._tcp.example.com", r.name)
+	s.Equal([]string{
+		"http://b.example.com:2379",
+		"http://a.example.com:2379",
+		"http://c.example.com:2379",
+		"http://a.example.com:22379",
+	}, endpoints)
+
+	endpoints, err = clientcfg.EndpointsFromSRV(context.Background(), "etcd-client-ssl", "tcp", "example.com", clientcfg.WithResolver(r), clientcfg.WithHTTPS())
+	s.NoError(err)
+	s.Equal("https://b.example.com:2379", endpoints[0])
+
+	// ready for the builder
+	_, err = clientcfg.NewBuilder(endpoints...).Build()
+	s.NoError(err)
+}
+
+func (s *ClientcfgTestSuite) TestEndpointsFromSRVNoRecords() {
+	var noRecords *clientcfg.NoRecordsError
+
+	_, err := clientcfg.EndpointsFromSRV(context.Background(), "etcd-client", "tcp", "example.com", clientcfg.WithResolver(&stubResolver{}))
+	s.ErrorAs(err, &noRecords)
+	s.Equal("example.com", noRecords.Domain)
+
+	notFound := &net.DNSError{Err: "no such host", Name: "_etcd-client._tcp.example.com", IsNotFound: true}
+	_, err = clientcfg.EndpointsFromSRV(context.Background(), "etcd-client", "tcp", "example.com", clientcfg.WithResolver(&stubResolver{err: notFound}))
+	s.ErrorAs(err, &noRecords)
+	s.ErrorIs(err, notFound)
+
+	// other failures are passed through
+	broken := errors.New("broken")
+	_, err = clientcfg.EndpointsFromSRV(context.Background(), "etcd-client", "tcp", "example.com", clientcfg.WithResolver(&stubResolver{err: broken}))
+	s.ErrorIs(err, broken)
+	s.False(errors.As(err, &noRecords))
+}
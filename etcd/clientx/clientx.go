@@ -0,0 +1,133 @@
+package clientx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultCheckTimeout = 5 * time.Second
+	warmUpInterval      = time.Second
+)
+
+var ErrUnreachable = errors.New("clientx: no endpoint reachable")
+
+type options struct {
+	lazy         bool
+	checkTimeout time.Duration
+}
+
+type Option func(*options)
+
+// WithLazyConnect makes Open return without checking the cluster, for
+// processes that must start before etcd does. The client then warms up in
+// the background until an endpoint answers, see Ready.
+func WithLazyConnect() Option {
+	return func(o *options) {
+		o.lazy = true
+	}
+}
+
+// WithCheckTimeout bounds the connectivity check of Open, the DialTimeout of
+// the config or else 5 seconds by default.
+func WithCheckTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.checkTimeout = d
+	}
+}
+
+// Client is a clientv3.Client that knows whether it ever reached the
+// cluster.
+type Client struct {
+	*clientv3.Client
+
+	ready  chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Open creates a client for cfg and, unlike clientv3.New, which succeeds
+// even when no endpoint is up, checks that one of the endpoints answers a
+// status request: if none does within the check timeout, the client is
+// closed and Open fails with ErrUnreachable. WithLazyConnect skips the check.
+func Open(ctx context.Context, cfg clientv3.Config, opts ...Option) (*Client, error) {
+	o := options{checkTimeout: cfg.DialTimeout}
+	if o.checkTimeout == 0 {
+		o.checkTimeout = defaultCheckTimeout
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cli, err := clientv3.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	c := &Client{Client: cli, ready: make(chan struct{}), cancel: cancel, done: make(chan struct{})}
+
+	if o.lazy {
+		go c.warmUp(runCtx, o.checkTimeout)
+		return c, nil
+	}
+	close(c.done)
+	checkCtx, checkCancel := context.WithTimeout(ctx, o.checkTimeout)
+	defer checkCancel()
+	if err := c.check(checkCtx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	close(c.ready)
+	return c, nil
+}
+
+// Ready is closed once an endpoint has answered, right away for a client
+// that Open checked.
+func (c *Client) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// Close stops the warm-up, if still running, and closes the client.
+func (c *Client) Close() error {
+	c.cancel()
+	<-c.done
+	return c.Client.Close()
+}
+
+// check asks every endpoint for its status in turn, and succeeds on the first
+// that answers.
+func (c *Client) check(ctx context.Context) error {
+	var errs []error
+	for _, ep := range c.Endpoints() {
+		if _, err := c.Status(ctx, ep); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ep, err))
+			continue
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrUnreachable, errors.Join(errs...))
+}
+
+// warmUp checks the endpoints every second until one answers, then closes
+// ready.
+func (c *Client) warmUp(ctx context.Context, timeout time.Duration) {
+	defer close(c.done)
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.check(checkCtx)
+		cancel()
+		if err == nil {
+			close(c.ready)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmUpInterval):
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package clientx_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clientx"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var endpoints = []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"}
+
+// unreachable has nothing listening.
+var unreachable = []string{"http://localhost:1", "http://localhost:2"}
+
+type ClientxTestSuite struct {
+	suite.Suite
+}
+
+func TestClientxTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientxTestSuite))
+}
+
+func (s *ClientxTestSuite) TestOpen() {
+	cli, err := clientx.Open(context.Background(), clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.Require().NoError(err)
+	defer cli.Close()
+	select {
+	case <-cli.Ready():
+	default:
+		s.Fail("not ready")
+	}
+	_, err = cli.Get(context.Background(), "/test/clientx")
+	s.NoError(err)
+}
+
+func (s *ClientxTestSuite) TestOpenUnreachable() {
+	start := time.Now()
+	_, err := clientx.Open(context.Background(), clientv3.Config{Endpoints: unreachable, DialTimeout: 5 * time.Second},
+		clientx.WithCheckTimeout(500*time.Millisecond))
+	s.ErrorIs(err, clientx.ErrUnreachable)
+	s.Less(time.Since(start), 2*time.Second)
+
+	// a cancelled context fails right away
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = clientx.Open(ctx, clientv3.Config{Endpoints: endpoints})
+	s.ErrorIs(err, context.Canceled)
+}
+
+func (s *ClientxTestSuite) TestLazyConnect() {
+	cli, err := clientx.Open(context.Background(), clientv3.Config{Endpoints: unreachable},
+		clientx.WithLazyConnect(), clientx.WithCheckTimeout(100*time.Millisecond))
+	s.Require().NoError(err)
+	select {
+	case <-cli.Ready():
+		s.Fail("ready without a cluster")
+	case <-time.After(300 * time.Millisecond):
+	}
+	// the warm-up stops with the client
+	start := time.Now()
+	s.NoError(cli.Close())
+	s.Less(time.Since(start), time.Second)
+
+	cli, err = clientx.Open(context.Background(), clientv3.Config{Endpoints: endpoints}, clientx.WithLazyConnect())
+	s.Require().NoError(err)
+	defer cli.Close()
+	select {
+	case <-cli.Ready():
+	case <-time.After(5 * time.Second):
+		s.Fail("not ready")
+	}
+}
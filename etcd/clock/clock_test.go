@@ -0,0 +1,28 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClockTestSuite struct {
+	suite.Suite
+}
+
+func TestClockTestSuite(t *testing.T) {
+	suite.Run(t, new(ClockTestSuite))
+}
+
+func (s *ClockTestSuite) TestReal() {
+	start := clock.Real.Now()
+	<-clock.Real.After(10 * time.Millisecond)
+
+	ticker := clock.Real.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	<-ticker.C()
+	<-ticker.C()
+	s.GreaterOrEqual(clock.Real.Now().Sub(start), 30*time.Millisecond)
+}
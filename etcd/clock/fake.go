@@ -0,0 +1,138 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when Advance is called.
+type Fake struct {
+	mu        sync.Mutex
+	now       time.Time
+	listeners []func(now time.Time)
+	waiters   []*waiter
+}
+
+// waiter is a pending After channel, or a ticker when period is set.
+type waiter struct {
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+func NewFake() *Fake {
+	return &Fake{now: time.Now()}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time once the clock has been
+// advanced by d.
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, &waiter{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// NewTicker returns a ticker firing every d the clock is advanced by. Like a
+// time.Ticker it drops ticks the receiver is not ready for.
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &waiter{at: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return &fakeTicker{c: c, w: w}
+}
+
+// BlockUntil waits until n After channels or tickers are pending, so a test
+// knows the code under test is waiting before it advances the clock.
+func (c *Fake) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Advance moves the clock forward by d. The After channels and ticks that are
+// due fire in the order of their times, each seeing Now at its own time, and
+// then the OnAdvance callbacks are called with the new time.
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		next := -1
+		for i, w := range c.waiters {
+			if !w.at.After(end) && (next < 0 || w.at.Before(c.waiters[next].at)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		w := c.waiters[next]
+		c.now = w.at
+		if w.period > 0 {
+			select {
+			case w.c <- c.now:
+			default:
+			}
+			w.at = w.at.Add(w.period)
+			continue
+		}
+		w.c <- c.now
+		c.waiters = append(c.waiters[:next], c.waiters[next+1:]...)
+	}
+	c.now = end
+	listeners := c.listeners
+	c.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(end)
+	}
+}
+
+// OnAdvance calls fn with the new time after every Advance.
+func (c *Fake) OnAdvance(fn func(now time.Time)) {
+	c.mu.Lock()
+	c.listeners = append(c.listeners, fn)
+	c.mu.Unlock()
+}
+
+func (c *Fake) stop(w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, p := range c.waiters {
+		if p == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+type fakeTicker struct {
+	c *Fake
+	w *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t *fakeTicker) Stop()               { t.c.stop(t.w) }
@@ -0,0 +1,79 @@
+package clock_test
+
+import (
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+)
+
+func (s *ClockTestSuite) TestFakeTicker() {
+	c := clock.NewFake()
+	start := c.Now()
+	ticker := c.NewTicker(time.Second)
+	c.BlockUntil(1)
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		s.FailNow("ticked early")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	s.Equal(start.Add(time.Second), <-ticker.C())
+
+	// ticks the receiver misses are dropped
+	c.Advance(3 * time.Second)
+	s.Equal(start.Add(2*time.Second), <-ticker.C())
+	select {
+	case <-ticker.C():
+		s.FailNow("missed ticks kept")
+	default:
+	}
+
+	ticker.Stop()
+	c.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		s.FailNow("ticked after Stop")
+	default:
+	}
+}
+
+func (s *ClockTestSuite) TestFakeAdvanceInOrder() {
+	c := clock.NewFake()
+	start := c.Now()
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+	late, early := c.After(1500*time.Millisecond), c.After(500*time.Millisecond)
+
+	var advanced time.Time
+	c.OnAdvance(func(now time.Time) { advanced = now })
+
+	// everything due within the advance fires at its own time
+	c.Advance(2 * time.Second)
+	s.Equal(start.Add(500*time.Millisecond), <-early)
+	s.Equal(start.Add(time.Second), <-ticker.C())
+	s.Equal(start.Add(1500*time.Millisecond), <-late)
+	s.Equal(start.Add(2*time.Second), advanced)
+	s.Equal(start.Add(2*time.Second), c.Now())
+}
+
+// ttlEntry expires ttl after it was set, by whatever clock it is given.
+type ttlEntry struct {
+	clock   clock.Clock
+	expires time.Time
+}
+
+func (e *ttlEntry) live() bool { return e.clock.Now().Before(e.expires) }
+
+func (s *ClockTestSuite) TestFakeTTL() {
+	c := clock.NewFake()
+	e := &ttlEntry{clock: c, expires: c.Now().Add(time.Minute)}
+	s.True(e.live())
+
+	c.Advance(59 * time.Second)
+	s.True(e.live())
+	c.Advance(time.Second)
+	s.False(e.live())
+}
@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrMemberNotFound = errors.New("cluster: member not found")
+	ErrBreaksQuorum   = errors.New("cluster: removing the member would break quorum")
+)
+
+type Member struct {
+	ID         uint64
+	Name       string
+	PeerURLs   []string
+	ClientURLs []string
+	IsLearner  bool
+}
+
+// Started reports whether the member has joined the cluster. A member that
+// was added but never started has no name and no client URLs.
+func (m Member) Started() bool {
+	return m.Name != "" && len(m.ClientURLs) > 0
+}
+
+type options struct {
+	force bool
+}
+
+type Option func(*options)
+
+// WithForce skips the quorum check of MemberRemove.
+func WithForce() Option {
+	return func(o *options) {
+		o.force = true
+	}
+}
+
+// Manager changes the membership of a cluster.
+type Manager struct {
+	c clientv3.Cluster
+}
+
+func NewManager(c clientv3.Cluster) *Manager {
+	return &Manager{c: c}
+}
+
+func (m *Manager) MemberList(ctx context.Context) ([]Member, error) {
+	resp, err := m.c.MemberList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return members(resp.Members), nil
+}
+
+// MemberAdd adds a voting member, which counts towards the quorum as soon as
+// it is added, before it has even started.
+func (m *Manager) MemberAdd(ctx context.Context, peerURLs []string) (Member, error) {
+	resp, err := m.c.MemberAdd(ctx, peerURLs)
+	if err != nil {
+		return Member{}, err
+	}
+	return member(resp.Member), nil
+}
+
+// MemberAddAsLearner adds a non-voting member, to be promoted by
+// MemberPromote once it has caught up with the leader.
+func (m *Manager) MemberAddAsLearner(ctx context.Context, peerURLs []string) (Member, error) {
+	resp, err := m.c.MemberAddAsLearner(ctx, peerURLs)
+	if err != nil {
+		return Member{}, err
+	}
+	return member(resp.Member), nil
+}
+
+func (m *Manager) MemberPromote(ctx context.Context, id uint64) error {
+	_, err := m.c.MemberPromote(ctx, id)
+	return err
+}
+
+// MemberRemove removes a member. Unless WithForce is given, it refuses with
+// ErrBreaksQuorum when the started voting members left would not make a
+// quorum of the voting members left.
+func (m *Manager) MemberRemove(ctx context.Context, id uint64, opts ...Option) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !o.force {
+		list, err := m.MemberList(ctx)
+		if err != nil {
+			return err
+		}
+		if err := checkRemove(list, id); err != nil {
+			return err
+		}
+	}
+	_, err := m.c.MemberRemove(ctx, id)
+	return err
+}
+
+func checkRemove(list []Member, id uint64) error {
+	var target *Member
+	var voters, started int
+	for i, mem := range list {
+		if mem.ID == id {
+			target = &list[i]
+			continue
+		}
+		if mem.IsLearner {
+			continue
+		}
+		voters++
+		if mem.Started() {
+			started++
+		}
+	}
+	if target == nil {
+		return ErrMemberNotFound
+	}
+	if target.IsLearner {
+		return nil
+	}
+	if voters == 0 || started <= voters/2 {
+		return ErrBreaksQuorum
+	}
+	return nil
+}
+
+func members(pbs []*pb.Member) []Member {
+	list := make([]Member, 0, len(pbs))
+	for _, mem := range pbs {
+		list = append(list, member(mem))
+	}
+	return list
+}
+
+func member(mem *pb.Member) Member {
+	return Member{
+		ID:         mem.ID,
+		Name:       mem.Name,
+		PeerURLs:   mem.PeerURLs,
+		ClientURLs: mem.ClientURLs,
+		IsLearner:  mem.IsLearner,
+	}
+}
@@ -0,0 +1,152 @@
+package cluster_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/cluster"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/stretchr/testify/suite"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var endpoints = []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"}
+
+type ClusterTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestClusterTestSuite(t *testing.T) {
+	suite.Run(t, new(ClusterTestSuite))
+}
+
+func (s *ClusterTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.NoError(err)
+}
+
+func (s *ClusterTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+// fakeCluster lists a fixed membership and records the removals.
+type fakeCluster struct {
+	clientv3.Cluster
+	members []*pb.Member
+	removed []uint64
+}
+
+func (c *fakeCluster) MemberList(ctx context.Context) (*clientv3.MemberListResponse, error) {
+	return &clientv3.MemberListResponse{Members: c.members}, nil
+}
+
+func (c *fakeCluster) MemberRemove(ctx context.Context, id uint64) (*clientv3.MemberRemoveResponse, error) {
+	c.removed = append(c.removed, id)
+	return &clientv3.MemberRemoveResponse{}, nil
+}
+
+func started(id uint64) *pb.Member {
+	return &pb.Member{ID: id, Name: "m", PeerURLs: []string{"http://m:2380"}, ClientURLs: []string{"http://m:2379"}}
+}
+
+func (s *ClusterTestSuite) TestMemberList() {
+	list, err := cluster.NewManager(s.cli).MemberList(context.Background())
+	s.NoError(err)
+	s.Len(list, 3)
+	for _, mem := range list {
+		s.True(mem.Started())
+		s.False(mem.IsLearner)
+	}
+}
+
+func (s *ClusterTestSuite) TestMemberRemoveQuorumGuard() {
+	c := &fakeCluster{members: []*pb.Member{
+		started(1), started(2),
+		// added but never started
+		{ID: 3, PeerURLs: []string{"http://c:2380"}},
+		{ID: 4, Name: "l", ClientURLs: []string{"http://l:2379"}, IsLearner: true},
+	}}
+	m := cluster.NewManager(c)
+
+	// 2 and 3 would be left as voters, with only 2 running
+	s.ErrorIs(m.MemberRemove(context.Background(), 1), cluster.ErrBreaksQuorum)
+	s.Empty(c.removed)
+	s.ErrorIs(m.MemberRemove(context.Background(), 5), cluster.ErrMemberNotFound)
+
+	// learners do not vote, and a member that never started does not help
+	s.NoError(m.MemberRemove(context.Background(), 4))
+	s.NoError(m.MemberRemove(context.Background(), 3))
+	s.NoError(m.MemberRemove(context.Background(), 1, cluster.WithForce()))
+	s.Equal([]uint64{4, 3, 1}, c.removed)
+}
+
+func (s *ClusterTestSuite) TestMemberRemoveLastVoter() {
+	c := &fakeCluster{members: []*pb.Member{started(1)}}
+	s.ErrorIs(cluster.NewManager(c).MemberRemove(context.Background(), 1), cluster.ErrBreaksQuorum)
+	s.Empty(c.removed)
+}
+
+// limitedKV refuses txns of more than max ops, like a server with
+// --max-txn-ops=max, and counts the txns.
+type limitedKV struct {
+	etcdx.KV
+	max  int
+	txns int
+}
+
+func (kv *limitedKV) Txn(ctx context.Context) clientv3.Txn {
+	kv.txns++
+	return &limitedTxn{Txn: kv.KV.Txn(ctx), max: kv.max}
+}
+
+type limitedTxn struct {
+	clientv3.Txn
+	max int
+	ops int
+}
+
+func (t *limitedTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops += len(ops)
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *limitedTxn) Commit() (*clientv3.TxnResponse, error) {
+	if t.ops > t.max {
+		return nil, rpctypes.ErrTooManyOps
+	}
+	return t.Txn.Commit()
+}
+
+func (s *ClusterTestSuite) TestMaxTxnOps() {
+	n, err := cluster.MaxTxnOps(context.Background(), s.cli)
+	s.NoError(err)
+	s.Equal(cluster.DefaultMaxTxnOps, n)
+
+	s.Run("Bisects and caches", func() {
+		kv := &limitedKV{KV: fake.NewKV(), max: 300}
+		n, err := cluster.MaxTxnOps(context.Background(), kv)
+		s.NoError(err)
+		s.Equal(300, n)
+
+		txns := kv.txns
+		n, err = cluster.MaxTxnOps(context.Background(), kv)
+		s.NoError(err)
+		s.Equal(300, n)
+		s.Equal(txns, kv.txns)
+	})
+
+	s.Run("Falls back", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		n, err := cluster.MaxTxnOps(ctx, &limitedKV{KV: s.cli, max: 1000})
+		s.Error(err)
+		s.Equal(cluster.DefaultMaxTxnOps, n)
+	})
+}
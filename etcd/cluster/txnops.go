@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultMaxTxnOps is the default of etcd's --max-txn-ops, which MaxTxnOps
+// falls back to.
+const DefaultMaxTxnOps = 128
+
+// maxProbedTxnOps caps the probing, well below where a txn of gets would hit
+// the request size limit instead.
+const maxProbedTxnOps = 1 << 14
+
+// probeKey is read by the probing txns; it need not exist.
+const probeKey = "/cluster/max-txn-ops-probe"
+
+// txnOps caches the discovered limits by client.
+var txnOps sync.Map
+
+// MaxTxnOps finds out how many ops the cluster accepts in one txn, which no
+// API reports. It probes with read-only txns, doubling from DefaultMaxTxnOps
+// until one is refused and then bisecting, and caches the limit per client.
+// Limits above 16384 are reported as 16384.
+//
+// Discovery is best effort: when probing fails, MaxTxnOps returns
+// DefaultMaxTxnOps along with the error, without caching it.
+func MaxTxnOps(ctx context.Context, cli etcdx.KV) (int, error) {
+	if n, ok := txnOps.Load(cli); ok {
+		return n.(int), nil
+	}
+
+	// lo ops fit in a txn, hi do not
+	lo, hi := 0, DefaultMaxTxnOps
+	for hi <= maxProbedTxnOps {
+		ok, err := fits(ctx, cli, hi)
+		if err != nil {
+			return DefaultMaxTxnOps, err
+		}
+		if !ok {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+	if lo < maxProbedTxnOps {
+		for hi-lo > 1 {
+			mid := lo + (hi-lo)/2
+			ok, err := fits(ctx, cli, mid)
+			if err != nil {
+				return DefaultMaxTxnOps, err
+			}
+			if ok {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+	}
+	txnOps.Store(cli, lo)
+	return lo, nil
+}
+
+func fits(ctx context.Context, cli etcdx.KV, n int) (bool, error) {
+	ops := make([]clientv3.Op, n)
+	for i := range ops {
+		ops[i] = clientv3.OpGet(probeKey, clientv3.WithCountOnly())
+	}
+	_, err := cli.Txn(ctx).Then(ops...).Commit()
+	if errors.Is(err, rpctypes.ErrTooManyOps) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,81 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrNotProto = errors.New("codec: value is not a proto.Message")
+
+// Codec encodes values stored in etcd. Single values and the
+// map[string]interface{} trees assembled from a prefix go through the same
+// Marshal and Unmarshal; a codec that cannot represent a tree, like Protobuf,
+// returns an error for it.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JSON     Codec = jsonCodec{}
+	YAML     Codec = yamlCodec{}
+	Protobuf Codec = protoCodec{}
+)
+
+var (
+	mu     sync.RWMutex
+	codecs = map[string]Codec{"json": JSON, "yaml": YAML, "protobuf": Protobuf}
+)
+
+// Register makes c available to Lookup under name, replacing any codec
+// registered under it before.
+func Register(name string, c Codec) {
+	mu.Lock()
+	codecs[name] = c
+	mu.Unlock()
+}
+
+// Lookup returns the codec registered under name; json, yaml and protobuf
+// are registered from the start.
+func Lookup(name string) (Codec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) { return yaml.Marshal(v) }
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error { return yaml.Unmarshal(data, v) }
+
+// protoCodec encodes proto.Message values in the protobuf wire format.
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrNotProto, v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrNotProto, v)
+	}
+	return proto.Unmarshal(data, m)
+}
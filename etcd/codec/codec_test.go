@@ -0,0 +1,70 @@
+package codec_test
+
+import (
+	"testing"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type CodecTestSuite struct {
+	suite.Suite
+}
+
+func TestCodecTestSuite(t *testing.T) {
+	suite.Run(t, new(CodecTestSuite))
+}
+
+type endpoint struct {
+	Host string `json:"host" yaml:"host"`
+	Port int    `json:"port" yaml:"port"`
+}
+
+func (s *CodecTestSuite) TestRoundTrip() {
+	for _, name := range []string{"json", "yaml"} {
+		c, ok := codec.Lookup(name)
+		s.Require().True(ok, name)
+
+		data, err := c.Marshal(endpoint{Host: "db", Port: 5432})
+		s.NoError(err)
+		var ep endpoint
+		s.NoError(c.Unmarshal(data, &ep))
+		s.Equal(endpoint{Host: "db", Port: 5432}, ep, name)
+
+		// the tree a config loader assembles from a prefix
+		data, err = c.Marshal(map[string]interface{}{"db": map[string]interface{}{"host": "db", "port": 5432}})
+		s.NoError(err)
+		var tree struct {
+			DB endpoint `json:"db" yaml:"db"`
+		}
+		s.NoError(c.Unmarshal(data, &tree))
+		s.Equal(endpoint{Host: "db", Port: 5432}, tree.DB, name)
+	}
+}
+
+func (s *CodecTestSuite) TestProtobuf() {
+	c, ok := codec.Lookup("protobuf")
+	s.Require().True(ok)
+
+	in, err := structpb.NewStruct(map[string]interface{}{"host": "db", "port": 5432})
+	s.Require().NoError(err)
+	data, err := c.Marshal(in)
+	s.NoError(err)
+	out := &structpb.Struct{}
+	s.NoError(c.Unmarshal(data, out))
+	s.Equal(in.AsMap(), out.AsMap())
+
+	_, err = c.Marshal(endpoint{})
+	s.ErrorIs(err, codec.ErrNotProto)
+	s.ErrorIs(c.Unmarshal(data, &endpoint{}), codec.ErrNotProto)
+}
+
+func (s *CodecTestSuite) TestRegister() {
+	_, ok := codec.Lookup("upper-json")
+	s.False(ok)
+	codec.Register("upper-json", codec.JSON)
+	c, ok := codec.Lookup("upper-json")
+	s.True(ok)
+	s.Equal(codec.JSON, c)
+}
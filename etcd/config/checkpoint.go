@@ -0,0 +1,75 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+)
+
+// CheckpointStore keeps the last revision a Watcher has processed, so that a
+// restarted process can pick up where the previous one stopped.
+type CheckpointStore interface {
+	// Load returns the saved revision, or 0 if none was saved yet.
+	Load(ctx context.Context) (int64, error)
+	Save(ctx context.Context, rev int64) error
+}
+
+// FileCheckpoint keeps the revision in a local file, replaced atomically on
+// every save.
+type FileCheckpoint string
+
+func (f FileCheckpoint) Load(ctx context.Context) (int64, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (f FileCheckpoint) Save(ctx context.Context, rev int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(string(f)), filepath.Base(string(f))+".part*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(rev, 10)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), string(f))
+}
+
+// KeyCheckpoint keeps the revision in an etcd key. Saving it is a write of
+// its own, so it should not live under a prefix the same watcher follows.
+type KeyCheckpoint struct {
+	KV  etcdx.KV
+	Key string
+}
+
+func (k KeyCheckpoint) Load(ctx context.Context) (int64, error) {
+	resp, err := k.KV.Get(ctx, k.Key)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseInt(string(resp.Kvs[0].Value), 10, 64)
+}
+
+func (k KeyCheckpoint) Save(ctx context.Context, rev int64) error {
+	_, err := k.KV.Put(ctx, k.Key, strconv.FormatInt(rev, 10))
+	return err
+}
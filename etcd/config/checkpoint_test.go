@@ -0,0 +1,89 @@
+package config_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/config"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *ConfigTestSuite) TestCheckpoint() {
+	key := "/test/config/checkpoint/db"
+	defer s.cli.Delete(context.Background(), "/test/config/checkpoint/", clientv3.WithPrefix())
+
+	for name, store := range map[string]config.CheckpointStore{
+		"File": config.FileCheckpoint(filepath.Join(s.T().TempDir(), "db.rev")),
+		"Key":  config.KeyCheckpoint{KV: s.cli, Key: "/test/config/checkpoint/rev"},
+	} {
+		s.Run(name, func() {
+			_, err := s.cli.Put(context.Background(), key, `{"host":"h0","port":0}`)
+			s.Require().NoError(err)
+
+			// start watches from the current value, then restart and resume
+			var seen []DB
+			changes := make(chan DB, 10)
+			start := func() *config.Watcher {
+				var db DB
+				w, err := config.NewWatcher(context.Background(), s.cli, key, &db,
+					config.WithCheckpoint(store),
+					config.WithOnChange(func(_, new interface{}) { changes <- new.(DB) }))
+				s.Require().NoError(err)
+				return w
+			}
+			next := func() {
+				select {
+				case db := <-changes:
+					seen = append(seen, db)
+				case <-time.After(5 * time.Second):
+					s.FailNow("no change callback")
+				}
+			}
+
+			w := start()
+			s.Equal(DB{Host: "h0", Port: 0}, w.Value())
+			_, err = s.cli.Put(context.Background(), key, `{"host":"h1","port":1}`)
+			s.Require().NoError(err)
+			next()
+			// wait for the checkpoint to be saved before stopping
+			resp, err := s.cli.Get(context.Background(), key)
+			s.Require().NoError(err)
+			s.Eventually(func() bool {
+				rev, err := store.Load(context.Background())
+				return err == nil && rev >= resp.Kvs[0].ModRevision
+			}, 5*time.Second, 10*time.Millisecond)
+			w.Stop()
+
+			// changes while no watcher runs
+			for _, val := range []string{`{"host":"h2","port":2}`, `{"host":"h3","port":3}`} {
+				_, err = s.cli.Put(context.Background(), key, val)
+				s.Require().NoError(err)
+			}
+
+			w = start()
+			defer w.Stop()
+			next()
+			next()
+			s.Equal([]DB{{"h1", 1}, {"h2", 2}, {"h3", 3}}, seen)
+			s.Equal(DB{Host: "h3", Port: 3}, w.Value())
+
+			select {
+			case db := <-changes:
+				s.Fail("change seen twice", "%v", db)
+			case <-time.After(300 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func (s *ConfigTestSuite) TestCheckpointEmpty() {
+	store := config.FileCheckpoint(filepath.Join(s.T().TempDir(), "none"))
+	rev, err := store.Load(context.Background())
+	s.NoError(err)
+	s.Zero(rev)
+	s.NoError(store.Save(context.Background(), 42))
+	rev, err = store.Load(context.Background())
+	s.NoError(err)
+	s.Equal(int64(42), rev)
+}
@@ -0,0 +1,12 @@
+package config
+
+import "github.com/gojustforfun/learn-by-test/etcd/codec"
+
+// Codec decodes stored values into config structs. It is codec.Codec, and
+// any codec of that package can be passed to WithCodec.
+type Codec = codec.Codec
+
+var (
+	JSON = codec.JSON
+	YAML = codec.YAML
+)
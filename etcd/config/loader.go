@@ -0,0 +1,171 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrNotFound = errors.New("config: not found")
+
+// DecodeError reports a value that the codec failed to decode.
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("config: decode %s: %v", e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+type options struct {
+	codec           Codec
+	defaultReadMode bool
+	checkpoint      CheckpointStore
+	onChange        []func(old, new interface{})
+}
+
+type Option func(*options)
+
+// WithCodec sets the codec used to decode values, JSON by default.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// WithDefaultReadMode makes the initial reads use kv.DefaultReadMode instead
+// of always being linearizable.
+func WithDefaultReadMode() Option {
+	return func(o *options) {
+		o.defaultReadMode = true
+	}
+}
+
+// WithCheckpoint makes a Watcher save every revision it has processed to
+// store, and start from the saved one when there is one: out is loaded with
+// the value as of that revision, and the changes since are replayed to the
+// callbacks, so none is missed or seen twice across a restart. Register the
+// callbacks with WithOnChange to be sure to see the replayed changes.
+//
+// If the saved revision was compacted, the watcher starts from the current
+// value and reports the gap on Errors.
+func WithCheckpoint(store CheckpointStore) Option {
+	return func(o *options) {
+		o.checkpoint = store
+	}
+}
+
+// WithOnChange registers fn as OnChange does, before the watch starts.
+func WithOnChange(fn func(old, new interface{})) Option {
+	return func(o *options) {
+		o.onChange = append(o.onChange, fn)
+	}
+}
+
+// readOpts adds the options of the read mode to opts.
+func (o options) readOpts(opts ...clientv3.OpOption) []clientv3.OpOption {
+	if o.defaultReadMode {
+		return append(opts, kv.DefaultReadMode.Options()...)
+	}
+	return opts
+}
+
+func newOptions(opts []Option) options {
+	o := options{codec: JSON}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+type Loader struct {
+	cli  *clientv3.Client
+	opts options
+}
+
+func NewLoader(cli *clientv3.Client, opts ...Option) *Loader {
+	return &Loader{cli: cli, opts: newOptions(opts)}
+}
+
+// Load decodes the value of key into out.
+func (l *Loader) Load(ctx context.Context, key string, out interface{}) error {
+	resp, err := l.cli.Get(ctx, key, l.opts.readOpts()...)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err := l.opts.codec.Unmarshal(resp.Kvs[0].Value, out); err != nil {
+		return &DecodeError{Key: key, Err: err}
+	}
+	return nil
+}
+
+// LoadTree reads every key under prefix, nests them by their slash-delimited
+// path below the prefix and decodes the resulting tree into out. Each leaf is
+// decoded with the codec when possible and kept as a plain string otherwise,
+// so /app/db/port = 5432 fills an int field while /app/db/host = localhost
+// fills a string one.
+func (l *Loader) LoadTree(ctx context.Context, prefix string, out interface{}) error {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	resp, err := l.cli.Get(ctx, prefix, l.opts.readOpts(clientv3.WithPrefix())...)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, prefix)
+	}
+
+	tree := make(map[string]interface{})
+	for _, kv := range resp.Kvs {
+		path := strings.Split(strings.Trim(strings.TrimPrefix(string(kv.Key), prefix), "/"), "/")
+		if err := insert(tree, path, l.leaf(kv.Value)); err != nil {
+			return &DecodeError{Key: string(kv.Key), Err: err}
+		}
+	}
+
+	data, err := l.opts.codec.Marshal(tree)
+	if err != nil {
+		return &DecodeError{Key: prefix, Err: err}
+	}
+	if err := l.opts.codec.Unmarshal(data, out); err != nil {
+		return &DecodeError{Key: prefix, Err: err}
+	}
+	return nil
+}
+
+func (l *Loader) leaf(value []byte) interface{} {
+	var v interface{}
+	if err := l.opts.codec.Unmarshal(value, &v); err != nil {
+		return string(value)
+	}
+	return v
+}
+
+func insert(tree map[string]interface{}, path []string, value interface{}) error {
+	for _, name := range path[:len(path)-1] {
+		child, ok := tree[name]
+		if !ok {
+			child = make(map[string]interface{})
+			tree[name] = child
+		}
+		if tree, ok = child.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s is both a value and a directory", name)
+		}
+	}
+	if _, ok := tree[path[len(path)-1]]; ok {
+		return fmt.Errorf("%s is both a value and a directory", path[len(path)-1])
+	}
+	tree[path[len(path)-1]] = value
+	return nil
+}
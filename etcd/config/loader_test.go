@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/config"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (s *ConfigTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *ConfigTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+type DB struct {
+	Host string `json:"host" yaml:"host"`
+	Port int    `json:"port" yaml:"port"`
+}
+
+type App struct {
+	DB DB `json:"db" yaml:"db"`
+}
+
+func (s *ConfigTestSuite) TestLoad() {
+	key := "/test/config/load/db"
+	_, err := s.cli.Put(context.Background(), key, `{"host":"localhost","port":5432}`)
+	s.NoError(err)
+	defer s.cli.Delete(context.Background(), key)
+
+	var db DB
+	s.NoError(config.NewLoader(s.cli).Load(context.Background(), key, &db))
+	s.Equal(DB{Host: "localhost", Port: 5432}, db)
+
+	s.Run("YAML", func() {
+		_, err := s.cli.Put(context.Background(), key, "host: db.local\nport: 3306\n")
+		s.NoError(err)
+
+		var db DB
+		s.NoError(config.NewLoader(s.cli, config.WithCodec(config.YAML)).Load(context.Background(), key, &db))
+		s.Equal(DB{Host: "db.local", Port: 3306}, db)
+	})
+}
+
+func (s *ConfigTestSuite) TestLoadMissing() {
+	var db DB
+	err := config.NewLoader(s.cli).Load(context.Background(), "/test/config/missing", &db)
+	s.ErrorIs(err, config.ErrNotFound)
+
+	err = config.NewLoader(s.cli).LoadTree(context.Background(), "/test/config/missing", &db)
+	s.ErrorIs(err, config.ErrNotFound)
+}
+
+func (s *ConfigTestSuite) TestLoadMalformed() {
+	key := "/test/config/malformed"
+	_, err := s.cli.Put(context.Background(), key, `{"host":`)
+	s.NoError(err)
+	defer s.cli.Delete(context.Background(), key)
+
+	var db DB
+	err = config.NewLoader(s.cli).Load(context.Background(), key, &db)
+	var decodeErr *config.DecodeError
+	s.ErrorAs(err, &decodeErr)
+	s.Equal(key, decodeErr.Key)
+}
+
+func (s *ConfigTestSuite) TestLoadTree() {
+	prefix := "/test/config/tree/app"
+	_, err := s.cli.Put(context.Background(), prefix+"/db/host", "localhost")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"/db/port", "5432")
+	s.NoError(err)
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	for name, codec := range map[string]config.Codec{"JSON": config.JSON, "YAML": config.YAML} {
+		s.Run(name, func() {
+			var app App
+			s.NoError(config.NewLoader(s.cli, config.WithCodec(codec)).LoadTree(context.Background(), prefix, &app))
+			s.Equal(App{DB: DB{Host: "localhost", Port: 5432}}, app)
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Watcher keeps a struct in sync with the value of a key. It loads the key
+// once and then watches from the revision right after that read, so no
+// update in between is dropped.
+type Watcher struct {
+	cli  *clientv3.Client
+	key  string
+	opts options
+
+	mu        sync.Mutex
+	out       reflect.Value
+	callbacks []func(old, new interface{})
+	// applied is the mod revision of the value out holds
+	applied int64
+
+	errs     chan error
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWatcher decodes the current value of key into out, which must be a
+// pointer, and keeps updating it until Stop is called. A value that fails to
+// decode leaves out untouched and is reported on Errors.
+func NewWatcher(ctx context.Context, cli *clientv3.Client, key string, out interface{}, opts ...Option) (*Watcher, error) {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, errors.New("config: out must be a non-nil pointer")
+	}
+
+	w := &Watcher{
+		cli:  cli,
+		key:  key,
+		opts: newOptions(opts),
+		out:  v,
+		errs: make(chan error, 16),
+		done: make(chan struct{}),
+	}
+	w.callbacks = append(w.callbacks, w.opts.onChange...)
+
+	rev, err := w.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go w.run(watchCtx, rev)
+	return w, nil
+}
+
+// load decodes the value into out and returns the revision to watch after:
+// the checkpoint if there is a usable one, the revision of the read if not.
+func (w *Watcher) load(ctx context.Context) (int64, error) {
+	var checkpoint int64
+	if w.opts.checkpoint != nil {
+		var err error
+		if checkpoint, err = w.opts.checkpoint.Load(ctx); err != nil {
+			return 0, fmt.Errorf("config: load checkpoint: %w", err)
+		}
+	}
+
+	var opts []clientv3.OpOption
+	if checkpoint > 0 {
+		opts = append(opts, clientv3.WithRev(checkpoint))
+	}
+	resp, err := w.cli.Get(ctx, w.key, w.opts.readOpts(opts...)...)
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		w.report(fmt.Errorf("config: checkpoint %d of %s compacted, changes since were missed: %w", checkpoint, w.key, err))
+		checkpoint = 0
+		resp, err = w.cli.Get(ctx, w.key, w.opts.readOpts()...)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, w.key)
+	}
+	if err := w.opts.codec.Unmarshal(resp.Kvs[0].Value, w.out.Interface()); err != nil {
+		return 0, &DecodeError{Key: w.key, Err: err}
+	}
+	w.applied = resp.Kvs[0].ModRevision
+
+	if checkpoint > 0 {
+		return checkpoint, nil
+	}
+	w.save(ctx, resp.Header.Revision)
+	return resp.Header.Revision, nil
+}
+
+// OnChange registers fn to be called with the previous and the new value,
+// both of the type out points to, after every successful reload. Callbacks
+// run on the watch goroutine and must not call Stop.
+func (w *Watcher) OnChange(fn func(old, new interface{})) {
+	w.mu.Lock()
+	w.callbacks = append(w.callbacks, fn)
+	w.mu.Unlock()
+}
+
+// Value returns a copy of the current config. Use it instead of reading out
+// directly when other goroutines may be reloading it.
+func (w *Watcher) Value() interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.Elem().Interface()
+}
+
+// Errors reports values that could not be decoded and deletions of the key.
+// Errors are dropped if nobody keeps up with the channel.
+func (w *Watcher) Errors() <-chan error {
+	return w.errs
+}
+
+// Stop ends the watch. No callback runs after Stop returns.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(w.cancel)
+	<-w.done
+}
+
+func (w *Watcher) run(ctx context.Context, rev int64) {
+	defer close(w.done)
+
+	for ctx.Err() == nil {
+		for watchResp := range w.cli.Watch(ctx, w.key, clientv3.WithRev(rev+1)) {
+			if err := watchResp.Err(); err != nil {
+				w.report(err)
+				if errors.Is(err, rpctypes.ErrCompacted) {
+					// the changes since rev are gone, catch up with the key as it is now
+					rev = w.reload(ctx, rev)
+				}
+				break
+			}
+			for _, ev := range watchResp.Events {
+				w.apply(ctx, ev)
+			}
+			rev = watchResp.Header.Revision
+			if ctx.Err() == nil {
+				w.save(ctx, rev)
+			}
+		}
+
+		// the watch closed, re-establish it from the last seen revision
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// reload reads the key, applies its value if it changed since the last one
+// applied and returns the revision of the read, or rev if it failed.
+func (w *Watcher) reload(ctx context.Context, rev int64) int64 {
+	resp, err := w.cli.Get(ctx, w.key, w.opts.readOpts()...)
+	if err != nil {
+		w.report(fmt.Errorf("config: reload %s: %w", w.key, err))
+		return rev
+	}
+	switch {
+	case len(resp.Kvs) == 0:
+		w.apply(ctx, &clientv3.Event{Type: mvccpb.DELETE})
+	case resp.Kvs[0].ModRevision > w.applied:
+		w.apply(ctx, &clientv3.Event{Type: mvccpb.PUT, Kv: resp.Kvs[0]})
+	}
+	if ctx.Err() == nil {
+		w.save(ctx, resp.Header.Revision)
+	}
+	return resp.Header.Revision
+}
+
+func (w *Watcher) apply(ctx context.Context, ev *clientv3.Event) {
+	if ev.Type == mvccpb.DELETE {
+		w.report(fmt.Errorf("%w: %s deleted, keeping last config", ErrNotFound, w.key))
+		return
+	}
+
+	next := reflect.New(w.out.Type().Elem())
+	if err := w.opts.codec.Unmarshal(ev.Kv.Value, next.Interface()); err != nil {
+		w.report(&DecodeError{Key: w.key, Err: err})
+		return
+	}
+
+	w.mu.Lock()
+	old := w.out.Elem().Interface()
+	w.out.Elem().Set(next.Elem())
+	w.applied = ev.Kv.ModRevision
+	callbacks := append([]func(old, new interface{}){}, w.callbacks...)
+	w.mu.Unlock()
+
+	for _, fn := range callbacks {
+		if ctx.Err() != nil {
+			return
+		}
+		fn(old, next.Elem().Interface())
+	}
+}
+
+// save records rev as processed, if the watcher keeps a checkpoint.
+func (w *Watcher) save(ctx context.Context, rev int64) {
+	if w.opts.checkpoint == nil {
+		return
+	}
+	if err := w.opts.checkpoint.Save(ctx, rev); err != nil {
+		w.report(fmt.Errorf("config: save checkpoint: %w", err))
+	}
+}
+
+func (w *Watcher) report(err error) {
+	select {
+	case w.errs <- err:
+	default:
+	}
+}
@@ -0,0 +1,141 @@
+package config_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/config"
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type change struct {
+	old, new DB
+}
+
+func (s *ConfigTestSuite) TestWatcher() {
+	key := "/test/config/watcher/db"
+	_, err := s.cli.Put(context.Background(), key, `{"host":"h0","port":1}`)
+	s.NoError(err)
+	defer s.cli.Delete(context.Background(), key)
+
+	var db DB
+	w, err := config.NewWatcher(context.Background(), s.cli, key, &db)
+	s.NoError(err)
+	defer w.Stop()
+	s.Equal(DB{Host: "h0", Port: 1}, w.Value())
+
+	changes := make(chan change, 10)
+	w.OnChange(func(old, new interface{}) {
+		changes <- change{old.(DB), new.(DB)}
+	})
+
+	nextChange := func() change {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(5 * time.Second):
+			s.FailNow("no change callback")
+		}
+		return change{}
+	}
+
+	for _, val := range []string{`{"host":"h1","port":1}`, `{"host":"h2","port":2}`, `{"host":"h3","port":3}`} {
+		_, err := s.cli.Put(context.Background(), key, val)
+		s.NoError(err)
+	}
+	s.Equal(change{DB{"h0", 1}, DB{"h1", 1}}, nextChange())
+	s.Equal(change{DB{"h1", 1}, DB{"h2", 2}}, nextChange())
+	s.Equal(change{DB{"h2", 2}, DB{"h3", 3}}, nextChange())
+
+	s.Run("Bad payload keeps last good config", func() {
+		_, err := s.cli.Put(context.Background(), key, `{"host":`)
+		s.NoError(err)
+
+		select {
+		case err := <-w.Errors():
+			var decodeErr *config.DecodeError
+			s.ErrorAs(err, &decodeErr)
+		case <-time.After(5 * time.Second):
+			s.Fail("decode error not reported")
+		}
+		s.Equal(DB{Host: "h3", Port: 3}, w.Value())
+
+		_, err = s.cli.Put(context.Background(), key, `{"host":"h4","port":4}`)
+		s.NoError(err)
+		s.Equal(change{DB{"h3", 3}, DB{"h4", 4}}, nextChange())
+	})
+
+	s.Run("No callback after Stop", func() {
+		w.Stop()
+		w.Stop()
+		_, err := s.cli.Put(context.Background(), key, `{"host":"h5","port":5}`)
+		s.NoError(err)
+
+		select {
+		case c := <-changes:
+			s.Fail("callback after Stop", "%v", c)
+		case <-time.After(300 * time.Millisecond):
+		}
+		s.Equal(DB{Host: "h4", Port: 4}, db)
+	})
+}
+
+// gatedWatcher holds back the first watch until open is closed.
+type gatedWatcher struct {
+	clientv3.Watcher
+	open chan struct{}
+	once sync.Once
+}
+
+func (w *gatedWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	w.once.Do(func() {
+		select {
+		case <-w.open:
+		case <-ctx.Done():
+		}
+	})
+	return w.Watcher.Watch(ctx, key, opts...)
+}
+
+func (s *ConfigTestSuite) TestWatcherCompacted() {
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+	gate := &gatedWatcher{Watcher: cli.Watcher, open: make(chan struct{})}
+	cli.Watcher = gate
+
+	key := "/test/config/watcher/compacted"
+	_, err := cli.Put(context.Background(), key, `{"host":"h0","port":0}`)
+	s.NoError(err)
+
+	store := config.KeyCheckpoint{KV: cli, Key: "/test/config/watcher/compacted-rev"}
+	var db DB
+	w, err := config.NewWatcher(context.Background(), cli, key, &db, config.WithCheckpoint(store))
+	s.NoError(err)
+	defer w.Stop()
+
+	// the watch starts only after the change it should see is compacted away
+	_, err = cli.Put(context.Background(), key, `{"host":"h1","port":1}`)
+	s.NoError(err)
+	resp, err := cli.Put(context.Background(), "/test/config/watcher/other", "")
+	s.NoError(err)
+	_, err = cli.Compact(context.Background(), resp.Header.Revision)
+	s.NoError(err)
+	close(gate.open)
+
+	s.Eventually(func() bool {
+		return w.Value() == DB{Host: "h1", Port: 1}
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Eventually(func() bool {
+		rev, err := store.Load(context.Background())
+		return err == nil && rev >= resp.Header.Revision
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// and it goes on from the revision of the reload
+	_, err = cli.Put(context.Background(), key, `{"host":"h2","port":2}`)
+	s.NoError(err)
+	s.Eventually(func() bool {
+		return w.Value() == DB{Host: "h2", Port: 2}
+	}, 5*time.Second, 10*time.Millisecond)
+}
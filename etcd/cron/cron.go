@@ -0,0 +1,281 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultTTL = 10
+	claimKey   = "/claim"
+)
+
+// Schedule computes when a job runs next after t.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// ScheduleFunc adapts a function to Schedule.
+type ScheduleFunc func(t time.Time) time.Time
+
+func (f ScheduleFunc) Next(t time.Time) time.Time { return f(t) }
+
+// Every runs a job every d.
+func Every(d time.Duration) Schedule {
+	return ScheduleFunc(func(t time.Time) time.Time { return t.Add(d) })
+}
+
+type options struct {
+	ttl     int
+	onError func(error)
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease backing the claims of a
+// worker, i.e. how long the job of a crashed worker waits to be picked up.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithOnError is called with the errors of the jobs this worker runs. They are
+// dropped by default.
+func WithOnError(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+type job struct {
+	schedule Schedule
+	fn       func(ctx context.Context) error
+}
+
+// jobState is a job as last read from etcd.
+type jobState struct {
+	due     time.Time
+	modRev  int64
+	claimed bool
+}
+
+// Coordinator runs jobs shared by several workers, each running its own
+// Coordinator on the same prefix with the same jobs registered.
+//
+// The time a job is due is stored under <prefix><id>. Once it has passed,
+// workers race to create <prefix><id>/claim with their lease in a txn also
+// guarded on the ModRevision of the job key, so exactly one worker runs it.
+// The winner then moves the due time forward and deletes the claim in one
+// txn. If it dies first, the claim goes with its lease and another worker
+// runs the job again.
+type Coordinator struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   options
+
+	mu   sync.Mutex
+	jobs map[string]job
+}
+
+func New(cli *clientv3.Client, prefix string, opts ...Option) *Coordinator {
+	o := options{ttl: defaultTTL, onError: func(error) {}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Coordinator{cli: cli, prefix: prefix, opts: o, jobs: make(map[string]job)}
+}
+
+// Register adds a job that runs fn on schedule. Whether fn fails or not, the
+// job is rescheduled once it returns. Jobs registered while Run is running
+// are picked up the next time the state changes.
+func (c *Coordinator) Register(id string, schedule Schedule, fn func(ctx context.Context) error) {
+	c.mu.Lock()
+	c.jobs[id] = job{schedule: schedule, fn: fn}
+	c.mu.Unlock()
+}
+
+// Run fires due jobs until ctx is done or the worker's lease is lost, then
+// waits for the jobs it started. Each job runs in its own goroutine.
+func (c *Coordinator) Run(ctx context.Context) error {
+	sess, err := session.New(c.cli, session.WithTTL(c.opts.ttl), session.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-sess.Done():
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	running := make(map[string]bool)
+	var runningMu sync.Mutex
+
+	for runCtx.Err() == nil {
+		getResp, err := c.cli.Get(runCtx, c.prefix, clientv3.WithPrefix())
+		if err != nil {
+			select {
+			case <-runCtx.Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		states := c.parse(getResp.Kvs)
+
+		now := time.Now()
+		var wakeup time.Time
+		for id, j := range c.snapshot() {
+			st, ok := states[id]
+			if !ok {
+				c.create(runCtx, id, j.schedule.Next(now))
+				continue
+			}
+
+			runningMu.Lock()
+			busy := running[id]
+			runningMu.Unlock()
+			if st.claimed || busy {
+				continue
+			}
+			if st.due.After(now) {
+				if wakeup.IsZero() || st.due.Before(wakeup) {
+					wakeup = st.due
+				}
+				continue
+			}
+			if !c.claim(runCtx, id, st.modRev, sess.Lease()) {
+				continue
+			}
+
+			runningMu.Lock()
+			running[id] = true
+			runningMu.Unlock()
+			wg.Add(1)
+			go func(id string, j job, modRev int64) {
+				defer wg.Done()
+				if err := j.fn(runCtx); err != nil && runCtx.Err() == nil {
+					c.opts.onError(fmt.Errorf("cron: job %s: %w", id, err))
+				}
+				c.complete(runCtx, id, modRev, sess.Lease(), j.schedule.Next(time.Now()))
+				runningMu.Lock()
+				delete(running, id)
+				runningMu.Unlock()
+			}(id, j, st.modRev)
+		}
+
+		c.waitChange(runCtx, getResp.Header.Revision, wakeup)
+	}
+	return ctx.Err()
+}
+
+func (c *Coordinator) snapshot() map[string]job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	jobs := make(map[string]job, len(c.jobs))
+	for id, j := range c.jobs {
+		jobs[id] = j
+	}
+	return jobs
+}
+
+func (c *Coordinator) parse(kvs []*mvccpb.KeyValue) map[string]jobState {
+	states := make(map[string]jobState)
+	for _, kv := range kvs {
+		name := strings.TrimPrefix(string(kv.Key), c.prefix)
+		if id := strings.TrimSuffix(name, claimKey); id != name {
+			st := states[id]
+			st.claimed = true
+			states[id] = st
+			continue
+		}
+		due, err := time.Parse(time.RFC3339Nano, string(kv.Value))
+		if err != nil {
+			continue
+		}
+		st := states[name]
+		st.due, st.modRev = due, kv.ModRevision
+		states[name] = st
+	}
+	// claims of jobs that do not exist are not jobs
+	for id, st := range states {
+		if st.modRev == 0 {
+			delete(states, id)
+		}
+	}
+	return states
+}
+
+// create stores the first due time of a job unless another worker did.
+func (c *Coordinator) create(ctx context.Context, id string, due time.Time) {
+	key := c.prefix + id
+	c.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, due.Format(time.RFC3339Nano))).
+		Commit()
+}
+
+// claim reports whether this worker won the job with its lease.
+func (c *Coordinator) claim(ctx context.Context, id string, modRev int64, lease clientv3.LeaseID) bool {
+	key := c.prefix + id
+	resp, err := c.cli.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.ModRevision(key), "=", modRev),
+			clientv3.Compare(clientv3.CreateRevision(key+claimKey), "=", 0),
+		).
+		Then(clientv3.OpPut(key+claimKey, "", clientv3.WithLease(lease))).
+		Commit()
+	return err == nil && resp.Succeeded
+}
+
+// complete moves the due time forward and releases the claim, provided the
+// claim is still ours.
+func (c *Coordinator) complete(ctx context.Context, id string, modRev int64, lease clientv3.LeaseID, next time.Time) {
+	key := c.prefix + id
+	c.cli.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.ModRevision(key), "=", modRev),
+			clientv3.Compare(clientv3.LeaseValue(key+claimKey), "=", lease),
+		).
+		Then(clientv3.OpPut(key, next.Format(time.RFC3339Nano)), clientv3.OpDelete(key+claimKey)).
+		Commit()
+}
+
+// waitChange blocks until something under the prefix changes after rev, until
+// wakeup unless it is zero, or until ctx is done.
+func (c *Coordinator) waitChange(ctx context.Context, rev int64, wakeup time.Time) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var timer <-chan time.Time
+	if !wakeup.IsZero() {
+		timer = time.After(time.Until(wakeup))
+	}
+	watchChan := c.cli.Watch(ctx, c.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer:
+			return
+		case watchResp, ok := <-watchChan:
+			if !ok || watchResp.Err() != nil || len(watchResp.Events) > 0 {
+				return
+			}
+		}
+	}
+}
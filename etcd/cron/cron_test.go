@@ -0,0 +1,148 @@
+package cron_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/cron"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type CronTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestCronTestSuite(t *testing.T) {
+	suite.Run(t, new(CronTestSuite))
+}
+
+func (s *CronTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *CronTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+// once is due shortly after the first time it is asked, then never again.
+func once() cron.Schedule {
+	var calls int32
+	return cron.ScheduleFunc(func(t time.Time) time.Time {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return t.Add(100 * time.Millisecond)
+		}
+		return t.Add(time.Hour)
+	})
+}
+
+func (s *CronTestSuite) TestExactlyOnce() {
+	prefix := "/test/cron/once/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs int32
+	var wg sync.WaitGroup
+	schedule := once()
+	for i := 0; i < 2; i++ {
+		c := cron.New(s.cli, prefix)
+		c.Register("job1", schedule, func(ctx context.Context) error {
+			atomic.AddInt32(&runs, 1)
+			return nil
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run(ctx)
+		}()
+	}
+
+	s.Eventually(func() bool { return atomic.LoadInt32(&runs) == 1 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	wg.Wait()
+	s.Equal(int32(1), atomic.LoadInt32(&runs))
+}
+
+func (s *CronTestSuite) TestOnError() {
+	prefix := "/test/cron/error/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	errFailed := errors.New("job failed")
+	errs := make(chan error, 1)
+	c := cron.New(s.cli, prefix, cron.WithOnError(func(err error) { errs <- err }))
+	c.Register("job1", once(), func(ctx context.Context) error { return errFailed })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	select {
+	case err := <-errs:
+		s.ErrorIs(err, errFailed)
+		s.Contains(err.Error(), "job1")
+	case <-time.After(5 * time.Second):
+		s.Fail("job error not reported")
+	}
+}
+
+func (s *CronTestSuite) TestCrashedClaimer() {
+	prefix := "/test/cron/crashed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	crashed, err := clientv3.New(clientv3.Config{
+		Endpoints:   s.cli.Endpoints(),
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+
+	schedule := once()
+	started := make(chan struct{})
+	c := cron.New(crashed, prefix, cron.WithTTL(2))
+	c.Register("job1", schedule, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	go c.Run(context.Background())
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		s.FailNow("job not started")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	recovered := make(chan struct{})
+	c = cron.New(s.cli, prefix)
+	c.Register("job1", schedule, func(ctx context.Context) error {
+		close(recovered)
+		return nil
+	})
+	go c.Run(ctx)
+
+	// the claimer dies mid-run and its claim expires with its lease
+	crashed.Close()
+	select {
+	case <-recovered:
+	case <-time.After(10 * time.Second):
+		s.Fail("job not recovered")
+	}
+}
@@ -0,0 +1,317 @@
+package election
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+var (
+	ErrNoLeader       = errors.New("election: no leader")
+	ErrLeaseExpired   = errors.New("election: lease expired while campaigning")
+	ErrNotCampaigning = errors.New("election: not campaigning")
+)
+
+type options struct {
+	ttl   int64
+	plain bool
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease backing the candidate key,
+// which bounds how long a crashed leader keeps leadership. A shorter TTL fails
+// over faster at the cost of more frequent keep-alives.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = int64(ttl)
+	}
+}
+
+// WithPlainValues makes Leader return every value as is, in LeaderInfo.Value,
+// for elections whose plain values might read as a LeaderInfo payload.
+func WithPlainValues() Option {
+	return func(o *options) {
+		o.plain = true
+	}
+}
+
+// LeaderInfo describes a candidate, as published by CampaignInfo.
+type LeaderInfo struct {
+	Host    string    `json:"host,omitempty"`
+	Version string    `json:"version,omitempty"`
+	Started time.Time `json:"started"`
+	// Value is the candidate key's value as stored: the encoded payload, or
+	// the value of a plain Campaign.
+	Value string `json:"-"`
+}
+
+// Election puts one lease-backed candidate key per campaign under the prefix.
+// The candidate with the lowest CreateRevision is the leader; when its lease
+// expires the next one in line is promoted.
+type Election struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   options
+
+	mu      sync.Mutex
+	leaseID clientv3.LeaseID
+	key     string
+	rev     int64
+	cancel  context.CancelFunc
+}
+
+func New(cli *clientv3.Client, prefix string, opts ...Option) *Election {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Election{cli: cli, prefix: prefix + "/", opts: o}
+}
+
+// CampaignInfo campaigns like Campaign with info, encoded as JSON, as the
+// value, so that Leader can tell followers who leads. A zero Started is set
+// to the current time.
+func (e *Election) CampaignInfo(ctx context.Context, info LeaderInfo) error {
+	if info.Started.IsZero() {
+		info.Started = time.Now()
+	}
+	val, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return e.Campaign(ctx, string(val))
+}
+
+// Campaign blocks until this candidate becomes leader or ctx is done. A
+// cancelled campaign revokes its lease so the candidate key does not linger.
+func (e *Election) Campaign(ctx context.Context, val string) error {
+	grantResp, err := e.cli.Grant(ctx, e.opts.ttl)
+	if err != nil {
+		return err
+	}
+	kaCtx, cancel := context.WithCancel(context.Background())
+	keepChan, err := e.cli.KeepAlive(kaCtx, grantResp.ID)
+	if err != nil {
+		cancel()
+		e.revoke(grantResp.ID)
+		return err
+	}
+	go func() {
+		for range keepChan {
+		}
+	}()
+	abort := func(err error) error {
+		cancel()
+		e.revoke(grantResp.ID)
+		return err
+	}
+
+	key := fmt.Sprintf("%s%x", e.prefix, grantResp.ID)
+	putResp, err := e.cli.Put(ctx, key, val, clientv3.WithLease(grantResp.ID))
+	if err != nil {
+		return abort(err)
+	}
+	rev := putResp.Header.Revision
+
+	if err := wait.Deletes(ctx, e.cli, e.prefix, rev-1); err != nil {
+		return abort(err)
+	}
+
+	e.mu.Lock()
+	e.leaseID, e.key, e.rev, e.cancel = grantResp.ID, key, rev, cancel
+	e.mu.Unlock()
+
+	// rewriting the key tells ResignAndAwaitSuccessor of the predecessor that
+	// this candidate has taken over; it also checks the lease is still there
+	txnResp, err := e.cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", rev)).
+		Then(clientv3.OpPut(key, val, clientv3.WithLease(grantResp.ID))).
+		Commit()
+	if err == nil && !txnResp.Succeeded {
+		err = ErrLeaseExpired
+	}
+	if err != nil {
+		e.mu.Lock()
+		e.leaseID, e.key, e.rev, e.cancel = clientv3.NoLease, "", 0, nil
+		e.mu.Unlock()
+		return abort(err)
+	}
+	return nil
+}
+
+// Resign steps down by deleting the candidate key and revoking its lease.
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	leaseID, key, cancel := e.leaseID, e.key, e.cancel
+	e.leaseID, e.key, e.rev, e.cancel = clientv3.NoLease, "", 0, nil
+	e.mu.Unlock()
+
+	if cancel == nil {
+		return ErrNotCampaigning
+	}
+	defer cancel()
+	if _, err := e.cli.Delete(ctx, key); err != nil {
+		return err
+	}
+	return e.revoke(leaseID)
+}
+
+// SuccessorError is returned by ResignAndAwaitSuccessor when it resigned but
+// no other candidate took over within the timeout.
+type SuccessorError struct {
+	Timeout time.Duration
+}
+
+func (e *SuccessorError) Error() string {
+	return fmt.Sprintf("election: resigned, but no successor took over within %v", e.Timeout)
+}
+
+// ResignAndAwaitSuccessor resigns like Resign, then waits up to timeout for
+// another candidate to take over, so that a planned restart leaves the
+// election leaderless as briefly as possible. Once it returns nil, the
+// successor's Campaign has succeeded. If no candidate takes over in time it
+// returns a *SuccessorError; the resignation stands either way.
+func (e *Election) ResignAndAwaitSuccessor(ctx context.Context, timeout time.Duration) error {
+	if err := e.Resign(ctx); err != nil {
+		return err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		resp, err := e.cli.Get(waitCtx, e.prefix, clientv3.WithFirstCreate()...)
+		if err == nil {
+			// a leader rewrites its key once it has taken over
+			if len(resp.Kvs) > 0 && resp.Kvs[0].ModRevision > resp.Kvs[0].CreateRevision {
+				return nil
+			}
+			err = wait.AnyPut(waitCtx, e.cli, e.prefix, resp.Header.Revision)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if waitCtx.Err() != nil {
+			return &SuccessorError{Timeout: timeout}
+		}
+		if err != nil && !errors.Is(err, wait.ErrWatchClosed) {
+			return err
+		}
+	}
+}
+
+// Leader returns the current leader: the LeaderInfo it campaigned with, or
+// only the Value of a plain Campaign.
+func (e *Election) Leader(ctx context.Context) (LeaderInfo, error) {
+	resp, err := e.cli.Get(ctx, e.prefix, clientv3.WithFirstCreate()...)
+	if err != nil {
+		return LeaderInfo{}, err
+	}
+	if len(resp.Kvs) == 0 {
+		return LeaderInfo{}, ErrNoLeader
+	}
+	return e.decode(resp.Kvs[0].Value), nil
+}
+
+// decode reads a CampaignInfo payload. Values that are not one, or all of
+// them with WithPlainValues, only fill in Value.
+func (e *Election) decode(val []byte) LeaderInfo {
+	var info LeaderInfo
+	if e.opts.plain || json.Unmarshal(val, &info) != nil {
+		info = LeaderInfo{}
+	}
+	info.Value = string(val)
+	return info
+}
+
+// TTL returns the TTL in seconds of the candidate lease.
+func (e *Election) TTL() int64 { return e.opts.ttl }
+
+// Key returns the candidate key of the current campaign, or "" if none.
+func (e *Election) Key() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.key
+}
+
+// Observe emits the current leader value, if there is a leader, and then the
+// new value every time leadership changes, until ctx is done. Leaderless gaps
+// are not reported.
+func (e *Election) Observe(ctx context.Context) <-chan string {
+	ch := make(chan string)
+	go e.observe(ctx, ch)
+	return ch
+}
+
+// ObserveOnce returns the current leader value, waiting for a leader to be
+// elected if there is none.
+func (e *Election) ObserveOnce(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	val, ok := <-e.Observe(ctx)
+	if !ok {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", ErrNoLeader
+	}
+	return val, nil
+}
+
+func (e *Election) observe(ctx context.Context, ch chan<- string) {
+	defer close(ch)
+
+	resp, err := e.cli.Get(ctx, e.prefix, clientv3.WithFirstCreate()...)
+	if err != nil {
+		return
+	}
+	var lastKey, lastVal string
+	if len(resp.Kvs) > 0 {
+		lastKey, lastVal = string(resp.Kvs[0].Key), string(resp.Kvs[0].Value)
+		select {
+		case ch <- lastVal:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	watchChan := e.cli.Watch(ctx, e.prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	for watchResp := range watchChan {
+		if watchResp.Err() != nil {
+			return
+		}
+		resp, err := e.cli.Get(ctx, e.prefix, clientv3.WithFirstCreate()...)
+		if err != nil {
+			return
+		}
+		if len(resp.Kvs) == 0 {
+			continue
+		}
+		key, val := string(resp.Kvs[0].Key), string(resp.Kvs[0].Value)
+		if key == lastKey && val == lastVal {
+			continue
+		}
+		lastKey, lastVal = key, val
+		select {
+		case ch <- val:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Election) revoke(id clientv3.LeaseID) error {
+	// revoke even if the caller's context is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := e.cli.Revoke(ctx, id)
+	return err
+}
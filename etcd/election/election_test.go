@@ -0,0 +1,232 @@
+package election_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/election"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type ElectionTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestElectionTestSuite(t *testing.T) {
+	suite.Run(t, new(ElectionTestSuite))
+}
+
+func (s *ElectionTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *ElectionTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *ElectionTestSuite) TestCampaignAndFailover() {
+	prefix := "/test/election/failover"
+	e1, e2 := election.New(s.cli, prefix), election.New(s.cli, prefix)
+
+	_, err := e1.Leader(context.Background())
+	s.Equal(election.ErrNoLeader, err)
+
+	s.NoError(e1.Campaign(context.Background(), "node1"))
+	leader, err := e1.Leader(context.Background())
+	s.NoError(err)
+	s.Equal("node1", leader.Value)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	observeChan := e2.Observe(ctx)
+	s.Equal("node1", <-observeChan)
+
+	elected := make(chan error)
+	go func() {
+		elected <- e2.Campaign(context.Background(), "node2")
+	}()
+
+	select {
+	case <-elected:
+		s.Fail("node2 elected while node1 leads")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// simulate a crash of node1 by killing its lease
+	getRes, err := s.cli.Get(context.Background(), e1.Key())
+	s.NoError(err)
+	_, err = s.cli.Revoke(context.Background(), clientv3.LeaseID(getRes.Kvs[0].Lease))
+	s.NoError(err)
+
+	select {
+	case err := <-elected:
+		s.NoError(err)
+	case <-time.After(5 * time.Second):
+		s.Fail("node2 not elected after node1 lease revoked")
+	}
+	s.Equal("node2", <-observeChan)
+
+	leader, err = e2.Leader(context.Background())
+	s.NoError(err)
+	s.Equal("node2", leader.Value)
+
+	s.NoError(e2.Resign(context.Background()))
+	_, err = e2.Leader(context.Background())
+	s.Equal(election.ErrNoLeader, err)
+	s.Equal(election.ErrNotCampaigning, e2.Resign(context.Background()))
+}
+
+func (s *ElectionTestSuite) TestCampaignCancelled() {
+	prefix := "/test/election/cancel"
+	leader := election.New(s.cli, prefix)
+	s.NoError(leader.Campaign(context.Background(), "leader"))
+	defer leader.Resign(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(election.New(s.cli, prefix).Campaign(ctx, "follower"), context.DeadlineExceeded)
+
+	getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(1), getRes.Count)
+}
+
+func (s *ElectionTestSuite) TestObserveLateSubscriber() {
+	prefix := "/test/election/late"
+	leader := election.New(s.cli, prefix, election.WithTTL(5))
+	s.Equal(int64(5), leader.TTL())
+
+	// with no leader yet, ObserveOnce waits for one
+	elected := make(chan string, 1)
+	go func() {
+		val, err := election.New(s.cli, prefix).ObserveOnce(context.Background())
+		s.NoError(err)
+		elected <- val
+	}()
+	time.Sleep(100 * time.Millisecond)
+	s.NoError(leader.Campaign(context.Background(), "leader"))
+	defer leader.Resign(context.Background())
+	select {
+	case val := <-elected:
+		s.Equal("leader", val)
+	case <-time.After(5 * time.Second):
+		s.FailNow("ObserveOnce did not return")
+	}
+
+	// a subscriber arriving after the election gets the leader right away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	select {
+	case val := <-election.New(s.cli, prefix).Observe(ctx):
+		s.Equal("leader", val)
+	case <-time.After(time.Second):
+		s.FailNow("late subscriber not told the leader")
+	}
+	val, err := election.New(s.cli, prefix).ObserveOnce(context.Background())
+	s.NoError(err)
+	s.Equal("leader", val)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = election.New(s.cli, prefix+"-empty").ObserveOnce(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *ElectionTestSuite) TestResignAndAwaitSuccessor() {
+	prefix := "/test/election/handoff"
+	e1, e2 := election.New(s.cli, prefix), election.New(s.cli, prefix)
+	s.Require().NoError(e1.Campaign(context.Background(), "node1"))
+
+	elected := make(chan error, 1)
+	go func() {
+		elected <- e2.Campaign(context.Background(), "node2")
+	}()
+	s.Eventually(func() bool {
+		resp, err := s.cli.Get(context.Background(), prefix+"/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+		return err == nil && resp.Count == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.Require().NoError(e1.ResignAndAwaitSuccessor(context.Background(), 5*time.Second))
+	// node2 has won by the time node1 is done handing over
+	s.NotEmpty(e2.Key())
+	leader, err := e1.Leader(context.Background())
+	s.NoError(err)
+	s.Equal("node2", leader.Value)
+	s.NoError(<-elected)
+
+	// with nobody in line, the resignation stands but is reported
+	start := time.Now()
+	err = e2.ResignAndAwaitSuccessor(context.Background(), 300*time.Millisecond)
+	var successorErr *election.SuccessorError
+	s.Require().ErrorAs(err, &successorErr)
+	s.Equal(300*time.Millisecond, successorErr.Timeout)
+	s.GreaterOrEqual(time.Since(start), 300*time.Millisecond)
+	_, err = e2.Leader(context.Background())
+	s.ErrorIs(err, election.ErrNoLeader)
+
+	s.ErrorIs(e2.ResignAndAwaitSuccessor(context.Background(), time.Second), election.ErrNotCampaigning)
+}
+
+func (s *ElectionTestSuite) TestLeaderInfo() {
+	prefix := "/test/election/info"
+	defer s.cli.Delete(context.Background(), prefix+"/", clientv3.WithPrefix())
+	e1, e2 := election.New(s.cli, prefix), election.New(s.cli, prefix)
+	follower := election.New(s.cli, prefix)
+
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.Require().NoError(e1.CampaignInfo(context.Background(), election.LeaderInfo{Host: "host1", Version: "v1.0.0", Started: started}))
+	info, err := follower.Leader(context.Background())
+	s.Require().NoError(err)
+	s.Equal("host1", info.Host)
+	s.Equal("v1.0.0", info.Version)
+	s.True(started.Equal(info.Started))
+	s.Contains(info.Value, `"host":"host1"`)
+
+	elected := make(chan error, 1)
+	go func() {
+		elected <- e2.CampaignInfo(context.Background(), election.LeaderInfo{Host: "host2", Version: "v1.1.0"})
+	}()
+	s.Eventually(func() bool {
+		resp, err := s.cli.Get(context.Background(), prefix+"/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+		return err == nil && resp.Count == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// node1 crashes and the follower sees node2 take over
+	getRes, err := s.cli.Get(context.Background(), e1.Key())
+	s.Require().NoError(err)
+	_, err = s.cli.Revoke(context.Background(), clientv3.LeaseID(getRes.Kvs[0].Lease))
+	s.Require().NoError(err)
+	s.Require().NoError(<-elected)
+	defer e2.Resign(context.Background())
+
+	info, err = follower.Leader(context.Background())
+	s.Require().NoError(err)
+	s.Equal("host2", info.Host)
+	s.Equal("v1.1.0", info.Version)
+	s.WithinDuration(time.Now(), info.Started, 5*time.Second)
+
+	// a plain election leaves the value alone
+	info, err = election.New(s.cli, prefix, election.WithPlainValues()).Leader(context.Background())
+	s.Require().NoError(err)
+	s.Empty(info.Host)
+	s.Contains(info.Value, `"host":"host2"`)
+}
+
+func (s *ElectionTestSuite) TestLeaderPlainValue() {
+	prefix := "/test/election/plain"
+	e := election.New(s.cli, prefix)
+	s.Require().NoError(e.Campaign(context.Background(), "node1"))
+	defer e.Resign(context.Background())
+
+	info, err := election.New(s.cli, prefix).Leader(context.Background())
+	s.Require().NoError(err)
+	s.Equal(election.LeaderInfo{Value: "node1"}, info)
+}
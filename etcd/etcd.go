@@ -0,0 +1,184 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// DeleteIfVersions deletes all keys in one txn only if every key's current
+// ModRevision still matches the expected one. It is all-or-nothing: false is
+// returned and nothing is deleted if any key has been modified since it was read.
+func DeleteIfVersions(ctx context.Context, cli *clientv3.Client, versions map[string]int64) (bool, error) {
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cmps := make([]clientv3.Cmp, 0, len(keys))
+	ops := make([]clientv3.Op, 0, len(keys))
+	for _, key := range keys {
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", versions[key]))
+		ops = append(ops, clientv3.OpDelete(key))
+	}
+
+	resp, err := cli.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// ServeAsLeader blocks until ctx is cancelled, campaigning under prefix and
+// re-campaigning whenever leadership is lost. Each time it is elected, onStart
+// runs in its own goroutine with a context that is cancelled when leadership
+// ends; onStop is called only after that onStart has returned, so every
+// onStop pairs with exactly one prior onStart.
+func ServeAsLeader(ctx context.Context, cli *clientv3.Client, prefix, id string, ttl int, onStart func(ctx context.Context), onStop func()) error {
+	for {
+		if err := serveOnce(ctx, cli, prefix, id, ttl, onStart, onStop); err != nil && ctx.Err() == nil {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func serveOnce(ctx context.Context, cli *clientv3.Client, prefix, id string, ttl int, onStart func(ctx context.Context), onStop func()) error {
+	// the session is not bound to ctx so that Close can still revoke its lease
+	// after ctx is cancelled
+	session, err := concurrency.NewSession(cli, concurrency.WithTTL(ttl))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	election := concurrency.NewElection(session, prefix)
+	if err := election.Campaign(ctx, id); err != nil {
+		return err
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		onStart(leaderCtx)
+	}()
+
+	// leadership is lost when the lease dies or the leader key is removed
+	watchChan := cli.Watch(leaderCtx, election.Key(), clientv3.WithRev(election.Rev()+1), clientv3.WithFilterPut())
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-session.Done():
+			break loop
+		case watchResp, ok := <-watchChan:
+			if !ok || watchResp.Err() != nil || len(watchResp.Events) > 0 {
+				break loop
+			}
+		}
+	}
+
+	cancel()
+	<-started
+	onStop()
+	return nil
+}
+
+const defaultAuditRetention = 100
+
+type auditOptions struct {
+	retention int
+}
+
+type AuditOption func(*auditOptions)
+
+// WithAuditRetention keeps only the n most recent audit records of the key,
+// 100 by default.
+func WithAuditRetention(n int) AuditOption {
+	return func(o *auditOptions) {
+		o.retention = max(n, 1)
+	}
+}
+
+// AuditRecord is one change made by AuditedPut, stored as JSON.
+type AuditRecord struct {
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+	OldValue  string    `json:"oldValue"`
+	NewValue  string    `json:"newValue"`
+}
+
+// AuditPrefix returns the prefix under which AuditedPut records changes of key.
+func AuditPrefix(key string) string {
+	return key + "/audit/"
+}
+
+// AuditedPut puts val to key and, in the same txn, records who changed it from
+// what under <key>/audit/<rev> and drops the records beyond the retention. rev
+// is the revision the change was read at, zero-padded so that records sort in
+// the order the changes were made.
+//
+// The old value is read before the txn rather than returned by WithPrevKV,
+// since the record holding it is written by the txn itself. The txn is guarded
+// on the key's ModRevision and retried if the key changed meanwhile, which
+// also keeps the records read alongside it current.
+func AuditedPut(ctx context.Context, cli *clientv3.Client, key, val, actor string, opts ...AuditOption) error {
+	o := auditOptions{retention: defaultAuditRetention}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	prefix := AuditPrefix(key)
+	for {
+		getRes, err := cli.Txn(ctx).Then(
+			clientv3.OpGet(key),
+			clientv3.OpGet(prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend)),
+		).Commit()
+		if err != nil {
+			return err
+		}
+		var oldValue string
+		var modRev int64
+		if kvs := getRes.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+			oldValue, modRev = string(kvs[0].Value), kvs[0].ModRevision
+		}
+
+		record, err := json.Marshal(AuditRecord{Actor: actor, Timestamp: time.Now(), OldValue: oldValue, NewValue: val})
+		if err != nil {
+			return err
+		}
+		auditKey := fmt.Sprintf("%s%020d", prefix, getRes.Header.Revision)
+		ops := []clientv3.Op{clientv3.OpPut(key, val), clientv3.OpPut(auditKey, string(record))}
+		// the new record counts towards the retention too
+		if records := getRes.Responses[1].GetResponseRange().Kvs; len(records) >= o.retention {
+			oldest, keep := records[0].Key, records[len(records)+1-o.retention].Key
+			ops = append(ops, clientv3.OpDelete(string(oldest), clientv3.WithRange(string(keep))))
+		}
+
+		txnResp, err := cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", modRev)).
+			Then(ops...).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+}
@@ -2,10 +2,18 @@ package etcd_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/gojustforfun/learn-by-test/etcd"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	"github.com/gojustforfun/learn-by-test/etcd/op"
+	"github.com/gojustforfun/learn-by-test/etcd/txnresult"
 	"github.com/stretchr/testify/suite"
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
@@ -52,11 +60,11 @@ func (s *ETCDTestSuite) TestGetDeleteWithPrefixOption() {
 	prefix := "/test/etcd/"
 	keys := []string{prefix + "abc1", prefix + "abc2", prefix + "abc3"}
 	vals := []string{"ABC1", "ABC2", "ABC3"}
+	kvs := make(map[string][]byte)
 	for i, key := range keys {
-		putRes, err := s.cli.Put(context.Background(), key, vals[i])
-		s.NoError(err)
-		s.NotZero(putRes)
+		kvs[key] = []byte(vals[i])
 	}
+	s.NoError(kv.PutAll(context.Background(), s.cli, kvs))
 
 	getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
 	s.NoError(err)
@@ -257,8 +265,12 @@ func (s *ETCDTestSuite) TestOperations() {
 	getRespOp, err := s.cli.Do(context.Background(), getOp)
 	s.NoError(err)
 
-	s.Equal(putRespOp.Put().Header.Revision, getRespOp.Get().Header.Revision)
-	s.Equal(val, string(getRespOp.Get().Kvs[0].Value))
+	putResp, err := op.AsPut(putRespOp)
+	s.Require().NoError(err)
+	getResp, err := op.AsGet(getRespOp)
+	s.Require().NoError(err)
+	s.Equal(putResp.Header.Revision, getResp.Header.Revision)
+	s.Equal(val, string(getResp.Kvs[0].Value))
 
 	_, err = s.cli.Delete(context.Background(), key)
 	s.NoError(err)
@@ -298,6 +310,153 @@ func (s *ETCDTestSuite) TestTransactions() {
 		txn := clientv3.NewKV(s.cli).Txn(context.Background())
 		txnResp, err := txn.If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).Then(clientv3.OpPut(key, val, clientv3.WithLease(respLease.ID))).Else(clientv3.OpGet(key)).Commit()
 		s.NoError(err)
-		s.Equal(val, string(txnResp.Responses[0].GetResponseRange().Kvs[0].Value))
+		getResp, err := txnresult.RangeAt(txnResp, 0)
+		s.Require().NoError(err)
+		s.Equal(val, string(getResp.Kvs[0].Value))
+	})
+}
+
+func (s *ETCDTestSuite) TestDeleteIfVersions() {
+	prefix := "/test/delete/versions/"
+	keys := []string{prefix + "a", prefix + "b", prefix + "c"}
+	for _, key := range keys {
+		_, err := s.cli.Put(context.Background(), key, "val")
+		s.NoError(err)
+	}
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	versions := make(map[string]int64, len(getRes.Kvs))
+	for _, kv := range getRes.Kvs {
+		versions[string(kv.Key)] = kv.ModRevision
+	}
+
+	// modify one key after read
+	_, err = s.cli.Put(context.Background(), keys[1], "changed")
+	s.NoError(err)
+
+	deleted, err := etcd.DeleteIfVersions(context.Background(), s.cli, versions)
+	s.NoError(err)
+	s.False(deleted)
+
+	getRes, err = s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Equal(int64(len(keys)), getRes.Count)
+
+	s.Run("All versions match", func() {
+		for _, kv := range getRes.Kvs {
+			versions[string(kv.Key)] = kv.ModRevision
+		}
+		deleted, err := etcd.DeleteIfVersions(context.Background(), s.cli, versions)
+		s.NoError(err)
+		s.True(deleted)
+
+		getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+		s.NoError(err)
+		s.Zero(getRes.Count)
+	})
+}
+
+func (s *ETCDTestSuite) TestServeAsLeader() {
+	prefix := "/test/serve/leader"
+	var starts, stops int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- etcd.ServeAsLeader(ctx, s.cli, prefix, "node1", 5, func(ctx context.Context) {
+			atomic.AddInt32(&starts, 1)
+			<-ctx.Done()
+		}, func() {
+			atomic.AddInt32(&stops, 1)
+		})
+	}()
+
+	waitStarts := func(n int32) {
+		s.Eventually(func() bool { return atomic.LoadInt32(&starts) == n }, 10*time.Second, 50*time.Millisecond)
+	}
+
+	// flap by deleting the leader key
+	waitStarts(1)
+	_, err := s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+
+	// flap by revoking the leader lease
+	waitStarts(2)
+	getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getRes.Kvs, 1)
+	_, err = s.cli.Revoke(context.Background(), clientv3.LeaseID(getRes.Kvs[0].Lease))
+	s.NoError(err)
+
+	waitStarts(3)
+	s.Equal(int32(2), atomic.LoadInt32(&stops))
+
+	cancel()
+	s.Equal(context.Canceled, <-done)
+	s.Equal(atomic.LoadInt32(&starts), atomic.LoadInt32(&stops))
+
+	getRes, err = s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Zero(getRes.Count)
+}
+
+func (s *ETCDTestSuite) TestAuditedPut() {
+	key := "/test/audited/key"
+	defer s.cli.Delete(context.Background(), key, clientv3.WithPrefix())
+
+	changes := []struct{ actor, val string }{{"alice", "v1"}, {"bob", "v2"}, {"carol", "v3"}}
+	for _, change := range changes {
+		s.NoError(etcd.AuditedPut(context.Background(), s.cli, key, change.val, change.actor))
+	}
+
+	getRes, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal("v3", string(getRes.Kvs[0].Value))
+
+	getRes, err = s.cli.Get(context.Background(), etcd.AuditPrefix(key), clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getRes.Kvs, len(changes))
+
+	oldValue := ""
+	for i, kv := range getRes.Kvs {
+		var record etcd.AuditRecord
+		s.NoError(json.Unmarshal(kv.Value, &record))
+		s.Equal(changes[i].actor, record.Actor)
+		s.Equal(oldValue, record.OldValue)
+		s.Equal(changes[i].val, record.NewValue)
+		s.NotZero(record.Timestamp)
+		oldValue = record.NewValue
+	}
+
+	s.Run("Retention", func() {
+		s.NoError(etcd.AuditedPut(context.Background(), s.cli, key, "v4", "dave", etcd.WithAuditRetention(2)))
+
+		getRes, err := s.cli.Get(context.Background(), etcd.AuditPrefix(key), clientv3.WithPrefix())
+		s.NoError(err)
+		s.Len(getRes.Kvs, 2)
+
+		var record etcd.AuditRecord
+		s.NoError(json.Unmarshal(getRes.Kvs[1].Value, &record))
+		s.Equal("dave", record.Actor)
+		s.Equal("v3", record.OldValue)
+	})
+
+	s.Run("Retention with concurrent writers", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 2; j++ {
+					s.NoError(etcd.AuditedPut(context.Background(), s.cli, key, fmt.Sprintf("w%d-%d", i, j), "writer", etcd.WithAuditRetention(3)))
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		getRes, err := s.cli.Get(context.Background(), etcd.AuditPrefix(key), clientv3.WithPrefix())
+		s.NoError(err)
+		s.Len(getRes.Kvs, 3)
 	})
 }
@@ -0,0 +1,17 @@
+package etcdx
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// KV is the part of clientv3.KV this repo uses. *clientv3.Client and
+// clientv3.KV satisfy it, and so does the in-memory fake.KV.
+type KV interface {
+	Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error)
+	Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error)
+	Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error)
+	Txn(ctx context.Context) clientv3.Txn
+	Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error)
+}
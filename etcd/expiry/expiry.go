@@ -0,0 +1,139 @@
+package expiry
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Notifier reports the keys under a prefix that disappear because their lease
+// expired, as opposed to being deleted explicitly.
+//
+// etcd does not say why a key was deleted, so the Notifier remembers the lease
+// of every key and, on deletion, asks whether that lease still exists. A key
+// deleted while its lease is alive was deleted explicitly. A lease revoked by
+// anyone other than Revoke cannot be told apart from one that expired, and
+// its keys are reported as expired.
+type Notifier struct {
+	cli     *clientv3.Client
+	watch   *watch.Resumable
+	cancel  context.CancelFunc
+	done    chan struct{}
+	expired chan string
+
+	mu     sync.Mutex
+	leases map[string]clientv3.LeaseID
+	// keys counts the tracked keys of each lease, so that a lease is forgotten
+	// along with its last key
+	keys    map[clientv3.LeaseID]int
+	revoked map[clientv3.LeaseID]bool
+}
+
+// New lists the leased keys under prefix and reports their expiry until Close
+// is called.
+func New(ctx context.Context, cli *clientv3.Client, prefix string) (*Notifier, error) {
+	getResp, err := cli.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	n := &Notifier{
+		cli:     cli,
+		done:    make(chan struct{}),
+		expired: make(chan string, 16),
+		leases:  make(map[string]clientv3.LeaseID),
+		keys:    make(map[clientv3.LeaseID]int),
+		revoked: make(map[clientv3.LeaseID]bool),
+	}
+	for _, kv := range getResp.Kvs {
+		n.track(kv)
+	}
+	n.watch = watch.NewResumable(cli, prefix, getResp.Header.Revision)
+	watchCtx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	go n.run(watchCtx)
+	return n, nil
+}
+
+// Expired streams the keys whose lease expired. It is closed by Close.
+func (n *Notifier) Expired() <-chan string {
+	return n.expired
+}
+
+// Revoke revokes a lease and marks it, so that the deletion of its keys is not
+// reported as expiry. The mark is dropped once the last key of the lease under
+// the prefix is deleted.
+func (n *Notifier) Revoke(ctx context.Context, id clientv3.LeaseID) error {
+	n.mu.Lock()
+	n.revoked[id] = true
+	n.mu.Unlock()
+	_, err := n.cli.Revoke(ctx, id)
+	return err
+}
+
+// Close stops watching and closes the Expired stream.
+func (n *Notifier) Close() {
+	n.cancel()
+	n.watch.Close()
+	<-n.done
+}
+
+func (n *Notifier) run(ctx context.Context) {
+	defer close(n.done)
+	defer close(n.expired)
+
+	for ev := range n.watch.Events() {
+		if ev.Type == mvccpb.PUT {
+			n.track(ev.Kv)
+			continue
+		}
+
+		key := string(ev.Kv.Key)
+		n.mu.Lock()
+		id, ok := n.leases[key]
+		revoked := n.revoked[id]
+		if ok {
+			n.untrack(key, id)
+		}
+		n.mu.Unlock()
+		if !ok || revoked {
+			continue
+		}
+		// the lease is gone along with the key only if it ran out; a failed
+		// lookup is taken as the key having been deleted explicitly
+		ttlResp, err := n.cli.TimeToLive(ctx, id)
+		if err != nil || ttlResp.TTL != -1 {
+			continue
+		}
+		select {
+		case n.expired <- key:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (n *Notifier) track(kv *mvccpb.KeyValue) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	key := string(kv.Key)
+	if id, ok := n.leases[key]; ok {
+		n.untrack(key, id)
+	}
+	if kv.Lease == 0 {
+		return
+	}
+	n.leases[key] = clientv3.LeaseID(kv.Lease)
+	n.keys[clientv3.LeaseID(kv.Lease)]++
+}
+
+// untrack forgets that key has lease id, and the lease too if that was its
+// last key. n.mu must be held.
+func (n *Notifier) untrack(key string, id clientv3.LeaseID) {
+	delete(n.leases, key)
+	if n.keys[id]--; n.keys[id] <= 0 {
+		delete(n.keys, id)
+		delete(n.revoked, id)
+	}
+}
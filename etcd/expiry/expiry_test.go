@@ -0,0 +1,87 @@
+package expiry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/expiry"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var endpoints = []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"}
+
+type ExpiryTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestExpiryTestSuite(t *testing.T) {
+	suite.Run(t, new(ExpiryTestSuite))
+}
+
+func (s *ExpiryTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.NoError(err)
+}
+
+func (s *ExpiryTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *ExpiryTestSuite) TestExpired() {
+	prefix := "/test/expiry/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	// a key leased before the notifier started is tracked from the listing
+	early, err := s.cli.Grant(context.Background(), 1)
+	s.Require().NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"early", "1", clientv3.WithLease(early.ID))
+	s.NoError(err)
+
+	n, err := expiry.New(context.Background(), s.cli, prefix)
+	s.Require().NoError(err)
+	defer n.Close()
+
+	short, err := s.cli.Grant(context.Background(), 2)
+	s.Require().NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"short", "1", clientv3.WithLease(short.ID))
+	s.NoError(err)
+
+	// neither explicit deletes nor revocations through the notifier count
+	live, err := s.cli.Grant(context.Background(), 60)
+	s.Require().NoError(err)
+	defer s.cli.Revoke(context.Background(), live.ID)
+	_, err = s.cli.Put(context.Background(), prefix+"deleted", "1", clientv3.WithLease(live.ID))
+	s.NoError(err)
+	_, err = s.cli.Delete(context.Background(), prefix+"deleted")
+	s.NoError(err)
+	revoked, err := s.cli.Grant(context.Background(), 60)
+	s.Require().NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"revoked", "1", clientv3.WithLease(revoked.ID))
+	s.NoError(err)
+	s.NoError(n.Revoke(context.Background(), revoked.ID))
+	_, err = s.cli.Put(context.Background(), prefix+"plain", "1")
+	s.NoError(err)
+	_, err = s.cli.Delete(context.Background(), prefix+"plain")
+	s.NoError(err)
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case key := <-n.Expired():
+			got = append(got, key)
+		case <-time.After(10 * time.Second):
+			s.FailNow("no expiry", "got %v", got)
+		}
+	}
+	s.ElementsMatch([]string{prefix + "early", prefix + "short"}, got)
+
+	select {
+	case key := <-n.Expired():
+		s.Fail("unexpected expiry", key)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,18 @@
+package fake
+
+import (
+	"context"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// NewClient returns a client backed by a fresh fake KV, Lease and Watcher,
+// for code that takes a *clientv3.Client.
+func NewClient(clock *Clock) *clientv3.Client {
+	kv := NewKV()
+	cli := clientv3.NewCtxClient(context.Background())
+	cli.KV = kv
+	cli.Lease = NewLease(kv, clock)
+	cli.Watcher = NewWatcher(kv)
+	return cli
+}
@@ -0,0 +1,10 @@
+package fake
+
+import "github.com/gojustforfun/learn-by-test/etcd/clock"
+
+// Clock is the time leases expire by. It only moves when Advance is called.
+type Clock = clock.Fake
+
+func NewClock() *Clock {
+	return clock.NewFake()
+}
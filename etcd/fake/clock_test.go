@@ -0,0 +1,29 @@
+package fake_test
+
+import "time"
+
+func (s *FakeTestSuite) TestClockAfter() {
+	start := s.clock.Now()
+	short, long := s.clock.After(time.Second), s.clock.After(3*time.Second)
+	s.clock.BlockUntil(2)
+
+	s.clock.Advance(999 * time.Millisecond)
+	select {
+	case <-short:
+		s.FailNow("fired early")
+	default:
+	}
+
+	s.clock.Advance(time.Millisecond)
+	s.Equal(start.Add(time.Second), <-short)
+	select {
+	case <-long:
+		s.FailNow("fired early")
+	default:
+	}
+
+	// a channel fires with the time it was due at
+	s.clock.Advance(5 * time.Second)
+	s.Equal(start.Add(3*time.Second), <-long)
+	s.Equal(s.clock.Now(), <-s.clock.After(0))
+}
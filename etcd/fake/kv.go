@@ -0,0 +1,126 @@
+package fake
+
+import (
+	"context"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+// KV is an in-memory clientv3.KV for tests that should not need a cluster.
+// It keeps revisions, versions and history like etcd does, and serves
+// ranges, txns and compares with etcd's semantics.
+type KV struct {
+	clientv3.KV
+	s *store
+}
+
+func NewKV() *KV {
+	s := newStore()
+	return &KV{KV: clientv3.NewKVFromKVClient(&kvClient{s: s}, nil), s: s}
+}
+
+// Rev returns the current revision.
+func (kv *KV) Rev() int64 {
+	kv.s.mu.Lock()
+	defer kv.s.mu.Unlock()
+	return kv.s.rev
+}
+
+// kvClient serves the KV RPCs from the store, so that clientv3 does the
+// translation of ops and options into requests.
+type kvClient struct {
+	s *store
+}
+
+func (c *kvClient) Range(ctx context.Context, r *pb.RangeRequest, _ ...grpc.CallOption) (*pb.RangeResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	resp, err := c.s.begin().rangeOp(r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header = c.s.header()
+	return resp, nil
+}
+
+func (c *kvClient) Put(ctx context.Context, r *pb.PutRequest, _ ...grpc.CallOption) (*pb.PutResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	t := c.s.begin()
+	resp, err := t.putOp(r)
+	if err != nil {
+		return nil, err
+	}
+	t.end()
+	resp.Header = c.s.header()
+	return resp, nil
+}
+
+func (c *kvClient) DeleteRange(ctx context.Context, r *pb.DeleteRangeRequest, _ ...grpc.CallOption) (*pb.DeleteRangeResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	t := c.s.begin()
+	resp, err := t.deleteOp(r)
+	if err != nil {
+		return nil, err
+	}
+	t.end()
+	resp.Header = c.s.header()
+	return resp, nil
+}
+
+func (c *kvClient) Txn(ctx context.Context, r *pb.TxnRequest, _ ...grpc.CallOption) (*pb.TxnResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	// like etcd, fail the whole txn rather than apply it partially
+	saved := c.s.snapshot()
+	t := c.s.begin()
+	resp, err := t.txnOp(r)
+	if err != nil {
+		c.s.keys = saved
+		return nil, err
+	}
+	t.end()
+	resp.Header = c.s.header()
+	setHeaders(resp, resp.Header)
+	return resp, nil
+}
+
+func (c *kvClient) Compact(ctx context.Context, r *pb.CompactionRequest, _ ...grpc.CallOption) (*pb.CompactionResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	switch {
+	case r.Revision > c.s.rev:
+		return nil, rpctypes.ErrGRPCFutureRev
+	case r.Revision <= c.s.compactRev:
+		return nil, rpctypes.ErrGRPCCompacted
+	}
+	c.s.compactRev = r.Revision
+	return &pb.CompactionResponse{Header: c.s.header()}, nil
+}
+
+// setHeaders gives the responses nested in a txn the txn's header, as etcd
+// does.
+func setHeaders(resp *pb.TxnResponse, header *pb.ResponseHeader) {
+	for _, r := range resp.Responses {
+		switch r := r.Response.(type) {
+		case *pb.ResponseOp_ResponseRange:
+			r.ResponseRange.Header = header
+		case *pb.ResponseOp_ResponsePut:
+			r.ResponsePut.Header = header
+		case *pb.ResponseOp_ResponseDeleteRange:
+			r.ResponseDeleteRange.Header = header
+		case *pb.ResponseOp_ResponseTxn:
+			r.ResponseTxn.Header = header
+			setHeaders(r.ResponseTxn, header)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package fake_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type FakeTestSuite struct {
+	suite.Suite
+	kv    *fake.KV
+	clock *fake.Clock
+	cli   *clientv3.Client
+}
+
+func TestFakeTestSuite(t *testing.T) {
+	suite.Run(t, new(FakeTestSuite))
+}
+
+func (s *FakeTestSuite) SetupTest() {
+	s.kv = fake.NewKV()
+	s.clock = fake.NewClock()
+	s.cli = fake.NewClient(s.clock)
+}
+
+func (s *FakeTestSuite) TearDownTest() {
+	s.cli.Close()
+}
+
+func (s *FakeTestSuite) TestRevisions() {
+	ctx := context.Background()
+	s.Equal(int64(1), s.kv.Rev())
+
+	putResp, err := s.kv.Put(ctx, "a", "1")
+	s.NoError(err)
+	s.Equal(int64(2), putResp.Header.Revision)
+	_, err = s.kv.Put(ctx, "a", "2")
+	s.NoError(err)
+
+	getResp, err := s.kv.Get(ctx, "a")
+	s.NoError(err)
+	s.Equal(int64(3), getResp.Header.Revision)
+	s.Equal("2", string(getResp.Kvs[0].Value))
+	s.Equal(int64(2), getResp.Kvs[0].CreateRevision)
+	s.Equal(int64(3), getResp.Kvs[0].ModRevision)
+	s.Equal(int64(2), getResp.Kvs[0].Version)
+
+	delResp, err := s.kv.Delete(ctx, "a")
+	s.NoError(err)
+	s.Equal(int64(1), delResp.Deleted)
+	s.Equal(int64(4), delResp.Header.Revision)
+
+	// deleting nothing does not bump the revision
+	delResp, err = s.kv.Delete(ctx, "a")
+	s.NoError(err)
+	s.Zero(delResp.Deleted)
+	s.Equal(int64(4), delResp.Header.Revision)
+
+	// a recreated key starts a new generation
+	_, err = s.kv.Put(ctx, "a", "3")
+	s.NoError(err)
+	getResp, err = s.kv.Get(ctx, "a")
+	s.NoError(err)
+	s.Equal(int64(5), getResp.Kvs[0].CreateRevision)
+	s.Equal(int64(1), getResp.Kvs[0].Version)
+}
+
+func (s *FakeTestSuite) TestPrefixAndPrevKV() {
+	ctx := context.Background()
+	for _, key := range []string{"/p/b", "/p/a", "/p/c", "/q/a"} {
+		_, err := s.kv.Put(ctx, key, key)
+		s.NoError(err)
+	}
+
+	getResp, err := s.kv.Get(ctx, "/p/", clientv3.WithPrefix())
+	s.NoError(err)
+	s.Equal(int64(3), getResp.Count)
+	s.Len(getResp.Kvs, 3)
+	for i, key := range []string{"/p/a", "/p/b", "/p/c"} {
+		s.Equal(key, string(getResp.Kvs[i].Key))
+	}
+
+	getResp, err = s.kv.Get(ctx, "/p/", clientv3.WithPrefix(), clientv3.WithLimit(2), clientv3.WithKeysOnly())
+	s.NoError(err)
+	s.Equal(int64(3), getResp.Count)
+	s.True(getResp.More)
+	s.Len(getResp.Kvs, 2)
+	s.Empty(getResp.Kvs[0].Value)
+
+	getResp, err = s.kv.Get(ctx, "/p/", clientv3.WithFirstCreate()...)
+	s.NoError(err)
+	s.Equal("/p/b", string(getResp.Kvs[0].Key))
+	getResp, err = s.kv.Get(ctx, "/p/", clientv3.WithLastKey()...)
+	s.NoError(err)
+	s.Equal("/p/c", string(getResp.Kvs[0].Key))
+
+	putResp, err := s.kv.Put(ctx, "/p/a", "new", clientv3.WithPrevKV())
+	s.NoError(err)
+	s.Equal("/p/a", string(putResp.PrevKv.Value))
+
+	delResp, err := s.kv.Delete(ctx, "/p/", clientv3.WithPrefix(), clientv3.WithPrevKV())
+	s.NoError(err)
+	s.Equal(int64(3), delResp.Deleted)
+	s.Len(delResp.PrevKvs, 3)
+	s.Equal("new", string(delResp.PrevKvs[0].Value))
+
+	getResp, err = s.kv.Get(ctx, "", clientv3.WithFromKey(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(1), getResp.Count)
+	s.Empty(getResp.Kvs)
+}
+
+func (s *FakeTestSuite) TestTxnCAS() {
+	ctx := context.Background()
+	putIfAbsent := func(val string) bool {
+		resp, err := s.kv.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision("k"), "=", 0)).
+			Then(clientv3.OpPut("k", val)).
+			Commit()
+		s.NoError(err)
+		return resp.Succeeded
+	}
+	s.True(putIfAbsent("1"))
+	s.False(putIfAbsent("2"))
+
+	getResp, err := s.kv.Get(ctx, "k")
+	s.NoError(err)
+	modRev := getResp.Kvs[0].ModRevision
+
+	// a stale ModRevision fails and runs the else branch
+	_, err = s.kv.Put(ctx, "k", "3")
+	s.NoError(err)
+	txnResp, err := s.kv.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision("k"), "=", modRev)).
+		Then(clientv3.OpPut("k", "4")).
+		Else(clientv3.OpGet("k")).
+		Commit()
+	s.NoError(err)
+	s.False(txnResp.Succeeded)
+	s.Equal("3", string(txnResp.Responses[0].GetResponseRange().Kvs[0].Value))
+
+	// reads in a txn see its writes, and all of them share one revision
+	rev := s.kv.Rev()
+	txnResp, err = s.kv.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value("k"), "=", "3"), clientv3.Compare(clientv3.Version("k"), ">", 1)).
+		Then(clientv3.OpPut("k", "5"), clientv3.OpPut("l", "1"), clientv3.OpGet("k")).
+		Commit()
+	s.NoError(err)
+	s.True(txnResp.Succeeded)
+	s.Equal(rev+1, txnResp.Header.Revision)
+	s.Equal("5", string(txnResp.Responses[2].GetResponseRange().Kvs[0].Value))
+
+	// a value compare against a missing key never holds, even for ""
+	txnResp, err = s.kv.Txn(ctx).If(clientv3.Compare(clientv3.Value("missing"), "=", "")).Commit()
+	s.NoError(err)
+	s.False(txnResp.Succeeded)
+	txnResp, err = s.kv.Txn(ctx).If(clientv3.Compare(clientv3.Version("missing"), "=", 0)).Commit()
+	s.NoError(err)
+	s.True(txnResp.Succeeded)
+
+	// a read-only txn does not bump the revision
+	s.Equal(rev+1, s.kv.Rev())
+}
+
+func (s *FakeTestSuite) TestDo() {
+	ctx := context.Background()
+	opResp, err := s.kv.Do(ctx, clientv3.OpPut("a", "1"))
+	s.NoError(err)
+	s.NotNil(opResp.Put())
+
+	opResp, err = s.kv.Do(ctx, clientv3.OpGet("a"))
+	s.NoError(err)
+	s.Equal("1", string(opResp.Get().Kvs[0].Value))
+
+	opResp, err = s.kv.Do(ctx, clientv3.OpTxn(nil, []clientv3.Op{clientv3.OpDelete("a")}, nil))
+	s.NoError(err)
+	s.Equal(int64(1), opResp.Txn().Responses[0].GetResponseDeleteRange().Deleted)
+}
+
+func (s *FakeTestSuite) TestHistory() {
+	ctx := context.Background()
+	putResp, err := s.kv.Put(ctx, "a", "1")
+	s.NoError(err)
+	_, err = s.kv.Put(ctx, "a", "2")
+	s.NoError(err)
+	_, err = s.kv.Delete(ctx, "a")
+	s.NoError(err)
+
+	getResp, err := s.kv.Get(ctx, "a", clientv3.WithRev(putResp.Header.Revision))
+	s.NoError(err)
+	s.Equal("1", string(getResp.Kvs[0].Value))
+
+	_, err = s.kv.Get(ctx, "a", clientv3.WithRev(s.kv.Rev()+1))
+	s.ErrorIs(err, rpctypes.ErrFutureRev)
+
+	_, err = s.kv.Compact(ctx, s.kv.Rev())
+	s.NoError(err)
+	_, err = s.kv.Get(ctx, "a", clientv3.WithRev(putResp.Header.Revision))
+	s.ErrorIs(err, rpctypes.ErrCompacted)
+}
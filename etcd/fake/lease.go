@@ -0,0 +1,162 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+// Lease is an in-memory clientv3.Lease over the keys of a fake KV. Leases
+// expire by clock, so a lease outlives any real time but expires once the
+// clock is advanced past its TTL since it was granted or last kept alive.
+type Lease struct {
+	clientv3.Lease
+}
+
+func NewLease(kv *KV, clock *Clock) *Lease {
+	c := &leaseClient{s: kv.s, clock: clock}
+	clock.OnAdvance(c.expire)
+	return &Lease{Lease: clientv3.NewLeaseFromLeaseClient(c, clientv3.NewCtxClient(context.Background()), 5*time.Second)}
+}
+
+type leaseClient struct {
+	s     *store
+	clock *Clock
+}
+
+func (c *leaseClient) LeaseGrant(ctx context.Context, r *pb.LeaseGrantRequest, _ ...grpc.CallOption) (*pb.LeaseGrantResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	id := r.ID
+	if id == 0 {
+		c.s.lastLeaseID++
+		id = c.s.lastLeaseID
+	}
+	if _, ok := c.s.leases[id]; ok {
+		return nil, rpctypes.ErrGRPCLeaseExist
+	}
+	c.s.leases[id] = &lease{
+		id:     id,
+		ttl:    r.TTL,
+		expiry: c.clock.Now().Add(time.Duration(r.TTL) * time.Second),
+		keys:   make(map[string]struct{}),
+	}
+	return &pb.LeaseGrantResponse{Header: c.s.header(), ID: id, TTL: r.TTL}, nil
+}
+
+func (c *leaseClient) LeaseRevoke(ctx context.Context, r *pb.LeaseRevokeRequest, _ ...grpc.CallOption) (*pb.LeaseRevokeResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if _, ok := c.s.leases[r.ID]; !ok {
+		return nil, rpctypes.ErrGRPCLeaseNotFound
+	}
+	c.s.revoke(r.ID)
+	return &pb.LeaseRevokeResponse{Header: c.s.header()}, nil
+}
+
+func (c *leaseClient) LeaseKeepAlive(ctx context.Context, _ ...grpc.CallOption) (pb.Lease_LeaseKeepAliveClient, error) {
+	return &keepAliveStream{ctx: ctx, c: c, resps: newQueue[*pb.LeaseKeepAliveResponse]()}, nil
+}
+
+func (c *leaseClient) LeaseTimeToLive(ctx context.Context, r *pb.LeaseTimeToLiveRequest, _ ...grpc.CallOption) (*pb.LeaseTimeToLiveResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	l, ok := c.s.leases[r.ID]
+	if !ok {
+		// etcd answers this rather than an error
+		return &pb.LeaseTimeToLiveResponse{Header: c.s.header(), ID: r.ID, TTL: -1}, nil
+	}
+	resp := &pb.LeaseTimeToLiveResponse{
+		Header:     c.s.header(),
+		ID:         l.id,
+		TTL:        int64(l.expiry.Sub(c.clock.Now()).Seconds()),
+		GrantedTTL: l.ttl,
+	}
+	if r.Keys {
+		for key := range l.keys {
+			resp.Keys = append(resp.Keys, []byte(key))
+		}
+		sort.Slice(resp.Keys, func(i, j int) bool { return string(resp.Keys[i]) < string(resp.Keys[j]) })
+	}
+	return resp, nil
+}
+
+func (c *leaseClient) LeaseLeases(ctx context.Context, r *pb.LeaseLeasesRequest, _ ...grpc.CallOption) (*pb.LeaseLeasesResponse, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	resp := &pb.LeaseLeasesResponse{Header: c.s.header()}
+	for id := range c.s.leases {
+		resp.Leases = append(resp.Leases, &pb.LeaseStatus{ID: id})
+	}
+	sort.Slice(resp.Leases, func(i, j int) bool { return resp.Leases[i].ID < resp.Leases[j].ID })
+	return resp, nil
+}
+
+func (c *leaseClient) keepAlive(id int64) *pb.LeaseKeepAliveResponse {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	resp := &pb.LeaseKeepAliveResponse{Header: c.s.header(), ID: id}
+	if l, ok := c.s.leases[id]; ok {
+		l.expiry = c.clock.Now().Add(time.Duration(l.ttl) * time.Second)
+		resp.TTL = l.ttl
+	}
+	return resp
+}
+
+func (c *leaseClient) expire(now time.Time) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	for id, l := range c.s.leases {
+		if !l.expiry.After(now) {
+			c.s.revoke(id)
+		}
+	}
+}
+
+// revoke drops lease id and deletes its keys in one revision.
+func (s *store) revoke(id int64) {
+	l := s.leases[id]
+	delete(s.leases, id)
+
+	keys := make([]string, 0, len(l.keys))
+	for key := range l.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	t := s.begin()
+	for _, key := range keys {
+		t.deleteOp(&pb.DeleteRangeRequest{Key: []byte(key)})
+	}
+	t.end()
+}
+
+type keepAliveStream struct {
+	grpc.ClientStream
+	ctx   context.Context
+	c     *leaseClient
+	resps *queue[*pb.LeaseKeepAliveResponse]
+}
+
+func (s *keepAliveStream) Send(r *pb.LeaseKeepAliveRequest) error {
+	s.resps.push(s.c.keepAlive(r.ID))
+	return nil
+}
+
+func (s *keepAliveStream) Recv() (*pb.LeaseKeepAliveResponse, error) {
+	return s.resps.pop(s.ctx)
+}
+
+func (s *keepAliveStream) Context() context.Context { return s.ctx }
+
+func (s *keepAliveStream) CloseSend() error { return nil }
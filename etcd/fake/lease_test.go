@@ -0,0 +1,100 @@
+package fake_test
+
+import (
+	"context"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *FakeTestSuite) TestLeaseExpiry() {
+	ctx := context.Background()
+	grantResp, err := s.cli.Grant(ctx, 5)
+	s.NoError(err)
+	_, err = s.cli.Put(ctx, "/l/a", "1", clientv3.WithLease(grantResp.ID))
+	s.NoError(err)
+	_, err = s.cli.Put(ctx, "/l/b", "1", clientv3.WithLease(grantResp.ID))
+	s.NoError(err)
+	watchChan := s.cli.Watch(ctx, "/l/", clientv3.WithPrefix())
+
+	ttlResp, err := s.cli.TimeToLive(ctx, grantResp.ID, clientv3.WithAttachedKeys())
+	s.NoError(err)
+	s.Equal(int64(5), ttlResp.GrantedTTL)
+	s.Equal(int64(5), ttlResp.TTL)
+	s.Equal([][]byte{[]byte("/l/a"), []byte("/l/b")}, ttlResp.Keys)
+
+	s.clock.Advance(3 * time.Second)
+	ttlResp, err = s.cli.TimeToLive(ctx, grantResp.ID)
+	s.NoError(err)
+	s.Equal(int64(2), ttlResp.TTL)
+
+	s.clock.Advance(3 * time.Second)
+	getResp, err := s.cli.Get(ctx, "/l/", clientv3.WithPrefix())
+	s.NoError(err)
+	s.Zero(getResp.Count)
+
+	// both keys are deleted in one revision
+	select {
+	case watchResp := <-watchChan:
+		s.Len(watchResp.Events, 2)
+		for _, ev := range watchResp.Events {
+			s.Equal(mvccpb.DELETE, ev.Type)
+			s.Equal(getResp.Header.Revision, ev.Kv.ModRevision)
+		}
+	case <-time.After(time.Second):
+		s.FailNow("no delete event")
+	}
+
+	ttlResp, err = s.cli.TimeToLive(ctx, grantResp.ID)
+	s.NoError(err)
+	s.Equal(int64(-1), ttlResp.TTL)
+	_, err = s.cli.Put(ctx, "/l/a", "1", clientv3.WithLease(grantResp.ID))
+	s.ErrorIs(err, rpctypes.ErrLeaseNotFound)
+}
+
+func (s *FakeTestSuite) TestLeaseKeepAlive() {
+	ctx := context.Background()
+	grantResp, err := s.cli.Grant(ctx, 5)
+	s.NoError(err)
+
+	s.clock.Advance(3 * time.Second)
+	kaResp, err := s.cli.KeepAliveOnce(ctx, grantResp.ID)
+	s.NoError(err)
+	s.Equal(int64(5), kaResp.TTL)
+
+	s.clock.Advance(3 * time.Second)
+	ttlResp, err := s.cli.TimeToLive(ctx, grantResp.ID)
+	s.NoError(err)
+	s.Equal(int64(2), ttlResp.TTL)
+
+	keepChan, err := s.cli.KeepAlive(ctx, grantResp.ID)
+	s.NoError(err)
+	<-keepChan
+
+	leasesResp, err := s.cli.Leases(ctx)
+	s.NoError(err)
+	s.Len(leasesResp.Leases, 1)
+
+	// the keep-alive channel closes once the lease is gone
+	_, err = s.cli.Revoke(ctx, grantResp.ID)
+	s.NoError(err)
+	s.Eventually(func() bool {
+		for {
+			select {
+			case _, ok := <-keepChan:
+				if !ok {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, 5*time.Second, 100*time.Millisecond)
+
+	_, err = s.cli.Revoke(ctx, grantResp.ID)
+	s.ErrorIs(err, rpctypes.ErrLeaseNotFound)
+	_, err = s.cli.KeepAliveOnce(ctx, grantResp.ID)
+	s.ErrorIs(err, rpctypes.ErrLeaseNotFound)
+}
@@ -0,0 +1,383 @@
+package fake
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// store is an in-memory MVCC key space. Every key keeps all its revisions;
+// a deletion is recorded as a revision with Version 0.
+type store struct {
+	mu         sync.Mutex
+	rev        int64
+	compactRev int64
+	keys       map[string][]*mvccpb.KeyValue
+	// events is the history of changes in revision order.
+	events []*mvccpb.Event
+	// changed is closed and replaced whenever a revision is committed.
+	changed chan struct{}
+
+	leases      map[int64]*lease
+	lastLeaseID int64
+}
+
+type lease struct {
+	id     int64
+	ttl    int64
+	expiry time.Time
+	keys   map[string]struct{}
+}
+
+func newStore() *store {
+	return &store{
+		rev:     1,
+		keys:    make(map[string][]*mvccpb.KeyValue),
+		changed: make(chan struct{}),
+		leases:  make(map[int64]*lease),
+	}
+}
+
+func (s *store) header() *pb.ResponseHeader {
+	return &pb.ResponseHeader{ClusterId: 1, MemberId: 1, Revision: s.rev, RaftTerm: 1}
+}
+
+// txn applies writes at rev, the revision after the current one. It is only
+// committed if it changed anything, like etcd does not bump the revision for
+// a no-op write.
+type txn struct {
+	s       *store
+	rev     int64
+	changed bool
+	events  []*mvccpb.Event
+}
+
+func (s *store) begin() *txn {
+	return &txn{s: s, rev: s.rev + 1}
+}
+
+func (t *txn) end() {
+	if !t.changed {
+		return
+	}
+	s := t.s
+	s.rev = t.rev
+	for _, ev := range t.events {
+		key := string(ev.Kv.Key)
+		if ev.PrevKv != nil {
+			if l, ok := s.leases[ev.PrevKv.Lease]; ok {
+				delete(l.keys, key)
+			}
+		}
+		if l, ok := s.leases[ev.Kv.Lease]; ok && ev.Type == mvccpb.PUT {
+			l.keys[key] = struct{}{}
+		}
+	}
+	s.events = append(s.events, t.events...)
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// at returns key as of rev, or nil if it did not exist then.
+func (s *store) at(key string, rev int64) *mvccpb.KeyValue {
+	revs := s.keys[key]
+	for i := len(revs) - 1; i >= 0; i-- {
+		if revs[i].ModRevision <= rev {
+			if revs[i].Version == 0 {
+				return nil
+			}
+			return revs[i]
+		}
+	}
+	return nil
+}
+
+// rangeKeys returns the keys in [key, end) as of rev, ordered by key. An empty
+// end means key alone, and "\x00" all keys from key on.
+func (s *store) rangeKeys(key, end []byte, rev int64) []*mvccpb.KeyValue {
+	var kvs []*mvccpb.KeyValue
+	if len(end) == 0 {
+		if kv := s.at(string(key), rev); kv != nil {
+			kvs = append(kvs, kv)
+		}
+		return kvs
+	}
+
+	for k := range s.keys {
+		if bytes.Compare([]byte(k), key) < 0 {
+			continue
+		}
+		if !bytes.Equal(end, []byte{0}) && bytes.Compare([]byte(k), end) >= 0 {
+			continue
+		}
+		if kv := s.at(k, rev); kv != nil {
+			kvs = append(kvs, kv)
+		}
+	}
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i].Key, kvs[j].Key) < 0 })
+	return kvs
+}
+
+func (t *txn) rangeOp(r *pb.RangeRequest) (*pb.RangeResponse, error) {
+	s := t.s
+	rev := r.Revision
+	switch {
+	case rev == 0:
+		// reads inside a txn see its own writes
+		rev = t.rev
+	case rev > s.rev:
+		return nil, rpctypes.ErrGRPCFutureRev
+	case rev < s.compactRev:
+		return nil, rpctypes.ErrGRPCCompacted
+	}
+
+	all := s.rangeKeys(r.Key, r.RangeEnd, rev)
+	// like etcd, count the range before the revision filters apply
+	resp := &pb.RangeResponse{Count: int64(len(all))}
+	var kvs []*mvccpb.KeyValue
+	for _, kv := range all {
+		if (r.MinModRevision != 0 && kv.ModRevision < r.MinModRevision) ||
+			(r.MaxModRevision != 0 && kv.ModRevision > r.MaxModRevision) ||
+			(r.MinCreateRevision != 0 && kv.CreateRevision < r.MinCreateRevision) ||
+			(r.MaxCreateRevision != 0 && kv.CreateRevision > r.MaxCreateRevision) {
+			continue
+		}
+		kvs = append(kvs, kv)
+	}
+
+	sortKVs(kvs, r.SortTarget, r.SortOrder)
+	if r.Limit > 0 && int64(len(kvs)) > r.Limit {
+		kvs, resp.More = kvs[:r.Limit], true
+	}
+	if r.CountOnly {
+		return resp, nil
+	}
+	for _, kv := range kvs {
+		kv := *kv
+		if r.KeysOnly {
+			kv.Value = nil
+		}
+		resp.Kvs = append(resp.Kvs, &kv)
+	}
+	return resp, nil
+}
+
+func sortKVs(kvs []*mvccpb.KeyValue, target pb.RangeRequest_SortTarget, order pb.RangeRequest_SortOrder) {
+	if order == pb.RangeRequest_NONE {
+		if target == pb.RangeRequest_KEY {
+			return
+		}
+		order = pb.RangeRequest_ASCEND
+	}
+	field := func(kv *mvccpb.KeyValue) int64 {
+		switch target {
+		case pb.RangeRequest_VERSION:
+			return kv.Version
+		case pb.RangeRequest_CREATE:
+			return kv.CreateRevision
+		case pb.RangeRequest_MOD:
+			return kv.ModRevision
+		}
+		return 0
+	}
+	less := func(i, j int) bool {
+		switch target {
+		case pb.RangeRequest_KEY:
+			return bytes.Compare(kvs[i].Key, kvs[j].Key) < 0
+		case pb.RangeRequest_VALUE:
+			return bytes.Compare(kvs[i].Value, kvs[j].Value) < 0
+		}
+		return field(kvs[i]) < field(kvs[j])
+	}
+	if order == pb.RangeRequest_DESCEND {
+		sort.SliceStable(kvs, func(i, j int) bool { return less(j, i) })
+		return
+	}
+	sort.SliceStable(kvs, less)
+}
+
+func (t *txn) putOp(r *pb.PutRequest) (*pb.PutResponse, error) {
+	s := t.s
+	prev := s.at(string(r.Key), t.rev)
+	if (r.IgnoreValue || r.IgnoreLease) && prev == nil {
+		return nil, rpctypes.ErrGRPCKeyNotFound
+	}
+	if _, ok := s.leases[r.Lease]; r.Lease != 0 && !ok {
+		return nil, rpctypes.ErrGRPCLeaseNotFound
+	}
+
+	kv := &mvccpb.KeyValue{
+		Key:            r.Key,
+		Value:          r.Value,
+		CreateRevision: t.rev,
+		ModRevision:    t.rev,
+		Version:        1,
+		Lease:          r.Lease,
+	}
+	if prev != nil {
+		kv.CreateRevision, kv.Version = prev.CreateRevision, prev.Version+1
+		if r.IgnoreValue {
+			kv.Value = prev.Value
+		}
+		if r.IgnoreLease {
+			kv.Lease = prev.Lease
+		}
+	}
+	t.write(kv)
+
+	resp := &pb.PutResponse{}
+	if r.PrevKv && prev != nil {
+		resp.PrevKv = prev
+	}
+	return resp, nil
+}
+
+func (t *txn) deleteOp(r *pb.DeleteRangeRequest) (*pb.DeleteRangeResponse, error) {
+	resp := &pb.DeleteRangeResponse{}
+	for _, prev := range t.s.rangeKeys(r.Key, r.RangeEnd, t.rev) {
+		t.write(&mvccpb.KeyValue{Key: prev.Key, ModRevision: t.rev})
+		resp.Deleted++
+		if r.PrevKv {
+			resp.PrevKvs = append(resp.PrevKvs, prev)
+		}
+	}
+	return resp, nil
+}
+
+// write records kv as the revision t.rev of its key, replacing an earlier
+// write of the same txn.
+func (t *txn) write(kv *mvccpb.KeyValue) {
+	key := string(kv.Key)
+	ev := &mvccpb.Event{Type: mvccpb.PUT, Kv: kv, PrevKv: t.s.at(key, t.s.rev)}
+	if kv.Version == 0 {
+		ev.Type = mvccpb.DELETE
+	}
+
+	revs := t.s.keys[key]
+	if n := len(revs); n > 0 && revs[n-1].ModRevision == t.rev {
+		revs = revs[:n-1]
+		for i := range t.events {
+			if string(t.events[i].Kv.Key) == key {
+				t.events = append(t.events[:i], t.events[i+1:]...)
+				break
+			}
+		}
+	}
+	t.s.keys[key] = append(revs, kv)
+	t.events = append(t.events, ev)
+	t.changed = true
+}
+
+func (t *txn) txnOp(r *pb.TxnRequest) (*pb.TxnResponse, error) {
+	resp := &pb.TxnResponse{Succeeded: true}
+	for _, c := range r.Compare {
+		if !t.compare(c) {
+			resp.Succeeded = false
+			break
+		}
+	}
+	ops := r.Success
+	if !resp.Succeeded {
+		ops = r.Failure
+	}
+
+	for _, op := range ops {
+		var respOp pb.ResponseOp
+		switch req := op.Request.(type) {
+		case *pb.RequestOp_RequestRange:
+			r, err := t.rangeOp(req.RequestRange)
+			if err != nil {
+				return nil, err
+			}
+			respOp.Response = &pb.ResponseOp_ResponseRange{ResponseRange: r}
+		case *pb.RequestOp_RequestPut:
+			r, err := t.putOp(req.RequestPut)
+			if err != nil {
+				return nil, err
+			}
+			respOp.Response = &pb.ResponseOp_ResponsePut{ResponsePut: r}
+		case *pb.RequestOp_RequestDeleteRange:
+			r, err := t.deleteOp(req.RequestDeleteRange)
+			if err != nil {
+				return nil, err
+			}
+			respOp.Response = &pb.ResponseOp_ResponseDeleteRange{ResponseDeleteRange: r}
+		case *pb.RequestOp_RequestTxn:
+			r, err := t.txnOp(req.RequestTxn)
+			if err != nil {
+				return nil, err
+			}
+			respOp.Response = &pb.ResponseOp_ResponseTxn{ResponseTxn: r}
+		}
+		resp.Responses = append(resp.Responses, &respOp)
+	}
+	return resp, nil
+}
+
+// compare holds if it holds for every key in its range. A missing key
+// compares as zero on every target but the value, which never matches.
+func (t *txn) compare(c *pb.Compare) bool {
+	kvs := t.s.rangeKeys(c.Key, c.RangeEnd, t.rev)
+	if len(kvs) == 0 {
+		if c.Target == pb.Compare_VALUE {
+			return false
+		}
+		kvs = []*mvccpb.KeyValue{{}}
+	}
+
+	for _, kv := range kvs {
+		var result int
+		switch c.Target {
+		case pb.Compare_VALUE:
+			result = bytes.Compare(kv.Value, c.GetValue())
+		case pb.Compare_VERSION:
+			result = compareInt64(kv.Version, c.GetVersion())
+		case pb.Compare_CREATE:
+			result = compareInt64(kv.CreateRevision, c.GetCreateRevision())
+		case pb.Compare_MOD:
+			result = compareInt64(kv.ModRevision, c.GetModRevision())
+		case pb.Compare_LEASE:
+			result = compareInt64(kv.Lease, c.GetLease())
+		}
+
+		var ok bool
+		switch c.Result {
+		case pb.Compare_EQUAL:
+			ok = result == 0
+		case pb.Compare_NOT_EQUAL:
+			ok = result != 0
+		case pb.Compare_GREATER:
+			ok = result > 0
+		case pb.Compare_LESS:
+			ok = result < 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// snapshot copies the revision lists so that a failed txn can be undone.
+func (s *store) snapshot() map[string][]*mvccpb.KeyValue {
+	keys := make(map[string][]*mvccpb.KeyValue, len(s.keys))
+	for key, revs := range s.keys {
+		keys[key] = revs
+	}
+	return keys
+}
@@ -0,0 +1,163 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc"
+)
+
+// Watcher is an in-memory clientv3.Watcher over the history of a fake KV.
+type Watcher struct {
+	clientv3.Watcher
+}
+
+func NewWatcher(kv *KV) *Watcher {
+	return &Watcher{Watcher: clientv3.NewWatchFromWatchClient(&watchClient{s: kv.s}, clientv3.NewCtxClient(context.Background()))}
+}
+
+type watchClient struct {
+	s *store
+}
+
+func (c *watchClient) Watch(ctx context.Context, _ ...grpc.CallOption) (pb.Watch_WatchClient, error) {
+	return &watchStream{
+		ctx:     ctx,
+		s:       c.s,
+		resps:   newQueue[*pb.WatchResponse](),
+		cancels: make(map[int64]context.CancelFunc),
+	}, nil
+}
+
+// watchStream serves the watches of one client stream, each from its own
+// goroutine that follows the store history.
+type watchStream struct {
+	grpc.ClientStream
+	ctx   context.Context
+	s     *store
+	resps *queue[*pb.WatchResponse]
+
+	mu      sync.Mutex
+	nextID  int64
+	cancels map[int64]context.CancelFunc
+}
+
+func (w *watchStream) Send(r *pb.WatchRequest) error {
+	switch r := r.RequestUnion.(type) {
+	case *pb.WatchRequest_CreateRequest:
+		w.create(r.CreateRequest)
+	case *pb.WatchRequest_CancelRequest:
+		w.mu.Lock()
+		cancel, ok := w.cancels[r.CancelRequest.WatchId]
+		delete(w.cancels, r.CancelRequest.WatchId)
+		w.mu.Unlock()
+		if ok {
+			cancel()
+			w.resps.push(&pb.WatchResponse{Header: w.header(), WatchId: r.CancelRequest.WatchId, Canceled: true})
+		}
+	case *pb.WatchRequest_ProgressRequest:
+		// a watch id of -1 makes the client broadcast it to all watches
+		w.resps.push(&pb.WatchResponse{Header: w.header(), WatchId: -1})
+	}
+	return nil
+}
+
+func (w *watchStream) Recv() (*pb.WatchResponse, error) {
+	return w.resps.pop(w.ctx)
+}
+
+func (w *watchStream) Context() context.Context { return w.ctx }
+
+func (w *watchStream) CloseSend() error { return nil }
+
+func (w *watchStream) header() *pb.ResponseHeader {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	return w.s.header()
+}
+
+func (w *watchStream) create(r *pb.WatchCreateRequest) {
+	w.mu.Lock()
+	id := w.nextID
+	w.nextID++
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.cancels[id] = cancel
+	w.mu.Unlock()
+
+	w.s.mu.Lock()
+	header, start, compactRev := w.s.header(), r.StartRevision, w.s.compactRev
+	if start == 0 {
+		start = w.s.rev + 1
+	}
+	w.s.mu.Unlock()
+
+	w.resps.push(&pb.WatchResponse{Header: header, WatchId: id, Created: true})
+	if start < compactRev {
+		cancel()
+		w.resps.push(&pb.WatchResponse{Header: header, WatchId: id, CompactRevision: compactRev, Canceled: true})
+		return
+	}
+	go w.serve(ctx, id, r, start)
+}
+
+// serve sends the events matching r from revision next on, as they are
+// committed.
+func (w *watchStream) serve(ctx context.Context, id int64, r *pb.WatchCreateRequest, next int64) {
+	for {
+		w.s.mu.Lock()
+		events := w.s.events
+		i := sort.Search(len(events), func(i int) bool { return events[i].Kv.ModRevision >= next })
+		var matched []*mvccpb.Event
+		for _, ev := range events[i:] {
+			if match(r, ev) {
+				ev := *ev
+				if !r.PrevKv {
+					ev.PrevKv = nil
+				}
+				matched = append(matched, &ev)
+			}
+		}
+		header, changed := w.s.header(), w.s.changed
+		next = w.s.rev + 1
+		w.s.mu.Unlock()
+
+		switch {
+		case len(matched) > 1 && r.Fragment:
+			// a fragment per event, the way etcd splits a response too large
+			// to send at once
+			for i, ev := range matched {
+				w.resps.push(&pb.WatchResponse{Header: header, WatchId: id, Events: []*mvccpb.Event{ev}, Fragment: i < len(matched)-1})
+			}
+		case len(matched) > 0:
+			w.resps.push(&pb.WatchResponse{Header: header, WatchId: id, Events: matched})
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func match(r *pb.WatchCreateRequest, ev *mvccpb.Event) bool {
+	for _, f := range r.Filters {
+		if (f == pb.WatchCreateRequest_NOPUT && ev.Type == mvccpb.PUT) ||
+			(f == pb.WatchCreateRequest_NODELETE && ev.Type == mvccpb.DELETE) {
+			return false
+		}
+	}
+
+	key := ev.Kv.Key
+	switch {
+	case len(r.RangeEnd) == 0:
+		return bytes.Equal(key, r.Key)
+	case bytes.Equal(r.RangeEnd, []byte{0}):
+		return bytes.Compare(key, r.Key) >= 0
+	}
+	return bytes.Compare(key, r.Key) >= 0 && bytes.Compare(key, r.RangeEnd) < 0
+}
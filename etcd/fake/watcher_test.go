@@ -0,0 +1,110 @@
+package fake_test
+
+import (
+	"context"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *FakeTestSuite) TestWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	putResp, err := s.cli.Put(ctx, "/w/a", "1")
+	s.NoError(err)
+	_, err = s.cli.Put(ctx, "/w/a", "2")
+	s.NoError(err)
+	_, err = s.cli.Put(ctx, "/x", "1")
+	s.NoError(err)
+	_, err = s.cli.Delete(ctx, "/w/a")
+	s.NoError(err)
+
+	// next returns the events one by one, however they were batched
+	pending := make(map[clientv3.WatchChan][]*clientv3.Event)
+	next := func(watchChan clientv3.WatchChan) *clientv3.Event {
+		if len(pending[watchChan]) == 0 {
+			select {
+			case watchResp := <-watchChan:
+				s.NoError(watchResp.Err())
+				pending[watchChan] = watchResp.Events
+			case <-time.After(time.Second):
+				s.FailNow("no event")
+			}
+		}
+		ev := pending[watchChan][0]
+		pending[watchChan] = pending[watchChan][1:]
+		return ev
+	}
+
+	// history is replayed in revision order from WithRev
+	watchChan := s.cli.Watch(ctx, "/w/", clientv3.WithPrefix(), clientv3.WithRev(putResp.Header.Revision), clientv3.WithPrevKV())
+	ev := next(watchChan)
+	s.Equal(mvccpb.PUT, ev.Type)
+	s.Equal("1", string(ev.Kv.Value))
+	s.Nil(ev.PrevKv)
+	ev = next(watchChan)
+	s.Equal("2", string(ev.Kv.Value))
+	s.Equal("1", string(ev.PrevKv.Value))
+	ev = next(watchChan)
+	s.Equal(mvccpb.DELETE, ev.Type)
+	s.Equal("2", string(ev.PrevKv.Value))
+
+	deletes := s.cli.Watch(ctx, "/w/b", clientv3.WithFilterPut())
+	_, err = s.cli.Put(ctx, "/w/b", "1")
+	s.NoError(err)
+	ev = next(watchChan)
+	s.Equal("/w/b", string(ev.Kv.Key))
+	_, err = s.cli.Delete(ctx, "/w/b")
+	s.NoError(err)
+	s.Equal(mvccpb.DELETE, next(deletes).Type)
+	s.Equal(mvccpb.DELETE, next(watchChan).Type)
+}
+
+func (s *FakeTestSuite) TestWatchCompacted() {
+	ctx := context.Background()
+	putResp, err := s.cli.Put(ctx, "a", "1")
+	s.NoError(err)
+	_, err = s.cli.Put(ctx, "a", "2")
+	s.NoError(err)
+	_, err = s.cli.Compact(ctx, putResp.Header.Revision+1)
+	s.NoError(err)
+
+	watchResp := <-s.cli.Watch(ctx, "a", clientv3.WithRev(putResp.Header.Revision))
+	s.ErrorIs(watchResp.Err(), rpctypes.ErrCompacted)
+	s.Equal(putResp.Header.Revision+1, watchResp.CompactRevision)
+}
+
+func (s *FakeTestSuite) TestWatchCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchChan := s.cli.Watch(ctx, "a")
+	cancel()
+
+	select {
+	case _, ok := <-watchChan:
+		s.False(ok)
+	case <-time.After(time.Second):
+		s.FailNow("watch not closed")
+	}
+}
+
+func (s *FakeTestSuite) TestWatchFragment() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the fragments the fake sends are joined back by the client
+	watchChan := s.cli.Watch(ctx, "/f/", clientv3.WithPrefix(), clientv3.WithFragment())
+	txnResp, err := s.cli.Txn(ctx).Then(clientv3.OpPut("/f/a", "1"), clientv3.OpPut("/f/b", "1"), clientv3.OpPut("/f/c", "1")).Commit()
+	s.NoError(err)
+
+	select {
+	case watchResp := <-watchChan:
+		s.NoError(watchResp.Err())
+		s.Len(watchResp.Events, 3)
+		s.Equal(txnResp.Header.Revision, watchResp.Header.Revision)
+	case <-time.After(time.Second):
+		s.FailNow("no event")
+	}
+}
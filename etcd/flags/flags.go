@@ -0,0 +1,257 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Prefix is where flags are stored, as /flags/<name>.
+const Prefix = "/flags/"
+
+// Flag is what is stored for a flag, as JSON unless WithCodec says otherwise.
+// Value holds the JSON of the value either way. Percentage, when set, rolls
+// the flag out to that share of users in Enabled.
+type Flag struct {
+	Value      json.RawMessage `json:"value,omitempty"`
+	Enabled    bool            `json:"enabled"`
+	Percentage *int            `json:"percentage,omitempty"`
+}
+
+// record is a Flag as stored by codecs other than JSON, with the value
+// decoded so that the codec writes it in its own format.
+type record struct {
+	Value      interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+	Enabled    bool        `json:"enabled" yaml:"enabled"`
+	Percentage *int        `json:"percentage,omitempty" yaml:"percentage,omitempty"`
+}
+
+type options struct {
+	codec codec.Codec
+}
+
+type Option func(*options)
+
+// WithCodec sets the codec flags are stored with, JSON by default. All Stores
+// sharing the flags must use the same one.
+func WithCodec(c codec.Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// Store keeps a local copy of all flags, loaded with a ranged Get and kept
+// current by watching from the revision right after it. Evaluations read
+// the copy and never go to etcd.
+type Store struct {
+	cli    *clientv3.Client
+	opts   options
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.RWMutex
+	flags map[string]Flag
+	rev   int64
+}
+
+// New loads the current flags and starts watching for changes until Close
+// is called.
+func New(ctx context.Context, cli *clientv3.Client, opts ...Option) (*Store, error) {
+	o := options{codec: codec.JSON}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := &Store{cli: cli, opts: o, done: make(chan struct{})}
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	watchCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.run(watchCtx)
+	return s, nil
+}
+
+// Close stops watching; evaluations keep returning the last known flags.
+func (s *Store) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Set writes the flag. The change reaches every Store through its watch,
+// this one included.
+func (s *Store) Set(ctx context.Context, name string, f Flag) error {
+	val, err := s.encode(f)
+	if err != nil {
+		return err
+	}
+	_, err = s.cli.Put(ctx, Prefix+name, string(val))
+	return err
+}
+
+// Bool returns the value of the flag, or def if it is missing or disabled.
+// A value that is not a bool is an error.
+func (s *Store) Bool(ctx context.Context, name string, def bool) (bool, error) {
+	v := def
+	err := s.value(name, &v)
+	return v, err
+}
+
+// String is Bool for string values.
+func (s *Store) String(ctx context.Context, name, def string) (string, error) {
+	v := def
+	err := s.value(name, &v)
+	return v, err
+}
+
+// Int is Bool for int values.
+func (s *Store) Int(ctx context.Context, name string, def int) (int, error) {
+	v := def
+	err := s.value(name, &v)
+	return v, err
+}
+
+// Enabled reports whether the flag is on for userID. Without a percentage an
+// enabled flag is on for everyone; with one, users are bucketed by a hash of
+// the flag name and their ID, so each user gets the same answer every time
+// and raising the percentage only adds users.
+func (s *Store) Enabled(ctx context.Context, name, userID string) bool {
+	s.mu.RLock()
+	f, ok := s.flags[name]
+	s.mu.RUnlock()
+	if !ok || !f.Enabled {
+		return false
+	}
+	if f.Percentage == nil {
+		return true
+	}
+	return bucket(name, userID) < *f.Percentage
+}
+
+func bucket(name, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return int(h.Sum32() % 100)
+}
+
+// value decodes the value of an enabled flag into out and leaves out alone
+// otherwise.
+func (s *Store) value(name string, out interface{}) error {
+	s.mu.RLock()
+	f, ok := s.flags[name]
+	s.mu.RUnlock()
+	if !ok || !f.Enabled || len(f.Value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(f.Value, out); err != nil {
+		return fmt.Errorf("flags: decode %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *Store) encode(f Flag) ([]byte, error) {
+	if s.opts.codec == codec.JSON {
+		return json.Marshal(f)
+	}
+	rec := record{Enabled: f.Enabled, Percentage: f.Percentage}
+	if len(f.Value) > 0 {
+		if err := json.Unmarshal(f.Value, &rec.Value); err != nil {
+			return nil, err
+		}
+	}
+	return s.opts.codec.Marshal(rec)
+}
+
+func (s *Store) decode(data []byte) (Flag, error) {
+	var f Flag
+	if s.opts.codec == codec.JSON {
+		err := json.Unmarshal(data, &f)
+		return f, err
+	}
+	var rec record
+	if err := s.opts.codec.Unmarshal(data, &rec); err != nil {
+		return f, err
+	}
+	f.Enabled, f.Percentage = rec.Enabled, rec.Percentage
+	if rec.Value != nil {
+		val, err := json.Marshal(rec.Value)
+		if err != nil {
+			return f, err
+		}
+		f.Value = val
+	}
+	return f, nil
+}
+
+func (s *Store) load(ctx context.Context) error {
+	resp, err := s.cli.Get(ctx, Prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	flags := make(map[string]Flag, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		f, err := s.decode(kv.Value)
+		if err != nil {
+			continue
+		}
+		flags[strings.TrimPrefix(string(kv.Key), Prefix)] = f
+	}
+
+	s.mu.Lock()
+	s.flags, s.rev = flags, resp.Header.Revision
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) run(ctx context.Context) {
+	defer close(s.done)
+
+	for ctx.Err() == nil {
+		s.mu.RLock()
+		rev := s.rev
+		s.mu.RUnlock()
+
+		for watchResp := range s.cli.Watch(ctx, Prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1)) {
+			if watchResp.Err() != nil {
+				if watchResp.Err() == rpctypes.ErrCompacted {
+					// history we need is gone, reload all flags
+					s.load(ctx)
+				}
+				break
+			}
+			s.mu.Lock()
+			for _, ev := range watchResp.Events {
+				name := strings.TrimPrefix(string(ev.Kv.Key), Prefix)
+				if ev.Type == mvccpb.DELETE {
+					delete(s.flags, name)
+					continue
+				}
+				f, err := s.decode(ev.Kv.Value)
+				if err != nil {
+					// a flag that does not decode counts as missing
+					delete(s.flags, name)
+					continue
+				}
+				s.flags[name] = f
+			}
+			s.rev = watchResp.Header.Revision
+			s.mu.Unlock()
+		}
+
+		// the watch closed, re-establish it from the last seen revision
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package flags_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/gojustforfun/learn-by-test/etcd/flags"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type FlagsTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestFlagsTestSuite(t *testing.T) {
+	suite.Run(t, new(FlagsTestSuite))
+}
+
+func (s *FlagsTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *FlagsTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *FlagsTestSuite) put(name string, f flags.Flag) {
+	val, err := json.Marshal(f)
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), flags.Prefix+name, string(val))
+	s.NoError(err)
+}
+
+func (s *FlagsTestSuite) TestDisabled() {
+	name := "test-disabled"
+	defer s.cli.Delete(context.Background(), flags.Prefix+name)
+	s.put(name, flags.Flag{Value: json.RawMessage(`"on"`), Enabled: false})
+
+	store, err := flags.New(context.Background(), s.cli)
+	s.NoError(err)
+	defer store.Close()
+
+	str, err := store.String(context.Background(), name, "def")
+	s.NoError(err)
+	s.Equal("def", str)
+	s.False(store.Enabled(context.Background(), name, "user"))
+
+	n, err := store.Int(context.Background(), "test-missing", 7)
+	s.NoError(err)
+	s.Equal(7, n)
+}
+
+func (s *FlagsTestSuite) TestPercentageRollout() {
+	name := "test-rollout"
+	defer s.cli.Delete(context.Background(), flags.Prefix+name)
+	half := 50
+	s.put(name, flags.Flag{Enabled: true, Percentage: &half})
+
+	store, err := flags.New(context.Background(), s.cli)
+	s.NoError(err)
+	defer store.Close()
+
+	var on int
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		enabled := store.Enabled(context.Background(), name, user)
+		s.Equal(enabled, store.Enabled(context.Background(), name, user))
+		if enabled {
+			on++
+		}
+	}
+	s.InDelta(500, on, 60)
+}
+
+func (s *FlagsTestSuite) TestLiveUpdate() {
+	name := "test-live"
+	defer s.cli.Delete(context.Background(), flags.Prefix+name)
+
+	store, err := flags.New(context.Background(), s.cli)
+	s.NoError(err)
+	defer store.Close()
+
+	v, err := store.Bool(context.Background(), name, false)
+	s.NoError(err)
+	s.False(v)
+
+	s.NoError(store.Set(context.Background(), name, flags.Flag{Value: json.RawMessage(`true`), Enabled: true}))
+	s.Eventually(func() bool {
+		v, err := store.Bool(context.Background(), name, false)
+		return err == nil && v
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err = s.cli.Delete(context.Background(), flags.Prefix+name)
+	s.NoError(err)
+	s.Eventually(func() bool {
+		return !store.Enabled(context.Background(), name, "user")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	s.put(name, flags.Flag{Value: json.RawMessage(`"text"`), Enabled: true})
+	s.Eventually(func() bool {
+		_, err := store.Bool(context.Background(), name, false)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *FlagsTestSuite) TestCodec() {
+	name := "test-codec"
+	defer s.cli.Delete(context.Background(), flags.Prefix+name)
+
+	store, err := flags.New(context.Background(), s.cli, flags.WithCodec(codec.YAML))
+	s.Require().NoError(err)
+	defer store.Close()
+	pct := 100
+	s.NoError(store.Set(context.Background(), name, flags.Flag{Value: json.RawMessage(`5`), Enabled: true, Percentage: &pct}))
+
+	// the whole flag is stored as YAML, value included
+	getResp, err := s.cli.Get(context.Background(), flags.Prefix+name)
+	s.NoError(err)
+	s.Equal("value: 5\nenabled: true\npercentage: 100\n", string(getResp.Kvs[0].Value))
+
+	s.Eventually(func() bool { return store.Enabled(context.Background(), name, "user") }, 5*time.Second, 10*time.Millisecond)
+	n, err := store.Int(context.Background(), name, 0)
+	s.NoError(err)
+	s.Equal(5, n)
+}
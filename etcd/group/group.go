@@ -0,0 +1,124 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+var ErrRevoked = errors.New("group: lease revoked")
+
+type options struct {
+	ttl int64
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the group lease, which is how long the
+// keys outlive a crashed process.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = int64(ttl)
+	}
+}
+
+// LeaseGroup is a set of keys on one keep-alived lease, say the endpoint keys
+// of a service, that live and die together: revoking the lease deletes them
+// all in one go.
+type LeaseGroup struct {
+	cli    *clientv3.Client
+	id     clientv3.LeaseID
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	members map[string]struct{}
+	revoked bool
+}
+
+// New grants the group lease and keeps it alive until Revoke.
+func New(ctx context.Context, cli *clientv3.Client, opts ...Option) (*LeaseGroup, error) {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	resp, err := cli.Grant(ctx, o.ttl)
+	if err != nil {
+		return nil, err
+	}
+	kaCtx, cancel := context.WithCancel(context.Background())
+	keepChan, err := cli.KeepAlive(kaCtx, resp.ID)
+	if err != nil {
+		cancel()
+		cli.Revoke(context.Background(), resp.ID)
+		return nil, err
+	}
+	go func() {
+		for range keepChan {
+		}
+	}()
+	return &LeaseGroup{cli: cli, id: resp.ID, cancel: cancel, members: make(map[string]struct{})}, nil
+}
+
+// Lease returns the group lease.
+func (g *LeaseGroup) Lease() clientv3.LeaseID {
+	return g.id
+}
+
+// Put writes key with the group lease, making it a member.
+func (g *LeaseGroup) Put(ctx context.Context, key, val string) error {
+	g.mu.Lock()
+	revoked := g.revoked
+	g.mu.Unlock()
+	if revoked {
+		return ErrRevoked
+	}
+
+	if _, err := g.cli.Put(ctx, key, val, clientv3.WithLease(g.id)); err != nil {
+		return err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.revoked {
+		g.members[key] = struct{}{}
+	}
+	return nil
+}
+
+// Members returns the keys put into the group, in key order. A member that
+// was since deleted or put without the lease by someone else is still
+// listed.
+func (g *LeaseGroup) Members() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	keys := make([]string, 0, len(g.members))
+	for key := range g.members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Revoke stops the keep-alive and revokes the lease, which deletes every
+// member at once. Puts fail with ErrRevoked afterwards. A lease that already
+// expired counts as revoked; if revoking fails, the keys still go once the
+// TTL runs out.
+func (g *LeaseGroup) Revoke(ctx context.Context) error {
+	g.mu.Lock()
+	g.revoked = true
+	g.members = make(map[string]struct{})
+	g.mu.Unlock()
+
+	g.cancel()
+	_, err := g.cli.Revoke(ctx, g.id)
+	if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return nil
+	}
+	return err
+}
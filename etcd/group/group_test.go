@@ -0,0 +1,96 @@
+package group_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/group"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type GroupTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestGroupTestSuite(t *testing.T) {
+	suite.Run(t, new(GroupTestSuite))
+}
+
+func (s *GroupTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *GroupTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *GroupTestSuite) TestRevoke() {
+	prefix := "/test/group/revoke/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	g, err := group.New(context.Background(), s.cli, group.WithTTL(5))
+	s.Require().NoError(err)
+	for _, name := range []string{"http", "grpc", "metrics"} {
+		s.NoError(g.Put(context.Background(), prefix+name, "127.0.0.1"))
+	}
+	s.Equal([]string{prefix + "grpc", prefix + "http", prefix + "metrics"}, g.Members())
+
+	resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.Require().NoError(err)
+	s.Len(resp.Kvs, 3)
+	for _, kv := range resp.Kvs {
+		s.Equal(int64(g.Lease()), kv.Lease)
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchChan := s.cli.Watch(watchCtx, prefix, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+	s.NoError(g.Revoke(context.Background()))
+	resp, err = s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.Require().NoError(err)
+	s.Empty(resp.Kvs)
+
+	// all three went in the same revision
+	var deletes []int64
+	for len(deletes) < 3 {
+		select {
+		case watchResp := <-watchChan:
+			for _, ev := range watchResp.Events {
+				s.Equal(clientv3.EventTypeDelete, ev.Type)
+				deletes = append(deletes, ev.Kv.ModRevision)
+			}
+		case <-time.After(5 * time.Second):
+			s.FailNow("no deletes")
+		}
+	}
+	s.Equal(deletes[0], deletes[1])
+	s.Equal(deletes[0], deletes[2])
+
+	s.Empty(g.Members())
+	s.ErrorIs(g.Put(context.Background(), prefix+"late", "v"), group.ErrRevoked)
+	s.NoError(g.Revoke(context.Background()))
+}
+
+func (s *GroupTestSuite) TestKeepAlive() {
+	key := "/test/group/keepalive"
+	defer s.cli.Delete(context.Background(), key)
+
+	g, err := group.New(context.Background(), s.cli, group.WithTTL(1))
+	s.Require().NoError(err)
+	defer g.Revoke(context.Background())
+	s.NoError(g.Put(context.Background(), key, "v"))
+
+	// the key outlives its TTL
+	time.Sleep(2 * time.Second)
+	resp, err := s.cli.Get(context.Background(), key)
+	s.Require().NoError(err)
+	s.Len(resp.Kvs, 1)
+}
@@ -0,0 +1,107 @@
+package grpcresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/registry"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/attributes"
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme is the scheme of dial targets resolved through the registry, as in
+// etcd://services/<name>.
+const Scheme = "etcd"
+
+const authority = "services"
+
+var ErrBadTarget = errors.New("grpcresolver: target must be etcd://services/<name>")
+
+type weightKey struct{}
+
+// Weight returns the weight the instance behind addr registered with, or 0
+// if addr did not come from this resolver.
+func Weight(addr resolver.Address) int {
+	w, _ := addr.BalancerAttributes.Value(weightKey{}).(int)
+	return w
+}
+
+type builder struct {
+	cli *clientv3.Client
+}
+
+// NewBuilder returns a resolver.Builder resolving service names through the
+// registry. Pass it to grpc.WithResolvers, or see Register.
+func NewBuilder(cli *clientv3.Client) resolver.Builder {
+	return &builder{cli: cli}
+}
+
+// Register makes the etcd scheme available to every grpc.Dial in the process.
+// Like resolver.Register, it must only be called during initialization.
+func Register(cli *clientv3.Client) {
+	resolver.Register(NewBuilder(cli))
+}
+
+func (b *builder) Scheme() string {
+	return Scheme
+}
+
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	name := strings.TrimPrefix(target.URL.Path, "/")
+	if target.URL.Host != authority || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("%w: %s", ErrBadTarget, target.URL.String())
+	}
+	d, err := registry.NewDiscovery(context.Background(), b.cli, name)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &etcdResolver{cc: cc, d: d, done: make(chan struct{})}
+	events := d.Events()
+	if err := r.update(); err != nil {
+		d.Close()
+		return nil, err
+	}
+	go r.run(events)
+	return r, nil
+}
+
+// etcdResolver pushes the full address list to the ClientConn whenever an
+// instance is added, updated or removed.
+type etcdResolver struct {
+	cc   resolver.ClientConn
+	d    *registry.Discovery
+	done chan struct{}
+}
+
+func (r *etcdResolver) run(events <-chan registry.DiscoveryEvent) {
+	defer close(r.done)
+	for range events {
+		r.update()
+	}
+}
+
+func (r *etcdResolver) update() error {
+	insts := r.d.Instances()
+	addrs := make([]resolver.Address, 0, len(insts))
+	for _, inst := range insts {
+		addrs = append(addrs, resolver.Address{
+			Addr:               net.JoinHostPort(inst.Host, strconv.Itoa(inst.Port)),
+			BalancerAttributes: attributes.New(weightKey{}, inst.Weight),
+		})
+	}
+	return r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+// ResolveNow is a no-op: the address list is kept current by the watch.
+func (r *etcdResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *etcdResolver) Close() {
+	r.d.Close()
+	<-r.done
+}
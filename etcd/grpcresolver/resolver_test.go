@@ -0,0 +1,89 @@
+package grpcresolver_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/grpcresolver"
+	"github.com/gojustforfun/learn-by-test/etcd/registry"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/resolver"
+)
+
+// clientConn forwards every state the resolver pushes.
+type clientConn struct {
+	resolver.ClientConn
+	states chan resolver.State
+}
+
+func (cc *clientConn) UpdateState(state resolver.State) error {
+	cc.states <- state
+	return nil
+}
+
+type GRPCResolverTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestGRPCResolverTestSuite(t *testing.T) {
+	suite.Run(t, new(GRPCResolverTestSuite))
+}
+
+func (s *GRPCResolverTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *GRPCResolverTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *GRPCResolverTestSuite) nextAddrs(cc *clientConn) map[string]int {
+	select {
+	case state := <-cc.states:
+		addrs := make(map[string]int, len(state.Addresses))
+		for _, addr := range state.Addresses {
+			addrs[addr.Addr] = grpcresolver.Weight(addr)
+		}
+		return addrs
+	case <-time.After(5 * time.Second):
+		s.FailNow("no state update")
+	}
+	return nil
+}
+
+func (s *GRPCResolverTestSuite) TestAddressUpdates() {
+	name := "test-grpcresolver"
+	r1, r2 := registry.New(s.cli), registry.New(s.cli)
+	s.NoError(r1.Register(context.Background(), registry.ServiceInstance{Name: name, ID: "1", Host: "10.0.0.1", Port: 80, Weight: 1}))
+	defer r1.Deregister(context.Background())
+
+	cc := &clientConn{states: make(chan resolver.State, 16)}
+	target := resolver.Target{URL: url.URL{Scheme: grpcresolver.Scheme, Host: "services", Path: "/" + name}}
+	res, err := grpcresolver.NewBuilder(s.cli).Build(target, cc, resolver.BuildOptions{})
+	s.Require().NoError(err)
+	defer res.Close()
+	s.Equal(map[string]int{"10.0.0.1:80": 1}, s.nextAddrs(cc))
+
+	s.NoError(r2.Register(context.Background(), registry.ServiceInstance{Name: name, ID: "2", Host: "10.0.0.2", Port: 80, Weight: 3}))
+	defer r2.Deregister(context.Background())
+	s.Equal(map[string]int{"10.0.0.1:80": 1, "10.0.0.2:80": 3}, s.nextAddrs(cc))
+
+	s.NoError(r1.Deregister(context.Background()))
+	s.Equal(map[string]int{"10.0.0.2:80": 3}, s.nextAddrs(cc))
+}
+
+func (s *GRPCResolverTestSuite) TestBadTarget() {
+	cc := &clientConn{states: make(chan resolver.State, 1)}
+	target := resolver.Target{URL: url.URL{Scheme: grpcresolver.Scheme, Host: "other", Path: "/svc"}}
+	_, err := grpcresolver.NewBuilder(s.cli).Build(target, cc, resolver.BuildOptions{})
+	s.ErrorIs(err, grpcresolver.ErrBadTarget)
+}
@@ -0,0 +1,236 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/maintenance"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultInterval = 10 * time.Second
+
+type Alarm struct {
+	MemberID uint64
+	Type     pb.AlarmType
+}
+
+func (a Alarm) String() string {
+	return fmt.Sprintf("%s on member %x", a.Type, a.MemberID)
+}
+
+type AlarmEventType int
+
+const (
+	Raised AlarmEventType = iota
+	Cleared
+)
+
+func (t AlarmEventType) String() string {
+	switch t {
+	case Raised:
+		return "Raised"
+	case Cleared:
+		return "Cleared"
+	}
+	return "Unknown"
+}
+
+type AlarmEvent struct {
+	Type  AlarmEventType
+	Alarm Alarm
+}
+
+type options struct {
+	interval  time.Duration
+	compactor *maintenance.Compactor
+	defrag    *maintenance.Defrag
+	onError   func(error)
+}
+
+type Option func(*options)
+
+// WithInterval sets how often the alarms are listed.
+func WithInterval(d time.Duration) Option {
+	return func(o *options) {
+		o.interval = d
+	}
+}
+
+// WithAutoDisarm makes the monitor respond to NOSPACE alarms by compacting
+// with c, defragmenting with d and, only if both succeed, disarming the
+// alarms. Both are required: with either nil, alarms are left alone.
+// Disarming hides the problem that raised the alarm, so it is off by default.
+func WithAutoDisarm(c *maintenance.Compactor, d *maintenance.Defrag) Option {
+	return func(o *options) {
+		o.compactor, o.defrag = c, d
+	}
+}
+
+// WithOnError is called with errors from listing, remediating or disarming
+// alarms; the monitor keeps running.
+func WithOnError(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// AlarmMonitor periodically lists the active cluster alarms and reports the
+// ones that are raised and cleared.
+type AlarmMonitor struct {
+	m      clientv3.Maintenance
+	opts   options
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu         sync.Mutex
+	active     map[Alarm]bool
+	subscribed bool
+	events     chan AlarmEvent
+}
+
+// NewAlarmMonitor starts listing alarms until Close is called.
+func NewAlarmMonitor(m clientv3.Maintenance, opts ...Option) *AlarmMonitor {
+	o := options{interval: defaultInterval, onError: func(error) {}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &AlarmMonitor{
+		m:      m,
+		opts:   o,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		active: make(map[Alarm]bool),
+		events: make(chan AlarmEvent, 16),
+	}
+	go a.run(ctx)
+	return a
+}
+
+// Alarms lists the alarms currently active in the cluster.
+func (a *AlarmMonitor) Alarms(ctx context.Context) ([]Alarm, error) {
+	resp, err := a.m.AlarmList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	alarms := make([]Alarm, 0, len(resp.Alarms))
+	for _, am := range resp.Alarms {
+		if am.Alarm == pb.AlarmType_NONE {
+			continue
+		}
+		alarms = append(alarms, Alarm{MemberID: am.MemberID, Type: am.Alarm})
+	}
+	return alarms, nil
+}
+
+// Watch streams alarms as they are raised and cleared. Events are only
+// produced once Watch has been called, and a subscriber that stops reading
+// stalls the monitor.
+func (a *AlarmMonitor) Watch() <-chan AlarmEvent {
+	a.mu.Lock()
+	a.subscribed = true
+	a.mu.Unlock()
+	return a.events
+}
+
+// Close stops the monitor and closes the event stream.
+func (a *AlarmMonitor) Close() {
+	a.cancel()
+	<-a.done
+}
+
+func (a *AlarmMonitor) run(ctx context.Context) {
+	defer close(a.done)
+	defer close(a.events)
+
+	ticker := time.NewTicker(a.opts.interval)
+	defer ticker.Stop()
+	for {
+		a.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *AlarmMonitor) poll(ctx context.Context) {
+	alarms, err := a.Alarms(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			a.opts.onError(err)
+		}
+		return
+	}
+
+	current := make(map[Alarm]bool, len(alarms))
+	var events []AlarmEvent
+	a.mu.Lock()
+	for _, alarm := range alarms {
+		current[alarm] = true
+		if !a.active[alarm] {
+			events = append(events, AlarmEvent{Type: Raised, Alarm: alarm})
+		}
+	}
+	for alarm := range a.active {
+		if !current[alarm] {
+			events = append(events, AlarmEvent{Type: Cleared, Alarm: alarm})
+		}
+	}
+	a.active = current
+	subscribed := a.subscribed
+	a.mu.Unlock()
+
+	for _, event := range events {
+		a.emit(ctx, subscribed, event)
+	}
+	if a.opts.compactor == nil || a.opts.defrag == nil {
+		return
+	}
+	var nospace []Alarm
+	for _, alarm := range alarms {
+		if alarm.Type == pb.AlarmType_NOSPACE {
+			nospace = append(nospace, alarm)
+		}
+	}
+	if len(nospace) > 0 {
+		if err := a.disarm(ctx, nospace); err != nil && ctx.Err() == nil {
+			a.opts.onError(err)
+		}
+	}
+}
+
+// disarm frees space once for the whole cluster and then disarms the NOSPACE
+// alarms of every member. It is retried on the next poll if any step fails,
+// since the alarms that are left are still listed.
+func (a *AlarmMonitor) disarm(ctx context.Context, alarms []Alarm) error {
+	if _, err := a.opts.compactor.CompactNow(ctx); err != nil {
+		return fmt.Errorf("health: compact for %s: %w", alarms[0].Type, err)
+	}
+	if _, err := a.opts.defrag.DefragAll(ctx); err != nil {
+		return fmt.Errorf("health: defragment for %s: %w", alarms[0].Type, err)
+	}
+	var errs []error
+	for _, alarm := range alarms {
+		if _, err := a.m.AlarmDisarm(ctx, &clientv3.AlarmMember{MemberID: alarm.MemberID, Alarm: alarm.Type}); err != nil {
+			errs = append(errs, fmt.Errorf("health: disarm %s: %w", alarm, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *AlarmMonitor) emit(ctx context.Context, subscribed bool, event AlarmEvent) {
+	if !subscribed {
+		return
+	}
+	select {
+	case a.events <- event:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,85 @@
+package health_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/health"
+	"github.com/gojustforfun/learn-by-test/etcd/maintenance"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+func (s *HealthTestSuite) TestAlarmMonitorEmits() {
+	m := &fakeMaintenance{alarms: []*pb.AlarmMember{{MemberID: 1, Alarm: pb.AlarmType_NOSPACE}}}
+	mon := health.NewAlarmMonitor(m, health.WithInterval(10*time.Millisecond))
+	defer mon.Close()
+	events := mon.Watch()
+
+	alarms, err := mon.Alarms(context.Background())
+	s.NoError(err)
+	s.Equal([]health.Alarm{{MemberID: 1, Type: pb.AlarmType_NOSPACE}}, alarms)
+
+	select {
+	case ev := <-events:
+		s.Equal(health.Raised, ev.Type)
+		s.Equal(pb.AlarmType_NOSPACE, ev.Alarm.Type)
+	case <-time.After(time.Second):
+		s.Fail("no alarm event")
+	}
+
+	// not enabled, so the alarm stays
+	time.Sleep(50 * time.Millisecond)
+	s.Empty(m.Disarmed())
+}
+
+func (s *HealthTestSuite) TestAlarmMonitorAutoDisarm() {
+	m := &fakeMaintenance{alarms: []*pb.AlarmMember{{MemberID: 1, Alarm: pb.AlarmType_NOSPACE}}}
+	c := maintenance.NewCompactor(fake.NewKV())
+	d := maintenance.NewDefrag(m, []string{"a:2379", "b:2379"})
+	mon := health.NewAlarmMonitor(m, health.WithInterval(10*time.Millisecond), health.WithAutoDisarm(c, d))
+	defer mon.Close()
+	events := mon.Watch()
+
+	for _, want := range []health.AlarmEventType{health.Raised, health.Cleared} {
+		select {
+		case ev := <-events:
+			s.Equal(want, ev.Type)
+		case <-time.After(time.Second):
+			s.Fail("no alarm event", want)
+		}
+	}
+	s.Len(m.Disarmed(), 1)
+	s.Equal(uint64(1), m.Disarmed()[0].MemberID)
+	m.mu.Lock()
+	s.Equal([]string{"a:2379", "b:2379"}, m.defrags)
+	m.mu.Unlock()
+}
+
+func (s *HealthTestSuite) TestAlarmMonitorAutoDisarmMembers() {
+	m := &fakeMaintenance{alarms: []*pb.AlarmMember{
+		{MemberID: 1, Alarm: pb.AlarmType_NOSPACE},
+		{MemberID: 2, Alarm: pb.AlarmType_NOSPACE},
+		{MemberID: 3, Alarm: pb.AlarmType_NOSPACE},
+	}}
+	c := maintenance.NewCompactor(fake.NewKV())
+	d := maintenance.NewDefrag(m, []string{"a:2379", "b:2379"})
+	mon := health.NewAlarmMonitor(m, health.WithInterval(10*time.Millisecond), health.WithAutoDisarm(c, d))
+	defer mon.Close()
+
+	s.Eventually(func() bool { return len(m.Disarmed()) == 3 }, time.Second, 10*time.Millisecond)
+	// the cluster is defragmented once, not once per member
+	m.mu.Lock()
+	s.Equal([]string{"a:2379", "b:2379"}, m.defrags)
+	m.mu.Unlock()
+}
+
+func (s *HealthTestSuite) TestAlarmMonitorAutoDisarmWithoutDefrag() {
+	m := &fakeMaintenance{alarms: []*pb.AlarmMember{{MemberID: 1, Alarm: pb.AlarmType_NOSPACE}}}
+	c := maintenance.NewCompactor(fake.NewKV())
+	mon := health.NewAlarmMonitor(m, health.WithInterval(10*time.Millisecond), health.WithAutoDisarm(c, nil))
+	defer mon.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	s.Empty(m.Disarmed())
+}
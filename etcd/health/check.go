@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrNoEndpoints = errors.New("health: no endpoints configured")
+
+// StatusClient is the part of the client Check needs; *clientv3.Client
+// implements it.
+type StatusClient interface {
+	Endpoints() []string
+	Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error)
+}
+
+type EndpointHealth struct {
+	Endpoint  string
+	Reachable bool
+	IsLeader  bool
+	RaftTerm  uint64
+	DBSize    int64
+	Err       error
+}
+
+// Check asks every configured endpoint for its status in parallel and
+// returns one result per endpoint, in the configured order. An endpoint
+// that cannot be reached is reported as such rather than failing the check.
+func Check(ctx context.Context, cli StatusClient) ([]EndpointHealth, error) {
+	endpoints := cli.Endpoints()
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+
+	results := make([]EndpointHealth, len(endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func(i int, ep string) {
+			defer wg.Done()
+			results[i] = checkEndpoint(ctx, cli, ep)
+		}(i, ep)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func checkEndpoint(ctx context.Context, cli StatusClient, ep string) EndpointHealth {
+	resp, err := cli.Status(ctx, ep)
+	if err != nil {
+		return EndpointHealth{Endpoint: ep, Err: err}
+	}
+	return EndpointHealth{
+		Endpoint:  ep,
+		Reachable: true,
+		IsLeader:  resp.Header.MemberId == resp.Leader,
+		RaftTerm:  resp.RaftTerm,
+		DBSize:    resp.DbSize,
+	}
+}
+
+// IsHealthy reports whether a quorum of the endpoints is reachable and
+// exactly one of them claims to be the leader.
+func IsHealthy(ctx context.Context, cli StatusClient) bool {
+	results, err := Check(ctx, cli)
+	if err != nil {
+		return false
+	}
+	return Quorum(results)
+}
+
+// Quorum is the predicate of IsHealthy: it reports whether a quorum of the
+// results is reachable and exactly one of them is the leader.
+func Quorum(results []EndpointHealth) bool {
+	var reachable, leaders int
+	for _, res := range results {
+		if res.Reachable {
+			reachable++
+		}
+		if res.IsLeader {
+			leaders++
+		}
+	}
+	return reachable > len(results)/2 && leaders == 1
+}
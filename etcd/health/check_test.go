@@ -0,0 +1,79 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/health"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type memberStatus struct {
+	id, leader uint64
+	err        error
+}
+
+var _ health.StatusClient = (*clientv3.Client)(nil)
+
+// statusClient answers Status from a fixed status per endpoint.
+type statusClient map[string]memberStatus
+
+func (c statusClient) Endpoints() []string {
+	return []string{"a:2379", "b:2379", "c:2379"}
+}
+
+func (c statusClient) Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	st := c[endpoint]
+	if st.err != nil {
+		return nil, st.err
+	}
+	return &clientv3.StatusResponse{
+		Header:   &pb.ResponseHeader{MemberId: st.id},
+		Leader:   st.leader,
+		RaftTerm: 2,
+		DbSize:   4096,
+	}, nil
+}
+
+func (s *HealthTestSuite) TestCheckAllHealthy() {
+	cli := statusClient{
+		"a:2379": {id: 1, leader: 1},
+		"b:2379": {id: 2, leader: 1},
+		"c:2379": {id: 3, leader: 1},
+	}
+	results, err := health.Check(context.Background(), cli)
+	s.NoError(err)
+	s.Len(results, 3)
+	s.Equal(health.EndpointHealth{Endpoint: "a:2379", Reachable: true, IsLeader: true, RaftTerm: 2, DBSize: 4096}, results[0])
+	s.False(results[1].IsLeader)
+	s.True(health.IsHealthy(context.Background(), cli))
+}
+
+func (s *HealthTestSuite) TestCheckOneDown() {
+	errDown := errors.New("connection refused")
+	cli := statusClient{
+		"a:2379": {id: 1, leader: 1},
+		"b:2379": {err: errDown},
+		"c:2379": {id: 3, leader: 1},
+	}
+	results, err := health.Check(context.Background(), cli)
+	s.NoError(err)
+	s.False(results[1].Reachable)
+	s.ErrorIs(results[1].Err, errDown)
+	s.True(results[2].Reachable)
+	s.True(health.IsHealthy(context.Background(), cli))
+
+	// without the leader there is no quorum left
+	cli["a:2379"] = memberStatus{err: errDown}
+	s.False(health.IsHealthy(context.Background(), cli))
+}
+
+func (s *HealthTestSuite) TestCheckSplitLeader() {
+	cli := statusClient{
+		"a:2379": {id: 1, leader: 1},
+		"b:2379": {id: 2, leader: 2},
+		"c:2379": {id: 3, leader: 1},
+	}
+	s.False(health.IsHealthy(context.Background(), cli))
+}
@@ -0,0 +1,63 @@
+package health_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// fakeMaintenance reports a fixed set of alarms and records the ones
+// disarmed and the endpoints defragmented.
+type fakeMaintenance struct {
+	clientv3.Maintenance
+
+	mu       sync.Mutex
+	alarms   []*pb.AlarmMember
+	disarmed []*clientv3.AlarmMember
+	defrags  []string
+}
+
+func (m *fakeMaintenance) AlarmList(ctx context.Context) (*clientv3.AlarmResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &clientv3.AlarmResponse{Alarms: append([]*pb.AlarmMember(nil), m.alarms...)}, nil
+}
+
+func (m *fakeMaintenance) AlarmDisarm(ctx context.Context, am *clientv3.AlarmMember) (*clientv3.AlarmResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.disarmed = append(m.disarmed, am)
+	alarms := m.alarms[:0]
+	for _, a := range m.alarms {
+		if a.MemberID != am.MemberID || a.Alarm != am.Alarm {
+			alarms = append(alarms, a)
+		}
+	}
+	m.alarms = alarms
+	return &clientv3.AlarmResponse{}, nil
+}
+
+func (m *fakeMaintenance) Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defrags = append(m.defrags, endpoint)
+	return &clientv3.DefragmentResponse{}, nil
+}
+
+func (m *fakeMaintenance) Disarmed() []*clientv3.AlarmMember {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*clientv3.AlarmMember(nil), m.disarmed...)
+}
+
+type HealthTestSuite struct {
+	suite.Suite
+}
+
+func TestHealthTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
@@ -0,0 +1,54 @@
+package health
+
+import (
+	"context"
+	"time"
+)
+
+type readyOptions struct {
+	base, max time.Duration
+	healthy   func([]EndpointHealth) bool
+}
+
+type ReadyOption func(*readyOptions)
+
+// WithPollInterval checks every base at first, doubling the wait after each
+// unhealthy check up to max. It defaults to 100ms up to 5s.
+func WithPollInterval(base, max time.Duration) ReadyOption {
+	return func(o *readyOptions) {
+		o.base, o.max = base, max
+	}
+}
+
+// WithPredicate sets what WaitReady waits for, Quorum by default.
+func WithPredicate(healthy func([]EndpointHealth) bool) ReadyOption {
+	return func(o *readyOptions) {
+		o.healthy = healthy
+	}
+}
+
+// WaitReady blocks until a Check of the cluster passes the predicate, or ctx
+// is done, for processes that should not start serving before etcd is usable.
+func WaitReady(ctx context.Context, cli StatusClient, opts ...ReadyOption) error {
+	o := readyOptions{base: 100 * time.Millisecond, max: 5 * time.Second, healthy: Quorum}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	wait := o.base
+	for {
+		results, err := Check(ctx, cli)
+		if err != nil {
+			return err
+		}
+		if o.healthy(results) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, o.max)
+	}
+}
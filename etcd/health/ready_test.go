@@ -0,0 +1,68 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/health"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// recoveringClient has no leader until it has been checked healthyAfter
+// times.
+type recoveringClient struct {
+	statusClient
+	healthyAfter int64
+	checks       atomic.Int64
+}
+
+func (c *recoveringClient) Endpoints() []string {
+	c.checks.Add(1)
+	return c.statusClient.Endpoints()
+}
+
+func (c *recoveringClient) Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	resp, err := c.statusClient.Status(ctx, endpoint)
+	if err == nil && c.checks.Load() <= c.healthyAfter {
+		resp.Leader = 0
+	}
+	return resp, err
+}
+
+func (s *HealthTestSuite) TestWaitReady() {
+	cli := &recoveringClient{statusClient: statusClient{
+		"a:2379": {id: 1, leader: 1},
+		"b:2379": {id: 2, leader: 1},
+		"c:2379": {id: 3, leader: 1},
+	}, healthyAfter: 3}
+
+	start := time.Now()
+	s.NoError(health.WaitReady(context.Background(), cli, health.WithPollInterval(10*time.Millisecond, 20*time.Millisecond)))
+	s.Equal(int64(4), cli.checks.Load())
+	// waited 10ms, then 20ms twice
+	s.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+}
+
+func (s *HealthTestSuite) TestWaitReadyTimeout() {
+	cli := statusClient{
+		"a:2379": {id: 1, leader: 1},
+		"b:2379": {err: errors.New("connection refused")},
+		"c:2379": {err: errors.New("connection refused")},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(health.WaitReady(ctx, cli, health.WithPollInterval(10*time.Millisecond, 10*time.Millisecond)), context.DeadlineExceeded)
+
+	// a looser predicate is content with any reachable leader
+	anyLeader := func(results []health.EndpointHealth) bool {
+		for _, res := range results {
+			if res.IsLeader {
+				return true
+			}
+		}
+		return false
+	}
+	s.NoError(health.WaitReady(context.Background(), cli, health.WithPredicate(anyLeader)))
+}
@@ -0,0 +1,63 @@
+package idgen
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+)
+
+const defaultBlockSize = 1000
+
+type options struct {
+	blockSize int64
+}
+
+type Option func(*options)
+
+// WithBlockSize sets how many IDs are reserved per round trip to etcd.
+func WithBlockSize(n int) Option {
+	return func(o *options) {
+		o.blockSize = int64(n)
+	}
+}
+
+// Sequence hands out int64 IDs that are unique across every Sequence sharing
+// the counter key. It reserves blocks of IDs by adding the block size to the
+// counter and serves them locally, so etcd is only hit once per block. The
+// IDs of one Sequence strictly increase; those of different Sequences
+// interleave by block. IDs left in the block of a stopped process are never
+// handed out, so the sequence has gaps.
+type Sequence struct {
+	counter *kv.Counter
+	opts    options
+
+	mu        sync.Mutex
+	next, end int64
+}
+
+func NewSequence(cli etcdx.KV, key string, opts ...Option) *Sequence {
+	o := options{blockSize: defaultBlockSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Sequence{counter: kv.NewCounter(cli, key), opts: o}
+}
+
+// Next returns the next ID, the first being 1.
+func (s *Sequence) Next(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == s.end {
+		end, err := s.counter.Inc(ctx, s.opts.blockSize)
+		if err != nil {
+			return 0, err
+		}
+		// the block is (end-blockSize, end]
+		s.next, s.end = end-s.opts.blockSize, end
+	}
+	s.next++
+	return s.next, nil
+}
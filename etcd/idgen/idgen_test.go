@@ -0,0 +1,86 @@
+package idgen_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/idgen"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type IDGenTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestIDGenTestSuite(t *testing.T) {
+	suite.Run(t, new(IDGenTestSuite))
+}
+
+func (s *IDGenTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *IDGenTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *IDGenTestSuite) TestUnique() {
+	key := "/test/idgen/unique"
+	defer s.cli.Delete(context.Background(), key)
+
+	const generators, perGenerator = 4, 2500
+	ids := make([][]int64, generators)
+	var wg sync.WaitGroup
+	for g := 0; g < generators; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			seq := idgen.NewSequence(s.cli, key, idgen.WithBlockSize(100))
+			for i := 0; i < perGenerator; i++ {
+				id, err := seq.Next(context.Background())
+				s.NoError(err)
+				ids[g] = append(ids[g], id)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool, generators*perGenerator)
+	for _, gen := range ids {
+		for i, id := range gen {
+			if i > 0 {
+				s.Greater(id, gen[i-1])
+			}
+			s.False(seen[id], "duplicate id %d", id)
+			seen[id] = true
+		}
+	}
+	s.Len(seen, generators*perGenerator)
+}
+
+func (s *IDGenTestSuite) TestRestart() {
+	key := "/test/idgen/restart"
+	defer s.cli.Delete(context.Background(), key)
+
+	seq := idgen.NewSequence(s.cli, key, idgen.WithBlockSize(10))
+	for want := int64(1); want <= 12; want++ {
+		id, err := seq.Next(context.Background())
+		s.NoError(err)
+		s.Equal(want, id)
+	}
+
+	// a restarted process skips the rest of the old block
+	seq = idgen.NewSequence(s.cli, key, idgen.WithBlockSize(10))
+	id, err := seq.Next(context.Background())
+	s.NoError(err)
+	s.Equal(int64(21), id)
+}
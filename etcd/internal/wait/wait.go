@@ -0,0 +1,78 @@
+package wait
+
+import (
+	"context"
+	"errors"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrWatchClosed = errors.New("wait: watch closed while waiting")
+
+// Delete blocks until key is deleted at a revision after rev.
+func Delete(ctx context.Context, cli *clientv3.Client, key string, rev int64, opts ...clientv3.OpOption) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	opts = append(opts, clientv3.WithRev(rev+1), clientv3.WithFilterPut())
+	var watchResp clientv3.WatchResponse
+	for watchResp = range cli.Watch(ctx, key, opts...) {
+		for _, ev := range watchResp.Events {
+			if ev.Type == mvccpb.DELETE {
+				return nil
+			}
+		}
+	}
+	if err := watchResp.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrWatchClosed
+}
+
+// AnyDelete blocks until some key under prefix is deleted at a revision
+// after rev.
+func AnyDelete(ctx context.Context, cli *clientv3.Client, prefix string, rev int64) error {
+	return Delete(ctx, cli, prefix, rev, clientv3.WithPrefix())
+}
+
+// Deletes blocks until every key under prefix created at or before
+// maxCreateRev is gone, waiting on the newest of them each round.
+func Deletes(ctx context.Context, cli *clientv3.Client, prefix string, maxCreateRev int64) error {
+	getOpts := append(clientv3.WithLastCreate(), clientv3.WithMaxCreateRev(maxCreateRev))
+	for {
+		resp, err := cli.Get(ctx, prefix, getOpts...)
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			return nil
+		}
+		if err := Delete(ctx, cli, string(resp.Kvs[0].Key), resp.Header.Revision); err != nil {
+			return err
+		}
+	}
+}
+
+// AnyPut blocks until some key under prefix is put at a revision after rev.
+func AnyPut(ctx context.Context, cli *clientv3.Client, prefix string, rev int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var watchResp clientv3.WatchResponse
+	for watchResp = range cli.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1), clientv3.WithFilterDelete()) {
+		if len(watchResp.Events) > 0 {
+			return nil
+		}
+	}
+	if err := watchResp.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrWatchClosed
+}
@@ -0,0 +1,158 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultChunkSize = 256 << 10
+	// maxTxnBytes keeps a txn of chunks under etcd's default
+	// --max-request-bytes of 1.5MiB.
+	maxTxnBytes = 1 << 20
+	maxChunks   = 10000
+)
+
+var (
+	ErrCorruptBlob  = errors.New("kv: blob chunk missing or corrupt")
+	ErrBlobTooLarge = errors.New("kv: blob has too many chunks")
+)
+
+type blobOptions struct {
+	chunkSize int
+}
+
+type BlobOption func(*blobOptions)
+
+// WithChunkSize sets the size of each chunk, 256KiB by default.
+func WithChunkSize(n int) BlobOption {
+	return func(o *blobOptions) {
+		o.chunkSize = n
+	}
+}
+
+type manifest struct {
+	Chunks int    `json:"chunks"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BlobStore stores values too large for a single etcd request. A blob is
+// split into chunks under <key>/chunk/0000 onwards, and <key> holds a
+// manifest with the chunk count, size and SHA-256 of the whole value.
+type BlobStore struct {
+	cli  etcdx.KV
+	opts blobOptions
+}
+
+func NewBlobStore(cli etcdx.KV, opts ...BlobOption) *BlobStore {
+	o := blobOptions{chunkSize: defaultChunkSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &BlobStore{cli: cli, opts: o}
+}
+
+func chunkPrefix(key string) string {
+	return key + "/chunk/"
+}
+
+func chunkKey(key string, i int) string {
+	return fmt.Sprintf("%s%04d", chunkPrefix(key), i)
+}
+
+// PutBlob writes the contents of r under key. The chunks are written in as
+// few txns as fit the request size limit, then the manifest is put in a txn
+// that also deletes the chunks a previous, larger blob left behind.
+func (b *BlobStore) PutBlob(ctx context.Context, key string, r io.Reader) error {
+	perTxn := maxTxnBytes / b.opts.chunkSize
+	if perTxn < 1 {
+		perTxn = 1
+	}
+
+	h := sha256.New()
+	var m manifest
+	var ops []clientv3.Op
+	buf := make([]byte, b.opts.chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if m.Chunks == maxChunks {
+				return fmt.Errorf("%w: more than %d", ErrBlobTooLarge, maxChunks)
+			}
+			h.Write(buf[:n])
+			ops = append(ops, clientv3.OpPut(chunkKey(key, m.Chunks), string(buf[:n])))
+			m.Chunks++
+			m.Size += int64(n)
+		}
+		if len(ops) == perTxn || (len(ops) > 0 && n < len(buf)) {
+			if _, err := b.cli.Txn(ctx).Then(ops...).Commit(); err != nil {
+				return err
+			}
+			ops = ops[:0]
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	val, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	end := clientv3.GetPrefixRangeEnd(chunkPrefix(key))
+	_, err = b.cli.Txn(ctx).Then(
+		clientv3.OpPut(key, string(val)),
+		clientv3.OpDelete(chunkKey(key, m.Chunks), clientv3.WithRange(end)),
+	).Commit()
+	return err
+}
+
+// GetBlob reads the blob under key, or returns ErrKeyNotFound. The chunks are
+// read at the revision of the manifest, so a concurrent PutBlob does not mix
+// in; a chunk that is missing or does not match the hash is ErrCorruptBlob.
+// The whole blob is verified, and so held in memory, before it is returned.
+func (b *BlobStore) GetBlob(ctx context.Context, key string) (io.Reader, error) {
+	resp, err := b.cli.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	var m manifest
+	if err := json.Unmarshal(resp.Kvs[0].Value, &m); err != nil {
+		return nil, err
+	}
+
+	rev := resp.Header.Revision
+	data := make([]byte, 0, m.Size)
+	for i := 0; i < m.Chunks; i++ {
+		chunkResp, err := b.cli.Get(ctx, chunkKey(key, i), clientv3.WithRev(rev))
+		if err != nil {
+			return nil, err
+		}
+		if len(chunkResp.Kvs) == 0 {
+			return nil, fmt.Errorf("%w: chunk %d of %s", ErrCorruptBlob, i, key)
+		}
+		data = append(data, chunkResp.Kvs[0].Value...)
+	}
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != m.Size || hex.EncodeToString(sum[:]) != m.SHA256 {
+		return nil, fmt.Errorf("%w: %s does not match its manifest", ErrCorruptBlob, key)
+	}
+	return bytes.NewReader(data), nil
+}
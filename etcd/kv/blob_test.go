@@ -0,0 +1,59 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestBlobRoundTrip() {
+	key := "/test/kv/blob/roundtrip"
+	defer s.cli.Delete(context.Background(), key, clientv3.WithPrefix())
+
+	data := make([]byte, 5<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	store := kv.NewBlobStore(s.cli)
+	s.Require().NoError(store.PutBlob(context.Background(), key, bytes.NewReader(data)))
+
+	r, err := store.GetBlob(context.Background(), key)
+	s.Require().NoError(err)
+	got, err := io.ReadAll(r)
+	s.NoError(err)
+	s.Equal(data, got)
+
+	// a smaller blob leaves no stale chunks behind
+	s.NoError(store.PutBlob(context.Background(), key, bytes.NewReader([]byte("small"))))
+	getResp, err := s.cli.Get(context.Background(), key+"/chunk/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(1), getResp.Count)
+	r, err = store.GetBlob(context.Background(), key)
+	s.Require().NoError(err)
+	got, err = io.ReadAll(r)
+	s.NoError(err)
+	s.Equal("small", string(got))
+
+	_, err = store.GetBlob(context.Background(), "/test/kv/blob/missing")
+	s.ErrorIs(err, kv.ErrKeyNotFound)
+}
+
+func (s *KVTestSuite) TestBlobMissingChunk() {
+	key := "/test/kv/blob/corrupt"
+	defer s.cli.Delete(context.Background(), key, clientv3.WithPrefix())
+
+	store := kv.NewBlobStore(s.cli, kv.WithChunkSize(1024))
+	s.NoError(store.PutBlob(context.Background(), key, bytes.NewReader(make([]byte, 4096))))
+
+	_, err := s.cli.Delete(context.Background(), key+"/chunk/0002")
+	s.NoError(err)
+	_, err = store.GetBlob(context.Background(), key)
+	s.ErrorIs(err, kv.ErrCorruptBlob)
+
+	_, err = s.cli.Put(context.Background(), key+"/chunk/0002", "tampered")
+	s.NoError(err)
+	_, err = store.GetBlob(context.Background(), key)
+	s.ErrorIs(err, kv.ErrCorruptBlob)
+}
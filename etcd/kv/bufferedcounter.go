@@ -0,0 +1,133 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+)
+
+type bufferedCounterOptions struct {
+	interval  time.Duration
+	threshold int64
+}
+
+type BufferedCounterOption func(*bufferedCounterOptions)
+
+// WithCounterFlushInterval sets how often the buffered delta is flushed,
+// every second by default.
+func WithCounterFlushInterval(d time.Duration) BufferedCounterOption {
+	return func(o *bufferedCounterOptions) {
+		o.interval = d
+	}
+}
+
+// WithCounterThreshold flushes early once the buffered delta reaches n in
+// either direction. By default only the interval triggers a flush.
+func WithCounterThreshold(n int64) BufferedCounterOption {
+	return func(o *bufferedCounterOptions) {
+		o.threshold = n
+	}
+}
+
+// BufferedCounter is a Counter that sums Adds in memory and writes the sum to
+// etcd in a single Inc per flush, for counters updated too often to afford a
+// txn each time.
+//
+// What was added shows up in etcd only once it is flushed, so readers of the
+// key, other processes included, lag behind by up to the flush interval. Adds
+// not yet flushed when the process dies are lost.
+type BufferedCounter struct {
+	c     *Counter
+	opts  bufferedCounterOptions
+	delta atomic.Int64
+
+	// flushMu keeps a failed flush from racing a later one when it puts its
+	// delta back
+	flushMu sync.Mutex
+	full    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewBufferedCounter starts flushing in the background until Close is called.
+func NewBufferedCounter(cli etcdx.KV, key string, opts ...BufferedCounterOption) *BufferedCounter {
+	o := bufferedCounterOptions{interval: time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BufferedCounter{
+		c:      NewCounter(cli, key),
+		opts:   o,
+		full:   make(chan struct{}, 1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go b.run(ctx)
+	return b
+}
+
+// Add buffers delta.
+func (b *BufferedCounter) Add(delta int64) {
+	sum := b.delta.Add(delta)
+	if b.opts.threshold > 0 && (sum >= b.opts.threshold || -sum >= b.opts.threshold) {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes the buffered delta. If that fails the delta stays buffered for
+// the next flush.
+func (b *BufferedCounter) Flush(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	delta := b.delta.Swap(0)
+	if delta == 0 {
+		return nil
+	}
+	if _, err := b.c.Inc(ctx, delta); err != nil {
+		b.delta.Add(delta)
+		return err
+	}
+	return nil
+}
+
+// Value flushes, then returns the value stored in etcd, which includes the
+// Adds of other processes flushed so far.
+func (b *BufferedCounter) Value(ctx context.Context) (int64, error) {
+	if err := b.Flush(ctx); err != nil {
+		return 0, err
+	}
+	return b.c.Get(ctx)
+}
+
+// Close stops flushing in the background and flushes what is buffered.
+func (b *BufferedCounter) Close(ctx context.Context) error {
+	b.cancel()
+	<-b.done
+	return b.Flush(ctx)
+}
+
+func (b *BufferedCounter) run(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+		// a failed flush keeps its delta for the next round
+		b.Flush(ctx)
+	}
+}
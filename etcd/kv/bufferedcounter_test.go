@@ -0,0 +1,69 @@
+package kv_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// countingKV counts the txns sent.
+type countingKV struct {
+	*fake.KV
+	txns atomic.Int64
+}
+
+func (c *countingKV) Txn(ctx context.Context) clientv3.Txn {
+	c.txns.Add(1)
+	return c.KV.Txn(ctx)
+}
+
+func (s *KVTestSuite) TestBufferedCounter() {
+	store := &countingKV{KV: fake.NewKV()}
+	counter := kv.NewBufferedCounter(store, "counter", kv.WithCounterFlushInterval(10*time.Millisecond), kv.WithCounterThreshold(500))
+	defer counter.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	s.NoError(counter.Flush(context.Background()))
+
+	val, err := kv.NewCounter(store, "counter").Get(context.Background())
+	s.NoError(err)
+	s.Equal(int64(10000), val)
+	s.Less(store.txns.Load(), int64(100))
+
+	counter.Add(-1)
+	val, err = counter.Value(context.Background())
+	s.NoError(err)
+	s.Equal(int64(9999), val)
+}
+
+func (s *KVTestSuite) TestBufferedCounterCluster() {
+	key := "/test/kv/bufferedcounter"
+	defer s.cli.Delete(context.Background(), key)
+
+	// two processes each buffering their own adds
+	c1, c2 := kv.NewBufferedCounter(s.cli, key), kv.NewBufferedCounter(s.cli, key)
+	for i := 0; i < 100; i++ {
+		c1.Add(1)
+		c2.Add(2)
+	}
+	s.NoError(c1.Close(context.Background()))
+	val, err := c2.Value(context.Background())
+	s.NoError(err)
+	s.Equal(int64(300), val)
+	s.NoError(c2.Close(context.Background()))
+}
@@ -0,0 +1,51 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DeleteIf deletes key only if its value equals expected, and reports whether
+// it did. A mismatch or a missing key is not an error.
+func DeleteIf(ctx context.Context, cli etcdx.KV, key string, expected []byte) (bool, error) {
+	return deleteIf(ctx, cli, key, clientv3.Compare(clientv3.Value(key), "=", string(expected)))
+}
+
+// DeleteIfVersion deletes key only if its ModRevision equals modRev, and
+// reports whether it did.
+func DeleteIfVersion(ctx context.Context, cli etcdx.KV, key string, modRev int64) (bool, error) {
+	return deleteIf(ctx, cli, key, clientv3.Compare(clientv3.ModRevision(key), "=", modRev))
+}
+
+// ClaimAndDelete deletes key only if its ModRevision equals modRev, and then
+// returns the value it deleted and true. Of consumers racing to claim the same
+// item, exactly one gets true; the others get false and move on to the next.
+func ClaimAndDelete(ctx context.Context, cli etcdx.KV, key string, modRev int64) ([]byte, bool, error) {
+	resp, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRev)).
+		Then(clientv3.OpDelete(key, clientv3.WithPrevKV())).
+		Commit()
+	if err != nil {
+		return nil, false, err
+	}
+	if !resp.Succeeded {
+		return nil, false, nil
+	}
+	prev := resp.Responses[0].GetResponseDeleteRange().PrevKvs
+	if len(prev) == 0 {
+		// modRev 0 matched a missing key
+		return nil, false, nil
+	}
+	return prev[0].Value, true, nil
+}
+
+func deleteIf(ctx context.Context, cli etcdx.KV, key string, cmp clientv3.Cmp) (bool, error) {
+	resp, err := cli.Txn(ctx).If(cmp).Then(clientv3.OpDelete(key)).Commit()
+	if err != nil {
+		return false, err
+	}
+	// ModRevision 0 matches a missing key, which is then not deleted either
+	return resp.Succeeded && resp.Responses[0].GetResponseDeleteRange().Deleted > 0, nil
+}
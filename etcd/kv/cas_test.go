@@ -0,0 +1,93 @@
+package kv_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+)
+
+func (s *KVTestSuite) TestDeleteIf() {
+	key := "/test/kv/delete-if"
+	defer s.cli.Delete(context.Background(), key)
+
+	_, err := s.cli.Put(context.Background(), key, "v1")
+	s.NoError(err)
+
+	deleted, err := kv.DeleteIf(context.Background(), s.cli, key, []byte("v2"))
+	s.NoError(err)
+	s.False(deleted)
+
+	deleted, err = kv.DeleteIf(context.Background(), s.cli, key, []byte("v1"))
+	s.NoError(err)
+	s.True(deleted)
+
+	resp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Zero(resp.Count)
+
+	deleted, err = kv.DeleteIf(context.Background(), s.cli, key, []byte("v1"))
+	s.NoError(err)
+	s.False(deleted)
+}
+
+func (s *KVTestSuite) TestDeleteIfVersion() {
+	key := "/test/kv/delete-if-version"
+	defer s.cli.Delete(context.Background(), key)
+
+	putResp, err := s.cli.Put(context.Background(), key, "v1")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), key, "v2")
+	s.NoError(err)
+
+	deleted, err := kv.DeleteIfVersion(context.Background(), s.cli, key, putResp.Header.Revision)
+	s.NoError(err)
+	s.False(deleted)
+
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	deleted, err = kv.DeleteIfVersion(context.Background(), s.cli, key, getResp.Kvs[0].ModRevision)
+	s.NoError(err)
+	s.True(deleted)
+
+	deleted, err = kv.DeleteIfVersion(context.Background(), s.cli, key, 0)
+	s.NoError(err)
+	s.False(deleted)
+}
+
+func (s *KVTestSuite) TestClaimAndDelete() {
+	key := "/test/kv/claim"
+	defer s.cli.Delete(context.Background(), key)
+
+	putResp, err := s.cli.Put(context.Background(), key, "item")
+	s.Require().NoError(err)
+	modRev := putResp.Header.Revision
+
+	// two consumers racing for the item they both saw
+	type result struct {
+		val     []byte
+		claimed bool
+	}
+	start := make(chan struct{})
+	results := make(chan result, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			<-start
+			val, claimed, err := kv.ClaimAndDelete(context.Background(), s.cli, key, modRev)
+			s.NoError(err)
+			results <- result{val, claimed}
+		}()
+	}
+	close(start)
+	r1, r2 := <-results, <-results
+	if r2.claimed {
+		r1, r2 = r2, r1
+	}
+	s.True(r1.claimed)
+	s.Equal("item", string(r1.val))
+	s.False(r2.claimed)
+	s.Nil(r2.val)
+
+	_, claimed, err := kv.ClaimAndDelete(context.Background(), s.cli, key, 0)
+	s.NoError(err)
+	s.False(claimed, "a missing key is not claimed")
+}
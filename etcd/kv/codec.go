@@ -0,0 +1,214 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/txnresult"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// valueCodec transforms values on their way into and out of etcd.
+type valueCodec interface {
+	encode(val []byte) ([]byte, error)
+	decode(val []byte) ([]byte, error)
+}
+
+// codecKV decorates a clientv3.KV, encoding every value it writes and
+// decoding every value it reads back, in txns and previous values too.
+// Comparisons on values see the encoded bytes, and puts with
+// clientv3.WithIgnoreValue are not supported.
+type codecKV struct {
+	clientv3.KV
+	codec valueCodec
+}
+
+func (c *codecKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	enc, err := c.codec.encode([]byte(val))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.KV.Put(ctx, key, string(enc), opts...)
+	if err != nil {
+		return nil, err
+	}
+	if resp.PrevKv, err = c.decodeKv(resp.PrevKv); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *codecKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := c.KV.Get(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.decodeKvs(resp.Kvs); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *codecKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	resp, err := c.KV.Delete(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.decodeKvs(resp.PrevKvs); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *codecKV) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	op, err := c.encodeOp(op)
+	if err != nil {
+		return clientv3.OpResponse{}, err
+	}
+	resp, err := c.KV.Do(ctx, op)
+	if err != nil {
+		return resp, err
+	}
+	if err := c.decodeResponse(resp); err != nil {
+		return clientv3.OpResponse{}, err
+	}
+	return resp, nil
+}
+
+func (c *codecKV) Txn(ctx context.Context) clientv3.Txn {
+	return &codecTxn{Txn: c.KV.Txn(ctx), c: c}
+}
+
+type codecTxn struct {
+	clientv3.Txn
+	c   *codecKV
+	err error
+}
+
+func (t *codecTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *codecTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	ops, err := t.c.encodeOps(ops)
+	if err != nil {
+		t.err = err
+		return t
+	}
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *codecTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	ops, err := t.c.encodeOps(ops)
+	if err != nil {
+		t.err = err
+		return t
+	}
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+// Commit fails without committing if encoding any of the ops failed.
+func (t *codecTxn) Commit() (*clientv3.TxnResponse, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	resp, err := t.Txn.Commit()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.c.decodeTxn(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (c *codecKV) encodeOp(op clientv3.Op) (clientv3.Op, error) {
+	switch {
+	case op.IsPut():
+		enc, err := c.codec.encode(op.ValueBytes())
+		if err != nil {
+			return op, err
+		}
+		op.WithValueBytes(enc)
+	case op.IsTxn():
+		cmps, thenOps, elseOps := op.Txn()
+		thenOps, err := c.encodeOps(thenOps)
+		if err != nil {
+			return op, err
+		}
+		elseOps, err = c.encodeOps(elseOps)
+		if err != nil {
+			return op, err
+		}
+		op = clientv3.OpTxn(cmps, thenOps, elseOps)
+	}
+	return op, nil
+}
+
+func (c *codecKV) encodeOps(ops []clientv3.Op) ([]clientv3.Op, error) {
+	encoded := make([]clientv3.Op, len(ops))
+	for i, op := range ops {
+		var err error
+		if encoded[i], err = c.encodeOp(op); err != nil {
+			return nil, err
+		}
+	}
+	return encoded, nil
+}
+
+// decodeKv returns a decoded copy of kv, leaving kv itself untouched as it
+// may be shared, e.g. by an in-memory store.
+func (c *codecKV) decodeKv(kv *mvccpb.KeyValue) (*mvccpb.KeyValue, error) {
+	if kv == nil {
+		return nil, nil
+	}
+	val, err := c.codec.decode(kv.Value)
+	if err != nil {
+		return nil, err
+	}
+	decoded := *kv
+	decoded.Value = val
+	return &decoded, nil
+}
+
+func (c *codecKV) decodeKvs(kvs []*mvccpb.KeyValue) error {
+	for i, kv := range kvs {
+		var err error
+		if kvs[i], err = c.decodeKv(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeResponse decodes the values of resp in place.
+func (c *codecKV) decodeResponse(resp clientv3.OpResponse) error {
+	var err error
+	switch {
+	case resp.Get() != nil:
+		err = c.decodeKvs(resp.Get().Kvs)
+	case resp.Put() != nil:
+		resp.Put().PrevKv, err = c.decodeKv(resp.Put().PrevKv)
+	case resp.Del() != nil:
+		err = c.decodeKvs(resp.Del().PrevKvs)
+	case resp.Txn() != nil:
+		err = c.decodeTxn(resp.Txn())
+	}
+	return err
+}
+
+func (c *codecKV) decodeTxn(resp *clientv3.TxnResponse) error {
+	for i := range resp.Responses {
+		r, err := txnresult.At(resp, i)
+		if err != nil {
+			return err
+		}
+		if err := c.decodeResponse(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package kv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultCompressThreshold = 1024
+
+// compressedMagic starts every compressed value. Values without it are read
+// back as they are, so keys written before compression was turned on keep
+// working.
+var compressedMagic = []byte("\x00gz\x01")
+
+type compressedOptions struct {
+	threshold int
+}
+
+type CompressedOption func(*compressedOptions)
+
+// WithThreshold only compresses values longer than n bytes, 1024 by default.
+// Smaller values gain nothing from gzip's overhead.
+func WithThreshold(n int) CompressedOption {
+	return func(o *compressedOptions) {
+		o.threshold = n
+	}
+}
+
+// Compressed decorates a clientv3.KV, gzip-compressing values on the way in
+// and decompressing them on the way out, in ranged Gets and txns alike.
+type Compressed struct {
+	codecKV
+}
+
+func NewCompressed(kv clientv3.KV, opts ...CompressedOption) *Compressed {
+	o := compressedOptions{threshold: defaultCompressThreshold}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Compressed{codecKV{KV: kv, codec: gzipCodec(o)}}
+}
+
+type gzipCodec compressedOptions
+
+func (c gzipCodec) encode(val []byte) ([]byte, error) {
+	// a small value that happens to start with the magic still has to be
+	// compressed, or it would not read back as itself
+	if len(val) <= c.threshold && !bytes.HasPrefix(val, compressedMagic) {
+		return val, nil
+	}
+	var buf bytes.Buffer
+	buf.Write(compressedMagic)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(val); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) decode(val []byte) ([]byte, error) {
+	if !bytes.HasPrefix(val, compressedMagic) {
+		return val, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(val[len(compressedMagic):]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
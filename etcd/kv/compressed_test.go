@@ -0,0 +1,48 @@
+package kv_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestCompressed() {
+	prefix := "/test/kv/compressed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	c := kv.NewCompressed(s.cli.KV)
+	big := strings.Repeat("compressible ", 1000)
+	_, err := c.Put(context.Background(), prefix+"big", big)
+	s.NoError(err)
+	_, err = c.Put(context.Background(), prefix+"small", "tiny")
+	s.NoError(err)
+	// written before compression, without the header
+	_, err = s.cli.Put(context.Background(), prefix+"legacy", big)
+	s.NoError(err)
+
+	raw, err := s.cli.Get(context.Background(), prefix+"big")
+	s.NoError(err)
+	s.Less(len(raw.Kvs[0].Value), len(big)/10)
+	raw, err = s.cli.Get(context.Background(), prefix+"small")
+	s.NoError(err)
+	s.Equal("tiny", string(raw.Kvs[0].Value))
+
+	getResp, err := c.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getResp.Kvs, 3)
+	s.Equal(big, string(getResp.Kvs[0].Value))
+	s.Equal(big, string(getResp.Kvs[1].Value))
+	s.Equal("tiny", string(getResp.Kvs[2].Value))
+
+	txnResp, err := c.Txn(context.Background()).
+		Then(clientv3.OpPut(prefix+"txn", big), clientv3.OpGet(prefix+"big")).
+		Commit()
+	s.NoError(err)
+	s.Equal(big, string(txnResp.Responses[1].GetResponseRange().Kvs[0].Value))
+
+	delResp, err := c.Delete(context.Background(), prefix+"txn", clientv3.WithPrevKV())
+	s.NoError(err)
+	s.Equal(big, string(delResp.PrevKvs[0].Value))
+}
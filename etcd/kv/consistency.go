@@ -0,0 +1,58 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ReadMode is the consistency a read asks for.
+//
+// A linearizable read goes through the leader and a quorum, so it sees every
+// write committed before it started. A serializable read is answered by
+// whichever member the client is talking to from its local state. It is
+// faster and keeps working without a leader, but can return data that is
+// already stale, and a later read may even see older data than an earlier
+// one if it lands on a member that is further behind.
+type ReadMode int
+
+const (
+	Linearizable ReadMode = iota
+	Serializable
+)
+
+func (m ReadMode) String() string {
+	switch m {
+	case Linearizable:
+		return "Linearizable"
+	case Serializable:
+		return "Serializable"
+	}
+	return "Unknown"
+}
+
+// Options returns the op options that select m.
+func (m ReadMode) Options() []clientv3.OpOption {
+	if m == Serializable {
+		return []clientv3.OpOption{clientv3.WithSerializable()}
+	}
+	return nil
+}
+
+// DefaultReadMode is the mode of reads that opt into the package default,
+// such as the seed reads of config.Loader and registry.Discovery when they
+// are created with WithDefaultReadMode. It should be set during
+// initialization.
+var DefaultReadMode = Linearizable
+
+// GetSerializable is a Get that may return stale data; see ReadMode.
+func GetSerializable(ctx context.Context, cli etcdx.KV, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return cli.Get(ctx, key, append(opts, clientv3.WithSerializable())...)
+}
+
+// GetLinearizable is a plain Get, which is linearizable; it only makes the
+// choice explicit at the call site.
+func GetLinearizable(ctx context.Context, cli etcdx.KV, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return cli.Get(ctx, key, opts...)
+}
@@ -0,0 +1,43 @@
+package kv_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// optsKV records the options of the last Get.
+type optsKV struct {
+	*fake.KV
+	opts []clientv3.OpOption
+}
+
+func (r *optsKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	r.opts = opts
+	return r.KV.Get(ctx, key, opts...)
+}
+
+func (r *optsKV) serializable() bool {
+	return clientv3.OpGet("", r.opts...).IsSerializable()
+}
+
+func (s *KVTestSuite) TestReadModes() {
+	store := &optsKV{KV: fake.NewKV()}
+	_, err := store.Put(context.Background(), "/k", "v")
+	s.NoError(err)
+
+	resp, err := kv.GetSerializable(context.Background(), store, "/k", clientv3.WithKeysOnly())
+	s.NoError(err)
+	s.Len(resp.Kvs, 1)
+	s.True(store.serializable())
+	s.True(clientv3.OpGet("", store.opts...).IsKeysOnly())
+
+	_, err = kv.GetLinearizable(context.Background(), store, "/k")
+	s.NoError(err)
+	s.False(store.serializable())
+
+	s.True(clientv3.OpGet("", kv.Serializable.Options()...).IsSerializable())
+	s.False(clientv3.OpGet("", kv.Linearizable.Options()...).IsSerializable())
+}
@@ -0,0 +1,26 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Count returns how many keys are under prefix without reading any of them.
+func Count(ctx context.Context, cli etcdx.KV, prefix string) (int64, error) {
+	resp, err := cli.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+// Exists reports whether key exists without reading its value.
+func Exists(ctx context.Context, cli etcdx.KV, key string) (bool, error) {
+	resp, err := cli.Get(ctx, key, clientv3.WithCountOnly())
+	if err != nil {
+		return false, err
+	}
+	return resp.Count > 0, nil
+}
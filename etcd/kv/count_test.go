@@ -0,0 +1,33 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestCountAndExists() {
+	prefix := "/test/kv/count/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	n, err := kv.Count(context.Background(), s.cli, prefix)
+	s.NoError(err)
+	s.Zero(n)
+
+	for i := 0; i < 7; i++ {
+		_, err := s.cli.Put(context.Background(), fmt.Sprintf("%s%d", prefix, i), "val")
+		s.NoError(err)
+	}
+	n, err = kv.Count(context.Background(), s.cli, prefix)
+	s.NoError(err)
+	s.Equal(int64(7), n)
+
+	ok, err := kv.Exists(context.Background(), s.cli, prefix+"3")
+	s.NoError(err)
+	s.True(ok)
+	ok, err = kv.Exists(context.Background(), s.cli, prefix+"absent")
+	s.NoError(err)
+	s.False(ok)
+}
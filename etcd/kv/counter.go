@@ -0,0 +1,56 @@
+package kv
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+)
+
+// Counter is an int64 stored as a decimal string under a single key. A
+// missing key counts as 0.
+type Counter struct {
+	cli etcdx.KV
+	key string
+}
+
+func NewCounter(cli etcdx.KV, key string) *Counter {
+	return &Counter{cli: cli, key: key}
+}
+
+// Inc adds delta and returns the new value. It is an Update without a retry
+// limit, so it is retried until no concurrent update gets in between.
+func (c *Counter) Inc(ctx context.Context, delta int64) (int64, error) {
+	var val int64
+	err := Update(ctx, c.cli, c.key, func(old []byte) ([]byte, error) {
+		cur, err := parseCounter(old)
+		if err != nil {
+			return nil, err
+		}
+		val = cur + delta
+		return []byte(strconv.FormatInt(val, 10)), nil
+	}, WithMaxRetries(0))
+	if err != nil {
+		return 0, err
+	}
+	return val, nil
+}
+
+func (c *Counter) Get(ctx context.Context) (int64, error) {
+	meta, err := Stat(ctx, c.cli, c.key)
+	if err == ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return parseCounter(meta.Value)
+}
+
+// parseCounter parses a stored value; a missing key, given as nil, is 0.
+func parseCounter(val []byte) (int64, error) {
+	if val == nil {
+		return 0, nil
+	}
+	return strconv.ParseInt(string(val), 10, 64)
+}
@@ -0,0 +1,57 @@
+package kv_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+)
+
+func (s *KVTestSuite) TestCounter() {
+	key := "/test/kv/counter"
+	defer s.cli.Delete(context.Background(), key)
+
+	counter := kv.NewCounter(s.cli, key)
+	val, err := counter.Get(context.Background())
+	s.NoError(err)
+	s.Zero(val)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := counter.Inc(context.Background(), 1)
+			s.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	val, err = counter.Get(context.Background())
+	s.NoError(err)
+	s.Equal(int64(50), val)
+
+	val, err = counter.Inc(context.Background(), -10)
+	s.NoError(err)
+	s.Equal(int64(40), val)
+}
+
+func (s *KVTestSuite) TestCounterFake() {
+	counter := kv.NewCounter(fake.NewKV(), "counter")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := counter.Inc(context.Background(), 2)
+			s.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	val, err := counter.Get(context.Background())
+	s.NoError(err)
+	s.Equal(int64(100), val)
+}
@@ -0,0 +1,80 @@
+package kv
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultBatchSize = 100
+
+type deleteOptions struct {
+	batchSize int
+	interval  time.Duration
+}
+
+type DeleteOption func(*deleteOptions)
+
+// WithBatchSize sets how many keys a single delete request removes.
+func WithBatchSize(n int) DeleteOption {
+	return func(o *deleteOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithBatchInterval makes DeletePrefix pause for d between batches.
+func WithBatchInterval(d time.Duration) DeleteOption {
+	return func(o *deleteOptions) {
+		o.interval = d
+	}
+}
+
+// DeletePrefix deletes all keys under prefix in bounded batches rather than in
+// one huge request, and returns how many keys it deleted. Keys added under the
+// prefix meanwhile are deleted too: it only returns once a page comes back
+// empty. If ctx is done, the count deleted so far is returned with ctx.Err().
+func DeletePrefix(ctx context.Context, cli etcdx.KV, prefix string, opts ...DeleteOption) (int64, error) {
+	o := deleteOptions{batchSize: defaultBatchSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var deleted int64
+	for {
+		kvs, _, err := NewRangeIterator(cli, prefix, WithPageSize(o.batchSize), WithKeysOnly()).Next(ctx)
+		if err != nil {
+			return deleted, err
+		}
+		if len(kvs) == 0 {
+			return deleted, nil
+		}
+
+		first, last := string(kvs[0].Key), string(kvs[len(kvs)-1].Key)
+		resp, err := cli.Delete(ctx, first, clientv3.WithRange(last+"\x00"))
+		if err != nil {
+			return deleted, err
+		}
+		deleted += resp.Deleted
+
+		if o.interval > 0 {
+			select {
+			case <-time.After(o.interval):
+			case <-ctx.Done():
+				return deleted, ctx.Err()
+			}
+		}
+	}
+}
+
+// DeleteReturning deletes key, or the range opts select such as WithPrefix,
+// and returns the pairs as they were right before the delete, in key order.
+func DeleteReturning(ctx context.Context, cli etcdx.KV, key string, opts ...clientv3.OpOption) ([]*mvccpb.KeyValue, error) {
+	resp, err := cli.Delete(ctx, key, append(opts, clientv3.WithPrevKV())...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.PrevKvs, nil
+}
@@ -0,0 +1,71 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestDeletePrefix() {
+	prefix := "/test/kv/delete/"
+	put := func(n int) {
+		for i := 0; i < n; i++ {
+			_, err := s.cli.Put(context.Background(), fmt.Sprintf("%skey%04d", prefix, i), "val")
+			s.NoError(err)
+		}
+	}
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	put(500)
+	deleted, err := kv.DeletePrefix(context.Background(), s.cli, prefix, kv.WithBatchSize(50))
+	s.NoError(err)
+	s.Equal(int64(500), deleted)
+
+	resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(resp.Count)
+
+	s.Run("Cancelled", func() {
+		put(100)
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+
+		deleted, err := kv.DeletePrefix(ctx, s.cli, prefix, kv.WithBatchSize(10), kv.WithBatchInterval(100*time.Millisecond))
+		s.ErrorIs(err, context.DeadlineExceeded)
+		s.Less(deleted, int64(100))
+
+		resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+		s.NoError(err)
+		s.Equal(100-deleted, resp.Count)
+	})
+}
+
+func (s *KVTestSuite) TestDeleteReturning() {
+	prefix := "/test/kv/deletereturning/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	for _, k := range []string{"a", "b", "c"} {
+		_, err := s.cli.Put(context.Background(), prefix+k, "val-"+k)
+		s.Require().NoError(err)
+	}
+
+	kvs, err := kv.DeleteReturning(context.Background(), s.cli, prefix+"b")
+	s.NoError(err)
+	s.Require().Len(kvs, 1)
+	s.Equal(prefix+"b", string(kvs[0].Key))
+	s.Equal("val-b", string(kvs[0].Value))
+
+	kvs, err = kv.DeleteReturning(context.Background(), s.cli, prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Require().Len(kvs, 2)
+	s.Equal(prefix+"a", string(kvs[0].Key))
+	s.Equal("val-a", string(kvs[0].Value))
+	s.Equal(prefix+"c", string(kvs[1].Key))
+	s.Equal("val-c", string(kvs[1].Value))
+
+	kvs, err = kv.DeleteReturning(context.Background(), s.cli, prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Empty(kvs)
+}
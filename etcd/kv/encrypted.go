@@ -0,0 +1,155 @@
+package kv
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrUnknownKeyVersion = errors.New("kv: value encrypted with unknown key version")
+	ErrTooManyKeys       = errors.New("kv: no key versions left")
+	ErrNotEncrypted      = errors.New("kv: value is not encrypted")
+)
+
+// Encrypted decorates a clientv3.KV, encrypting values with AES-GCM on the
+// way in and decrypting them on the way out. Every value is stored as a
+// key-version byte, a random nonce and the ciphertext, so values written
+// before a RotateKey can still be read.
+type Encrypted struct {
+	codecKV
+	aead *aeadCodec
+}
+
+// NewEncrypted encrypts with key, which must be 16, 24 or 32 bytes long to
+// select AES-128, AES-192 or AES-256. It becomes key version 1.
+func NewEncrypted(kv clientv3.KV, key []byte) (*Encrypted, error) {
+	c := &aeadCodec{keys: make(map[byte]cipher.AEAD)}
+	if err := c.add(key); err != nil {
+		return nil, err
+	}
+	return &Encrypted{codecKV: codecKV{KV: kv, codec: c}, aead: c}, nil
+}
+
+// RotateKey encrypts all further writes with newKey under the next key
+// version. Earlier keys are kept for reading; ReEncryptPrefix moves existing
+// values over to newKey.
+func (e *Encrypted) RotateKey(newKey []byte) error {
+	return e.aead.add(newKey)
+}
+
+// ReEncryptPrefix rewrites every value under prefix that is not encrypted
+// with the current key, and returns how many it rewrote. Each rewrite is
+// guarded on the ModRevision it was read at and keeps the lease; a value
+// that changed in between was rewritten by that change and is skipped. It
+// can run in the background while the prefix is in use.
+func (e *Encrypted) ReEncryptPrefix(ctx context.Context, prefix string) (int, error) {
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	next := prefix
+	var n int
+	for {
+		getResp, err := e.KV.Get(ctx, next, clientv3.WithRange(end), clientv3.WithLimit(defaultPageSize))
+		if err != nil {
+			return n, err
+		}
+		for _, kv := range getResp.Kvs {
+			if e.aead.current(kv.Value) {
+				continue
+			}
+			val, err := e.aead.decode(kv.Value)
+			if err != nil {
+				return n, fmt.Errorf("%w: %s", err, kv.Key)
+			}
+			enc, err := e.aead.encode(val)
+			if err != nil {
+				return n, err
+			}
+			txnResp, err := e.KV.Txn(ctx).
+				If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+				Then(clientv3.OpPut(string(kv.Key), string(enc), clientv3.WithLease(clientv3.LeaseID(kv.Lease)))).
+				Commit()
+			if err != nil {
+				return n, err
+			}
+			if txnResp.Succeeded {
+				n++
+			}
+		}
+		if !getResp.More {
+			return n, nil
+		}
+		next = string(getResp.Kvs[len(getResp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+type aeadCodec struct {
+	mu      sync.RWMutex
+	keys    map[byte]cipher.AEAD
+	version byte
+}
+
+func (c *aeadCodec) add(key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version == 255 {
+		return ErrTooManyKeys
+	}
+	c.version++
+	c.keys[c.version] = aead
+	return nil
+}
+
+// current reports whether val is encrypted with the current key.
+func (c *aeadCodec) current(val []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(val) > 0 && val[0] == c.version
+}
+
+func (c *aeadCodec) encode(val []byte) ([]byte, error) {
+	c.mu.RLock()
+	version, aead := c.version, c.keys[c.version]
+	c.mu.RUnlock()
+
+	out := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(val)+aead.Overhead())
+	out[0] = version
+	if _, err := io.ReadFull(rand.Reader, out[1:]); err != nil {
+		return nil, err
+	}
+	// the version byte is authenticated along with the value
+	return aead.Seal(out, out[1:], val, out[:1]), nil
+}
+
+func (c *aeadCodec) decode(val []byte) ([]byte, error) {
+	// nothing to decrypt in keys-only reads
+	if len(val) == 0 {
+		return val, nil
+	}
+	c.mu.RLock()
+	aead, ok := c.keys[val[0]]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownKeyVersion, val[0])
+	}
+	if len(val) < 1+aead.NonceSize() {
+		return nil, ErrNotEncrypted
+	}
+	nonce, ciphertext := val[1:1+aead.NonceSize()], val[1+aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, val[:1])
+}
@@ -0,0 +1,68 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestEncrypted() {
+	prefix := "/test/kv/encrypted/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	e, err := kv.NewEncrypted(s.cli.KV, bytes.Repeat([]byte{1}, 32))
+	s.Require().NoError(err)
+	_, err = e.Put(context.Background(), prefix+"a", "secret-a")
+	s.NoError(err)
+
+	raw, err := s.cli.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.NotContains(string(raw.Kvs[0].Value), "secret")
+	s.Equal(byte(1), raw.Kvs[0].Value[0])
+
+	getResp, err := e.Get(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal("secret-a", string(getResp.Kvs[0].Value))
+
+	// another key cannot read it
+	other, err := kv.NewEncrypted(s.cli.KV, bytes.Repeat([]byte{2}, 32))
+	s.Require().NoError(err)
+	_, err = other.Get(context.Background(), prefix+"a")
+	s.Error(err)
+}
+
+func (s *KVTestSuite) TestEncryptedRotateKey() {
+	prefix := "/test/kv/encrypted/rotate/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	e, err := kv.NewEncrypted(s.cli.KV, bytes.Repeat([]byte{1}, 16))
+	s.Require().NoError(err)
+	_, err = e.Put(context.Background(), prefix+"old", "v1")
+	s.NoError(err)
+
+	s.NoError(e.RotateKey(bytes.Repeat([]byte{2}, 16)))
+	_, err = e.Put(context.Background(), prefix+"new", "v2")
+	s.NoError(err)
+
+	getResp, err := e.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getResp.Kvs, 2)
+	s.Equal("v2", string(getResp.Kvs[0].Value))
+	s.Equal("v1", string(getResp.Kvs[1].Value))
+
+	n, err := e.ReEncryptPrefix(context.Background(), prefix)
+	s.NoError(err)
+	s.Equal(1, n)
+	raw, err := s.cli.Get(context.Background(), prefix+"old")
+	s.NoError(err)
+	s.Equal(byte(2), raw.Kvs[0].Value[0])
+	getResp, err = e.Get(context.Background(), prefix+"old")
+	s.NoError(err)
+	s.Equal("v1", string(getResp.Kvs[0].Value))
+
+	n, err = e.ReEncryptPrefix(context.Background(), prefix)
+	s.NoError(err)
+	s.Zero(n)
+}
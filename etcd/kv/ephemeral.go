@@ -0,0 +1,143 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Ephemeral is a key that lives as long as the process that wrote it, for
+// callers who do not want to manage a lease themselves. It is attached to a
+// lease of its own that is kept alive until Close.
+//
+// If the keep-alive is lost, say because the process was cut off from the
+// cluster for longer than the TTL, the key is gone with its lease: Ephemeral
+// then grants a fresh lease and writes the key again, retrying every second
+// until it succeeds or Close is called.
+type Ephemeral struct {
+	cli *clientv3.Client
+	key string
+	ttl int64
+
+	mu      sync.Mutex
+	val     []byte
+	leaseID clientv3.LeaseID
+	// kaCancel stops the keep-alive of leaseID
+	kaCancel context.CancelFunc
+
+	lost   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEphemeral puts key under a lease of ttl, rounded up to whole seconds,
+// and keeps it alive until Close.
+func NewEphemeral(ctx context.Context, cli *clientv3.Client, key string, val []byte, ttl time.Duration) (*Ephemeral, error) {
+	runCtx, cancel := context.WithCancel(context.Background())
+	e := &Ephemeral{
+		cli:    cli,
+		key:    key,
+		ttl:    int64(math.Max(1, math.Ceil(ttl.Seconds()))),
+		val:    val,
+		lost:   make(chan struct{}, 1),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if err := e.attach(ctx, runCtx); err != nil {
+		cancel()
+		return nil, err
+	}
+	go e.run(runCtx)
+	return e, nil
+}
+
+// Update replaces the value, keeping the key on its lease. If the lease was
+// just lost the new value is kept and written when the key is restored.
+func (e *Ephemeral) Update(ctx context.Context, val []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.val = val
+	_, err := e.cli.Put(ctx, e.key, string(val), clientv3.WithLease(e.leaseID))
+	if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Close stops restoring the key and revokes its lease, deleting the key.
+func (e *Ephemeral) Close(ctx context.Context) error {
+	e.cancel()
+	<-e.done
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.kaCancel()
+	_, err := e.cli.Revoke(ctx, e.leaseID)
+	if errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return nil
+	}
+	return err
+}
+
+// attach grants a lease, puts the key under it and keeps it alive until
+// runCtx is done, reporting on lost if the keep-alive ends before that.
+func (e *Ephemeral) attach(ctx, runCtx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	resp, err := e.cli.Grant(ctx, e.ttl)
+	if err != nil {
+		return err
+	}
+	if _, err := e.cli.Put(ctx, e.key, string(e.val), clientv3.WithLease(resp.ID)); err != nil {
+		e.cli.Revoke(context.Background(), resp.ID)
+		return err
+	}
+	kaCtx, cancel := context.WithCancel(runCtx)
+	keepChan, err := e.cli.KeepAlive(kaCtx, resp.ID)
+	if err != nil {
+		cancel()
+		e.cli.Revoke(context.Background(), resp.ID)
+		return err
+	}
+	go func() {
+		for range keepChan {
+		}
+		if kaCtx.Err() == nil {
+			select {
+			case e.lost <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	if e.kaCancel != nil {
+		e.kaCancel()
+	}
+	e.leaseID, e.kaCancel = resp.ID, cancel
+	return nil
+}
+
+func (e *Ephemeral) run(ctx context.Context) {
+	defer close(e.done)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.lost:
+		}
+		for e.attach(ctx, ctx) != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
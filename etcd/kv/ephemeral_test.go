@@ -0,0 +1,41 @@
+package kv_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestEphemeral() {
+	key := "/test/kv/ephemeral"
+	defer s.cli.Delete(context.Background(), key)
+
+	e, err := kv.NewEphemeral(context.Background(), s.cli, key, []byte("v1"), 2*time.Second)
+	s.Require().NoError(err)
+
+	// outlives its TTL while kept alive
+	time.Sleep(3 * time.Second)
+	s.NoError(e.Update(context.Background(), []byte("v2")))
+	resp, err := s.cli.Get(context.Background(), key)
+	s.Require().NoError(err)
+	s.Require().Len(resp.Kvs, 1)
+	s.Equal("v2", string(resp.Kvs[0].Value))
+
+	// revoking the lease behind its back loses the keep-alive, and the key
+	// comes back under a fresh lease
+	lost := clientv3.LeaseID(resp.Kvs[0].Lease)
+	_, err = s.cli.Revoke(context.Background(), lost)
+	s.Require().NoError(err)
+	s.Eventually(func() bool {
+		resp, err := s.cli.Get(context.Background(), key)
+		return err == nil && len(resp.Kvs) == 1 && clientv3.LeaseID(resp.Kvs[0].Lease) != lost &&
+			string(resp.Kvs[0].Value) == "v2"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	s.NoError(e.Close(context.Background()))
+	resp, err = s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Empty(resp.Kvs)
+}
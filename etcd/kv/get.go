@@ -0,0 +1,53 @@
+package kv
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+)
+
+// GetOrDefault returns the value of key, or def if the key does not exist. A
+// key with an empty value exists, so its empty value is returned.
+func GetOrDefault(ctx context.Context, cli etcdx.KV, key string, def []byte) ([]byte, error) {
+	val, ok, err := get(ctx, cli, key)
+	if err != nil || !ok {
+		return def, err
+	}
+	return val, nil
+}
+
+// GetString is GetOrDefault for string values.
+func GetString(ctx context.Context, cli etcdx.KV, key, def string) (string, error) {
+	val, ok, err := get(ctx, cli, key)
+	if err != nil || !ok {
+		return def, err
+	}
+	return string(val), nil
+}
+
+// GetInt64 returns the value of key parsed as a decimal int64, or def if the
+// key does not exist. An existing key that does not parse, including one with
+// an empty value, is an error.
+func GetInt64(ctx context.Context, cli etcdx.KV, key string, def int64) (int64, error) {
+	val, ok, err := get(ctx, cli, key)
+	if err != nil || !ok {
+		return def, err
+	}
+	return strconv.ParseInt(string(val), 10, 64)
+}
+
+func get(ctx context.Context, cli etcdx.KV, key string) ([]byte, bool, error) {
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, false, nil
+	}
+	// an empty value is decoded as nil
+	if resp.Kvs[0].Value == nil {
+		return []byte{}, true, nil
+	}
+	return resp.Kvs[0].Value, true, nil
+}
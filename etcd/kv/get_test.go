@@ -0,0 +1,65 @@
+package kv_test
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestGetOrDefault() {
+	prefix := "/test/kv/get/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	_, err := s.cli.Put(context.Background(), prefix+"present", "val")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"empty", "")
+	s.NoError(err)
+
+	val, err := kv.GetOrDefault(context.Background(), s.cli, prefix+"present", []byte("def"))
+	s.NoError(err)
+	s.Equal("val", string(val))
+
+	val, err = kv.GetOrDefault(context.Background(), s.cli, prefix+"absent", []byte("def"))
+	s.NoError(err)
+	s.Equal("def", string(val))
+
+	str, err := kv.GetString(context.Background(), s.cli, prefix+"empty", "def")
+	s.NoError(err)
+	s.Equal("", str)
+
+	val, err = kv.GetOrDefault(context.Background(), s.cli, prefix+"empty", []byte("def"))
+	s.NoError(err)
+	s.Equal([]byte{}, val)
+
+	str, err = kv.GetString(context.Background(), s.cli, prefix+"absent", "def")
+	s.NoError(err)
+	s.Equal("def", str)
+}
+
+func (s *KVTestSuite) TestGetInt64() {
+	prefix := "/test/kv/get-int64/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	_, err := s.cli.Put(context.Background(), prefix+"present", "-42")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"bad", "forty-two")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"empty", "")
+	s.NoError(err)
+
+	n, err := kv.GetInt64(context.Background(), s.cli, prefix+"present", 7)
+	s.NoError(err)
+	s.Equal(int64(-42), n)
+
+	n, err = kv.GetInt64(context.Background(), s.cli, prefix+"absent", 7)
+	s.NoError(err)
+	s.Equal(int64(7), n)
+
+	_, err = kv.GetInt64(context.Background(), s.cli, prefix+"bad", 7)
+	s.ErrorIs(err, strconv.ErrSyntax)
+
+	_, err = kv.GetInt64(context.Background(), s.cli, prefix+"empty", 7)
+	s.ErrorIs(err, strconv.ErrSyntax)
+}
@@ -0,0 +1,97 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type Revision struct {
+	// Rev is the revision the value was read at.
+	Rev         int64
+	Value       []byte
+	ModRevision int64
+}
+
+// History returns the values key has had, newest first, as of fromRev or of
+// the current revision if fromRev is 0. It reads the key once per value,
+// each time just before the last ModRevision seen, and stops at the
+// revision that created the key. When older revisions have been compacted
+// away, the values found until then are returned without an error.
+func History(ctx context.Context, cli *clientv3.Client, key string, fromRev int64) ([]Revision, error) {
+	var history []Revision
+	err := walkHistory(ctx, cli, key, fromRev, func(r Revision) bool {
+		history = append(history, r)
+		return true
+	})
+	return history, err
+}
+
+type recentOptions struct {
+	dedupe bool
+}
+
+type RecentOption func(*recentOptions)
+
+// WithDedupe skips a value equal to the next newer one, so that rewrites of
+// the same value do not use up the window.
+func WithDedupe() RecentOption {
+	return func(o *recentOptions) {
+		o.dedupe = true
+	}
+}
+
+// RecentValues is History bounded to the n newest values, reading no more
+// revisions than it returns.
+func RecentValues(ctx context.Context, cli *clientv3.Client, key string, n int, opts ...RecentOption) ([]Revision, error) {
+	var o recentOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var recent []Revision
+	if n <= 0 {
+		return recent, nil
+	}
+	err := walkHistory(ctx, cli, key, 0, func(r Revision) bool {
+		if o.dedupe && len(recent) > 0 && bytes.Equal(recent[len(recent)-1].Value, r.Value) {
+			return true
+		}
+		recent = append(recent, r)
+		return len(recent) < n
+	})
+	return recent, err
+}
+
+// walkHistory calls fn with the values of key, newest first, until fn
+// returns false or the history ends.
+func walkHistory(ctx context.Context, cli *clientv3.Client, key string, fromRev int64, fn func(Revision) bool) error {
+	rev := fromRev
+	for {
+		resp, err := cli.Get(ctx, key, clientv3.WithRev(rev))
+		if err == rpctypes.ErrCompacted {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(resp.Kvs) == 0 {
+			return nil
+		}
+
+		kv := resp.Kvs[0]
+		read := rev
+		if read == 0 {
+			read = resp.Header.Revision
+		}
+		if !fn(Revision{Rev: read, Value: kv.Value, ModRevision: kv.ModRevision}) {
+			return nil
+		}
+		if kv.ModRevision == kv.CreateRevision {
+			return nil
+		}
+		rev = kv.ModRevision - 1
+	}
+}
@@ -0,0 +1,99 @@
+package kv_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+)
+
+func (s *KVTestSuite) TestHistory() {
+	key := "/test/kv/history"
+	defer s.cli.Delete(context.Background(), key)
+
+	// a previous life of the key is not part of its history
+	_, err := s.cli.Put(context.Background(), key, "0")
+	s.NoError(err)
+	_, err = s.cli.Delete(context.Background(), key)
+	s.NoError(err)
+
+	var revs []int64
+	for _, val := range []string{"1", "2", "3"} {
+		putResp, err := s.cli.Put(context.Background(), key, val)
+		s.NoError(err)
+		revs = append(revs, putResp.Header.Revision)
+		// unrelated revisions in between
+		_, err = s.cli.Put(context.Background(), key+"/other", val)
+		s.NoError(err)
+	}
+	defer s.cli.Delete(context.Background(), key+"/other")
+
+	history, err := kv.History(context.Background(), s.cli, key, 0)
+	s.NoError(err)
+	s.Len(history, 3)
+	for i, want := range []string{"3", "2", "1"} {
+		s.Equal(want, string(history[i].Value))
+		s.Equal(revs[2-i], history[i].ModRevision)
+	}
+	s.Equal(revs[1]-1, history[2].Rev)
+
+	history, err = kv.History(context.Background(), s.cli, key, revs[1])
+	s.NoError(err)
+	s.Len(history, 2)
+	s.Equal(revs[1], history[0].Rev)
+	s.Equal("2", string(history[0].Value))
+}
+
+func (s *KVTestSuite) TestHistoryCompacted() {
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+
+	key := "/test/kv/history"
+	for _, val := range []string{"1", "2", "3"} {
+		_, err := cli.Put(context.Background(), key, val)
+		s.NoError(err)
+	}
+	getResp, err := cli.Get(context.Background(), key)
+	s.NoError(err)
+	_, err = cli.Compact(context.Background(), getResp.Kvs[0].ModRevision-1)
+	s.NoError(err)
+
+	// the first value is gone with the compacted revisions
+	history, err := kv.History(context.Background(), cli, key, 0)
+	s.NoError(err)
+	s.Len(history, 2)
+	s.Equal("3", string(history[0].Value))
+	s.Equal("2", string(history[1].Value))
+}
+
+func (s *KVTestSuite) TestRecentValues() {
+	key := "/test/kv/history/recent"
+	defer s.cli.Delete(context.Background(), key)
+
+	for _, val := range []string{"1", "2", "3", "4", "5"} {
+		_, err := s.cli.Put(context.Background(), key, val)
+		s.NoError(err)
+	}
+
+	recent, err := kv.RecentValues(context.Background(), s.cli, key, 3)
+	s.NoError(err)
+	s.Len(recent, 3)
+	for i, want := range []string{"5", "4", "3"} {
+		s.Equal(want, string(recent[i].Value))
+	}
+
+	recent, err = kv.RecentValues(context.Background(), s.cli, key, 10)
+	s.NoError(err)
+	s.Len(recent, 5)
+
+	for _, val := range []string{"5", "5", "6", "6"} {
+		_, err := s.cli.Put(context.Background(), key, val)
+		s.NoError(err)
+	}
+	recent, err = kv.RecentValues(context.Background(), s.cli, key, 3, kv.WithDedupe())
+	s.NoError(err)
+	s.Len(recent, 3)
+	for i, want := range []string{"6", "5", "4"} {
+		s.Equal(want, string(recent[i].Value))
+	}
+}
@@ -0,0 +1,99 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultPageSize = 100
+
+type rangeOptions struct {
+	pageSize int64
+	keysOnly bool
+}
+
+type RangeOption func(*rangeOptions)
+
+// WithPageSize sets how many keys a single page holds.
+func WithPageSize(n int) RangeOption {
+	return func(o *rangeOptions) {
+		o.pageSize = int64(n)
+	}
+}
+
+// WithKeysOnly leaves values out of the returned pages.
+func WithKeysOnly() RangeOption {
+	return func(o *rangeOptions) {
+		o.keysOnly = true
+	}
+}
+
+func newRangeOptions(opts []RangeOption) rangeOptions {
+	o := rangeOptions{pageSize: defaultPageSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// RangeIterator pages through all keys under a prefix in key order. Every
+// page is read at the revision of the first one, so concurrent writes cause
+// neither duplicates nor skips.
+type RangeIterator struct {
+	cli  etcdx.KV
+	end  string
+	opts rangeOptions
+
+	next string
+	rev  int64
+	done bool
+}
+
+func NewRangeIterator(cli etcdx.KV, prefix string, opts ...RangeOption) *RangeIterator {
+	return &RangeIterator{
+		cli:  cli,
+		end:  clientv3.GetPrefixRangeEnd(prefix),
+		opts: newRangeOptions(opts),
+		next: prefix,
+	}
+}
+
+// Next returns the next page and whether more pages follow.
+func (it *RangeIterator) Next(ctx context.Context) ([]*mvccpb.KeyValue, bool, error) {
+	if it.done {
+		return nil, false, nil
+	}
+
+	opts := []clientv3.OpOption{
+		clientv3.WithRange(it.end),
+		clientv3.WithLimit(it.opts.pageSize),
+		clientv3.WithSort(clientv3.SortByKey, clientv3.SortAscend),
+	}
+	if it.rev != 0 {
+		opts = append(opts, clientv3.WithRev(it.rev))
+	}
+	if it.opts.keysOnly {
+		opts = append(opts, clientv3.WithKeysOnly())
+	}
+
+	resp, err := it.cli.Get(ctx, it.next, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	if it.rev == 0 {
+		it.rev = resp.Header.Revision
+	}
+	if len(resp.Kvs) > 0 {
+		it.next = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+	it.done = !resp.More
+	return resp.Kvs, resp.More, nil
+}
+
+// Rev returns the revision pages are read at, or 0 before the first page.
+func (it *RangeIterator) Rev() int64 {
+	return it.rev
+}
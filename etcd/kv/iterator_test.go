@@ -0,0 +1,57 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestRangeIterator() {
+	prefix := "/test/kv/iterator/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	var want []string
+	for i := 0; i < 250; i++ {
+		key := fmt.Sprintf("%skey%04d", prefix, i)
+		_, err := s.cli.Put(context.Background(), key, "val")
+		s.NoError(err)
+		want = append(want, key)
+	}
+
+	it := kv.NewRangeIterator(s.cli, prefix, kv.WithPageSize(100))
+	var got []string
+	var pages []int
+	for {
+		kvs, more, err := it.Next(context.Background())
+		s.NoError(err)
+		pages = append(pages, len(kvs))
+		for _, kv := range kvs {
+			got = append(got, string(kv.Key))
+			s.Equal("val", string(kv.Value))
+		}
+
+		// writes after the first page are not seen
+		_, err = s.cli.Put(context.Background(), prefix+"key0000a", "new")
+		s.NoError(err)
+
+		if !more {
+			break
+		}
+	}
+	s.Equal([]int{100, 100, 50}, pages)
+	s.Equal(want, got)
+
+	kvs, more, err := it.Next(context.Background())
+	s.NoError(err)
+	s.False(more)
+	s.Empty(kvs)
+
+	s.Run("Keys only", func() {
+		kvs, _, err := kv.NewRangeIterator(s.cli, prefix, kv.WithPageSize(10), kv.WithKeysOnly()).Next(context.Background())
+		s.NoError(err)
+		s.Len(kvs, 10)
+		s.Empty(kvs[0].Value)
+	})
+}
@@ -0,0 +1,31 @@
+package kv_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type KVTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestKVTestSuite(t *testing.T) {
+	suite.Run(t, new(KVTestSuite))
+}
+
+func (s *KVTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *KVTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
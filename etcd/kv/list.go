@@ -0,0 +1,51 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+)
+
+// maxListBytes keeps a list value, and the txn writing it, well under etcd's
+// default --max-request-bytes of 1.5MiB.
+const maxListBytes = 1 << 20
+
+var (
+	ErrListTooLarge = errors.New("kv: list value too large, keep the items as keys under a prefix instead")
+	ErrItemHasSep   = errors.New("kv: list item contains the separator")
+)
+
+// Append adds item to the end of the sep-separated list stored at key,
+// creating the key if missing. It is an Update, so concurrent appends retry
+// and none is lost. The list is one value, rewritten whole on every append:
+// keep it to small sets, and past 1MiB ErrListTooLarge is returned.
+func Append(ctx context.Context, cli etcdx.KV, key string, item []byte, sep byte) error {
+	if bytes.IndexByte(item, sep) >= 0 {
+		return ErrItemHasSep
+	}
+	return Update(ctx, cli, key, func(old []byte) ([]byte, error) {
+		val := item
+		if len(old) > 0 {
+			val = append(append(append([]byte{}, old...), sep), item...)
+		}
+		if len(val) > maxListBytes {
+			return nil, ErrListTooLarge
+		}
+		return val, nil
+	})
+}
+
+// ListItems returns the items of the list stored at key, in the order they
+// were appended, or none if the key is missing.
+func ListItems(ctx context.Context, cli etcdx.KV, key string, sep byte) ([][]byte, error) {
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 || len(resp.Kvs[0].Value) == 0 {
+		return nil, nil
+	}
+	return bytes.Split(resp.Kvs[0].Value, []byte{sep}), nil
+}
@@ -0,0 +1,60 @@
+package kv_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+)
+
+func (s *KVTestSuite) TestAppend() {
+	key := "/test/kv/list"
+	defer s.cli.Delete(context.Background(), key)
+
+	items, err := kv.ListItems(context.Background(), s.cli, key, ',')
+	s.NoError(err)
+	s.Empty(items)
+
+	// 5 writers appending 10 items each
+	var wg sync.WaitGroup
+	for w := 0; w < 5; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				s.NoError(kv.Append(context.Background(), s.cli, key, []byte(fmt.Sprintf("w%d-%d", w, i)), ','))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	items, err = kv.ListItems(context.Background(), s.cli, key, ',')
+	s.NoError(err)
+	s.Len(items, 50)
+	next := make([]int, 5)
+	for _, item := range items {
+		var w, i int
+		_, err := fmt.Sscanf(string(item), "w%d-%d", &w, &i)
+		s.Require().NoError(err)
+		s.Equal(next[w], i, "items of a writer keep their order")
+		next[w]++
+	}
+
+	s.ErrorIs(kv.Append(context.Background(), s.cli, key, []byte("a,b"), ','), kv.ErrItemHasSep)
+}
+
+func (s *KVTestSuite) TestAppendTooLarge() {
+	store := fake.NewKV()
+	big := []byte(strings.Repeat("x", 512<<10))
+	s.NoError(kv.Append(context.Background(), store, "list", big, '\n'))
+	s.ErrorIs(kv.Append(context.Background(), store, "list", big, '\n'), kv.ErrListTooLarge)
+
+	items, err := kv.ListItems(context.Background(), store, "list", '\n')
+	s.NoError(err)
+	s.Len(items, 1)
+	s.True(bytes.Equal(big, items[0]))
+}
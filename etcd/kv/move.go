@@ -0,0 +1,74 @@
+package kv
+
+import (
+	"context"
+	"errors"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrSourceChanged     = errors.New("kv: source changed during move")
+	ErrDestinationExists = errors.New("kv: destination exists")
+)
+
+type moveOptions struct {
+	overwrite bool
+}
+
+type MoveOption func(*moveOptions)
+
+// WithOverwrite sets whether Move may replace an existing destination, which
+// it does by default. Without it Move fails with ErrDestinationExists.
+func WithOverwrite(overwrite bool) MoveOption {
+	return func(o *moveOptions) {
+		o.overwrite = overwrite
+	}
+}
+
+// Move renames src to dst: in one txn the value, and the lease if any, is
+// put to dst and src is deleted. The txn is guarded on the ModRevision src
+// was read at, so a concurrent change to src aborts the move with
+// ErrSourceChanged rather than moving a stale value. A missing src is
+// ErrKeyNotFound.
+func Move(ctx context.Context, cli *clientv3.Client, src, dst string, opts ...MoveOption) error {
+	o := moveOptions{overwrite: true}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	meta, err := Stat(ctx, cli, src)
+	if err != nil {
+		return err
+	}
+
+	cmps := []clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(src), "=", meta.ModRevision)}
+	if !o.overwrite {
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(dst), "=", 0))
+	}
+	var putOpts []clientv3.OpOption
+	if meta.HasLease() {
+		putOpts = append(putOpts, clientv3.WithLease(meta.Lease))
+	}
+	resp, err := cli.Txn(ctx).
+		If(cmps...).
+		Then(clientv3.OpPut(dst, string(meta.Value), putOpts...), clientv3.OpDelete(src)).
+		Else(clientv3.OpGet(src, clientv3.WithKeysOnly())).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if resp.Succeeded {
+		return nil
+	}
+
+	// tell which guard failed
+	srcKvs := resp.Responses[0].GetResponseRange().Kvs
+	if len(srcKvs) == 0 {
+		return ErrKeyNotFound
+	}
+	if srcKvs[0].ModRevision != meta.ModRevision {
+		return ErrSourceChanged
+	}
+	return ErrDestinationExists
+}
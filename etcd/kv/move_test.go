@@ -0,0 +1,79 @@
+package kv_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestMove() {
+	prefix := "/test/kv/move/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	leaseResp, err := s.cli.Grant(context.Background(), 10)
+	s.NoError(err)
+	defer s.cli.Revoke(context.Background(), leaseResp.ID)
+	_, err = s.cli.Put(context.Background(), prefix+"src", "val", clientv3.WithLease(leaseResp.ID))
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"dst", "old")
+	s.NoError(err)
+
+	s.NoError(kv.Move(context.Background(), s.cli, prefix+"src", prefix+"dst"))
+	_, err = kv.Stat(context.Background(), s.cli, prefix+"src")
+	s.Equal(kv.ErrKeyNotFound, err)
+	meta, err := kv.Stat(context.Background(), s.cli, prefix+"dst")
+	s.NoError(err)
+	s.Equal("val", string(meta.Value))
+	s.Equal(leaseResp.ID, meta.Lease)
+
+	s.Equal(kv.ErrKeyNotFound, kv.Move(context.Background(), s.cli, prefix+"src", prefix+"dst"))
+}
+
+func (s *KVTestSuite) TestMoveDestinationExists() {
+	prefix := "/test/kv/move/exists/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"src", "val")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"dst", "old")
+	s.NoError(err)
+
+	s.Equal(kv.ErrDestinationExists, kv.Move(context.Background(), s.cli, prefix+"src", prefix+"dst", kv.WithOverwrite(false)))
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(2), getResp.Count)
+
+	_, err = s.cli.Delete(context.Background(), prefix+"dst")
+	s.NoError(err)
+	s.NoError(kv.Move(context.Background(), s.cli, prefix+"src", prefix+"dst", kv.WithOverwrite(false)))
+}
+
+// changingKV changes the source once between Move reading and moving it.
+type changingKV struct {
+	clientv3.KV
+	once sync.Once
+}
+
+func (c *changingKV) Txn(ctx context.Context) clientv3.Txn {
+	c.once.Do(func() {
+		c.KV.Put(ctx, "/test/kv/move/changed/src", "new")
+	})
+	return c.KV.Txn(ctx)
+}
+
+func (s *KVTestSuite) TestMoveSourceChanged() {
+	prefix := "/test/kv/move/changed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"src", "val")
+	s.NoError(err)
+
+	cli := clientv3.NewCtxClient(context.Background())
+	cli.KV = &changingKV{KV: s.cli.KV}
+	s.Equal(kv.ErrSourceChanged, kv.Move(context.Background(), cli, prefix+"src", prefix+"dst"))
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getResp.Kvs, 1)
+	s.Equal("new", string(getResp.Kvs[0].Value))
+}
@@ -0,0 +1,130 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/cluster"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrTooManyOps = errors.New("kv: too many ops for one txn")
+
+type putAllOptions struct {
+	atomic bool
+}
+
+type PutAllOption func(*putAllOptions)
+
+// WithAtomic makes PutAll write every key in a single txn, so that either all
+// of them are written or none is. The txn is limited to the ops the cluster
+// allows, see cluster.MaxTxnOps.
+func WithAtomic() PutAllOption {
+	return func(o *putAllOptions) {
+		o.atomic = true
+	}
+}
+
+// PutAllError lists the keys a best-effort PutAll failed to write.
+type PutAllError struct {
+	Errs map[string]error
+}
+
+func (e *PutAllError) Error() string {
+	keys := make([]string, 0, len(e.Errs))
+	for key := range e.Errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("%s: %v", key, e.Errs[key])
+	}
+	return fmt.Sprintf("kv: %d of the puts failed: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+func (e *PutAllError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// PutAll writes every key of kvs, in key order. By default each key is its
+// own put, and the keys that fail are reported in a *PutAllError while the
+// others are written; see WithAtomic to write them all or none.
+func PutAll(ctx context.Context, cli etcdx.KV, kvs map[string][]byte, opts ...PutAllOption) error {
+	var o putAllOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if o.atomic {
+		if max, _ := cluster.MaxTxnOps(ctx, cli); len(keys) > max {
+			return fmt.Errorf("%w: %d puts, at most %d", ErrTooManyOps, len(keys), max)
+		}
+		ops := make([]clientv3.Op, len(keys))
+		for i, key := range keys {
+			ops[i] = clientv3.OpPut(key, string(kvs[key]))
+		}
+		_, err := cli.Txn(ctx).Then(ops...).Commit()
+		return err
+	}
+
+	errs := make(map[string]error)
+	for _, key := range keys {
+		if _, err := cli.Put(ctx, key, string(kvs[key])); err != nil {
+			errs[key] = err
+		}
+	}
+	if len(errs) > 0 {
+		return &PutAllError{Errs: errs}
+	}
+	return nil
+}
+
+// PutIfAbsent puts key only if it does not exist, and reports whether it did.
+// Otherwise the key is left alone and its current value is returned. opts
+// apply to the put, to attach a lease for instance.
+func PutIfAbsent(ctx context.Context, cli etcdx.KV, key string, val []byte, opts ...clientv3.OpOption) (bool, []byte, error) {
+	resp, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(val), opts...)).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	if err != nil {
+		return false, nil, err
+	}
+	if resp.Succeeded {
+		return true, nil, nil
+	}
+	// the compare failed, so the get in the same txn finds the key
+	return false, resp.Responses[0].GetResponseRange().Kvs[0].Value, nil
+}
+
+// PutIfMatches puts key only if its current value equals expected, and
+// reports whether it did. A missing key is a mismatch, unless expected is nil,
+// which requires the key not to exist; an empty non-nil expected matches an
+// existing empty value.
+func PutIfMatches(ctx context.Context, cli etcdx.KV, key string, expected, new []byte) (bool, error) {
+	cmp := clientv3.Compare(clientv3.Value(key), "=", string(expected))
+	if expected == nil {
+		cmp = clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+	}
+	resp, err := cli.Txn(ctx).If(cmp).Then(clientv3.OpPut(key, string(new))).Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
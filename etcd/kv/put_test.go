@@ -0,0 +1,145 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestPutIfAbsent() {
+	key := "/test/kv/putifabsent"
+	defer s.cli.Delete(context.Background(), key)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var creators []string
+	var existing [][]byte
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val := fmt.Sprint("caller-", i)
+			created, old, err := kv.PutIfAbsent(context.Background(), s.cli, key, []byte(val))
+			s.NoError(err)
+			mu.Lock()
+			defer mu.Unlock()
+			if created {
+				s.Nil(old)
+				creators = append(creators, val)
+			} else {
+				existing = append(existing, old)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	s.Require().Len(creators, 1)
+	s.Len(existing, 9)
+	for _, old := range existing {
+		s.Equal(creators[0], string(old))
+	}
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal(creators[0], string(getResp.Kvs[0].Value))
+	s.Equal(int64(1), getResp.Kvs[0].Version)
+}
+
+func (s *KVTestSuite) TestPutIfAbsentLease() {
+	key := "/test/kv/putifabsent-lease"
+	defer s.cli.Delete(context.Background(), key)
+
+	lease, err := s.cli.Grant(context.Background(), 60)
+	s.Require().NoError(err)
+	created, _, err := kv.PutIfAbsent(context.Background(), s.cli, key, []byte("once"), clientv3.WithLease(lease.ID))
+	s.NoError(err)
+	s.True(created)
+
+	// revoking the lease removes the key, which can then be created again
+	_, err = s.cli.Revoke(context.Background(), lease.ID)
+	s.NoError(err)
+	created, _, err = kv.PutIfAbsent(context.Background(), s.cli, key, []byte("again"))
+	s.NoError(err)
+	s.True(created)
+}
+
+func (s *KVTestSuite) TestPutIfMatches() {
+	key := "/test/kv/putifmatches"
+	defer s.cli.Delete(context.Background(), key)
+
+	// a missing key only matches nil
+	ok, err := kv.PutIfMatches(context.Background(), s.cli, key, []byte{}, []byte("1"))
+	s.NoError(err)
+	s.False(ok)
+	ok, err = kv.PutIfMatches(context.Background(), s.cli, key, nil, []byte(""))
+	s.NoError(err)
+	s.True(ok)
+	ok, err = kv.PutIfMatches(context.Background(), s.cli, key, nil, []byte("1"))
+	s.NoError(err)
+	s.False(ok)
+
+	// an empty value matches empty, but not nil
+	ok, err = kv.PutIfMatches(context.Background(), s.cli, key, []byte{}, []byte("1"))
+	s.NoError(err)
+	s.True(ok)
+
+	ok, err = kv.PutIfMatches(context.Background(), s.cli, key, []byte("2"), []byte("3"))
+	s.NoError(err)
+	s.False(ok)
+	ok, err = kv.PutIfMatches(context.Background(), s.cli, key, []byte("1"), []byte("2"))
+	s.NoError(err)
+	s.True(ok)
+
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal("2", string(getResp.Kvs[0].Value))
+}
+
+func (s *KVTestSuite) TestPutAll() {
+	prefix := "/test/kv/putall/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	kvs := map[string][]byte{prefix + "a": []byte("1"), prefix + "b": []byte("2"), prefix + "c": []byte("3")}
+	s.Require().NoError(kv.PutAll(context.Background(), s.cli, kvs, kv.WithAtomic()))
+	resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.Require().NoError(err)
+	s.Len(resp.Kvs, 3)
+	// a single txn gives every key the same revision
+	s.Equal(resp.Kvs[0].ModRevision, resp.Kvs[2].ModRevision)
+
+	// a value over etcd's request size limit fails its put
+	kvs[prefix+"b"] = make([]byte, 2<<20-1024)
+	kvs[prefix+"d"] = []byte("4")
+
+	s.Run("Atomic rolls back", func() {
+		s.Error(kv.PutAll(context.Background(), s.cli, kvs, kv.WithAtomic()))
+		resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+		s.Require().NoError(err)
+		s.Len(resp.Kvs, 3)
+		s.Equal("2", string(resp.Kvs[1].Value))
+	})
+
+	s.Run("Best effort reports the failed keys", func() {
+		err := kv.PutAll(context.Background(), s.cli, kvs)
+		var putErr *kv.PutAllError
+		s.Require().ErrorAs(err, &putErr)
+		s.Len(putErr.Errs, 1)
+		s.Contains(putErr.Errs, prefix+"b")
+
+		resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+		s.Require().NoError(err)
+		s.Len(resp.Kvs, 4)
+		s.Equal("2", string(resp.Kvs[1].Value))
+		s.Equal("4", string(resp.Kvs[3].Value))
+	})
+
+	s.Run("Too many ops", func() {
+		many := make(map[string][]byte)
+		for i := 0; i < 129; i++ {
+			many[fmt.Sprint(prefix, "many/", i)] = nil
+		}
+		s.ErrorIs(kv.PutAll(context.Background(), s.cli, many, kv.WithAtomic()), kv.ErrTooManyOps)
+	})
+}
@@ -0,0 +1,82 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// SortTarget is what WithSort orders keys by.
+type SortTarget int
+
+const (
+	SortByKey SortTarget = iota
+	SortByVersion
+	SortByCreateRevision
+	SortByModRevision
+	SortByValue
+)
+
+type SortOrder int
+
+const (
+	SortAscend SortOrder = iota
+	SortDescend
+)
+
+var sortTargets = map[SortTarget]clientv3.SortTarget{
+	SortByKey:            clientv3.SortByKey,
+	SortByVersion:        clientv3.SortByVersion,
+	SortByCreateRevision: clientv3.SortByCreateRevision,
+	SortByModRevision:    clientv3.SortByModRevision,
+	SortByValue:          clientv3.SortByValue,
+}
+
+// WithSort orders the keys a ranged Get returns.
+func WithSort(target SortTarget, order SortOrder) clientv3.OpOption {
+	o := clientv3.SortAscend
+	if order == SortDescend {
+		o = clientv3.SortDescend
+	}
+	return clientv3.WithSort(sortTargets[target], o)
+}
+
+// GetRange reads the keys in [start, end).
+func GetRange(ctx context.Context, cli etcdx.KV, start, end string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return cli.Get(ctx, start, append(opts, clientv3.WithRange(end))...)
+}
+
+// GetPrefix reads the keys under prefix, in key order unless WithSort says
+// otherwise.
+func GetPrefix(ctx context.Context, cli etcdx.KV, prefix string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return cli.Get(ctx, prefix, append(opts, clientv3.WithPrefix())...)
+}
+
+// ScanDesc returns the last limit keys under prefix, the greatest first, for
+// tail reads such as the latest entries of a log. The server sorts the range
+// and cuts it at limit, so only those keys are sent. An empty prefix scans
+// the whole keyspace, and a limit of 0 returns every key.
+func ScanDesc(ctx context.Context, cli etcdx.KV, prefix string, limit int) ([]*mvccpb.KeyValue, error) {
+	resp, err := GetPrefix(ctx, cli, prefix, WithSort(SortByKey, SortDescend), clientv3.WithLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Kvs, nil
+}
+
+// GetFrom reads every key from start to the end of the keyspace.
+func GetFrom(ctx context.Context, cli etcdx.KV, start string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return cli.Get(ctx, start, append(opts, clientv3.WithFromKey())...)
+}
+
+// DeleteRange deletes the keys in [start, end) and returns how many it
+// deleted.
+func DeleteRange(ctx context.Context, cli etcdx.KV, start, end string) (int64, error) {
+	resp, err := cli.Delete(ctx, start, clientv3.WithRange(end))
+	if err != nil {
+		return 0, err
+	}
+	return resp.Deleted, nil
+}
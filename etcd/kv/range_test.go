@@ -0,0 +1,127 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func keys(resp *clientv3.GetResponse) []string {
+	var keys []string
+	for _, kv := range resp.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	return keys
+}
+
+func kvKeys(kvs []*mvccpb.KeyValue) []string {
+	return keys(&clientv3.GetResponse{Kvs: kvs})
+}
+
+func (s *KVTestSuite) TestRange() {
+	prefix := "/test/kv/range/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	for i := 1; i <= 9; i++ {
+		_, err := s.cli.Put(context.Background(), fmt.Sprintf("%sk%d", prefix, i), fmt.Sprint(10-i))
+		s.NoError(err)
+	}
+
+	resp, err := kv.GetRange(context.Background(), s.cli, prefix+"k2", prefix+"k5")
+	s.NoError(err)
+	s.Equal([]string{prefix + "k2", prefix + "k3", prefix + "k4"}, keys(resp))
+
+	resp, err = kv.GetRange(context.Background(), s.cli, prefix+"k2", prefix+"k5", kv.WithSort(kv.SortByKey, kv.SortDescend))
+	s.NoError(err)
+	s.Equal([]string{prefix + "k4", prefix + "k3", prefix + "k2"}, keys(resp))
+
+	resp, err = kv.GetRange(context.Background(), s.cli, prefix+"k1", prefix+"k4", kv.WithSort(kv.SortByValue, kv.SortAscend))
+	s.NoError(err)
+	s.Equal([]string{prefix + "k3", prefix + "k2", prefix + "k1"}, keys(resp))
+
+	resp, err = kv.GetFrom(context.Background(), s.cli, prefix+"k8", kv.WithSort(kv.SortByKey, kv.SortAscend), clientv3.WithLimit(2))
+	s.NoError(err)
+	s.Equal([]string{prefix + "k8", prefix + "k9"}, keys(resp))
+
+	deleted, err := kv.DeleteRange(context.Background(), s.cli, prefix+"k2", prefix+"k5")
+	s.NoError(err)
+	s.Equal(int64(3), deleted)
+	resp, err = s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(6), resp.Count)
+}
+
+func (s *KVTestSuite) TestGetPrefixSorted() {
+	prefix := "/test/kv/sorted/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	// created c, a, b; modified last b, then a; a has the most versions
+	for _, put := range [][2]string{{"c", "1"}, {"a", "9"}, {"b", "9"}, {"b", "3"}, {"a", "5"}, {"a", "0"}} {
+		_, err := s.cli.Put(context.Background(), prefix+put[0], put[1])
+		s.Require().NoError(err)
+	}
+
+	for _, tc := range []struct {
+		target kv.SortTarget
+		order  kv.SortOrder
+		want   string
+	}{
+		{kv.SortByKey, kv.SortAscend, "abc"},
+		{kv.SortByKey, kv.SortDescend, "cba"},
+		{kv.SortByCreateRevision, kv.SortAscend, "cab"},
+		{kv.SortByModRevision, kv.SortDescend, "abc"},
+		{kv.SortByModRevision, kv.SortAscend, "cba"},
+		{kv.SortByVersion, kv.SortAscend, "cba"},
+		{kv.SortByValue, kv.SortAscend, "acb"},
+	} {
+		resp, err := kv.GetPrefix(context.Background(), s.cli, prefix, kv.WithSort(tc.target, tc.order))
+		s.Require().NoError(err)
+		var got string
+		for _, key := range keys(resp) {
+			got += key[len(prefix):]
+		}
+		s.Equal(tc.want, got, "target %d order %d", tc.target, tc.order)
+	}
+
+	resp, err := kv.GetPrefix(context.Background(), s.cli, prefix)
+	s.NoError(err)
+	s.Equal([]string{prefix + "a", prefix + "b", prefix + "c"}, keys(resp))
+}
+
+func (s *KVTestSuite) TestScanDesc() {
+	prefix := "/test/kv/scandesc/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	for i := 1; i <= 20; i++ {
+		_, err := s.cli.Put(context.Background(), fmt.Sprintf("%sk%02d", prefix, i), "v")
+		s.Require().NoError(err)
+	}
+	// a neighbour sorting right after the prefix stays out
+	_, err := s.cli.Put(context.Background(), "/test/kv/scandesc0", "v")
+	s.Require().NoError(err)
+	defer s.cli.Delete(context.Background(), "/test/kv/scandesc0")
+
+	kvs, err := kv.ScanDesc(context.Background(), s.cli, prefix, 5)
+	s.NoError(err)
+	s.Equal([]string{prefix + "k20", prefix + "k19", prefix + "k18", prefix + "k17", prefix + "k16"}, kvKeys(kvs))
+
+	s.Run("Limit above the key count", func() {
+		kvs, err := kv.ScanDesc(context.Background(), s.cli, prefix, 50)
+		s.NoError(err)
+		s.Len(kvs, 20)
+		s.Equal(prefix+"k20", string(kvs[0].Key))
+		s.Equal(prefix+"k01", string(kvs[19].Key))
+	})
+
+	s.Run("Empty prefix", func() {
+		fakeKV := fake.NewKV()
+		for _, key := range []string{"a", "c", "b"} {
+			_, err := fakeKV.Put(context.Background(), key, "v")
+			s.Require().NoError(err)
+		}
+		kvs, err := kv.ScanDesc(context.Background(), fakeKV, "", 2)
+		s.NoError(err)
+		s.Equal([]string{"c", "b"}, kvKeys(kvs))
+	})
+}
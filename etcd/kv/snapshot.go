@@ -0,0 +1,48 @@
+package kv
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// SnapshotRead reads keys as they all were at one revision, so that related
+// keys read one by one are still consistent with each other, and returns the
+// revision. The first key is read at the current revision and the others at
+// the same one; keys that do not exist there are left out of the map. If that
+// revision is compacted before all keys are read, they are read again at a
+// fresh one.
+func SnapshotRead(ctx context.Context, cli etcdx.KV, keys []string) (map[string][]byte, int64, error) {
+	for {
+		vals, rev, err := snapshotRead(ctx, cli, keys)
+		if errors.Is(err, rpctypes.ErrCompacted) {
+			continue
+		}
+		return vals, rev, err
+	}
+}
+
+func snapshotRead(ctx context.Context, cli etcdx.KV, keys []string) (map[string][]byte, int64, error) {
+	vals := make(map[string][]byte, len(keys))
+	var rev int64
+	for _, key := range keys {
+		var opts []clientv3.OpOption
+		if rev != 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		resp, err := cli.Get(ctx, key, opts...)
+		if err != nil {
+			return nil, 0, err
+		}
+		if rev == 0 {
+			rev = resp.Header.Revision
+		}
+		if len(resp.Kvs) > 0 {
+			vals[key] = resp.Kvs[0].Value
+		}
+	}
+	return vals, rev, nil
+}
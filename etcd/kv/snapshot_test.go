@@ -0,0 +1,64 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// meddlingKV calls meddle after every Get, to change the keys between the
+// reads of a caller.
+type meddlingKV struct {
+	*fake.KV
+	gets   int
+	meddle func(gets int)
+}
+
+func (m *meddlingKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := m.KV.Get(ctx, key, opts...)
+	m.gets++
+	m.meddle(m.gets)
+	return resp, err
+}
+
+func (s *KVTestSuite) TestSnapshotRead() {
+	fakeKV := fake.NewKV()
+	for _, key := range []string{"/cfg/host", "/cfg/port", "/cfg/tls"} {
+		_, err := fakeKV.Put(context.Background(), key, "v1")
+		s.Require().NoError(err)
+	}
+	start, err := fakeKV.Get(context.Background(), "/cfg/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.Require().NoError(err)
+
+	// every key changes, and one goes, after each read
+	cli := &meddlingKV{KV: fakeKV, meddle: func(gets int) {
+		for _, key := range []string{"/cfg/host", "/cfg/port", "/cfg/tls"} {
+			fakeKV.Put(context.Background(), key, fmt.Sprint("v", gets+1))
+		}
+		fakeKV.Delete(context.Background(), "/cfg/tls")
+	}}
+	vals, rev, err := kv.SnapshotRead(context.Background(), cli, []string{"/cfg/host", "/cfg/port", "/cfg/tls", "/cfg/missing"})
+	s.Require().NoError(err)
+	s.Equal(start.Header.Revision, rev)
+	s.Equal(map[string][]byte{"/cfg/host": []byte("v1"), "/cfg/port": []byte("v1"), "/cfg/tls": []byte("v1")}, vals)
+
+	s.Run("Compacted between reads", func() {
+		cli := &meddlingKV{KV: fakeKV, meddle: func(gets int) {
+			if gets == 1 {
+				resp, _ := fakeKV.Put(context.Background(), "/cfg/host", "compacted")
+				fakeKV.Compact(context.Background(), resp.Header.Revision)
+			}
+		}}
+		vals, rev, err := kv.SnapshotRead(context.Background(), cli, []string{"/cfg/host", "/cfg/port"})
+		s.Require().NoError(err)
+		// the read of /cfg/port failed and both were read again
+		s.Equal(4, cli.gets)
+		s.Equal("compacted", string(vals["/cfg/host"]))
+		resp, err := fakeKV.Get(context.Background(), "/cfg/port", clientv3.WithRev(rev))
+		s.Require().NoError(err)
+		s.Equal(resp.Kvs[0].Value, vals["/cfg/port"])
+	})
+}
@@ -0,0 +1,43 @@
+package kv
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// KeyMeta is a key with the revisions guarding CAS updates of it.
+type KeyMeta struct {
+	Key            string
+	Value          []byte
+	CreateRevision int64
+	ModRevision    int64
+	Version        int64
+	Lease          clientv3.LeaseID
+}
+
+// HasLease reports whether the key is attached to a lease.
+func (m *KeyMeta) HasLease() bool {
+	return m.Lease != clientv3.NoLease
+}
+
+// Stat reads key with its metadata, or returns ErrKeyNotFound.
+func Stat(ctx context.Context, cli etcdx.KV, key string) (*KeyMeta, error) {
+	resp, err := cli.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	kv := resp.Kvs[0]
+	return &KeyMeta{
+		Key:            string(kv.Key),
+		Value:          kv.Value,
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+		Lease:          clientv3.LeaseID(kv.Lease),
+	}, nil
+}
@@ -0,0 +1,43 @@
+package kv_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestStat() {
+	key := "/test/kv/stat"
+	defer s.cli.Delete(context.Background(), key)
+
+	_, err := kv.Stat(context.Background(), s.cli, key)
+	s.Equal(kv.ErrKeyNotFound, err)
+
+	_, err = s.cli.Put(context.Background(), key, "1")
+	s.NoError(err)
+	meta, err := kv.Stat(context.Background(), s.cli, key)
+	s.NoError(err)
+	s.False(meta.HasLease())
+
+	leaseResp, err := s.cli.Grant(context.Background(), 10)
+	s.NoError(err)
+	defer s.cli.Revoke(context.Background(), leaseResp.ID)
+	_, err = s.cli.Put(context.Background(), key, "2", clientv3.WithLease(leaseResp.ID))
+	s.NoError(err)
+
+	meta, err = kv.Stat(context.Background(), s.cli, key)
+	s.NoError(err)
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	raw := getResp.Kvs[0]
+	s.Equal(kv.KeyMeta{
+		Key:            key,
+		Value:          []byte("2"),
+		CreateRevision: raw.CreateRevision,
+		ModRevision:    raw.ModRevision,
+		Version:        2,
+		Lease:          leaseResp.ID,
+	}, *meta)
+	s.True(meta.HasLease())
+}
@@ -0,0 +1,75 @@
+package kv
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrKeyNotFound = errors.New("kv: key not found")
+	ErrSameKey     = errors.New("kv: cannot swap a key with itself")
+)
+
+type swapOptions struct {
+	missingAsEmpty bool
+}
+
+type SwapOption func(*swapOptions)
+
+// WithMissingAsEmpty makes Swap treat a missing key as holding an empty value
+// instead of failing with ErrKeyNotFound.
+func WithMissingAsEmpty() SwapOption {
+	return func(o *swapOptions) {
+		o.missingAsEmpty = true
+	}
+}
+
+// Swap atomically exchanges the values of keys a and b. Both are read in one
+// txn, then written in a txn guarded on both ModRevisions, which is retried
+// if either key changed in between. Swapping a key with itself fails with
+// ErrSameKey, as a txn cannot put the same key twice.
+func Swap(ctx context.Context, cli etcdx.KV, a, b string, opts ...SwapOption) error {
+	if a == b {
+		return ErrSameKey
+	}
+	var o swapOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for {
+		getResp, err := cli.Txn(ctx).Then(clientv3.OpGet(a), clientv3.OpGet(b)).Commit()
+		if err != nil {
+			return err
+		}
+		var vals [2]string
+		var modRevs [2]int64
+		for i, r := range getResp.Responses {
+			kvs := r.GetResponseRange().Kvs
+			if len(kvs) == 0 {
+				if !o.missingAsEmpty {
+					return ErrKeyNotFound
+				}
+				continue
+			}
+			vals[i], modRevs[i] = string(kvs[0].Value), kvs[0].ModRevision
+		}
+
+		txnResp, err := cli.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.ModRevision(a), "=", modRevs[0]),
+				clientv3.Compare(clientv3.ModRevision(b), "=", modRevs[1]),
+			).
+			Then(clientv3.OpPut(a, vals[1]), clientv3.OpPut(b, vals[0])).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+}
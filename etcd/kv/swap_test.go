@@ -0,0 +1,67 @@
+package kv_test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestSwap() {
+	prefix := "/test/kv/swap/"
+	a, b := prefix+"a", prefix+"b"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	values := func() []string {
+		resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+		s.NoError(err)
+		var vals []string
+		for _, kv := range resp.Kvs {
+			vals = append(vals, string(kv.Value))
+		}
+		return vals
+	}
+
+	s.ErrorIs(kv.Swap(context.Background(), s.cli, a, b), kv.ErrKeyNotFound)
+
+	_, err := s.cli.Put(context.Background(), a, "x")
+	s.NoError(err)
+	s.ErrorIs(kv.Swap(context.Background(), s.cli, a, b), kv.ErrKeyNotFound)
+	s.NoError(kv.Swap(context.Background(), s.cli, a, b, kv.WithMissingAsEmpty()))
+	s.Equal([]string{"", "x"}, values())
+
+	_, err = s.cli.Put(context.Background(), a, "y")
+	s.NoError(err)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				s.NoError(kv.Swap(context.Background(), s.cli, a, b))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// an even number of swaps leaves both keys where they started
+	s.Equal([]string{"y", "x"}, values())
+}
+
+func (s *KVTestSuite) TestSwapSameKey() {
+	store := fake.NewKV()
+	_, err := store.Put(context.Background(), "a", "x")
+	s.NoError(err)
+
+	s.ErrorIs(kv.Swap(context.Background(), store, "a", "a"), kv.ErrSameKey)
+	s.ErrorIs(kv.Swap(context.Background(), store, "b", "b", kv.WithMissingAsEmpty()), kv.ErrSameKey)
+
+	_, err = store.Put(context.Background(), "b", "y")
+	s.NoError(err)
+	s.NoError(kv.Swap(context.Background(), store, "a", "b"))
+	val, err := kv.GetString(context.Background(), store, "a", "")
+	s.NoError(err)
+	s.Equal("y", val)
+}
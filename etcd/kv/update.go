@@ -0,0 +1,82 @@
+package kv
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultMaxRetries = 100
+
+var (
+	// ErrAbort is returned by an Update function to leave the key as it is.
+	ErrAbort    = errors.New("kv: update aborted")
+	ErrConflict = errors.New("kv: too many conflicting updates")
+)
+
+type updateOptions struct {
+	maxRetries int
+}
+
+type UpdateOption func(*updateOptions)
+
+// WithMaxRetries sets how often Update starts over after a conflicting
+// write, 100 by default. 0 retries without limit.
+func WithMaxRetries(n int) UpdateOption {
+	return func(o *updateOptions) {
+		o.maxRetries = n
+	}
+}
+
+// Update replaces the value of key with fn(old). old is nil for a missing
+// key. The write is a txn guarded on the ModRevision that was read, or on the
+// key not existing yet; if another write got in between, the key is read
+// again and fn called again, so fn must not have side effects. fn returning
+// ErrAbort, or an error wrapping it, ends Update without writing and without
+// an error; any other error is returned as it is. ErrConflict is returned
+// once the retries are used up.
+func Update(ctx context.Context, cli etcdx.KV, key string, fn func(old []byte) ([]byte, error), opts ...UpdateOption) error {
+	o := updateOptions{maxRetries: defaultMaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for i := 0; o.maxRetries == 0 || i <= o.maxRetries; i++ {
+		var old []byte
+		var modRev int64
+		meta, err := Stat(ctx, cli, key)
+		switch {
+		case err == nil:
+			old, modRev = meta.Value, meta.ModRevision
+			// an empty value is decoded as nil
+			if old == nil {
+				old = []byte{}
+			}
+		case err != ErrKeyNotFound:
+			return err
+		}
+
+		val, err := fn(old)
+		if errors.Is(err, ErrAbort) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		cmp := clientv3.Compare(clientv3.ModRevision(key), "=", modRev)
+		if modRev == 0 {
+			cmp = clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+		}
+		resp, err := cli.Txn(ctx).If(cmp).Then(clientv3.OpPut(key, string(val))).Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+	return ErrConflict
+}
@@ -0,0 +1,88 @@
+package kv_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func appendItem(item string) func(old []byte) ([]byte, error) {
+	return func(old []byte) ([]byte, error) {
+		var list []string
+		if old != nil {
+			if err := json.Unmarshal(old, &list); err != nil {
+				return nil, err
+			}
+		}
+		return json.Marshal(append(list, item))
+	}
+}
+
+func (s *KVTestSuite) TestUpdateConcurrent() {
+	key := "/test/kv/update/list"
+	defer s.cli.Delete(context.Background(), key)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.NoError(kv.Update(context.Background(), s.cli, key, appendItem(fmt.Sprint(i))))
+		}(i)
+	}
+	wg.Wait()
+
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	var list []string
+	s.NoError(json.Unmarshal(getResp.Kvs[0].Value, &list))
+	s.Len(list, 20)
+	for i := 0; i < 20; i++ {
+		s.Contains(list, fmt.Sprint(i))
+	}
+}
+
+func (s *KVTestSuite) TestUpdateAbort() {
+	store := fake.NewKV()
+	_, err := store.Put(context.Background(), "/k", "v")
+	s.NoError(err)
+	rev := store.Rev()
+
+	err = kv.Update(context.Background(), store, "/k", func(old []byte) ([]byte, error) {
+		s.Equal("v", string(old))
+		return nil, fmt.Errorf("nothing to do: %w", kv.ErrAbort)
+	})
+	s.NoError(err)
+	s.Equal(rev, store.Rev())
+}
+
+// conflictKV makes every read see a value another writer replaces right
+// after.
+type conflictKV struct {
+	*fake.KV
+}
+
+func (c *conflictKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := c.KV.Get(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.KV.Put(ctx, key, "other")
+	return resp, err
+}
+
+func (s *KVTestSuite) TestUpdateConflict() {
+	store := &conflictKV{KV: fake.NewKV()}
+	var calls int
+	err := kv.Update(context.Background(), store, "/k", func(old []byte) ([]byte, error) {
+		calls++
+		return []byte("mine"), nil
+	}, kv.WithMaxRetries(3))
+	s.ErrorIs(err, kv.ErrConflict)
+	s.Equal(4, calls)
+}
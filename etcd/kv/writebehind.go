@@ -0,0 +1,152 @@
+package kv
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/cluster"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type writeBehindOptions struct {
+	interval   time.Duration
+	maxPending int
+}
+
+type WriteBehindOption func(*writeBehindOptions)
+
+// WithFlushInterval sets how often pending writes are flushed, every second
+// by default.
+func WithFlushInterval(d time.Duration) WriteBehindOption {
+	return func(o *writeBehindOptions) {
+		o.interval = d
+	}
+}
+
+// WithMaxPending flushes early once n distinct keys are pending.
+func WithMaxPending(n int) WriteBehindOption {
+	return func(o *writeBehindOptions) {
+		o.maxPending = n
+	}
+}
+
+// WriteBehind buffers Puts in memory and writes them to etcd in batched txns
+// in the background. Puts to the same key between two flushes coalesce, the
+// last one winning, into a single write. Writes that fail to flush stay
+// pending, unless a newer Put replaced them, and are retried by the next
+// flush.
+type WriteBehind struct {
+	cli  etcdx.KV
+	opts writeBehindOptions
+
+	mu      sync.Mutex
+	pending map[string]string
+
+	// flushMu keeps flushes in order, so an older value never overwrites a
+	// newer one
+	flushMu sync.Mutex
+	full    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewWriteBehind starts flushing in the background until Close is called.
+func NewWriteBehind(cli etcdx.KV, opts ...WriteBehindOption) *WriteBehind {
+	o := writeBehindOptions{interval: time.Second, maxPending: cluster.DefaultMaxTxnOps}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &WriteBehind{
+		cli:     cli,
+		opts:    o,
+		pending: make(map[string]string),
+		full:    make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go w.run(ctx)
+	return w
+}
+
+// Put buffers a write of val to key.
+func (w *WriteBehind) Put(key, val string) {
+	w.mu.Lock()
+	w.pending[key] = val
+	full := len(w.pending) >= w.opts.maxPending
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes everything pending, in txns of as many keys as the cluster
+// allows, see cluster.MaxTxnOps.
+func (w *WriteBehind) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	writes := w.pending
+	w.pending = make(map[string]string)
+	w.mu.Unlock()
+
+	ops := make([]clientv3.Op, 0, len(writes))
+	for key, val := range writes {
+		ops = append(ops, clientv3.OpPut(key, val))
+	}
+	max, _ := cluster.MaxTxnOps(ctx, w.cli)
+	for len(ops) > 0 {
+		n := min(len(ops), max)
+		if _, err := w.cli.Txn(ctx).Then(ops[:n]...).Commit(); err != nil {
+			w.requeue(ops)
+			return err
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
+
+// requeue puts back the writes that did not make it, unless they were
+// replaced meanwhile.
+func (w *WriteBehind) requeue(ops []clientv3.Op) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, op := range ops {
+		key := string(op.KeyBytes())
+		if _, ok := w.pending[key]; !ok {
+			w.pending[key] = string(op.ValueBytes())
+		}
+	}
+}
+
+// Close stops flushing in the background and flushes what is pending.
+func (w *WriteBehind) Close(ctx context.Context) error {
+	w.cancel()
+	<-w.done
+	return w.Flush(ctx)
+}
+
+func (w *WriteBehind) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.full:
+		}
+		// a failed flush keeps its writes pending for the next round
+		w.Flush(ctx)
+	}
+}
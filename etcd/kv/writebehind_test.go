@@ -0,0 +1,78 @@
+package kv_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *KVTestSuite) TestWriteBehindCoalesce() {
+	prefix := "/test/kv/writebehind/coalesce/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	w := kv.NewWriteBehind(s.cli, kv.WithFlushInterval(time.Hour))
+	defer w.Close(context.Background())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				w.Put(prefix+"a", fmt.Sprint(i*10+j))
+			}
+		}(i)
+	}
+	wg.Wait()
+	w.Put(prefix+"a", "last")
+	w.Put(prefix+"b", "1")
+	s.NoError(w.Flush(context.Background()))
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getResp.Kvs, 2)
+	// one write per key
+	s.Equal("last", string(getResp.Kvs[0].Value))
+	s.Equal(int64(1), getResp.Kvs[0].Version)
+	s.Equal(getResp.Kvs[0].ModRevision, getResp.Kvs[1].ModRevision)
+}
+
+func (s *KVTestSuite) TestWriteBehindClose() {
+	prefix := "/test/kv/writebehind/close/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	// room for more than fit in one txn, so that nothing is flushed early
+	w := kv.NewWriteBehind(s.cli, kv.WithFlushInterval(time.Hour), kv.WithMaxPending(1000))
+	for i := 0; i < 300; i++ {
+		w.Put(fmt.Sprintf("%s%03d", prefix, i), "val")
+	}
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(getResp.Count)
+
+	s.NoError(w.Close(context.Background()))
+	getResp, err = s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(300), getResp.Count)
+}
+
+func (s *KVTestSuite) TestWriteBehindMaxPending() {
+	prefix := "/test/kv/writebehind/full/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	w := kv.NewWriteBehind(s.cli, kv.WithFlushInterval(time.Hour), kv.WithMaxPending(10))
+	defer w.Close(context.Background())
+	for i := 0; i < 10; i++ {
+		w.Put(fmt.Sprintf("%s%d", prefix, i), "val")
+	}
+
+	s.Eventually(func() bool {
+		getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+		return err == nil && getResp.Count == 10
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,52 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrKeyNotFound = errors.New("lease: key not found")
+	ErrConflict    = errors.New("lease: key changed while re-putting it")
+)
+
+// Attach ties an existing key to lease id, so that it is deleted when the
+// lease expires. etcd only sets a lease on a put, so the key is re-put with
+// its current value, guarded on the ModRevision it was read at: a concurrent
+// change aborts with ErrConflict rather than being overwritten.
+func Attach(ctx context.Context, cli etcdx.KV, key string, id clientv3.LeaseID) error {
+	return reput(ctx, cli, key, clientv3.WithLease(id))
+}
+
+// Detach makes key permanent by re-putting it without a lease, guarded like
+// Attach. The lease itself is left alone, along with any other keys on it.
+func Detach(ctx context.Context, cli etcdx.KV, key string) error {
+	return reput(ctx, cli, key)
+}
+
+func reput(ctx context.Context, cli etcdx.KV, key string, opts ...clientv3.OpOption) error {
+	getResp, err := cli.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(getResp.Kvs) == 0 {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+	kv := getResp.Kvs[0]
+
+	txnResp, err := cli.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision)).
+		Then(clientv3.OpPut(key, string(kv.Value), opts...)).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("%w: %s", ErrConflict, key)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package lease_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/lease"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *LeaseTestSuite) TestAttach() {
+	key := "/test/lease/attach"
+	defer s.cli.Delete(context.Background(), key)
+	_, err := s.cli.Put(context.Background(), key, "job")
+	s.Require().NoError(err)
+
+	grantResp, err := s.cli.Grant(context.Background(), 1)
+	s.Require().NoError(err)
+	s.Require().NoError(lease.Attach(context.Background(), s.cli, key, grantResp.ID))
+
+	resp, err := s.cli.Get(context.Background(), key)
+	s.Require().NoError(err)
+	s.Equal("job", string(resp.Kvs[0].Value))
+	s.Equal(int64(grantResp.ID), resp.Kvs[0].Lease)
+
+	// the key goes with the lease
+	s.Eventually(func() bool {
+		resp, err := s.cli.Get(context.Background(), key)
+		return err == nil && len(resp.Kvs) == 0
+	}, 5*time.Second, 100*time.Millisecond)
+
+	s.ErrorIs(lease.Attach(context.Background(), s.cli, key, grantResp.ID), lease.ErrKeyNotFound)
+}
+
+func (s *LeaseTestSuite) TestDetach() {
+	key := "/test/lease/detach"
+	defer s.cli.Delete(context.Background(), key)
+
+	grantResp, err := s.cli.Grant(context.Background(), 1)
+	s.Require().NoError(err)
+	_, err = s.cli.Put(context.Background(), key, "claimed", clientv3.WithLease(grantResp.ID))
+	s.Require().NoError(err)
+	s.Require().NoError(lease.Detach(context.Background(), s.cli, key))
+
+	// the key outlives the lease
+	s.Eventually(func() bool {
+		alive, err := lease.IsAlive(context.Background(), s.cli, grantResp.ID)
+		return err == nil && !alive
+	}, 5*time.Second, 100*time.Millisecond)
+	resp, err := s.cli.Get(context.Background(), key)
+	s.Require().NoError(err)
+	s.Require().Len(resp.Kvs, 1)
+	s.Equal("claimed", string(resp.Kvs[0].Value))
+	s.Zero(resp.Kvs[0].Lease)
+}
+
+// racingKV changes the key between the read and the re-put.
+type racingKV struct {
+	*clientv3.Client
+}
+
+func (r racingKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := r.Client.Get(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Client.Put(ctx, key, "changed")
+	return resp, err
+}
+
+func (s *LeaseTestSuite) TestAttachConflict() {
+	key := "/test/lease/conflict"
+	defer s.cli.Delete(context.Background(), key)
+	_, err := s.cli.Put(context.Background(), key, "v")
+	s.Require().NoError(err)
+
+	s.ErrorIs(lease.Detach(context.Background(), racingKV{s.cli}, key), lease.ErrConflict)
+	resp, err := s.cli.Get(context.Background(), key)
+	s.Require().NoError(err)
+	s.Equal("changed", string(resp.Kvs[0].Value))
+}
@@ -0,0 +1,55 @@
+package lease
+
+import (
+	"context"
+	"errors"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrLeaseExpired = errors.New("lease: lease expired or revoked")
+
+type LeaseInfo struct {
+	ID clientv3.LeaseID
+	// GrantedTTL is the TTL in seconds the lease was granted with.
+	GrantedTTL int64
+	// TTL is the remaining TTL in seconds.
+	TTL int64
+	// Keys are the keys attached to the lease, if asked for.
+	Keys []string
+}
+
+// TimeToLive looks up the remaining TTL of lease id. A lease that expired or
+// was revoked yields ErrLeaseExpired.
+func TimeToLive(ctx context.Context, cli *clientv3.Client, id clientv3.LeaseID, withKeys bool) (LeaseInfo, error) {
+	var opts []clientv3.LeaseOption
+	if withKeys {
+		opts = append(opts, clientv3.WithAttachedKeys())
+	}
+	resp, err := cli.TimeToLive(ctx, id, opts...)
+	if err != nil {
+		return LeaseInfo{}, err
+	}
+	// the server answers with a TTL of -1 for leases it does not know
+	if resp.TTL == -1 {
+		return LeaseInfo{}, ErrLeaseExpired
+	}
+
+	info := LeaseInfo{ID: resp.ID, GrantedTTL: resp.GrantedTTL, TTL: resp.TTL}
+	for _, key := range resp.Keys {
+		info.Keys = append(info.Keys, string(key))
+	}
+	return info, nil
+}
+
+// IsAlive reports whether lease id still has TTL left.
+func IsAlive(ctx context.Context, cli *clientv3.Client, id clientv3.LeaseID) (bool, error) {
+	info, err := TimeToLive(ctx, cli, id, false)
+	if errors.Is(err, ErrLeaseExpired) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.TTL > 0, nil
+}
@@ -0,0 +1,86 @@
+package lease_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/lease"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type LeaseTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestLeaseTestSuite(t *testing.T) {
+	suite.Run(t, new(LeaseTestSuite))
+}
+
+func (s *LeaseTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *LeaseTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *LeaseTestSuite) TestTimeToLive() {
+	key := "/test/lease/ttl"
+	grantResp, err := s.cli.Grant(context.Background(), 2)
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), key, "val", clientv3.WithLease(grantResp.ID))
+	s.NoError(err)
+
+	info, err := lease.TimeToLive(context.Background(), s.cli, grantResp.ID, true)
+	s.NoError(err)
+	s.Equal(grantResp.ID, info.ID)
+	s.Equal(int64(2), info.GrantedTTL)
+	s.LessOrEqual(info.TTL, int64(2))
+	s.Equal([]string{key}, info.Keys)
+
+	info, err = lease.TimeToLive(context.Background(), s.cli, grantResp.ID, false)
+	s.NoError(err)
+	s.Empty(info.Keys)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		info, err := lease.TimeToLive(context.Background(), s.cli, grantResp.ID, false)
+		if err != nil {
+			s.ErrorIs(err, lease.ErrLeaseExpired)
+			break
+		}
+		s.LessOrEqual(info.TTL, int64(2))
+		s.True(time.Now().Before(deadline), "lease did not expire")
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	alive, err := lease.IsAlive(context.Background(), s.cli, grantResp.ID)
+	s.NoError(err)
+	s.False(alive)
+}
+
+func (s *LeaseTestSuite) TestRevoked() {
+	grantResp, err := s.cli.Grant(context.Background(), 60)
+	s.NoError(err)
+
+	alive, err := lease.IsAlive(context.Background(), s.cli, grantResp.ID)
+	s.NoError(err)
+	s.True(alive)
+
+	_, err = s.cli.Revoke(context.Background(), grantResp.ID)
+	s.NoError(err)
+
+	alive, err = lease.IsAlive(context.Background(), s.cli, grantResp.ID)
+	s.NoError(err)
+	s.False(alive)
+	_, err = lease.TimeToLive(context.Background(), s.cli, grantResp.ID, true)
+	s.ErrorIs(err, lease.ErrLeaseExpired)
+}
@@ -0,0 +1,325 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultPoolSize = 4
+	defaultPoolTTL  = 10
+)
+
+type poolOptions struct {
+	size    int
+	ttl     int64
+	maxAge  time.Duration
+	onError func(error)
+	clock   clock.Clock
+}
+
+type PoolOption func(*poolOptions)
+
+// WithPoolSize sets how many leases the pool keeps, 4 by default.
+func WithPoolSize(n int) PoolOption {
+	return func(o *poolOptions) {
+		o.size = n
+	}
+}
+
+// WithPoolTTL sets the TTL in seconds of the pooled leases, 10 by default.
+func WithPoolTTL(ttl int) PoolOption {
+	return func(o *poolOptions) {
+		o.ttl = int64(ttl)
+	}
+}
+
+// WithMaxAge replaces every lease once it is d old, moving the keys attached
+// to it over to its replacement. The old lease is not revoked but left to
+// expire. By default leases are only replaced when their keep-alive fails.
+func WithMaxAge(d time.Duration) PoolOption {
+	return func(o *poolOptions) {
+		o.maxAge = d
+	}
+}
+
+// WithPoolOnError is called with errors from replacing a lease; the pool
+// tries again on the next occasion.
+func WithPoolOnError(fn func(error)) PoolOption {
+	return func(o *poolOptions) {
+		o.onError = fn
+	}
+}
+
+// WithPoolClock sets the clock lease ages are measured by.
+func WithPoolClock(c clock.Clock) PoolOption {
+	return func(o *poolOptions) {
+		o.clock = c
+	}
+}
+
+type pooledLease struct {
+	id      clientv3.LeaseID
+	granted time.Time
+	cancel  context.CancelFunc
+}
+
+// Pool shares a few keep-alived leases among many short-lived keys, instead
+// of granting and keeping alive a lease per key. Acquire hands the leases
+// out in turn.
+//
+// A pooled lease is shared: revoking it, or letting it expire, deletes every
+// key attached to it, not just the keys of one caller. When a keep-alive
+// fails or a lease reaches its max age, the pool grants a replacement and
+// moves the keys attached to the old lease over before it can expire.
+type Pool struct {
+	cli    *clientv3.Client
+	opts   poolOptions
+	cancel context.CancelFunc
+	done   chan struct{}
+	lost   chan int
+
+	mu     sync.Mutex
+	leases []*pooledLease
+	next   int
+	// retired are the replaced leases that may still get keys attached
+	retired  map[clientv3.LeaseID]bool
+	retiring sync.WaitGroup
+}
+
+// NewPool grants the leases and keeps them alive until Close.
+func NewPool(ctx context.Context, cli *clientv3.Client, opts ...PoolOption) (*Pool, error) {
+	o := poolOptions{size: defaultPoolSize, ttl: defaultPoolTTL, onError: func(error) {}, clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		cli:    cli,
+		opts:   o,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		lost:    make(chan int, o.size),
+		leases:  make([]*pooledLease, o.size),
+		retired: make(map[clientv3.LeaseID]bool),
+	}
+	for i := range p.leases {
+		l, err := p.grant(ctx, runCtx, i)
+		if err != nil {
+			cancel()
+			p.revokeAll()
+			return nil, err
+		}
+		p.leases[i] = l
+	}
+	go p.run(runCtx)
+	return p, nil
+}
+
+// Acquire returns one of the pooled leases to attach a key to.
+func (p *Pool) Acquire() clientv3.LeaseID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l := p.leases[p.next%len(p.leases)]
+	p.next++
+	return l.id
+}
+
+// Close stops the keep-alives and revokes the leases, the replaced ones that
+// did not expire yet too, deleting every key attached to them.
+func (p *Pool) Close(ctx context.Context) error {
+	p.cancel()
+	<-p.done
+	p.retiring.Wait()
+	var errs []error
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]clientv3.LeaseID, 0, len(p.leases)+len(p.retired))
+	for _, l := range p.leases {
+		if l != nil {
+			ids = append(ids, l.id)
+		}
+	}
+	for id := range p.retired {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		if _, err := p.cli.Revoke(ctx, id); err != nil && !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// grant grants a lease for slot i and keeps it alive until runCtx is done,
+// reporting the slot on lost if the keep-alive ends before that.
+func (p *Pool) grant(ctx, runCtx context.Context, i int) (*pooledLease, error) {
+	resp, err := p.cli.Grant(ctx, p.opts.ttl)
+	if err != nil {
+		return nil, err
+	}
+	kaCtx, cancel := context.WithCancel(runCtx)
+	keepChan, err := p.cli.KeepAlive(kaCtx, resp.ID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	l := &pooledLease{id: resp.ID, granted: p.opts.clock.Now(), cancel: cancel}
+	go func() {
+		for range keepChan {
+		}
+		if kaCtx.Err() == nil {
+			select {
+			case p.lost <- i:
+			case <-runCtx.Done():
+			}
+		}
+	}()
+	return l, nil
+}
+
+func (p *Pool) revokeAll() {
+	for _, l := range p.leases {
+		if l != nil {
+			l.cancel()
+			p.cli.Revoke(context.Background(), l.id)
+		}
+	}
+}
+
+func (p *Pool) run(ctx context.Context) {
+	defer close(p.done)
+
+	var tick <-chan time.Time
+	if p.opts.maxAge > 0 {
+		ticker := p.opts.clock.NewTicker(p.opts.maxAge / 4)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case i := <-p.lost:
+			p.replace(ctx, i)
+		case <-tick:
+			for i := range p.leases {
+				p.mu.Lock()
+				old := p.opts.clock.Now().Sub(p.leases[i].granted) >= p.opts.maxAge
+				p.mu.Unlock()
+				if old {
+					p.replace(ctx, i)
+				}
+			}
+		}
+	}
+}
+
+// replace swaps the lease in slot i for a fresh one and retires the old one.
+func (p *Pool) replace(ctx context.Context, i int) {
+	l, err := p.grant(ctx, ctx, i)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.opts.onError(err)
+			// try again shortly
+			go func() {
+				select {
+				case <-p.opts.clock.After(time.Second):
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case p.lost <- i:
+				default:
+				}
+			}()
+		}
+		return
+	}
+
+	p.mu.Lock()
+	old := p.leases[i]
+	p.leases[i] = l
+	p.mu.Unlock()
+
+	old.cancel()
+	p.retire(ctx, old.id, l.id)
+}
+
+// retire moves the keys of a replaced lease over to its replacement, and once
+// more when half of the TTL it had left has passed, for the keys put on it by
+// callers that acquired it just before it was replaced. It is not revoked, so
+// that a key attached in between is not deleted, but left to expire.
+func (p *Pool) retire(ctx context.Context, from, to clientv3.LeaseID) {
+	ttl, err := p.migrate(ctx, from, to)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		p.opts.onError(err)
+		ttl = p.opts.ttl
+	}
+	if ttl == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	p.retired[from] = true
+	p.mu.Unlock()
+	p.retiring.Add(1)
+	go func() {
+		defer p.retiring.Done()
+		select {
+		case <-p.opts.clock.After(time.Duration(ttl) * time.Second / 2):
+		case <-ctx.Done():
+			// left for Close to revoke
+			return
+		}
+		if _, err := p.migrate(ctx, from, to); err != nil && ctx.Err() == nil {
+			p.opts.onError(err)
+		}
+		p.mu.Lock()
+		delete(p.retired, from)
+		p.mu.Unlock()
+	}()
+}
+
+// migrate reattaches the keys of lease from to lease to, each in a txn
+// guarded on the key still being unchanged and attached to from, and returns
+// the TTL in seconds from had left, 0 if it expired.
+func (p *Pool) migrate(ctx context.Context, from, to clientv3.LeaseID) (int64, error) {
+	info, err := TimeToLive(ctx, p.cli, from, true)
+	if errors.Is(err, ErrLeaseExpired) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range info.Keys {
+		getResp, err := p.cli.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if len(getResp.Kvs) == 0 {
+			continue
+		}
+		kv := getResp.Kvs[0]
+		_, err = p.cli.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.ModRevision(key), "=", kv.ModRevision),
+				clientv3.Compare(clientv3.LeaseValue(key), "=", from),
+			).
+			Then(clientv3.OpPut(key, string(kv.Value), clientv3.WithLease(to))).
+			Commit()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return info.TTL, nil
+}
@@ -0,0 +1,136 @@
+package lease_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/lease"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *LeaseTestSuite) TestPoolSharedRevoke() {
+	prefix := "/test/lease/pool/revoke/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	pool, err := lease.NewPool(context.Background(), s.cli, lease.WithPoolSize(1))
+	s.Require().NoError(err)
+	defer pool.Close(context.Background())
+
+	id := pool.Acquire()
+	for i := 0; i < 100; i++ {
+		s.Equal(id, pool.Acquire())
+		_, err := s.cli.Put(context.Background(), fmt.Sprintf("%s%03d", prefix, i), "val", clientv3.WithLease(pool.Acquire()))
+		s.NoError(err)
+	}
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(100), getResp.Count)
+
+	// one revoke takes every key with it
+	_, err = s.cli.Revoke(context.Background(), id)
+	s.NoError(err)
+	getResp, err = s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(getResp.Count)
+
+	// the pool replaces the lost lease
+	s.Eventually(func() bool { return pool.Acquire() != id }, 5*time.Second, 10*time.Millisecond)
+	alive, err := lease.IsAlive(context.Background(), s.cli, pool.Acquire())
+	s.NoError(err)
+	s.True(alive)
+}
+
+func (s *LeaseTestSuite) TestPoolRotation() {
+	prefix := "/test/lease/pool/rotate/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	pool, err := lease.NewPool(context.Background(), s.cli, lease.WithPoolSize(2), lease.WithMaxAge(time.Second))
+	s.Require().NoError(err)
+
+	first := map[clientv3.LeaseID]bool{}
+	for i := 0; i < 10; i++ {
+		id := pool.Acquire()
+		first[id] = true
+		_, err := s.cli.Put(context.Background(), fmt.Sprintf("%s%d", prefix, i), "val", clientv3.WithLease(id))
+		s.NoError(err)
+	}
+	s.Len(first, 2)
+
+	s.Eventually(func() bool {
+		a, b := pool.Acquire(), pool.Acquire()
+		return !first[a] && !first[b]
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// the keys moved to the new leases and the old ones are gone
+	s.Eventually(func() bool {
+		getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+		if err != nil || len(getResp.Kvs) != 10 {
+			return false
+		}
+		for _, kv := range getResp.Kvs {
+			if first[clientv3.LeaseID(kv.Lease)] {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 50*time.Millisecond)
+
+	s.NoError(pool.Close(context.Background()))
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(getResp.Count)
+}
+
+func (s *LeaseTestSuite) TestPoolRotationFakeClock() {
+	clock := fake.NewClock()
+	cli := fake.NewClient(clock)
+	defer cli.Close()
+
+	pool, err := lease.NewPool(context.Background(), cli, lease.WithPoolSize(1), lease.WithPoolTTL(60),
+		lease.WithMaxAge(40*time.Second), lease.WithPoolClock(clock))
+	s.Require().NoError(err)
+	defer pool.Close(context.Background())
+
+	first := pool.Acquire()
+	_, err = cli.Put(context.Background(), "key", "val", clientv3.WithLease(first))
+	s.Require().NoError(err)
+
+	// no real time needs to pass for the lease to come of age
+	clock.BlockUntil(1)
+	clock.Advance(39 * time.Second)
+	s.Equal(first, pool.Acquire())
+	s.Eventually(func() bool {
+		clock.Advance(time.Second)
+		return pool.Acquire() != first
+	}, time.Second, 10*time.Millisecond)
+	second := pool.Acquire()
+
+	// a caller that acquired the old lease just before it was replaced
+	_, err = cli.Put(context.Background(), "late", "val", clientv3.WithLease(first))
+	s.Require().NoError(err)
+
+	onLease := func(key string, id clientv3.LeaseID) bool {
+		getResp, err := cli.Get(context.Background(), key)
+		return err == nil && len(getResp.Kvs) == 1 && clientv3.LeaseID(getResp.Kvs[0].Lease) == id
+	}
+	s.Eventually(func() bool { return onLease("key", second) }, time.Second, 10*time.Millisecond)
+
+	// the old lease had 20s left, its keys are moved again halfway
+	clock.BlockUntil(2)
+	clock.Advance(10 * time.Second)
+	s.Eventually(func() bool { return onLease("late", second) }, time.Second, 10*time.Millisecond)
+
+	// and it expires rather than being revoked
+	alive, err := cli.TimeToLive(context.Background(), first)
+	s.NoError(err)
+	s.Positive(alive.TTL)
+	clock.Advance(11 * time.Second)
+	s.Eventually(func() bool {
+		alive, err := cli.TimeToLive(context.Background(), first)
+		return err == nil && alive.TTL == -1
+	}, time.Second, 10*time.Millisecond)
+	s.True(onLease("key", second))
+	s.True(onLease("late", second))
+}
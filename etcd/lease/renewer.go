@@ -0,0 +1,80 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type renewerOptions struct {
+	regrantTTL int64
+	onRegrant  func(old, new clientv3.LeaseID)
+}
+
+type RenewerOption func(*renewerOptions)
+
+// WithRegrant makes Renew grant a fresh lease with the given TTL in seconds
+// when the current one is gone, and report it to onRegrant.
+//
+// Keys attached to the old lease are deleted when it expires, and nothing
+// recreates them until onRegrant rewrites them with the new lease, so readers
+// may see them missing in between.
+func WithRegrant(ttl int, onRegrant func(old, new clientv3.LeaseID)) RenewerOption {
+	return func(o *renewerOptions) {
+		o.regrantTTL, o.onRegrant = int64(ttl), onRegrant
+	}
+}
+
+// Renewer renews a lease one KeepAliveOnce at a time, for callers that want to
+// control when renewals happen rather than leave it to the streaming
+// KeepAlive.
+type Renewer struct {
+	cli  *clientv3.Client
+	opts renewerOptions
+
+	mu sync.Mutex
+	id clientv3.LeaseID
+}
+
+func NewRenewer(cli *clientv3.Client, id clientv3.LeaseID, opts ...RenewerOption) *Renewer {
+	var o renewerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Renewer{cli: cli, id: id, opts: o}
+}
+
+// ID returns the lease currently renewed.
+func (r *Renewer) ID() clientv3.LeaseID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+// Renew refreshes the lease TTL once. If the lease is gone it returns
+// ErrLeaseExpired, unless WithRegrant is set, in which case a new lease
+// replaces it.
+func (r *Renewer) Renew(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.cli.KeepAliveOnce(ctx, r.id)
+	if !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+		return err
+	}
+	if r.opts.onRegrant == nil {
+		return ErrLeaseExpired
+	}
+
+	resp, err := r.cli.Grant(ctx, r.opts.regrantTTL)
+	if err != nil {
+		return err
+	}
+	old := r.id
+	r.id = resp.ID
+	r.opts.onRegrant(old, resp.ID)
+	return nil
+}
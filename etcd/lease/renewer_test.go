@@ -0,0 +1,56 @@
+package lease_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/lease"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *LeaseTestSuite) TestRenewer() {
+	grantResp, err := s.cli.Grant(context.Background(), 5)
+	s.NoError(err)
+	defer s.cli.Revoke(context.Background(), grantResp.ID)
+
+	r := lease.NewRenewer(s.cli, grantResp.ID)
+	s.NoError(r.Renew(context.Background()))
+	s.Equal(grantResp.ID, r.ID())
+
+	_, err = s.cli.Revoke(context.Background(), grantResp.ID)
+	s.NoError(err)
+	s.ErrorIs(r.Renew(context.Background()), lease.ErrLeaseExpired)
+}
+
+func (s *LeaseTestSuite) TestRenewerRegrant() {
+	key := "/test/lease/regrant"
+	defer s.cli.Delete(context.Background(), key)
+
+	grantResp, err := s.cli.Grant(context.Background(), 5)
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), key, "val", clientv3.WithLease(grantResp.ID))
+	s.NoError(err)
+
+	var regranted []clientv3.LeaseID
+	r := lease.NewRenewer(s.cli, grantResp.ID, lease.WithRegrant(5, func(old, new clientv3.LeaseID) {
+		regranted = append(regranted, old, new)
+		_, err := s.cli.Put(context.Background(), key, "val", clientv3.WithLease(new))
+		s.NoError(err)
+	}))
+
+	_, err = s.cli.Revoke(context.Background(), grantResp.ID)
+	s.NoError(err)
+	s.NoError(r.Renew(context.Background()))
+	defer s.cli.Revoke(context.Background(), r.ID())
+
+	s.Len(regranted, 2)
+	s.Equal(grantResp.ID, regranted[0])
+	s.Equal(r.ID(), regranted[1])
+	s.NotEqual(grantResp.ID, r.ID())
+
+	info, err := lease.TimeToLive(context.Background(), s.cli, r.ID(), true)
+	s.NoError(err)
+	s.Equal([]string{key}, info.Keys)
+
+	s.NoError(r.Renew(context.Background()))
+	s.Len(regranted, 2)
+}
@@ -0,0 +1,109 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// stages in shutdown order: watchers first so nothing reacts to the keys
+// disappearing, then sessions, whose leases go with them, then bare leases.
+const (
+	watchers = iota
+	sessions
+	leases
+	stages
+)
+
+type cleanup struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Group collects what an application has to clean up on its way out and
+// releases it all in one Shutdown call. The zero value is ready to use.
+type Group struct {
+	mu       sync.Mutex
+	cleanups [stages][]cleanup
+}
+
+// AddWatcher registers w to be closed, which cancels all of its watches.
+func (g *Group) AddWatcher(w clientv3.Watcher) {
+	g.add(watchers, "watcher", func(context.Context) error { return w.Close() })
+}
+
+// AddSession registers s to be closed, which stops its keep-alive and revokes
+// its lease.
+func (g *Group) AddSession(s *session.Session) {
+	g.add(sessions, fmt.Sprintf("session %x", s.Lease()), func(context.Context) error { return s.Close() })
+}
+
+// AddLease registers the lease id to be revoked through l.
+func (g *Group) AddLease(l clientv3.Lease, id clientv3.LeaseID) {
+	g.add(leases, fmt.Sprintf("lease %x", id), func(ctx context.Context) error {
+		_, err := l.Revoke(ctx, id)
+		return err
+	})
+}
+
+func (g *Group) add(stage int, name string, fn func(ctx context.Context) error) {
+	g.mu.Lock()
+	g.cleanups[stage] = append(g.cleanups[stage], cleanup{name: name, fn: fn})
+	g.mu.Unlock()
+}
+
+// Shutdown closes the watchers, then the sessions, then revokes the leases,
+// running the cleanups of one stage concurrently. Once ctx is done the
+// cleanups still running, and those of later stages, are abandoned and
+// reported as failed with ctx.Err(). All failures are joined into the returned
+// error. Everything registered so far is dropped from the group.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	all := g.cleanups
+	g.cleanups = [stages][]cleanup{}
+	g.mu.Unlock()
+
+	var errs []error
+	for _, cleanups := range all {
+		errs = append(errs, run(ctx, cleanups)...)
+	}
+	return errors.Join(errs...)
+}
+
+// run runs the cleanups concurrently until they all return or ctx is done.
+func run(ctx context.Context, cleanups []cleanup) []error {
+	results := make([]chan error, len(cleanups))
+	for i, c := range cleanups {
+		results[i] = make(chan error, 1)
+		if ctx.Err() != nil {
+			results[i] <- ctx.Err()
+			continue
+		}
+		go func(c cleanup, result chan<- error) {
+			result <- c.fn(ctx)
+		}(c, results[i])
+	}
+
+	var errs []error
+	for i, result := range results {
+		var err error
+		select {
+		case err = <-result:
+		case <-ctx.Done():
+			// prefer a result that is already in
+			select {
+			case err = <-result:
+			default:
+				err = ctx.Err()
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("lifecycle: %s: %w", cleanups[i].name, err))
+		}
+	}
+	return errs
+}
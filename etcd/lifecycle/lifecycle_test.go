@@ -0,0 +1,128 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/lifecycle"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// recorder logs the cleanups in the order they happen.
+type recorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) {
+	r.mu.Lock()
+	r.calls = append(r.calls, call)
+	r.mu.Unlock()
+}
+
+func (r *recorder) Calls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.calls...)
+}
+
+type mockWatcher struct {
+	clientv3.Watcher
+	r       *recorder
+	release chan struct{}
+}
+
+func (w *mockWatcher) Close() error {
+	if w.release != nil {
+		<-w.release
+	}
+	w.r.record("watcher")
+	return nil
+}
+
+type mockLease struct {
+	clientv3.Lease
+	r   *recorder
+	err error
+}
+
+func (l *mockLease) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	l.r.record("lease")
+	return &clientv3.LeaseRevokeResponse{}, l.err
+}
+
+type LifecycleTestSuite struct {
+	suite.Suite
+	r *recorder
+}
+
+func TestLifecycleTestSuite(t *testing.T) {
+	suite.Run(t, new(LifecycleTestSuite))
+}
+
+func (s *LifecycleTestSuite) SetupTest() {
+	s.r = &recorder{}
+}
+
+func (s *LifecycleTestSuite) TestShutdown() {
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+	sess, err := session.New(cli)
+	s.NoError(err)
+
+	var g lifecycle.Group
+	g.AddLease(&mockLease{r: s.r}, 1)
+	g.AddSession(sess)
+	g.AddWatcher(&mockWatcher{r: s.r})
+
+	s.NoError(g.Shutdown(context.Background()))
+	s.Equal([]string{"watcher", "lease"}, s.r.Calls())
+	select {
+	case <-sess.Done():
+	case <-time.After(time.Second):
+		s.Fail("session not closed")
+	}
+
+	// everything was released already
+	s.NoError(g.Shutdown(context.Background()))
+	s.Len(s.r.Calls(), 2)
+}
+
+func (s *LifecycleTestSuite) TestShutdownErrors() {
+	broken := errors.New("broken")
+	var g lifecycle.Group
+	g.AddLease(&mockLease{r: s.r, err: broken}, 1)
+	g.AddLease(&mockLease{r: s.r}, 2)
+
+	err := g.Shutdown(context.Background())
+	s.ErrorIs(err, broken)
+	s.Contains(err.Error(), "lease 1")
+	s.NotContains(err.Error(), "lease 2")
+	s.Equal([]string{"lease", "lease"}, s.r.Calls())
+}
+
+func (s *LifecycleTestSuite) TestShutdownTimeout() {
+	stuck := &mockWatcher{r: s.r, release: make(chan struct{})}
+	defer close(stuck.release)
+
+	var g lifecycle.Group
+	g.AddWatcher(stuck)
+	g.AddWatcher(&mockWatcher{r: s.r})
+	g.AddLease(&mockLease{r: s.r}, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := g.Shutdown(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.Contains(err.Error(), "watcher")
+	s.Contains(err.Error(), "lease 1")
+
+	// the other watcher was still closed, the lease was given up on
+	s.Equal([]string{"watcher"}, s.r.Calls())
+}
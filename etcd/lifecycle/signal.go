@@ -0,0 +1,57 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ShutdownOn waits for the first signal on signals and runs Shutdown, bounded
+// by timeout. It returns the signal along with the error of Shutdown, or a nil
+// signal without shutting down if signals is closed first.
+func (g *Group) ShutdownOn(signals <-chan os.Signal, timeout time.Duration) (os.Signal, error) {
+	sig, ok := <-signals
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return sig, g.Shutdown(ctx)
+}
+
+// OnShutdown shuts g down on the first SIGTERM or SIGINT, or on the first of
+// sig if any are given, so that leases are revoked rather than left to expire.
+// The signal is then raised again with its default handling restored, so the
+// process exits as it would have without the handler. Shutdown errors are
+// dropped, as the process is on its way out; use ShutdownOn to get at them.
+//
+// The returned func removes the handler if no signal has arrived yet.
+func OnShutdown(g *Group, timeout time.Duration, sig ...os.Signal) (stop func()) {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig...)
+
+	go func() {
+		got, _ := g.ShutdownOn(signals, timeout)
+		if got == nil {
+			return
+		}
+		signal.Reset(sig...)
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Signal(got)
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			// no signal is delivered after Stop, so closing cannot race a send
+			close(signals)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package lifecycle_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/lifecycle"
+)
+
+func (s *LifecycleTestSuite) TestShutdownOn() {
+	var g lifecycle.Group
+	g.AddWatcher(&mockWatcher{r: s.r})
+	g.AddLease(&mockLease{r: s.r}, 1)
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+	sig, err := g.ShutdownOn(signals, time.Second)
+	s.NoError(err)
+	s.Equal(syscall.SIGTERM, sig)
+	s.Equal([]string{"watcher", "lease"}, s.r.Calls())
+}
+
+func (s *LifecycleTestSuite) TestShutdownOnTimeout() {
+	stuck := &mockWatcher{r: s.r, release: make(chan struct{})}
+	defer close(stuck.release)
+	var g lifecycle.Group
+	g.AddWatcher(stuck)
+
+	signals := make(chan os.Signal, 1)
+	signals <- os.Interrupt
+	sig, err := g.ShutdownOn(signals, 50*time.Millisecond)
+	s.Equal(os.Interrupt, sig)
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (s *LifecycleTestSuite) TestOnShutdownStop() {
+	var g lifecycle.Group
+	g.AddLease(&mockLease{r: s.r}, 1)
+
+	// stopping before any signal leaves the group alone
+	signals := make(chan os.Signal)
+	close(signals)
+	sig, err := g.ShutdownOn(signals, time.Second)
+	s.Nil(sig)
+	s.NoError(err)
+
+	stop := lifecycle.OnShutdown(&g, time.Second)
+	stop()
+	stop()
+	s.Empty(s.r.Calls())
+	s.NoError(g.Shutdown(context.Background()))
+	s.Equal([]string{"lease"}, s.r.Calls())
+}
@@ -0,0 +1,179 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+var (
+	ErrLocked         = errors.New("lock: held by another owner")
+	ErrSessionExpired = errors.New("lock: lease expired while waiting")
+	ErrLockTimeout    = errors.New("lock: not acquired in time")
+)
+
+type options struct {
+	ttl     int64
+	session *session.Session
+	metrics Metrics
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease backing each acquisition.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = int64(ttl)
+	}
+}
+
+// WithSession queues acquisitions with the lease of an existing session
+// instead of granting one per acquisition. Unlock then deletes the holder key
+// and leaves the session alive.
+func WithSession(s *session.Session) Option {
+	return func(o *options) {
+		o.session = s
+	}
+}
+
+// Metrics receives the instrumentation of a Mutex; metrics.Client provides
+// one backed by Prometheus.
+type Metrics interface {
+	// QueueDepth is called on every Lock and TryLock with how many
+	// acquisitions, the holder and the new one included, are queued under the
+	// prefix.
+	QueueDepth(n int)
+	// Waited is called with how long an acquisition took, from Lock being
+	// called until it returned holding the lock.
+	Waited(d time.Duration)
+	// Held is called on Unlock with how long the lock was held.
+	Held(d time.Duration)
+}
+
+// WithMetrics reports the contention of a Mutex to m. Counting the queue adds
+// a range to the txn that enqueues. RWMutex ignores it.
+func WithMetrics(m Metrics) Option {
+	return func(o *options) {
+		o.metrics = m
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// holder is the lease-backed key a single acquisition queues with.
+type holder struct {
+	key     string
+	rev     int64
+	leaseID clientv3.LeaseID
+	// cancel is nil when the lease belongs to a shared session
+	cancel context.CancelFunc
+	// acquired and waited are set by Mutex once the lock is held
+	acquired time.Time
+	waited   time.Duration
+}
+
+// grant creates a keep-alived lease for one acquisition, or borrows the
+// session's lease if one is configured.
+func grant(ctx context.Context, cli *clientv3.Client, o options) (*holder, error) {
+	if o.session != nil {
+		return &holder{leaseID: o.session.Lease()}, nil
+	}
+	resp, err := cli.Grant(ctx, o.ttl)
+	if err != nil {
+		return nil, err
+	}
+	kaCtx, cancel := context.WithCancel(context.Background())
+	keepChan, err := cli.KeepAlive(kaCtx, resp.ID)
+	if err != nil {
+		cancel()
+		revoke(cli, resp.ID)
+		return nil, err
+	}
+	go func() {
+		for range keepChan {
+		}
+	}()
+	return &holder{leaseID: resp.ID, cancel: cancel}, nil
+}
+
+// sharedSeq tells apart the holder keys of acquisitions sharing a session.
+var sharedSeq atomic.Int64
+
+// enqueue grants a lease and puts a holder key for it under keyPrefix. extra
+// ops run in the same txn, after the put.
+func enqueue(ctx context.Context, cli *clientv3.Client, o options, keyPrefix string, extra ...clientv3.Op) (*holder, *clientv3.TxnResponse, error) {
+	h, err := grant(ctx, cli, o)
+	if err != nil {
+		return nil, nil, err
+	}
+	h.key = fmt.Sprintf("%s%x", keyPrefix, h.leaseID)
+	if h.cancel == nil {
+		// the lease is not unique to this acquisition
+		h.key += fmt.Sprintf("/%d", sharedSeq.Add(1))
+	}
+
+	ops := append([]clientv3.Op{clientv3.OpPut(h.key, "", clientv3.WithLease(h.leaseID))}, extra...)
+	resp, err := cli.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		h.release(cli)
+		return nil, nil, err
+	}
+	h.rev = resp.Header.Revision
+	return h, resp, nil
+}
+
+// awaitTurn blocks until every key under waitPrefix queued before h is gone.
+// On failure the holder is released so its key does not leak.
+func awaitTurn(ctx context.Context, cli *clientv3.Client, h *holder, waitPrefix string) error {
+	if err := wait.Deletes(ctx, cli, waitPrefix, h.rev-1); err != nil {
+		h.release(cli)
+		return err
+	}
+
+	// make sure the lease did not expire while waiting
+	resp, err := cli.Get(ctx, h.key)
+	if err != nil {
+		h.release(cli)
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		h.release(cli)
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// release removes the holder key: its own lease is revoked, while a shared
+// session lease is left alone and only the key is deleted.
+func (h *holder) release(cli *clientv3.Client) error {
+	if h.cancel == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := cli.Delete(ctx, h.key)
+		return err
+	}
+	h.cancel()
+	return revoke(cli, h.leaseID)
+}
+
+func revoke(cli *clientv3.Client, id clientv3.LeaseID) error {
+	// revoke even if the caller's context is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := cli.Revoke(ctx, id)
+	return err
+}
@@ -0,0 +1,155 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Mutex is a distributed lock. Every acquisition puts a key tied to its own
+// keep-alived lease under the prefix, and the key with the lowest
+// CreateRevision holds the lock, so waiters are served in FIFO order.
+type Mutex struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   options
+
+	mu     sync.Mutex
+	holder *holder
+}
+
+func NewMutex(cli *clientv3.Client, prefix string, opts ...Option) *Mutex {
+	return &Mutex{cli: cli, prefix: prefix + "/", opts: newOptions(opts)}
+}
+
+// Lock blocks until the lock is acquired or ctx is done. If ctx is cancelled
+// while waiting, the queued key is removed by revoking its lease.
+func (m *Mutex) Lock(ctx context.Context) error {
+	start := time.Now()
+	h, owner, err := m.enqueue(ctx)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		if err := awaitTurn(ctx, m.cli, h, m.prefix); err != nil {
+			return err
+		}
+	}
+	m.setHolder(h, start)
+	return nil
+}
+
+// LockTimeout is Lock giving up with ErrLockTimeout if the lock is not
+// acquired within d. As with a cancelled Lock, the queued key is removed.
+func (m *Mutex) LockTimeout(ctx context.Context, d time.Duration) error {
+	lockCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	err := m.Lock(lockCtx)
+	if err != nil && lockCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return ErrLockTimeout
+	}
+	return err
+}
+
+// TryLock acquires the lock if it is free, otherwise it returns ErrLocked
+// without waiting.
+func (m *Mutex) TryLock(ctx context.Context) error {
+	start := time.Now()
+	h, owner, err := m.enqueue(ctx)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		h.release(m.cli)
+		return ErrLocked
+	}
+	m.setHolder(h, start)
+	return nil
+}
+
+// Unlock releases the lock by revoking its lease. Unlocking a Mutex that is
+// not held is a no-op.
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	h := m.holder
+	m.holder = nil
+	m.mu.Unlock()
+
+	if h == nil {
+		return nil
+	}
+	if m.opts.metrics != nil {
+		m.opts.metrics.Held(time.Since(h.acquired))
+	}
+	return h.release(m.cli)
+}
+
+// Key returns the key of the current acquisition, or "" if not held.
+func (m *Mutex) Key() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder == nil {
+		return ""
+	}
+	return m.holder.key
+}
+
+// Token returns a fencing token for the current acquisition, or 0 if not
+// held. It is the CreateRevision of the holder key, so it strictly increases
+// across successive acquisitions, whoever makes them: storage that remembers
+// the highest token it has seen can reject writes from a holder whose lease
+// expired while it was stalled.
+func (m *Mutex) Token() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder == nil {
+		return 0
+	}
+	return m.holder.rev
+}
+
+// WaitDuration returns how long the current acquisition took to get the
+// lock, or 0 if not held.
+func (m *Mutex) WaitDuration() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.holder == nil {
+		return 0
+	}
+	return m.holder.waited
+}
+
+// setHolder records h as the holder of an acquisition that started at start.
+func (m *Mutex) setHolder(h *holder, start time.Time) {
+	h.acquired = time.Now()
+	h.waited = h.acquired.Sub(start)
+	if m.opts.metrics != nil {
+		m.opts.metrics.Waited(h.waited)
+	}
+	m.mu.Lock()
+	m.holder = h
+	m.mu.Unlock()
+}
+
+// enqueue puts a new holder key under the prefix and reports whether it is
+// already the owner.
+func (m *Mutex) enqueue(ctx context.Context) (*holder, bool, error) {
+	// fetch the current owner in the same txn to finish the uncontended path in one round trip
+	extra := []clientv3.Op{clientv3.OpGet(m.prefix, clientv3.WithFirstCreate()...)}
+	if m.opts.metrics != nil {
+		extra = append(extra, clientv3.OpGet(m.prefix, clientv3.WithPrefix(), clientv3.WithCountOnly()))
+	}
+	h, resp, err := enqueue(ctx, m.cli, m.opts, m.prefix, extra...)
+	if err != nil {
+		return nil, false, err
+	}
+	if m.opts.metrics != nil {
+		m.opts.metrics.QueueDepth(int(resp.Responses[2].GetResponseRange().Count))
+	}
+	ownerKey := resp.Responses[1].GetResponseRange().Kvs
+	owner := len(ownerKey) == 0 || ownerKey[0].CreateRevision == h.rev
+	return h, owner, nil
+}
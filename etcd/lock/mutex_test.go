@@ -0,0 +1,265 @@
+package lock_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/lock"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type LockTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestLockTestSuite(t *testing.T) {
+	suite.Run(t, new(LockTestSuite))
+}
+
+func (s *LockTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *LockTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *LockTestSuite) countKeys(prefix string) int64 {
+	resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	return resp.Count
+}
+
+func (s *LockTestSuite) TestMutexFIFO() {
+	prefix := "/test/lock/fifo"
+	first := lock.NewMutex(s.cli, prefix)
+	s.NoError(first.Lock(context.Background()))
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for i, name := range []string{"second", "third"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			m := lock.NewMutex(s.cli, prefix)
+			s.NoError(m.Lock(context.Background()))
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			s.NoError(m.Unlock(context.Background()))
+		}(name)
+		// make sure waiters queue up in a known order
+		want := int64(i + 2)
+		s.Eventually(func() bool { return s.countKeys(prefix) == want }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	s.NoError(first.Unlock(context.Background()))
+	wg.Wait()
+	s.Equal([]string{"second", "third"}, order)
+	s.Zero(s.countKeys(prefix))
+}
+
+func (s *LockTestSuite) TestMutexTryLock() {
+	prefix := "/test/lock/try"
+	holder := lock.NewMutex(s.cli, prefix)
+	s.NoError(holder.TryLock(context.Background()))
+	defer holder.Unlock(context.Background())
+
+	m := lock.NewMutex(s.cli, prefix)
+	s.Equal(lock.ErrLocked, m.TryLock(context.Background()))
+	s.Equal(int64(1), s.countKeys(prefix))
+}
+
+func (s *LockTestSuite) TestMutexUnlockIdempotent() {
+	m := lock.NewMutex(s.cli, "/test/lock/idempotent")
+	s.NoError(m.Unlock(context.Background()))
+	s.NoError(m.Lock(context.Background()))
+	s.NotEmpty(m.Key())
+	s.NoError(m.Unlock(context.Background()))
+	s.NoError(m.Unlock(context.Background()))
+	s.Empty(m.Key())
+}
+
+func (s *LockTestSuite) TestMutexLockCancelled() {
+	prefix := "/test/lock/cancel"
+	holder := lock.NewMutex(s.cli, prefix)
+	s.NoError(holder.Lock(context.Background()))
+	defer holder.Unlock(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	m := lock.NewMutex(s.cli, prefix)
+	s.ErrorIs(m.Lock(ctx), context.DeadlineExceeded)
+
+	// the waiter's key must not leak
+	s.Equal(int64(1), s.countKeys(prefix))
+}
+
+func (s *LockTestSuite) TestMutexSharedSession() {
+	prefix := "/test/lock/shared"
+	sess, err := session.New(s.cli)
+	s.NoError(err)
+	defer sess.Close()
+
+	first := lock.NewMutex(s.cli, prefix, lock.WithSession(sess))
+	s.NoError(first.Lock(context.Background()))
+
+	// a second acquisition on the same session queues behind the first
+	acquired := make(chan error, 1)
+	second := lock.NewMutex(s.cli, prefix, lock.WithSession(sess))
+	go func() { acquired <- second.Lock(context.Background()) }()
+	select {
+	case err := <-acquired:
+		s.Failf("acquired while held", "%v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	s.NotEqual(first.Key(), second.Key())
+
+	s.NoError(first.Unlock(context.Background()))
+	select {
+	case err := <-acquired:
+		s.NoError(err)
+	case <-time.After(5 * time.Second):
+		s.Fail("not acquired after unlock")
+	}
+	s.NoError(second.Unlock(context.Background()))
+	s.Zero(s.countKeys(prefix))
+}
+
+func (s *LockTestSuite) TestMutexToken() {
+	prefix := "/test/lock/token"
+	first := lock.NewMutex(s.cli, prefix)
+	s.Zero(first.Token())
+	s.NoError(first.Lock(context.Background()))
+	token := first.Token()
+
+	getResp, err := s.cli.Get(context.Background(), first.Key())
+	s.NoError(err)
+	s.Equal(getResp.Kvs[0].CreateRevision, token)
+	s.NoError(first.Unlock(context.Background()))
+	s.Zero(first.Token())
+
+	second := lock.NewMutex(s.cli, prefix)
+	s.NoError(second.Lock(context.Background()))
+	s.Greater(second.Token(), token)
+	token = second.Token()
+	s.NoError(second.Unlock(context.Background()))
+
+	s.NoError(first.Lock(context.Background()))
+	defer first.Unlock(context.Background())
+	s.Greater(first.Token(), token)
+}
+
+func (s *LockTestSuite) TestMutexLockTimeout() {
+	prefix := "/test/lock/timeout"
+	holder := lock.NewMutex(s.cli, prefix)
+	s.NoError(holder.Lock(context.Background()))
+
+	m := lock.NewMutex(s.cli, prefix)
+	s.Equal(lock.ErrLockTimeout, m.LockTimeout(context.Background(), 200*time.Millisecond))
+	s.Empty(m.Key())
+	// the waiter's key must not leak
+	s.Equal(int64(1), s.countKeys(prefix))
+
+	s.NoError(holder.Unlock(context.Background()))
+	s.NoError(m.LockTimeout(context.Background(), 5*time.Second))
+	s.NoError(m.Unlock(context.Background()))
+	s.Zero(s.countKeys(prefix))
+
+	// a cancelled context is not a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.ErrorIs(m.LockTimeout(ctx, time.Second), context.Canceled)
+}
+
+// recordingMetrics records what a Mutex reports.
+type recordingMetrics struct {
+	mu     sync.Mutex
+	depths []int
+	waits  []time.Duration
+	holds  []time.Duration
+}
+
+func (m *recordingMetrics) QueueDepth(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.depths = append(m.depths, n)
+}
+
+func (m *recordingMetrics) Waited(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits = append(m.waits, d)
+}
+
+func (m *recordingMetrics) Held(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.holds = append(m.holds, d)
+}
+
+func (s *LockTestSuite) TestMutexMetrics() {
+	prefix := "/test/lock/metrics"
+	metrics := &recordingMetrics{}
+	first := lock.NewMutex(s.cli, prefix, lock.WithMetrics(metrics))
+	s.Zero(first.WaitDuration())
+	s.NoError(first.Lock(context.Background()))
+	s.Less(first.WaitDuration(), time.Second)
+
+	const hold = 200 * time.Millisecond
+	waits := make(chan time.Duration, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m := lock.NewMutex(s.cli, prefix, lock.WithMetrics(metrics))
+			s.NoError(m.Lock(context.Background()))
+			waits <- m.WaitDuration()
+			time.Sleep(hold)
+			s.NoError(m.Unlock(context.Background()))
+		}()
+		// queue the waiters one at a time so that each sees the ones before
+		want := int64(i + 2)
+		s.Eventually(func() bool { return s.countKeys(prefix) == want }, 5*time.Second, 10*time.Millisecond)
+	}
+
+	time.Sleep(hold)
+	s.NoError(first.Unlock(context.Background()))
+	wg.Wait()
+	close(waits)
+	s.Zero(first.WaitDuration())
+
+	// the i-th waiter waited for the first holder and the i-1 before it
+	var sorted []time.Duration
+	for d := range waits {
+		sorted = append(sorted, d)
+	}
+	slices.Sort(sorted)
+	for i, d := range sorted {
+		s.GreaterOrEqual(d, time.Duration(i+1)*hold)
+		s.Less(d, time.Duration(i+1)*hold+5*time.Second)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	s.Equal([]int{1, 2, 3, 4}, metrics.depths)
+	s.Len(metrics.waits, 4)
+	s.Len(metrics.holds, 4)
+	for _, d := range metrics.holds {
+		s.GreaterOrEqual(d, hold)
+	}
+	s.Zero(s.countKeys(prefix))
+}
@@ -0,0 +1,85 @@
+package lock
+
+import (
+	"context"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// RWMutex is a distributed reader/writer lock. Readers queue under
+// <prefix>/read/ and writers under <prefix>/write/, ordered by CreateRevision:
+// a reader waits only for writers queued before it, and a writer waits for
+// everyone queued before it. Readers arriving after a queued writer therefore
+// block behind it, so writers are not starved.
+//
+// Like sync.RWMutex, one RWMutex may hold several read locks at once, from
+// several goroutines; each RUnlock releases one of them.
+type RWMutex struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   options
+
+	mu      sync.Mutex
+	readers []*holder
+	writer  *holder
+}
+
+func NewRWMutex(cli *clientv3.Client, prefix string, opts ...Option) *RWMutex {
+	return &RWMutex{cli: cli, prefix: prefix + "/", opts: newOptions(opts)}
+}
+
+func (rw *RWMutex) RLock(ctx context.Context) error {
+	h, _, err := enqueue(ctx, rw.cli, rw.opts, rw.prefix+"read/")
+	if err != nil {
+		return err
+	}
+	if err := awaitTurn(ctx, rw.cli, h, rw.prefix+"write/"); err != nil {
+		return err
+	}
+	rw.mu.Lock()
+	rw.readers = append(rw.readers, h)
+	rw.mu.Unlock()
+	return nil
+}
+
+// RUnlock releases a read lock; it is a no-op if no read lock is held.
+func (rw *RWMutex) RUnlock(ctx context.Context) error {
+	rw.mu.Lock()
+	if len(rw.readers) == 0 {
+		rw.mu.Unlock()
+		return nil
+	}
+	h := rw.readers[len(rw.readers)-1]
+	rw.readers = rw.readers[:len(rw.readers)-1]
+	rw.mu.Unlock()
+
+	return h.release(rw.cli)
+}
+
+func (rw *RWMutex) Lock(ctx context.Context) error {
+	h, _, err := enqueue(ctx, rw.cli, rw.opts, rw.prefix+"write/")
+	if err != nil {
+		return err
+	}
+	if err := awaitTurn(ctx, rw.cli, h, rw.prefix); err != nil {
+		return err
+	}
+	rw.mu.Lock()
+	rw.writer = h
+	rw.mu.Unlock()
+	return nil
+}
+
+// Unlock releases the write lock; it is a no-op if the write lock is not held.
+func (rw *RWMutex) Unlock(ctx context.Context) error {
+	rw.mu.Lock()
+	h := rw.writer
+	rw.writer = nil
+	rw.mu.Unlock()
+
+	if h == nil {
+		return nil
+	}
+	return h.release(rw.cli)
+}
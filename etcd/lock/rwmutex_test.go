@@ -0,0 +1,156 @@
+package lock_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/lock"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+)
+
+func (s *LockTestSuite) TestRWMutexNoOverlap() {
+	prefix := "/test/rwlock/overlap"
+	var readers, writers int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rw := lock.NewRWMutex(s.cli, prefix)
+			for j := 0; j < 3; j++ {
+				s.NoError(rw.RLock(context.Background()))
+				atomic.AddInt32(&readers, 1)
+				s.Zero(atomic.LoadInt32(&writers))
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&readers, -1)
+				s.NoError(rw.RUnlock(context.Background()))
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rw := lock.NewRWMutex(s.cli, prefix)
+			for j := 0; j < 3; j++ {
+				s.NoError(rw.Lock(context.Background()))
+				s.Equal(int32(1), atomic.AddInt32(&writers, 1))
+				s.Zero(atomic.LoadInt32(&readers))
+				time.Sleep(20 * time.Millisecond)
+				atomic.AddInt32(&writers, -1)
+				s.NoError(rw.Unlock(context.Background()))
+			}
+		}()
+	}
+
+	wg.Wait()
+	s.Zero(s.countKeys(prefix))
+}
+
+func (s *LockTestSuite) TestRWMutexReadersShare() {
+	prefix := "/test/rwlock/share"
+	first, second := lock.NewRWMutex(s.cli, prefix), lock.NewRWMutex(s.cli, prefix)
+	s.NoError(first.RLock(context.Background()))
+	defer first.RUnlock(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.NoError(second.RLock(ctx))
+	s.NoError(second.RUnlock(context.Background()))
+}
+
+func (s *LockTestSuite) TestRWMutexSharedSession() {
+	prefix := "/test/rwlock/shared"
+	sess, err := session.New(s.cli)
+	s.NoError(err)
+	defer sess.Close()
+
+	first := lock.NewRWMutex(s.cli, prefix, lock.WithSession(sess))
+	second := lock.NewRWMutex(s.cli, prefix, lock.WithSession(sess))
+	s.NoError(first.RLock(context.Background()))
+	s.NoError(second.RLock(context.Background()))
+	s.NoError(first.RUnlock(context.Background()))
+
+	// the second reader still holds its lock
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(lock.NewRWMutex(s.cli, prefix).Lock(ctx), context.DeadlineExceeded)
+	s.NoError(second.RUnlock(context.Background()))
+}
+
+func (s *LockTestSuite) TestRWMutexConcurrentReaders() {
+	prefix := "/test/rwlock/concurrent"
+	rw := lock.NewRWMutex(s.cli, prefix)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.NoError(rw.RLock(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	// one read lock left, so the writer still waits
+	s.NoError(rw.RUnlock(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(lock.NewRWMutex(s.cli, prefix).Lock(ctx), context.DeadlineExceeded)
+
+	s.NoError(rw.RUnlock(context.Background()))
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	w := lock.NewRWMutex(s.cli, prefix)
+	s.NoError(w.Lock(ctx))
+	s.NoError(w.Unlock(context.Background()))
+	s.Zero(s.countKeys(prefix))
+}
+
+func (s *LockTestSuite) TestRWMutexWriterNotStarved() {
+	prefix := "/test/rwlock/starve"
+	reader := lock.NewRWMutex(s.cli, prefix)
+	s.NoError(reader.RLock(context.Background()))
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		writer := lock.NewRWMutex(s.cli, prefix)
+		s.NoError(writer.Lock(context.Background()))
+		record("writer")
+		time.Sleep(100 * time.Millisecond)
+		s.NoError(writer.Unlock(context.Background()))
+	}()
+	s.Eventually(func() bool { return s.countKeys(prefix) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	go func() {
+		defer wg.Done()
+		late := lock.NewRWMutex(s.cli, prefix)
+		s.NoError(late.RLock(context.Background()))
+		record("late reader")
+		s.NoError(late.RUnlock(context.Background()))
+	}()
+	s.Eventually(func() bool { return s.countKeys(prefix) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	// the late reader must queue behind the writer rather than join the first reader
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	s.Empty(order)
+	mu.Unlock()
+
+	s.NoError(reader.RUnlock(context.Background()))
+	wg.Wait()
+	s.Equal([]string{"writer", "late reader"}, order)
+}
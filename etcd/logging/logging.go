@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Client decorates a clientv3.KV, logging every operation at debug level with
+// its key, duration and outcome, and failed operations at warn level. Put it
+// beneath a retry.Client to see every failed attempt rather than only the
+// last one.
+type Client struct {
+	clientv3.KV
+	logger *slog.Logger
+}
+
+func New(kv clientv3.KV, logger *slog.Logger) *Client {
+	return &Client{KV: kv, logger: logger}
+}
+
+// log records an operation that started at start and failed if *err is set,
+// which is read once the deferred call runs. Nothing is allocated when
+// neither level is enabled.
+func (c *Client) log(ctx context.Context, op, key string, start time.Time, err *error) {
+	level := slog.LevelDebug
+	if *err != nil {
+		level = slog.LevelWarn
+	}
+	if !c.logger.Enabled(ctx, level) {
+		return
+	}
+
+	if *err != nil {
+		c.logger.LogAttrs(ctx, level, "etcd "+op+" failed",
+			slog.String("key", key), slog.Duration("duration", time.Since(start)), slog.Any("error", *err))
+		return
+	}
+	c.logger.LogAttrs(ctx, level, "etcd "+op,
+		slog.String("key", key), slog.Duration("duration", time.Since(start)))
+}
+
+func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (resp *clientv3.PutResponse, err error) {
+	defer c.log(ctx, "put", key, time.Now(), &err)
+	return c.KV.Put(ctx, key, val, opts...)
+}
+
+func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.GetResponse, err error) {
+	defer c.log(ctx, "get", key, time.Now(), &err)
+	return c.KV.Get(ctx, key, opts...)
+}
+
+func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.DeleteResponse, err error) {
+	defer c.log(ctx, "delete", key, time.Now(), &err)
+	return c.KV.Delete(ctx, key, opts...)
+}
+
+func (c *Client) Do(ctx context.Context, op clientv3.Op) (resp clientv3.OpResponse, err error) {
+	defer c.log(ctx, "do", string(op.KeyBytes()), time.Now(), &err)
+	return c.KV.Do(ctx, op)
+}
+
+// Txn logs the txn when it is committed.
+func (c *Client) Txn(ctx context.Context) clientv3.Txn {
+	return &txn{Txn: c.KV.Txn(ctx), ctx: ctx, c: c}
+}
+
+type txn struct {
+	clientv3.Txn
+	ctx context.Context
+	c   *Client
+}
+
+func (t *txn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *txn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *txn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *txn) Commit() (resp *clientv3.TxnResponse, err error) {
+	defer t.c.log(t.ctx, "txn", "", time.Now(), &err)
+	return t.Txn.Commit()
+}
+
+// Watcher decorates w, logging the lifecycle of every watch: when it is
+// established, each batch of events at debug level, a compaction or other
+// failure at warn level, and when it is closed.
+func (c *Client) Watcher(w clientv3.Watcher) clientv3.Watcher {
+	return &watcher{Watcher: w, logger: c.logger}
+}
+
+type watcher struct {
+	clientv3.Watcher
+	logger *slog.Logger
+}
+
+func (w *watcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	in := w.Watcher.Watch(ctx, key, opts...)
+	w.logger.LogAttrs(ctx, slog.LevelInfo, "etcd watch established", slog.String("key", key))
+
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+		var events int
+		defer func() {
+			w.logger.LogAttrs(ctx, slog.LevelInfo, "etcd watch closed", slog.String("key", key), slog.Int("events", events))
+		}()
+
+		for watchResp := range in {
+			events += len(watchResp.Events)
+			switch err := watchResp.Err(); {
+			case err == rpctypes.ErrCompacted:
+				w.logger.LogAttrs(ctx, slog.LevelWarn, "etcd watch compacted",
+					slog.String("key", key), slog.Int64("compactRevision", watchResp.CompactRevision))
+			case err != nil:
+				w.logger.LogAttrs(ctx, slog.LevelWarn, "etcd watch failed", slog.String("key", key), slog.Any("error", err))
+			case w.logger.Enabled(ctx, slog.LevelDebug):
+				w.logger.LogAttrs(ctx, slog.LevelDebug, "etcd watch events",
+					slog.String("key", key), slog.Int("events", len(watchResp.Events)), slog.Int64("revision", watchResp.Header.Revision))
+			}
+
+			select {
+			case out <- watchResp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
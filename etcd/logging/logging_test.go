@@ -0,0 +1,173 @@
+package logging_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/logging"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var errBroken = errors.New("broken")
+
+// brokenKV fails every Delete.
+type brokenKV struct {
+	clientv3.KV
+}
+
+func (kv *brokenKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	return nil, errBroken
+}
+
+type record struct {
+	Level   slog.Level
+	Message string
+	Attrs   map[string]slog.Value
+}
+
+// handler captures the records logged at or above level.
+type handler struct {
+	level slog.Level
+
+	mu      sync.Mutex
+	records []record
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	rec := record{Level: r.Level, Message: r.Message, Attrs: make(map[string]slog.Value)}
+	r.Attrs(func(a slog.Attr) bool {
+		rec.Attrs[a.Key] = a.Value
+		return true
+	})
+	h.mu.Lock()
+	h.records = append(h.records, rec)
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler { return h }
+
+func (h *handler) WithGroup(name string) slog.Handler { return h }
+
+func (h *handler) Records() []record {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]record(nil), h.records...)
+}
+
+func messages(records []record) []string {
+	msgs := make([]string, 0, len(records))
+	for _, r := range records {
+		msgs = append(msgs, r.Message)
+	}
+	return msgs
+}
+
+type LoggingTestSuite struct {
+	suite.Suite
+	h   *handler
+	cli *clientv3.Client
+	c   *logging.Client
+}
+
+func TestLoggingTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
+
+func (s *LoggingTestSuite) SetupTest() {
+	s.h = &handler{level: slog.LevelDebug}
+	s.cli = fake.NewClient(fake.NewClock())
+	s.c = logging.New(&brokenKV{KV: s.cli.KV}, slog.New(s.h))
+}
+
+func (s *LoggingTestSuite) TearDownTest() {
+	s.cli.Close()
+}
+
+func (s *LoggingTestSuite) TestOps() {
+	ctx := context.Background()
+	_, err := s.c.Put(ctx, "/t/a", "1")
+	s.NoError(err)
+	_, err = s.c.Get(ctx, "/t/", clientv3.WithPrefix())
+	s.NoError(err)
+	_, err = s.c.Txn(ctx).If(clientv3.Compare(clientv3.Version("/t/a"), "=", 1)).Then(clientv3.OpPut("/t/a", "2")).Commit()
+	s.NoError(err)
+	_, err = s.c.Do(ctx, clientv3.OpGet("/t/a"))
+	s.NoError(err)
+	_, err = s.c.Delete(ctx, "/t/a")
+	s.ErrorIs(err, errBroken)
+
+	records := s.h.Records()
+	s.Equal([]string{"etcd put", "etcd get", "etcd txn", "etcd do", "etcd delete failed"}, messages(records))
+	for _, r := range records[:4] {
+		s.Equal(slog.LevelDebug, r.Level)
+		s.Contains(r.Attrs, "duration")
+	}
+	s.Equal("/t/a", records[0].Attrs["key"].String())
+	s.Equal("/t/", records[1].Attrs["key"].String())
+
+	failed := records[4]
+	s.Equal(slog.LevelWarn, failed.Level)
+	s.Equal("/t/a", failed.Attrs["key"].String())
+	s.Equal(errBroken, failed.Attrs["error"].Any())
+}
+
+func (s *LoggingTestSuite) TestLevel() {
+	s.h.level = slog.LevelWarn
+	ctx := context.Background()
+	_, err := s.c.Put(ctx, "/t/a", "1")
+	s.NoError(err)
+	_, err = s.c.Delete(ctx, "/t/a")
+	s.ErrorIs(err, errBroken)
+
+	// only the failure is above the level
+	s.Equal([]string{"etcd delete failed"}, messages(s.h.Records()))
+}
+
+func (s *LoggingTestSuite) TestWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchChan := s.c.Watcher(s.cli.Watcher).Watch(ctx, "/t/", clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), "/t/a", "1")
+	s.NoError(err)
+	select {
+	case <-watchChan:
+	case <-time.After(time.Second):
+		s.FailNow("no event")
+	}
+
+	cancel()
+	for range watchChan {
+	}
+	records := s.h.Records()
+	s.Equal([]string{"etcd watch established", "etcd watch events", "etcd watch closed"}, messages(records))
+	s.Equal(int64(1), records[1].Attrs["events"].Int64())
+	s.Equal(int64(1), records[2].Attrs["events"].Int64())
+}
+
+func (s *LoggingTestSuite) TestWatchCompacted() {
+	ctx := context.Background()
+	_, err := s.cli.Put(ctx, "/t/a", "1")
+	s.NoError(err)
+	putResp, err := s.cli.Put(ctx, "/t/a", "2")
+	s.NoError(err)
+	_, err = s.cli.Compact(ctx, putResp.Header.Revision)
+	s.NoError(err)
+
+	watchChan := s.c.Watcher(s.cli.Watcher).Watch(ctx, "/t/", clientv3.WithPrefix(), clientv3.WithRev(1))
+	for range watchChan {
+	}
+	records := s.h.Records()
+	s.Equal([]string{"etcd watch established", "etcd watch compacted", "etcd watch closed"}, messages(records))
+	s.Equal(slog.LevelWarn, records[1].Level)
+	s.Equal(putResp.Header.Revision, records[1].Attrs["compactRevision"].Int64())
+}
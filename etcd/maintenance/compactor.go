@@ -0,0 +1,150 @@
+package maintenance
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultKeepRevisions = 10000
+
+type compactorOptions struct {
+	keep      int64
+	retention time.Duration
+	clock     clock.Clock
+	onError   func(error)
+}
+
+type CompactorOption func(*compactorOptions)
+
+// WithKeepRevisions keeps the last n revisions, 10000 by default.
+func WithKeepRevisions(n int64) CompactorOption {
+	return func(o *compactorOptions) {
+		o.keep, o.retention = n, 0
+	}
+}
+
+// WithRetention keeps the revisions of the last d instead of a fixed number
+// of them. The Compactor has to have run for d before it first compacts,
+// since it learns which revision was current when from its own runs.
+func WithRetention(d time.Duration) CompactorOption {
+	return func(o *compactorOptions) {
+		o.retention = d
+	}
+}
+
+// WithClock makes the retention window follow c.
+func WithClock(c clock.Clock) CompactorOption {
+	return func(o *compactorOptions) {
+		o.clock = c
+	}
+}
+
+// WithCompactorOnError is called with the errors of RunPeriodically. They are
+// dropped by default.
+func WithCompactorOnError(fn func(error)) CompactorOption {
+	return func(o *compactorOptions) {
+		o.onError = fn
+	}
+}
+
+type sample struct {
+	at  time.Time
+	rev int64
+}
+
+// Compactor compacts the history of the keyspace, keeping only the recent
+// revisions.
+type Compactor struct {
+	kv   clientv3.KV
+	opts compactorOptions
+
+	mu        sync.Mutex
+	samples   []sample
+	compacted int64
+}
+
+func NewCompactor(kv clientv3.KV, opts ...CompactorOption) *Compactor {
+	o := compactorOptions{keep: defaultKeepRevisions, clock: clock.Real, onError: func(error) {}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Compactor{kv: kv, opts: o}
+}
+
+// CompactNow compacts up to the target revision and waits for the freed
+// space to be reclaimed. It returns the revision compacted to, or 0 if there
+// is nothing to compact. A target that someone else already compacted past
+// counts as nothing to compact.
+func (c *Compactor) CompactNow(ctx context.Context) (int64, error) {
+	getResp, err := c.kv.Get(ctx, "/", clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	rev := getResp.Header.Revision
+
+	c.mu.Lock()
+	target := c.target(rev)
+	skip := target <= c.compacted || target > rev
+	c.mu.Unlock()
+	if target <= 0 || skip {
+		return 0, nil
+	}
+
+	_, err = c.kv.Compact(ctx, target, clientv3.WithCompactPhysical())
+	if err == rpctypes.ErrCompacted {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	if target > c.compacted {
+		c.compacted = target
+	}
+	c.mu.Unlock()
+	return target, nil
+}
+
+// target returns the revision to compact to given the current one.
+func (c *Compactor) target(rev int64) int64 {
+	if c.opts.retention == 0 {
+		return rev - c.opts.keep
+	}
+
+	// the newest revision that was current at least retention ago
+	now := c.opts.clock.Now()
+	c.samples = append(c.samples, sample{at: now, rev: rev})
+	cutoff := now.Add(-c.opts.retention)
+	var target int64
+	i := 0
+	for ; i < len(c.samples) && !c.samples[i].at.After(cutoff); i++ {
+		target = c.samples[i].rev
+	}
+	// keep the sample found, later targets can only be newer
+	if i > 0 {
+		c.samples = c.samples[i-1:]
+	}
+	return target
+}
+
+// RunPeriodically calls CompactNow every interval until ctx is done.
+func (c *Compactor) RunPeriodically(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if _, err := c.CompactNow(ctx); err != nil && ctx.Err() == nil {
+			c.opts.onError(err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package maintenance_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/maintenance"
+)
+
+// puts makes n writes, moving the revision forward by n.
+func (s *MaintenanceTestSuite) puts(kv *fake.KV, n int) {
+	for i := 0; i < n; i++ {
+		_, err := kv.Put(context.Background(), fmt.Sprintf("/k%d", i), "v")
+		s.NoError(err)
+	}
+}
+
+func (s *MaintenanceTestSuite) TestCompactKeepRevisions() {
+	store := fake.NewKV()
+	kv := &compactKV{KV: store}
+	c := maintenance.NewCompactor(kv, maintenance.WithKeepRevisions(10))
+
+	// not enough history yet
+	s.puts(store, 5)
+	rev, err := c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Zero(rev)
+
+	s.puts(store, 20)
+	rev, err = c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Equal(store.Rev()-10, rev)
+
+	// nothing new to compact
+	rev, err = c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Zero(rev)
+	s.Equal([]int64{store.Rev() - 10}, kv.Revs())
+
+	// compacting beyond the current revision is refused
+	c = maintenance.NewCompactor(kv, maintenance.WithKeepRevisions(-5))
+	rev, err = c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Zero(rev)
+	s.Len(kv.Revs(), 1)
+}
+
+func (s *MaintenanceTestSuite) TestCompactRetention() {
+	store := fake.NewKV()
+	kv := &compactKV{KV: store}
+	clock := fake.NewClock()
+	c := maintenance.NewCompactor(kv, maintenance.WithRetention(time.Hour), maintenance.WithClock(clock))
+
+	s.puts(store, 5)
+	rev, err := c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Zero(rev)
+	first := store.Rev()
+
+	clock.Advance(30 * time.Minute)
+	s.puts(store, 5)
+	rev, err = c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Zero(rev)
+	second := store.Rev()
+
+	// what was current an hour ago can go
+	clock.Advance(30 * time.Minute)
+	s.puts(store, 5)
+	rev, err = c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Equal(first, rev)
+
+	clock.Advance(45 * time.Minute)
+	rev, err = c.CompactNow(context.Background())
+	s.NoError(err)
+	s.Equal(second, rev)
+	s.Equal([]int64{first, second}, kv.Revs())
+}
@@ -0,0 +1,125 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type DefragResult struct {
+	Endpoint string
+	Duration time.Duration
+	Err      error
+}
+
+// Window is a daily maintenance window, as offsets from local midnight. A
+// window whose End is before its Start spans midnight.
+type Window struct {
+	Start, End time.Duration
+}
+
+// Contains reports whether t falls within the window.
+func (w Window) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+type defragOptions struct {
+	pause   time.Duration
+	onError func(error)
+}
+
+type DefragOption func(*defragOptions)
+
+// WithPause waits d between two members, giving each time to catch up.
+func WithPause(d time.Duration) DefragOption {
+	return func(o *defragOptions) {
+		o.pause = d
+	}
+}
+
+// WithDefragOnError is called with the errors of the runs of RunInWindow.
+// They are dropped by default.
+func WithDefragOnError(fn func(error)) DefragOption {
+	return func(o *defragOptions) {
+		o.onError = fn
+	}
+}
+
+// Defrag defragments the members of a cluster one after the other. A member
+// does not serve requests while it defragments, so doing them all at once
+// could take the cluster down.
+type Defrag struct {
+	m         clientv3.Maintenance
+	endpoints []string
+	opts      defragOptions
+}
+
+func NewDefrag(m clientv3.Maintenance, endpoints []string, opts ...DefragOption) *Defrag {
+	o := defragOptions{onError: func(error) {}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Defrag{m: m, endpoints: endpoints, opts: o}
+}
+
+// DefragAll defragments every endpoint in turn and returns a result for each
+// one it got to. A failing member does not stop the others, but ctx being
+// done does. The returned error joins the failures.
+func (d *Defrag) DefragAll(ctx context.Context) ([]DefragResult, error) {
+	results := make([]DefragResult, 0, len(d.endpoints))
+	var errs []error
+	for i, ep := range d.endpoints {
+		if i > 0 && d.opts.pause > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(d.opts.pause):
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		start := time.Now()
+		_, err := d.m.Defragment(ctx, ep)
+		results = append(results, DefragResult{Endpoint: ep, Duration: time.Since(start), Err: err})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("maintenance: defragment %s: %w", ep, err))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// RunInWindow checks every interval until ctx is done and calls DefragAll
+// once per occurrence of the window, on the first check that falls within
+// it. Failed runs are reported to WithDefragOnError.
+func (d *Defrag) RunInWindow(ctx context.Context, w Window, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var ran bool
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if !w.Contains(time.Now()) {
+			ran = false
+			continue
+		}
+		if !ran {
+			ran = true
+			if _, err := d.DefragAll(ctx); err != nil && ctx.Err() == nil {
+				d.opts.onError(err)
+			}
+		}
+	}
+}
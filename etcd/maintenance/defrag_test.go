@@ -0,0 +1,123 @@
+package maintenance_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/maintenance"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// defragMaintenance records the endpoints defragmented and how many calls
+// were ever in flight at once.
+type defragMaintenance struct {
+	clientv3.Maintenance
+
+	mu          sync.Mutex
+	calls       []string
+	inFlight    int
+	maxInFlight int
+	fail        map[string]error
+}
+
+func (m *defragMaintenance) Defragment(ctx context.Context, endpoint string) (*clientv3.DefragmentResponse, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, endpoint)
+	m.inFlight++
+	if m.inFlight > m.maxInFlight {
+		m.maxInFlight = m.inFlight
+	}
+	m.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	m.mu.Lock()
+	m.inFlight--
+	err := m.fail[endpoint]
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &clientv3.DefragmentResponse{}, nil
+}
+
+func (s *MaintenanceTestSuite) TestDefragAllSequential() {
+	m := &defragMaintenance{}
+	endpoints := []string{"a:2379", "b:2379", "c:2379"}
+	d := maintenance.NewDefrag(m, endpoints, maintenance.WithPause(20*time.Millisecond))
+
+	start := time.Now()
+	results, err := d.DefragAll(context.Background())
+	s.NoError(err)
+	s.GreaterOrEqual(time.Since(start), 40*time.Millisecond)
+
+	s.Equal(endpoints, m.calls)
+	s.Equal(1, m.maxInFlight)
+	s.Len(results, 3)
+	for i, res := range results {
+		s.Equal(endpoints[i], res.Endpoint)
+		s.NoError(res.Err)
+	}
+}
+
+func (s *MaintenanceTestSuite) TestDefragAllContinuesOnFailure() {
+	errDown := errors.New("member down")
+	m := &defragMaintenance{fail: map[string]error{"b:2379": errDown}}
+	d := maintenance.NewDefrag(m, []string{"a:2379", "b:2379", "c:2379"})
+
+	results, err := d.DefragAll(context.Background())
+	s.ErrorIs(err, errDown)
+	s.Contains(err.Error(), "b:2379")
+	s.Len(results, 3)
+	s.NoError(results[0].Err)
+	s.ErrorIs(results[1].Err, errDown)
+	s.NoError(results[2].Err)
+}
+
+func (s *MaintenanceTestSuite) TestDefragAllCancelled() {
+	m := &defragMaintenance{}
+	d := maintenance.NewDefrag(m, []string{"a:2379", "b:2379"}, maintenance.WithPause(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results, err := d.DefragAll(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.Len(results, 1)
+	s.Equal([]string{"a:2379"}, m.calls)
+}
+
+func (s *MaintenanceTestSuite) TestRunInWindowReportsErrors() {
+	errDown := errors.New("member down")
+	m := &defragMaintenance{fail: map[string]error{"b:2379": errDown}}
+	errs := make(chan error, 1)
+	d := maintenance.NewDefrag(m, []string{"a:2379", "b:2379"}, maintenance.WithDefragOnError(func(err error) { errs <- err }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.RunInWindow(ctx, maintenance.Window{Start: 0, End: 24 * time.Hour}, 10*time.Millisecond)
+
+	select {
+	case err := <-errs:
+		s.ErrorIs(err, errDown)
+	case <-time.After(time.Second):
+		s.Fail("failed defragmentation not reported")
+	}
+}
+
+func (s *MaintenanceTestSuite) TestWindowContains() {
+	day := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	w := maintenance.Window{Start: 2 * time.Hour, End: 4 * time.Hour}
+	s.False(w.Contains(day.Add(time.Hour)))
+	s.True(w.Contains(day.Add(2 * time.Hour)))
+	s.True(w.Contains(day.Add(3 * time.Hour)))
+	s.False(w.Contains(day.Add(4 * time.Hour)))
+
+	// spans midnight
+	w = maintenance.Window{Start: 23 * time.Hour, End: time.Hour}
+	s.True(w.Contains(day.Add(23*time.Hour + 30*time.Minute)))
+	s.True(w.Contains(day.Add(30 * time.Minute)))
+	s.False(w.Contains(day.Add(12 * time.Hour)))
+}
@@ -0,0 +1,39 @@
+package maintenance_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// compactKV records the revisions compacted to.
+type compactKV struct {
+	clientv3.KV
+
+	mu   sync.Mutex
+	revs []int64
+}
+
+func (kv *compactKV) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	kv.mu.Lock()
+	kv.revs = append(kv.revs, rev)
+	kv.mu.Unlock()
+	return kv.KV.Compact(ctx, rev, opts...)
+}
+
+func (kv *compactKV) Revs() []int64 {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return append([]int64(nil), kv.revs...)
+}
+
+type MaintenanceTestSuite struct {
+	suite.Suite
+}
+
+func TestMaintenanceTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceTestSuite))
+}
@@ -0,0 +1,97 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// ReportClient is the part of the client KeyspaceReport needs;
+// *clientv3.Client implements it.
+type ReportClient interface {
+	etcdx.KV
+	Endpoints() []string
+	Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error)
+}
+
+// PrefixUsage is how much of the keyspace one prefix takes.
+type PrefixUsage struct {
+	Prefix     string
+	Keys       int64
+	ValueBytes int64
+}
+
+type Report struct {
+	// Prefixes line up with the prefixes asked for.
+	Prefixes []PrefixUsage
+	// DBSize and DBSizeInUse are those of the largest member that answered:
+	// the size of its backend file, and how much of it is not free pages
+	// that a defragmentation would reclaim.
+	DBSize      int64
+	DBSizeInUse int64
+}
+
+// KeyspaceReport tells how many keys are under each of topPrefixes and how
+// many bytes their values take, next to the size of the database, to find
+// which subtree makes it grow. The values are summed page by page, so that
+// a large subtree is never loaded at once. The prefixes are read one after
+// the other, not at a single revision.
+//
+// Members that do not answer their status are left out of the sizes; the
+// report fails only if none does.
+func KeyspaceReport(ctx context.Context, cli ReportClient, topPrefixes []string) (Report, error) {
+	var report Report
+	for _, prefix := range topPrefixes {
+		usage, err := prefixUsage(ctx, cli, prefix)
+		if err != nil {
+			return Report{}, fmt.Errorf("maintenance: report %s: %w", prefix, err)
+		}
+		report.Prefixes = append(report.Prefixes, usage)
+	}
+
+	endpoints := cli.Endpoints()
+	var errs []error
+	for _, ep := range endpoints {
+		resp, err := cli.Status(ctx, ep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("maintenance: status of %s: %w", ep, err))
+			continue
+		}
+		if resp.DbSize > report.DBSize {
+			report.DBSize, report.DBSizeInUse = resp.DbSize, resp.DbSizeInUse
+		}
+	}
+	if len(endpoints) > 0 && len(errs) == len(endpoints) {
+		return Report{}, errors.Join(errs...)
+	}
+	return report, nil
+}
+
+func prefixUsage(ctx context.Context, cli etcdx.KV, prefix string) (PrefixUsage, error) {
+	n, err := kv.Count(ctx, cli, prefix)
+	if err != nil {
+		return PrefixUsage{}, err
+	}
+	usage := PrefixUsage{Prefix: prefix, Keys: n}
+	if n == 0 {
+		return usage, nil
+	}
+
+	it := kv.NewRangeIterator(cli, prefix)
+	for {
+		kvs, more, err := it.Next(ctx)
+		if err != nil {
+			return PrefixUsage{}, err
+		}
+		for _, item := range kvs {
+			usage.ValueBytes += int64(len(item.Value))
+		}
+		if !more {
+			return usage, nil
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package maintenance_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/maintenance"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// reportClient serves a fake KV and fixed member statuses, nil for a member
+// that does not answer.
+type reportClient struct {
+	*fake.KV
+	statuses map[string]*clientv3.StatusResponse
+	order    []string
+}
+
+func (c *reportClient) Endpoints() []string {
+	return c.order
+}
+
+func (c *reportClient) Status(ctx context.Context, endpoint string) (*clientv3.StatusResponse, error) {
+	if resp := c.statuses[endpoint]; resp != nil {
+		return resp, nil
+	}
+	return nil, errors.New("unreachable")
+}
+
+func (s *MaintenanceTestSuite) TestKeyspaceReport() {
+	cli := &reportClient{
+		KV: fake.NewKV(),
+		statuses: map[string]*clientv3.StatusResponse{
+			"a": {DbSize: 4096, DbSizeInUse: 1024},
+			"b": {DbSize: 8192, DbSizeInUse: 2048},
+		},
+		order: []string{"a", "b", "c"},
+	}
+	// more keys than a page under /big/
+	var bigBytes int64
+	for i := 0; i < 250; i++ {
+		val := strings.Repeat("x", i%7)
+		bigBytes += int64(len(val))
+		_, err := cli.Put(context.Background(), fmt.Sprint("/big/", i), val)
+		s.Require().NoError(err)
+	}
+	for _, key := range []string{"/small/a", "/small/b", "/smaller"} {
+		_, err := cli.Put(context.Background(), key, "12345")
+		s.Require().NoError(err)
+	}
+
+	report, err := maintenance.KeyspaceReport(context.Background(), cli, []string{"/big/", "/small/", "/none/"})
+	s.NoError(err)
+	s.Equal([]maintenance.PrefixUsage{
+		{Prefix: "/big/", Keys: 250, ValueBytes: bigBytes},
+		{Prefix: "/small/", Keys: 2, ValueBytes: 10},
+		{Prefix: "/none/"},
+	}, report.Prefixes)
+	s.Equal(int64(8192), report.DBSize)
+	s.Equal(int64(2048), report.DBSizeInUse)
+
+	s.Run("No member answers", func() {
+		cli := &reportClient{KV: fake.NewKV(), order: []string{"a"}}
+		_, err := maintenance.KeyspaceReport(context.Background(), cli, nil)
+		s.Error(err)
+	})
+}
@@ -0,0 +1,92 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrSnapshotCorrupt = errors.New("maintenance: snapshot integrity hash mismatch")
+
+type SnapshotInfo struct {
+	Path string
+	Size int64
+	// SHA256 is the hex hash of the whole file, to check copies of it against.
+	SHA256 string
+}
+
+// Snapshot saves a snapshot of the member's database to path, like etcdctl
+// snapshot save. The snapshot is streamed to a temporary file next to path and
+// renamed once complete and verified, so that an interrupted download never
+// leaves a file at path.
+func Snapshot(ctx context.Context, m clientv3.Maintenance, path string) (SnapshotInfo, error) {
+	rc, err := m.Snapshot(ctx)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".part*")
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), rc)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("maintenance: save snapshot: %w", err)
+	}
+	if err := VerifySnapshot(tmp); err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return SnapshotInfo{}, err
+	}
+	return SnapshotInfo{Path: path, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// VerifySnapshot checks the SHA-256 hash etcd appends to a snapshot against
+// the database before it.
+func VerifySnapshot(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() < sha256.Size {
+		return ErrSnapshotCorrupt
+	}
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, st.Size()-sha256.Size); err != nil {
+		return err
+	}
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(f, sum); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, h.Sum(nil)) {
+		return ErrSnapshotCorrupt
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package maintenance_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/maintenance"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// snapshotMaintenance streams a fixed snapshot, failing with err once it is
+// sent.
+type snapshotMaintenance struct {
+	clientv3.Maintenance
+	data []byte
+	err  error
+}
+
+func (m *snapshotMaintenance) Snapshot(ctx context.Context) (io.ReadCloser, error) {
+	r := io.Reader(strings.NewReader(string(m.data)))
+	if m.err != nil {
+		r = io.MultiReader(r, &errReader{m.err})
+	}
+	return io.NopCloser(r), nil
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// snapshotData is a database followed by its hash, as etcd sends it.
+func snapshotData(db string) []byte {
+	sum := sha256.Sum256([]byte(db))
+	return append([]byte(db), sum[:]...)
+}
+
+func (s *MaintenanceTestSuite) TestSnapshot() {
+	data := snapshotData("bolt database")
+	path := filepath.Join(s.T().TempDir(), "etcd.db")
+
+	info, err := maintenance.Snapshot(context.Background(), &snapshotMaintenance{data: data}, path)
+	s.Require().NoError(err)
+	sum := sha256.Sum256(data)
+	s.Equal(maintenance.SnapshotInfo{Path: path, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}, info)
+
+	got, err := os.ReadFile(path)
+	s.NoError(err)
+	s.Equal(data, got)
+	s.NoError(maintenance.VerifySnapshot(path))
+}
+
+func (s *MaintenanceTestSuite) TestSnapshotInterrupted() {
+	dir := s.T().TempDir()
+	path := filepath.Join(dir, "etcd.db")
+
+	errBroken := errors.New("stream broken")
+	_, err := maintenance.Snapshot(context.Background(), &snapshotMaintenance{data: []byte("bolt"), err: errBroken}, path)
+	s.ErrorIs(err, errBroken)
+
+	// a truncated stream fails the integrity check
+	_, err = maintenance.Snapshot(context.Background(), &snapshotMaintenance{data: snapshotData("bolt database")[:20]}, path)
+	s.ErrorIs(err, maintenance.ErrSnapshotCorrupt)
+
+	entries, err := os.ReadDir(dir)
+	s.NoError(err)
+	s.Empty(entries)
+}
+
+func (s *MaintenanceTestSuite) TestVerifySnapshot() {
+	dir := s.T().TempDir()
+	corrupt := snapshotData("bolt database")
+	corrupt[0] = 'B'
+	s.NoError(os.WriteFile(filepath.Join(dir, "corrupt.db"), corrupt, 0o600))
+	s.NoError(os.WriteFile(filepath.Join(dir, "short.db"), []byte("bolt"), 0o600))
+
+	s.ErrorIs(maintenance.VerifySnapshot(filepath.Join(dir, "corrupt.db")), maintenance.ErrSnapshotCorrupt)
+	s.ErrorIs(maintenance.VerifySnapshot(filepath.Join(dir, "short.db")), maintenance.ErrSnapshotCorrupt)
+}
@@ -0,0 +1,319 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type options struct {
+	namespace string
+	subsystem string
+}
+
+type Option func(*options)
+
+// WithNamespace sets the first part of the metric names, "etcd" by default.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithSubsystem sets the middle part of the metric names, "client" by
+// default.
+func WithSubsystem(subsystem string) Option {
+	return func(o *options) {
+		o.subsystem = subsystem
+	}
+}
+
+// Client decorates a clientv3.KV, recording the latency and the errors of
+// every operation by op type: put, get, delete, txn or compact.
+type Client struct {
+	clientv3.KV
+
+	duration   *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+	events     prometheus.Counter
+	keepAlives prometheus.Counter
+	watchLag   *prometheus.GaugeVec
+	processed  *prometheus.CounterVec
+	dropped    *prometheus.CounterVec
+	lockWait   *prometheus.HistogramVec
+	lockHold   *prometheus.HistogramVec
+	lockQueue  *prometheus.GaugeVec
+}
+
+// New registers the metrics with reg and decorates kv.
+func New(kv clientv3.KV, reg prometheus.Registerer, opts ...Option) (*Client, error) {
+	o := options{namespace: "etcd", subsystem: "client"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	c := &Client{
+		KV: kv,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "op_duration_seconds",
+			Help:      "Latency of etcd operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "op_errors_total",
+			Help:      "Failed etcd operations.",
+		}, []string{"op"}),
+		events: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "watch_events_total",
+			Help:      "Events received by watches.",
+		}),
+		keepAlives: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "lease_keepalives_total",
+			Help:      "Lease keep-alive renewals received.",
+		}),
+		watchLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "watch_lag_revisions",
+			Help:      "Revisions between the cluster and what a watcher has processed.",
+		}, []string{"watcher"}),
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "watch_processed_events_total",
+			Help:      "Events handed to the consumer of a watcher.",
+		}, []string{"watcher"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "watch_dropped_events_total",
+			Help:      "Events a watcher dropped because its consumer fell behind.",
+		}, []string{"watcher"}),
+		lockWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "lock_wait_seconds",
+			Help:      "Time taken to acquire a lock.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"lock"}),
+		lockHold: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "lock_hold_seconds",
+			Help:      "Time a lock was held for.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"lock"}),
+		lockQueue: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: o.namespace,
+			Subsystem: o.subsystem,
+			Name:      "lock_queue_depth",
+			Help:      "Acquisitions queued for a lock, the holder included, at the last attempt.",
+		}, []string{"lock"}),
+	}
+	for _, collector := range []prometheus.Collector{c.duration, c.errors, c.events, c.keepAlives, c.watchLag, c.processed, c.dropped, c.lockWait, c.lockHold, c.lockQueue} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// observe records an operation that started at start and failed if *err is
+// set, which is read once the deferred call runs.
+func (c *Client) observe(op string, start time.Time, err *error) {
+	c.duration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		c.errors.WithLabelValues(op).Inc()
+	}
+}
+
+func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (resp *clientv3.PutResponse, err error) {
+	defer c.observe("put", time.Now(), &err)
+	return c.KV.Put(ctx, key, val, opts...)
+}
+
+func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.GetResponse, err error) {
+	defer c.observe("get", time.Now(), &err)
+	return c.KV.Get(ctx, key, opts...)
+}
+
+func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.DeleteResponse, err error) {
+	defer c.observe("delete", time.Now(), &err)
+	return c.KV.Delete(ctx, key, opts...)
+}
+
+func (c *Client) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (resp *clientv3.CompactResponse, err error) {
+	defer c.observe("compact", time.Now(), &err)
+	return c.KV.Compact(ctx, rev, opts...)
+}
+
+func (c *Client) Do(ctx context.Context, op clientv3.Op) (resp clientv3.OpResponse, err error) {
+	defer c.observe(opType(op), time.Now(), &err)
+	return c.KV.Do(ctx, op)
+}
+
+// Txn records the txn when it is committed.
+func (c *Client) Txn(ctx context.Context) clientv3.Txn {
+	return &txn{Txn: c.KV.Txn(ctx), c: c}
+}
+
+type txn struct {
+	clientv3.Txn
+	c *Client
+}
+
+func (t *txn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *txn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *txn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *txn) Commit() (resp *clientv3.TxnResponse, err error) {
+	defer t.c.observe("txn", time.Now(), &err)
+	return t.Txn.Commit()
+}
+
+func opType(op clientv3.Op) string {
+	switch {
+	case op.IsPut():
+		return "put"
+	case op.IsGet():
+		return "get"
+	case op.IsDelete():
+		return "delete"
+	case op.IsTxn():
+		return "txn"
+	}
+	return "unknown"
+}
+
+// Watcher decorates w, counting the events its watches receive.
+func (c *Client) Watcher(w clientv3.Watcher) clientv3.Watcher {
+	return &watcher{Watcher: w, c: c}
+}
+
+type watcher struct {
+	clientv3.Watcher
+	c *Client
+}
+
+func (w *watcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	out := make(chan clientv3.WatchResponse)
+	in := w.Watcher.Watch(ctx, key, opts...)
+	go func() {
+		defer close(out)
+		for watchResp := range in {
+			w.c.events.Add(float64(len(watchResp.Events)))
+			select {
+			case out <- watchResp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WatchMetrics returns the instrumentation of one watcher, labelled name, to
+// pass to watch.WithMetrics.
+func (c *Client) WatchMetrics(name string) *WatchMetrics {
+	return &WatchMetrics{
+		lag:       c.watchLag.WithLabelValues(name),
+		processed: c.processed.WithLabelValues(name),
+		dropped:   c.dropped.WithLabelValues(name),
+	}
+}
+
+// WatchMetrics records the lag and the processed and dropped events of a
+// watcher.
+type WatchMetrics struct {
+	lag       prometheus.Gauge
+	processed prometheus.Counter
+	dropped   prometheus.Counter
+}
+
+func (m *WatchMetrics) Events(n int) { m.processed.Add(float64(n)) }
+
+func (m *WatchMetrics) Lag(revisions int64) { m.lag.Set(float64(revisions)) }
+
+func (m *WatchMetrics) Dropped(n int) { m.dropped.Add(float64(n)) }
+
+// LockMetrics returns the instrumentation of one lock, labelled name, to pass
+// to lock.WithMetrics.
+func (c *Client) LockMetrics(name string) *LockMetrics {
+	return &LockMetrics{
+		wait:  c.lockWait.WithLabelValues(name),
+		hold:  c.lockHold.WithLabelValues(name),
+		queue: c.lockQueue.WithLabelValues(name),
+	}
+}
+
+// LockMetrics records the wait and hold times and the queue depth of a lock.
+type LockMetrics struct {
+	wait  prometheus.Observer
+	hold  prometheus.Observer
+	queue prometheus.Gauge
+}
+
+func (m *LockMetrics) Waited(d time.Duration) { m.wait.Observe(d.Seconds()) }
+
+func (m *LockMetrics) Held(d time.Duration) { m.hold.Observe(d.Seconds()) }
+
+func (m *LockMetrics) QueueDepth(n int) { m.queue.Set(float64(n)) }
+
+// Lease decorates l, counting the keep-alive renewals it receives.
+func (c *Client) Lease(l clientv3.Lease) clientv3.Lease {
+	return &lease{Lease: l, c: c}
+}
+
+type lease struct {
+	clientv3.Lease
+	c *Client
+}
+
+func (l *lease) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	in, err := l.Lease.KeepAlive(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan *clientv3.LeaseKeepAliveResponse)
+	go func() {
+		defer close(out)
+		for resp := range in {
+			l.c.keepAlives.Inc()
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (l *lease) KeepAliveOnce(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseKeepAliveResponse, error) {
+	resp, err := l.Lease.KeepAliveOnce(ctx, id)
+	if err == nil {
+		l.c.keepAlives.Inc()
+	}
+	return resp, err
+}
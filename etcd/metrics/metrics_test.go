@@ -0,0 +1,212 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/lock"
+	"github.com/gojustforfun/learn-by-test/etcd/metrics"
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var errBroken = errors.New("broken")
+
+// brokenKV fails every Get.
+type brokenKV struct {
+	clientv3.KV
+}
+
+func (kv *brokenKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	return nil, errBroken
+}
+
+type MetricsTestSuite struct {
+	suite.Suite
+	reg *prometheus.Registry
+	kv  *fake.KV
+	c   *metrics.Client
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+func (s *MetricsTestSuite) SetupTest() {
+	s.reg = prometheus.NewRegistry()
+	s.kv = fake.NewKV()
+	var err error
+	s.c, err = metrics.New(&brokenKV{KV: s.kv}, s.reg)
+	s.NoError(err)
+}
+
+// count returns the samples of metric name with the op label, or of the
+// unlabeled metric if op is empty.
+func (s *MetricsTestSuite) count(name, op string) uint64 {
+	families, err := s.reg.Gather()
+	s.NoError(err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if op != "" && m.GetLabel()[0].GetValue() != op {
+				continue
+			}
+			if h := m.GetHistogram(); h != nil {
+				return h.GetSampleCount()
+			}
+			return uint64(m.GetCounter().GetValue())
+		}
+	}
+	return 0
+}
+
+func (s *MetricsTestSuite) TestOps() {
+	ctx := context.Background()
+	_, err := s.c.Put(ctx, "a", "1")
+	s.NoError(err)
+	_, err = s.c.Put(ctx, "b", "1")
+	s.NoError(err)
+	_, err = s.c.Delete(ctx, "a")
+	s.NoError(err)
+	_, err = s.c.Txn(ctx).If(clientv3.Compare(clientv3.Version("b"), "=", 1)).Then(clientv3.OpPut("b", "2")).Commit()
+	s.NoError(err)
+	_, err = s.c.Do(ctx, clientv3.OpPut("c", "1"))
+	s.NoError(err)
+
+	s.Equal(uint64(3), s.count("etcd_client_op_duration_seconds", "put"))
+	s.Equal(uint64(1), s.count("etcd_client_op_duration_seconds", "delete"))
+	s.Equal(uint64(1), s.count("etcd_client_op_duration_seconds", "txn"))
+	s.Zero(s.count("etcd_client_op_errors_total", "put"))
+
+	_, err = s.c.Get(ctx, "a")
+	s.ErrorIs(err, errBroken)
+	s.Equal(uint64(1), s.count("etcd_client_op_duration_seconds", "get"))
+	s.Equal(uint64(1), s.count("etcd_client_op_errors_total", "get"))
+}
+
+func (s *MetricsTestSuite) TestNames() {
+	_, err := metrics.New(s.kv, s.reg)
+	s.Error(err, "metrics registered twice")
+
+	c, err := metrics.New(s.kv, s.reg, metrics.WithNamespace("app"), metrics.WithSubsystem("store"))
+	s.NoError(err)
+	_, err = c.Put(context.Background(), "a", "1")
+	s.NoError(err)
+	s.Equal(uint64(1), s.count("app_store_op_duration_seconds", "put"))
+}
+
+func (s *MetricsTestSuite) TestWatchAndLease() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+
+	watchChan := s.c.Watcher(cli.Watcher).Watch(ctx, "/m/", clientv3.WithPrefix())
+	for _, key := range []string{"/m/a", "/m/b", "/other"} {
+		_, err := cli.Put(ctx, key, "1")
+		s.NoError(err)
+	}
+	var n int
+	for n < 2 {
+		select {
+		case watchResp := <-watchChan:
+			n += len(watchResp.Events)
+		case <-time.After(time.Second):
+			s.FailNow("no events")
+		}
+	}
+	s.Equal(uint64(2), s.count("etcd_client_watch_events_total", ""))
+
+	lease := s.c.Lease(cli.Lease)
+	grantResp, err := lease.Grant(ctx, 5)
+	s.NoError(err)
+	_, err = lease.KeepAliveOnce(ctx, grantResp.ID)
+	s.NoError(err)
+	keepChan, err := lease.KeepAlive(ctx, grantResp.ID)
+	s.NoError(err)
+	<-keepChan
+	s.Equal(uint64(2), s.count("etcd_client_lease_keepalives_total", ""))
+
+	// closes along with the underlying stream
+	cancel()
+	for range watchChan {
+	}
+}
+
+// gauge returns the value of gauge name with the given label value.
+func (s *MetricsTestSuite) gauge(name, label string) float64 {
+	families, err := s.reg.Gather()
+	s.NoError(err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			if m.GetLabel()[0].GetValue() == label {
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func (s *MetricsTestSuite) TestWatchLag() {
+	var _ watch.Metrics = s.c.WatchMetrics("")
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+
+	r := watch.NewResumable(cli, "/lag/", 0, watch.WithMetrics(s.c.WatchMetrics("stalled"), 10*time.Millisecond))
+	defer r.Close()
+
+	// the consumer reads nothing, so the watch falls behind once its buffer
+	// is full
+	put := func(n int) {
+		for i := 0; i < n; i++ {
+			_, err := cli.Put(context.Background(), fmt.Sprint("/lag/", i), "v")
+			s.Require().NoError(err)
+		}
+	}
+	put(30)
+	s.Eventually(func() bool { return s.gauge("etcd_client_watch_lag_revisions", "stalled") > 0 }, 5*time.Second, 10*time.Millisecond)
+	lag := s.gauge("etcd_client_watch_lag_revisions", "stalled")
+	put(20)
+	s.Eventually(func() bool { return s.gauge("etcd_client_watch_lag_revisions", "stalled") >= lag+20 }, 5*time.Second, 10*time.Millisecond)
+
+	// draining the events catches up
+	for i := 0; i < 50; i++ {
+		select {
+		case <-r.Events():
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event")
+		}
+	}
+	s.Eventually(func() bool { return s.gauge("etcd_client_watch_lag_revisions", "stalled") == 0 }, 5*time.Second, 10*time.Millisecond)
+	s.Equal(uint64(50), s.count("etcd_client_watch_processed_events_total", "stalled"))
+
+	// writes elsewhere are no lag for a watch that is caught up
+	_, err := cli.Put(context.Background(), "/other", "v")
+	s.Require().NoError(err)
+	time.Sleep(50 * time.Millisecond)
+	s.Eventually(func() bool { return s.gauge("etcd_client_watch_lag_revisions", "stalled") == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *MetricsTestSuite) TestLock() {
+	var _ lock.Metrics = s.c.LockMetrics("")
+	m := s.c.LockMetrics("jobs")
+	m.QueueDepth(3)
+	m.Waited(20 * time.Millisecond)
+	m.Waited(time.Second)
+	m.Held(time.Millisecond)
+
+	s.Equal(float64(3), s.gauge("etcd_client_lock_queue_depth", "jobs"))
+	s.Equal(uint64(2), s.count("etcd_client_lock_wait_seconds", "jobs"))
+	s.Equal(uint64(1), s.count("etcd_client_lock_hold_seconds", "jobs"))
+}
@@ -0,0 +1,59 @@
+package op
+
+import (
+	"errors"
+	"fmt"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrUnexpectedResponse = errors.New("op: unexpected response type")
+
+// AsGet returns the response of a get op, or ErrUnexpectedResponse if resp is
+// of another op.
+func AsGet(resp clientv3.OpResponse) (*clientv3.GetResponse, error) {
+	if resp.Get() == nil {
+		return nil, unexpected("get", resp)
+	}
+	return resp.Get(), nil
+}
+
+func AsPut(resp clientv3.OpResponse) (*clientv3.PutResponse, error) {
+	if resp.Put() == nil {
+		return nil, unexpected("put", resp)
+	}
+	return resp.Put(), nil
+}
+
+func AsDelete(resp clientv3.OpResponse) (*clientv3.DeleteResponse, error) {
+	if resp.Del() == nil {
+		return nil, unexpected("delete", resp)
+	}
+	return resp.Del(), nil
+}
+
+func AsTxn(resp clientv3.OpResponse) (*clientv3.TxnResponse, error) {
+	if resp.Txn() == nil {
+		return nil, unexpected("txn", resp)
+	}
+	return resp.Txn(), nil
+}
+
+// Kind names the op resp is the response of, or "none" for an empty one.
+func Kind(resp clientv3.OpResponse) string {
+	switch {
+	case resp.Get() != nil:
+		return "get"
+	case resp.Put() != nil:
+		return "put"
+	case resp.Del() != nil:
+		return "delete"
+	case resp.Txn() != nil:
+		return "txn"
+	}
+	return "none"
+}
+
+func unexpected(want string, resp clientv3.OpResponse) error {
+	return fmt.Errorf("%w: want %s, got %s", ErrUnexpectedResponse, want, Kind(resp))
+}
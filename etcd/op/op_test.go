@@ -0,0 +1,62 @@
+package op_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/op"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type OpTestSuite struct {
+	suite.Suite
+	kv *fake.KV
+}
+
+func TestOpTestSuite(t *testing.T) {
+	suite.Run(t, new(OpTestSuite))
+}
+
+func (s *OpTestSuite) SetupTest() {
+	s.kv = fake.NewKV()
+}
+
+func (s *OpTestSuite) TestAs() {
+	ctx := context.Background()
+	putResp, err := s.kv.Do(ctx, clientv3.OpPut("k", "v"))
+	s.Require().NoError(err)
+	put, err := op.AsPut(putResp)
+	s.Require().NoError(err)
+	s.NotZero(put.Header.Revision)
+
+	getResp, err := s.kv.Do(ctx, clientv3.OpGet("k"))
+	s.Require().NoError(err)
+	get, err := op.AsGet(getResp)
+	s.Require().NoError(err)
+	s.Equal("v", string(get.Kvs[0].Value))
+
+	txnResp, err := s.kv.Do(ctx, clientv3.OpTxn(nil, []clientv3.Op{clientv3.OpGet("k")}, nil))
+	s.Require().NoError(err)
+	txn, err := op.AsTxn(txnResp)
+	s.Require().NoError(err)
+	s.True(txn.Succeeded)
+
+	delResp, err := s.kv.Do(ctx, clientv3.OpDelete("k"))
+	s.Require().NoError(err)
+	del, err := op.AsDelete(delResp)
+	s.Require().NoError(err)
+	s.Equal(int64(1), del.Deleted)
+
+	// the wrong type is an error naming both
+	_, err = op.AsGet(putResp)
+	s.ErrorIs(err, op.ErrUnexpectedResponse)
+	s.EqualError(err, "op: unexpected response type: want get, got put")
+	_, err = op.AsPut(clientv3.OpResponse{})
+	s.EqualError(err, "op: unexpected response type: want put, got none")
+	_, err = op.AsDelete(getResp)
+	s.ErrorIs(err, op.ErrUnexpectedResponse)
+	_, err = op.AsTxn(delResp)
+	s.ErrorIs(err, op.ErrUnexpectedResponse)
+}
@@ -0,0 +1,262 @@
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+type Member struct {
+	ID   string            `json:"id"`
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+type options struct {
+	ttl int
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the presence lease, which is how long a
+// crashed node stays on the roster.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// Tracker keeps a live roster of the nodes present under a prefix, and can
+// make this node one of them. It seeds the roster with a ranged Get and then
+// watches from the revision right after it.
+type Tracker struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   options
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	members   map[string]Member
+	rev       int64
+	callbacks []func(joined, left []Member)
+	session   *session.Session
+	key       string
+}
+
+// New loads the roster and keeps it current until Close is called.
+func New(ctx context.Context, cli *clientv3.Client, prefix string, opts ...Option) (*Tracker, error) {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	t := &Tracker{
+		cli:     cli,
+		prefix:  prefix + "/",
+		opts:    o,
+		done:    make(chan struct{}),
+		members: make(map[string]Member),
+	}
+	if err := t.resync(ctx); err != nil {
+		return nil, err
+	}
+	watchCtx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	go t.run(watchCtx)
+	return t, nil
+}
+
+// Join puts this node on the roster under a lease kept alive until Leave or
+// Close. Joining again updates the meta, or changes the ID the node is
+// present as.
+func (t *Tracker) Join(ctx context.Context, nodeID string, meta map[string]string) error {
+	val, err := json.Marshal(Member{ID: nodeID, Meta: meta})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session == nil {
+		if t.session, err = session.New(t.cli, session.WithTTL(t.opts.ttl), session.WithContext(ctx)); err != nil {
+			return err
+		}
+	}
+	key := t.prefix + nodeID
+	if t.key != "" && t.key != key {
+		if _, err := t.cli.Delete(ctx, t.key); err != nil {
+			return err
+		}
+	}
+	if _, err := t.cli.Put(ctx, key, string(val), clientv3.WithLease(t.session.Lease())); err != nil {
+		return err
+	}
+	t.key = key
+	return nil
+}
+
+// Leave takes this node off the roster; it is a no-op if it has not joined.
+func (t *Tracker) Leave(ctx context.Context) error {
+	t.mu.Lock()
+	sess := t.session
+	t.session, t.key = nil, ""
+	t.mu.Unlock()
+
+	if sess == nil {
+		return nil
+	}
+	return sess.Close()
+}
+
+// Members returns the roster ordered by ID.
+func (t *Tracker) Members() []Member {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	members := make([]Member, 0, len(t.members))
+	for _, m := range t.members {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	return members
+}
+
+// OnChange registers fn to be called with the nodes that joined and left
+// whenever the roster changes. Callbacks run on the watch goroutine and must
+// not call Close.
+func (t *Tracker) OnChange(fn func(joined, left []Member)) {
+	t.mu.Lock()
+	t.callbacks = append(t.callbacks, fn)
+	t.mu.Unlock()
+}
+
+// Close leaves the roster and stops watching it.
+func (t *Tracker) Close() error {
+	err := t.Leave(context.Background())
+	t.cancel()
+	<-t.done
+	return err
+}
+
+func (t *Tracker) run(ctx context.Context) {
+	defer close(t.done)
+
+	for ctx.Err() == nil {
+		t.mu.Lock()
+		rev := t.rev
+		t.mu.Unlock()
+
+		for watchResp := range t.cli.Watch(clientv3.WithRequireLeader(ctx), t.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1)) {
+			if watchResp.Err() != nil {
+				if watchResp.Err() == rpctypes.ErrCompacted {
+					// history we need is gone, reload the whole roster
+					t.resync(ctx)
+				}
+				break
+			}
+			t.apply(ctx, watchResp)
+		}
+
+		// the watch closed, re-establish it from the last seen revision
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (t *Tracker) apply(ctx context.Context, watchResp clientv3.WatchResponse) {
+	var joined, left []Member
+	t.mu.Lock()
+	for _, ev := range watchResp.Events {
+		id := strings.TrimPrefix(string(ev.Kv.Key), t.prefix)
+		old, exists := t.members[id]
+		switch ev.Type {
+		case mvccpb.PUT:
+			m, ok := decode(id, ev.Kv.Value)
+			if !ok {
+				continue
+			}
+			t.members[id] = m
+			if !exists {
+				joined = append(joined, m)
+			}
+		case mvccpb.DELETE:
+			if exists {
+				delete(t.members, id)
+				left = append(left, old)
+			}
+		}
+	}
+	t.rev = watchResp.Header.Revision
+	callbacks := append([]func(joined, left []Member){}, t.callbacks...)
+	t.mu.Unlock()
+
+	t.notify(ctx, callbacks, joined, left)
+}
+
+// resync replaces the roster with a fresh ranged Get, reporting the
+// differences to the callbacks.
+func (t *Tracker) resync(ctx context.Context) error {
+	resp, err := t.cli.Get(ctx, t.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	current := make(map[string]Member, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		id := strings.TrimPrefix(string(kv.Key), t.prefix)
+		if m, ok := decode(id, kv.Value); ok {
+			current[id] = m
+		}
+	}
+
+	var joined, left []Member
+	t.mu.Lock()
+	for id, m := range t.members {
+		if _, ok := current[id]; !ok {
+			left = append(left, m)
+		}
+	}
+	for id, m := range current {
+		if _, ok := t.members[id]; !ok {
+			joined = append(joined, m)
+		}
+	}
+	t.members, t.rev = current, resp.Header.Revision
+	callbacks := append([]func(joined, left []Member){}, t.callbacks...)
+	t.mu.Unlock()
+
+	t.notify(ctx, callbacks, joined, left)
+	return nil
+}
+
+func (t *Tracker) notify(ctx context.Context, callbacks []func(joined, left []Member), joined, left []Member) {
+	if len(joined) == 0 && len(left) == 0 {
+		return
+	}
+	for _, fn := range callbacks {
+		if ctx.Err() != nil {
+			return
+		}
+		fn(joined, left)
+	}
+}
+
+// decode reads a presence value; the ID always comes from the key.
+func decode(id string, val []byte) (Member, bool) {
+	var m Member
+	if err := json.Unmarshal(val, &m); err != nil {
+		return Member{}, false
+	}
+	m.ID = id
+	return m, true
+}
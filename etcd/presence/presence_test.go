@@ -0,0 +1,90 @@
+package presence_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/presence"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var endpoints = []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"}
+
+type PresenceTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestPresenceTestSuite(t *testing.T) {
+	suite.Run(t, new(PresenceTestSuite))
+}
+
+func (s *PresenceTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.NoError(err)
+}
+
+func (s *PresenceTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func ids(members []presence.Member) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func (s *PresenceTestSuite) TestRoster() {
+	prefix := "/test/presence/roster"
+	defer s.cli.Delete(context.Background(), prefix+"/", clientv3.WithPrefix())
+
+	var mu sync.Mutex
+	var joined, left []string
+	observer, err := presence.New(context.Background(), s.cli, prefix)
+	s.Require().NoError(err)
+	defer observer.Close()
+	observer.OnChange(func(j, l []presence.Member) {
+		mu.Lock()
+		joined, left = append(joined, ids(j)...), append(left, ids(l)...)
+		mu.Unlock()
+	})
+
+	n1, err := presence.New(context.Background(), s.cli, prefix)
+	s.Require().NoError(err)
+	defer n1.Close()
+	s.NoError(n1.Join(context.Background(), "node-1", map[string]string{"zone": "a"}))
+	n2, err := presence.New(context.Background(), s.cli, prefix)
+	s.Require().NoError(err)
+	defer n2.Close()
+	s.NoError(n2.Join(context.Background(), "node-2", nil))
+
+	// node-3 runs on its own client so that it can crash without revoking
+	crashCli, err := clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.Require().NoError(err)
+	n3, err := presence.New(context.Background(), crashCli, prefix, presence.WithTTL(1))
+	s.Require().NoError(err)
+	s.NoError(n3.Join(context.Background(), "node-3", nil))
+
+	s.Eventually(func() bool { return len(observer.Members()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{"node-1", "node-2", "node-3"}, ids(observer.Members()))
+	s.Equal(map[string]string{"zone": "a"}, observer.Members()[0].Meta)
+
+	// the keep-alive stops with the client, and the lease runs out
+	crashCli.Close()
+	s.Eventually(func() bool { return len(observer.Members()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	s.Equal([]string{"node-1", "node-2"}, ids(observer.Members()))
+
+	s.NoError(n1.Leave(context.Background()))
+	s.Eventually(func() bool { return len(observer.Members()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.ElementsMatch([]string{"node-1", "node-2", "node-3"}, joined)
+	s.Equal([]string{"node-3", "node-1"}, left)
+}
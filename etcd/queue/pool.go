@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// nackTimeout bounds enqueuing a failed item again, which must not depend on
+// the handler context: the item is already gone from the queue.
+const nackTimeout = 5 * time.Second
+
+// ackError is a handler failure that is acked nevertheless.
+type ackError struct{ err error }
+
+func (e *ackError) Error() string { return e.err.Error() }
+func (e *ackError) Unwrap() error { return e.err }
+
+// Ack wraps a handler error so that Pool acks the item instead of nacking
+// it, for failures that retrying will not fix.
+func Ack(err error) error {
+	return &ackError{err: err}
+}
+
+// Pool runs Workers goroutines that each loop dequeuing an item from Queue
+// and passing it to Handler. Dequeuing deletes the item, so every item is
+// handled at most once. An item whose handler fails is nacked: it is enqueued
+// again at the tail of the queue, to be retried after the items ahead of it.
+// A handler error wrapped with Ack acks the item instead, dropping it.
+type Pool struct {
+	Queue   *Queue
+	Workers int
+	Handler func(ctx context.Context, val []byte) error
+	// MaxAttempts, unless zero, is how many times an item is tried before it
+	// is moved to the dead items instead of being enqueued again.
+	MaxAttempts int
+	// OnError, if set, is called with the errors of Handler and of nacking.
+	// An item that could not be nacked is lost.
+	OnError func(err error)
+
+	stopPulling context.CancelFunc
+	stopHandler context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// Start starts the workers.
+func (p *Pool) Start() {
+	pullCtx, stopPulling := context.WithCancel(context.Background())
+	handlerCtx, stopHandler := context.WithCancel(context.Background())
+	p.stopPulling, p.stopHandler = stopPulling, stopHandler
+
+	for i := 0; i < p.Workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.work(pullCtx, handlerCtx)
+		}()
+	}
+}
+
+// Stop drains the pool: workers stop pulling new items and Stop waits for
+// the items in flight to be handled. If ctx is done first, the context passed
+// to the handlers is cancelled and Stop returns ctx.Err() without waiting
+// further.
+func (p *Pool) Stop(ctx context.Context) error {
+	if p.stopPulling == nil {
+		return nil
+	}
+	p.stopPulling()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.stopHandler()
+		return nil
+	case <-ctx.Done():
+		p.stopHandler()
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work(pullCtx, handlerCtx context.Context) {
+	for {
+		item, err := p.Queue.dequeue(pullCtx)
+		if err != nil {
+			select {
+			case <-pullCtx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		err = p.Handler(handlerCtx, item.Value)
+		if err == nil {
+			continue
+		}
+		p.report(err)
+		var ack *ackError
+		if errors.As(err, &ack) {
+			continue
+		}
+		// nack even once Stop gave up on the handlers
+		ctx, cancel := context.WithTimeout(context.Background(), nackTimeout)
+		if err := p.Queue.nack(ctx, item, p.MaxAttempts); err != nil {
+			p.report(fmt.Errorf("queue: nack %s: %w", item.ID, err))
+		}
+		cancel()
+	}
+}
+
+func (p *Pool) report(err error) {
+	if p.OnError != nil {
+		p.OnError(err)
+	}
+}
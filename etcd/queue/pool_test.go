@@ -0,0 +1,182 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/queue"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *QueueTestSuite) TestPool() {
+	prefix := "/test/queue/pool"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	q := queue.New(s.cli, prefix)
+	for i := 0; i < 100; i++ {
+		s.NoError(q.Enqueue(context.Background(), []byte(fmt.Sprint(i))))
+	}
+
+	var mu sync.Mutex
+	handled := make(map[string]int)
+	var failed int32
+	p := &queue.Pool{Queue: q, Workers: 4, Handler: func(ctx context.Context, val []byte) error {
+		// the first attempt at item 7 is nacked
+		if string(val) == "7" && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			return errors.New("try again")
+		}
+		mu.Lock()
+		handled[string(val)]++
+		mu.Unlock()
+		return nil
+	}}
+	p.Start()
+	s.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 100
+	}, 10*time.Second, 10*time.Millisecond)
+	s.NoError(p.Stop(context.Background()))
+
+	for i := 0; i < 100; i++ {
+		s.Equal(1, handled[fmt.Sprint(i)], "item %d", i)
+	}
+}
+
+func (s *QueueTestSuite) TestPoolDrain() {
+	prefix := "/test/queue/drain"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	q := queue.New(s.cli, prefix)
+	for i := 0; i < 3; i++ {
+		s.NoError(q.Enqueue(context.Background(), []byte(fmt.Sprint(i))))
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var handled int32
+	p := &queue.Pool{Queue: q, Workers: 1, Handler: func(ctx context.Context, val []byte) error {
+		close(started)
+		<-release
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}}
+	p.Start()
+	<-started
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- p.Stop(context.Background())
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	// the item in flight is finished, the others are left in the queue
+	s.NoError(<-stopped)
+	s.Equal(int32(1), atomic.LoadInt32(&handled))
+	getResp, err := s.cli.Get(context.Background(), prefix+"/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(2), getResp.Count)
+}
+
+func (s *QueueTestSuite) TestPoolAck() {
+	prefix := "/test/queue/ack"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	q := queue.New(s.cli, prefix)
+	s.NoError(q.Enqueue(context.Background(), []byte("malformed")))
+
+	errs := make(chan error, 10)
+	var attempts int32
+	p := &queue.Pool{Queue: q, Workers: 1, OnError: func(err error) { errs <- err },
+		Handler: func(ctx context.Context, val []byte) error {
+			atomic.AddInt32(&attempts, 1)
+			return queue.Ack(errors.New("cannot parse"))
+		}}
+	p.Start()
+	select {
+	case err := <-errs:
+		s.EqualError(err, "cannot parse")
+	case <-time.After(5 * time.Second):
+		s.Fail("handler error not reported")
+	}
+	s.NoError(p.Stop(context.Background()))
+
+	// the failed item is dropped, not retried
+	s.Equal(int32(1), atomic.LoadInt32(&attempts))
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(getResp.Count)
+}
+
+func (s *QueueTestSuite) TestPoolStopTimeout() {
+	prefix := "/test/queue/timeout"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	q := queue.New(s.cli, prefix)
+	s.NoError(q.Enqueue(context.Background(), []byte("slow")))
+
+	started := make(chan struct{})
+	p := &queue.Pool{Queue: q, Workers: 1, Handler: func(ctx context.Context, val []byte) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	p.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(p.Stop(ctx), context.DeadlineExceeded)
+
+	// the interrupted item is enqueued again rather than lost
+	var val []byte
+	s.Eventually(func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		var err error
+		val, err = q.Dequeue(ctx)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Equal("slow", string(val))
+}
+
+func (s *QueueTestSuite) TestPoolDeadLetters() {
+	prefix := "/test/queue/dead"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	q := queue.New(s.cli, prefix)
+	s.NoError(q.Enqueue(context.Background(), []byte("poison")))
+
+	var attempts int32
+	p := &queue.Pool{Queue: q, Workers: 2, MaxAttempts: 3, Handler: func(ctx context.Context, val []byte) error {
+		atomic.AddInt32(&attempts, 1)
+		return errors.New("always fails")
+	}}
+	p.Start()
+	var dead []queue.Item
+	s.Eventually(func() bool {
+		var err error
+		dead, err = q.DeadLetters(context.Background())
+		return err == nil && len(dead) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	s.NoError(p.Stop(context.Background()))
+
+	s.Equal(int32(3), atomic.LoadInt32(&attempts))
+	s.Equal("poison", string(dead[0].Value))
+	s.Equal(3, dead[0].Attempts)
+
+	// dead items are not dequeued
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := q.Dequeue(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	s.NoError(q.Requeue(context.Background(), dead[0].ID))
+	s.Equal(queue.ErrNotFound, q.Requeue(context.Background(), dead[0].ID))
+	val, err := q.Dequeue(context.Background())
+	s.NoError(err)
+	s.Equal("poison", string(val))
+	dead, err = q.DeadLetters(context.Background())
+	s.NoError(err)
+	s.Empty(dead)
+}
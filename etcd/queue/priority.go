@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// PriorityQueue pops the item with the lowest priority number first, and
+// items of equal priority in the order they were enqueued.
+//
+// An item is stored under <prefix>/<priority>/<id>, where priority is zero
+// padded to 5 digits, the width of the largest uint16, so that keys sort by
+// priority lexically.
+type PriorityQueue struct {
+	cli    *clientv3.Client
+	prefix string
+}
+
+func NewPriorityQueue(cli *clientv3.Client, prefix string) *PriorityQueue {
+	return &PriorityQueue{cli: cli, prefix: prefix + "/"}
+}
+
+func (q *PriorityQueue) Enqueue(ctx context.Context, priority uint16, val []byte) error {
+	return putUnique(ctx, q.cli, fmt.Sprintf("%s%05d/", q.prefix, priority), val, 0)
+}
+
+// Dequeue pops the highest-priority item, blocking while the queue is empty
+// until an item is enqueued or ctx is done. Keys under the prefix that do not
+// fit the layout are skipped.
+func (q *PriorityQueue) Dequeue(ctx context.Context) ([]byte, error) {
+	kv, err := pop(ctx, q.cli, q.prefix, func(ctx context.Context) (*clientv3.GetResponse, error) {
+		// the lowest key has the highest priority, then the oldest item of
+		// that priority goes first
+		end := clientv3.GetPrefixRangeEnd(q.prefix)
+		resp, err := q.cli.Get(ctx, q.prefix, clientv3.WithRange(end), clientv3.WithLimit(1))
+		for err == nil && len(resp.Kvs) > 0 {
+			if level, ok := q.level(string(resp.Kvs[0].Key)); ok {
+				return q.cli.Get(ctx, level, append(clientv3.WithFirstCreate(), clientv3.WithRev(resp.Header.Revision))...)
+			}
+			resp, err = q.cli.Get(ctx, string(resp.Kvs[0].Key)+"\x00", clientv3.WithRange(end), clientv3.WithLimit(1), clientv3.WithRev(resp.Header.Revision))
+		}
+		return resp, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return kv.Value, nil
+}
+
+// level returns the <prefix>/<priority>/ part of key, if key has one.
+func (q *PriorityQueue) level(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, q.prefix)
+	if !ok || len(rest) < len("00000/") || rest[5] != '/' {
+		return "", false
+	}
+	for _, c := range rest[:5] {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return key[:len(q.prefix)+len("00000/")], true
+}
@@ -0,0 +1,63 @@
+package queue_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/queue"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *QueueTestSuite) TestPriorityQueue() {
+	prefix := "/test/queue/priority"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	q := queue.NewPriorityQueue(s.cli, prefix)
+	items := []struct {
+		priority uint16
+		val      string
+	}{
+		{10, "10-a"},
+		{2, "2-a"},
+		{65535, "max"},
+		{10, "10-b"},
+		{0, "0-a"},
+		{2, "2-b"},
+		{100, "100-a"},
+		{10, "10-c"},
+	}
+	for _, item := range items {
+		s.NoError(q.Enqueue(context.Background(), item.priority, []byte(item.val)))
+	}
+
+	var got []string
+	for range items {
+		val, err := q.Dequeue(context.Background())
+		s.NoError(err)
+		got = append(got, string(val))
+	}
+	s.Equal([]string{"0-a", "2-a", "2-b", "10-a", "10-b", "10-c", "100-a", "max"}, got)
+}
+
+func (s *QueueTestSuite) TestPriorityQueueStrayKeys() {
+	prefix := "/test/queue/stray"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	// keys of other clients, sorting before and after the items
+	for _, key := range []string{prefix + "/", prefix + "/0", prefix + "/0000x/a", prefix + "/x"} {
+		_, err := s.cli.Put(context.Background(), key, "stray")
+		s.NoError(err)
+	}
+	q := queue.NewPriorityQueue(s.cli, prefix)
+	s.NoError(q.Enqueue(context.Background(), 3, []byte("item")))
+
+	val, err := q.Dequeue(context.Background())
+	s.NoError(err)
+	s.Equal("item", string(val))
+
+	// only stray keys left, so the queue counts as empty
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = q.Dequeue(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
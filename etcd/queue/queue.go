@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const deadPrefix = "dead/"
+
+var ErrNotFound = errors.New("queue: item not found")
+
+// Item is a stored item with the number of times handling it failed.
+type Item struct {
+	ID       string
+	Value    []byte
+	Attempts int
+}
+
+// Queue is a FIFO shared across processes. Every item is a key under the
+// prefix, and items are popped in the order their keys were created, i.e. by
+// CreateRevision, no matter which producer wrote them.
+//
+// An item is stored under <prefix>/<id>, where id is a zero padded timestamp,
+// followed by .<attempts> once an attempt at handling it failed. Items given
+// up on are moved to <prefix>/dead/<id>, out of the range of ids, which only
+// start with digits.
+type Queue struct {
+	cli    *clientv3.Client
+	prefix string
+}
+
+func New(cli *clientv3.Client, prefix string) *Queue {
+	return &Queue{cli: cli, prefix: prefix + "/"}
+}
+
+func (q *Queue) Enqueue(ctx context.Context, val []byte) error {
+	return putUnique(ctx, q.cli, q.prefix, val, 0)
+}
+
+// Dequeue pops the oldest item, blocking while the queue is empty until an
+// item is enqueued or ctx is done.
+func (q *Queue) Dequeue(ctx context.Context) ([]byte, error) {
+	item, err := q.dequeue(ctx)
+	return item.Value, err
+}
+
+func (q *Queue) dequeue(ctx context.Context) (Item, error) {
+	kv, err := pop(ctx, q.cli, q.prefix, func(ctx context.Context) (*clientv3.GetResponse, error) {
+		// ':' follows the digits, keeping the dead items out of range
+		return q.cli.Get(ctx, q.prefix, append(clientv3.WithFirstCreate(), clientv3.WithRange(q.prefix+":"))...)
+	})
+	if err != nil {
+		return Item{}, err
+	}
+	return parseItem(q.prefix, kv), nil
+}
+
+// nack enqueues item again at the tail with one more failed attempt, or
+// moves it to the dead items once maxAttempts, unless zero, is reached.
+func (q *Queue) nack(ctx context.Context, item Item, maxAttempts int) error {
+	attempts := item.Attempts + 1
+	if maxAttempts > 0 && attempts >= maxAttempts {
+		id, _, _ := strings.Cut(item.ID, ".")
+		_, err := q.cli.Put(ctx, fmt.Sprintf("%s%s%s.%d", q.prefix, deadPrefix, id, attempts), string(item.Value))
+		return err
+	}
+	return putUnique(ctx, q.cli, q.prefix, item.Value, attempts)
+}
+
+// DeadLetters returns the items given up on, oldest first.
+func (q *Queue) DeadLetters(ctx context.Context) ([]Item, error) {
+	prefix := q.prefix + deadPrefix
+	resp, err := kv.GetPrefix(ctx, q.cli, prefix, kv.WithSort(kv.SortByCreateRevision, kv.SortAscend))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		items = append(items, parseItem(prefix, kv))
+	}
+	return items, nil
+}
+
+// Requeue moves the dead item id back to the tail of the queue with its
+// attempts reset, or returns ErrNotFound.
+func (q *Queue) Requeue(ctx context.Context, id string) error {
+	deadKey := q.prefix + deadPrefix + id
+	for {
+		getResp, err := q.cli.Get(ctx, deadKey)
+		if err != nil {
+			return err
+		}
+		if len(getResp.Kvs) == 0 {
+			return ErrNotFound
+		}
+
+		key := itemKey(q.prefix, 0)
+		txnResp, err := q.cli.Txn(ctx).
+			If(
+				clientv3.Compare(clientv3.ModRevision(deadKey), "=", getResp.Kvs[0].ModRevision),
+				clientv3.Compare(clientv3.CreateRevision(key), "=", 0),
+			).
+			Then(clientv3.OpPut(key, string(getResp.Kvs[0].Value)), clientv3.OpDelete(deadKey)).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+}
+
+func parseItem(prefix string, kv *mvccpb.KeyValue) Item {
+	item := Item{ID: strings.TrimPrefix(string(kv.Key), prefix), Value: kv.Value}
+	if _, attempts, ok := strings.Cut(item.ID, "."); ok {
+		item.Attempts, _ = strconv.Atoi(attempts)
+	}
+	return item
+}
+
+// itemKey returns <prefix><id>, where id is the current time in nanoseconds,
+// with the attempts appended unless zero.
+func itemKey(prefix string, attempts int) string {
+	key := fmt.Sprintf("%s%020d", prefix, time.Now().UnixNano())
+	if attempts > 0 {
+		key += "." + strconv.Itoa(attempts)
+	}
+	return key
+}
+
+// putUnique puts val under a new item key, retrying with a new id if another
+// producer took it.
+func putUnique(ctx context.Context, cli *clientv3.Client, prefix string, val []byte, attempts int) error {
+	for {
+		key := itemKey(prefix, attempts)
+		resp, err := cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, string(val))).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			return nil
+		}
+	}
+}
+
+// pop claims the item returned by first with kv.ClaimAndDelete, so that only
+// one consumer gets it; when another consumer wins, pop picks again. If first
+// returns no item, pop waits for a put under prefix.
+func pop(ctx context.Context, cli *clientv3.Client, prefix string, first func(ctx context.Context) (*clientv3.GetResponse, error)) (*mvccpb.KeyValue, error) {
+	for {
+		getResp, err := first(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(getResp.Kvs) == 0 {
+			if err := wait.AnyPut(ctx, cli, prefix, getResp.Header.Revision); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		item := getResp.Kvs[0]
+		_, claimed, err := kv.ClaimAndDelete(ctx, cli, string(item.Key), item.ModRevision)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return item, nil
+		}
+	}
+}
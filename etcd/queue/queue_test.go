@@ -0,0 +1,126 @@
+package queue_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/queue"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type QueueTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueTestSuite))
+}
+
+func (s *QueueTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *QueueTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *QueueTestSuite) TestFIFO() {
+	prefix := "/test/queue/fifo"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	q := queue.New(s.cli, prefix)
+	for i := 0; i < 10; i++ {
+		s.NoError(q.Enqueue(context.Background(), []byte(fmt.Sprint(i))))
+	}
+	for i := 0; i < 10; i++ {
+		val, err := q.Dequeue(context.Background())
+		s.NoError(err)
+		s.Equal(fmt.Sprint(i), string(val))
+	}
+}
+
+func (s *QueueTestSuite) TestDequeueBlocks() {
+	prefix := "/test/queue/block"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	q := queue.New(s.cli, prefix)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := q.Dequeue(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		q.Enqueue(context.Background(), []byte("late"))
+	}()
+	val, err := q.Dequeue(context.Background())
+	s.NoError(err)
+	s.Equal("late", string(val))
+}
+
+func (s *QueueTestSuite) TestExactlyOnce() {
+	prefix := "/test/queue/once"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	const producers, consumers, perProducer = 3, 3, 20
+	q := queue.New(s.cli, prefix)
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				s.NoError(q.Enqueue(context.Background(), []byte(fmt.Sprintf("%d-%d", p, i))))
+			}
+		}(p)
+	}
+
+	var mu sync.Mutex
+	got := make(map[string]int)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cwg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		cwg.Add(1)
+		go func() {
+			defer cwg.Done()
+			for {
+				val, err := q.Dequeue(ctx)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				got[string(val)]++
+				if len(got) == producers*perProducer {
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		cancel()
+	}
+	cwg.Wait()
+
+	s.Len(got, producers*perProducer)
+	for val, n := range got {
+		s.Equal(1, n, val)
+	}
+}
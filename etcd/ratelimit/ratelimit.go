@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultMaxRetries = 10
+
+var ErrContention = errors.New("ratelimit: too many concurrent updates to the bucket")
+
+type options struct {
+	clock      clock.Clock
+	maxRetries int
+}
+
+type Option func(*options)
+
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithMaxRetries sets how often Allow retries when another process updates
+// the bucket in between, 10 by default.
+func WithMaxRetries(n int) Option {
+	return func(o *options) {
+		o.maxRetries = n
+	}
+}
+
+type bucket struct {
+	Tokens float64 `json:"tokens"`
+	Last   int64   `json:"last"`
+}
+
+// Limiter is a token bucket shared by every process using the same key. It
+// refills at rate tokens per second up to burst.
+//
+// Every Allow is a read and a txn against etcd, and processes contending for
+// the bucket retry each other's updates, so it is much slower than a local
+// limiter. Use it to coordinate a rate across processes, not on hot paths.
+type Limiter struct {
+	cli   etcdx.KV
+	key   string
+	rate  float64
+	burst float64
+	opts  options
+}
+
+func New(cli etcdx.KV, key string, rate float64, burst int, opts ...Option) *Limiter {
+	o := options{clock: clock.Real, maxRetries: defaultMaxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Limiter{cli: cli, key: key, rate: rate, burst: float64(burst), opts: o}
+}
+
+// Allow takes a token if one is available and reports whether it did. The
+// bucket is written in a txn guarded on the ModRevision it was read at, and
+// ErrContention is returned if that keeps failing.
+func (l *Limiter) Allow(ctx context.Context) (bool, error) {
+	for i := 0; i <= l.opts.maxRetries; i++ {
+		getResp, err := l.cli.Get(ctx, l.key)
+		if err != nil {
+			return false, err
+		}
+		now := l.opts.clock.Now().UnixNano()
+		b := bucket{Tokens: l.burst, Last: now}
+		var modRev int64
+		if len(getResp.Kvs) > 0 {
+			if err := json.Unmarshal(getResp.Kvs[0].Value, &b); err != nil {
+				return false, err
+			}
+			modRev = getResp.Kvs[0].ModRevision
+		}
+
+		if elapsed := now - b.Last; elapsed > 0 {
+			b.Tokens = math.Min(l.burst, b.Tokens+l.rate*float64(elapsed)/float64(time.Second))
+			b.Last = now
+		}
+		if b.Tokens < 1 {
+			// nothing to take, and nothing worth writing
+			return false, nil
+		}
+		b.Tokens--
+
+		val, err := json.Marshal(b)
+		if err != nil {
+			return false, err
+		}
+		cmp := clientv3.Compare(clientv3.ModRevision(l.key), "=", modRev)
+		if modRev == 0 {
+			cmp = clientv3.Compare(clientv3.CreateRevision(l.key), "=", 0)
+		}
+		txnResp, err := l.cli.Txn(ctx).If(cmp).Then(clientv3.OpPut(l.key, string(val))).Commit()
+		if err != nil {
+			return false, err
+		}
+		if txnResp.Succeeded {
+			return true, nil
+		}
+	}
+	return false, ErrContention
+}
@@ -0,0 +1,93 @@
+package ratelimit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/ratelimit"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
+
+func (s *RateLimitTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *RateLimitTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *RateLimitTestSuite) TestSharedRateFake() {
+	clock := fake.NewClock()
+	kv := fake.NewKV()
+	l1 := ratelimit.New(kv, "/test/ratelimit/fake", 10, 5, ratelimit.WithClock(clock))
+	l2 := ratelimit.New(kv, "/test/ratelimit/fake", 10, 5, ratelimit.WithClock(clock))
+
+	var allowed int
+	for step := 0; step < 10; step++ {
+		for i := 0; i < 5; i++ {
+			for _, l := range []*ratelimit.Limiter{l1, l2} {
+				ok, err := l.Allow(context.Background())
+				s.NoError(err)
+				if ok {
+					allowed++
+				}
+			}
+		}
+		clock.Advance(100 * time.Millisecond)
+	}
+	// the burst, plus one token per 100ms for the nine steps after the first
+	s.Equal(5+9, allowed)
+}
+
+func (s *RateLimitTestSuite) TestSharedRateConcurrent() {
+	key := "/test/ratelimit/concurrent"
+	_, err := s.cli.Delete(context.Background(), key)
+	s.NoError(err)
+	l1 := ratelimit.New(s.cli, key, 20, 5, ratelimit.WithMaxRetries(100))
+	l2 := ratelimit.New(s.cli, key, 20, 5, ratelimit.WithMaxRetries(100))
+
+	start := time.Now()
+	var mu sync.Mutex
+	var allowed int
+	var wg sync.WaitGroup
+	for _, l := range []*ratelimit.Limiter{l1, l2} {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(l *ratelimit.Limiter) {
+				defer wg.Done()
+				for time.Since(start) < time.Second {
+					ok, err := l.Allow(context.Background())
+					s.NoError(err)
+					if ok {
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					}
+				}
+			}(l)
+		}
+	}
+	wg.Wait()
+
+	limit := 5 + int(20*time.Since(start).Seconds())
+	s.LessOrEqual(allowed, limit)
+	s.Greater(allowed, 5)
+}
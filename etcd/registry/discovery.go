@@ -0,0 +1,323 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/gojustforfun/learn-by-test/etcd/kv"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type EventType int
+
+const (
+	Added EventType = iota
+	Updated
+	Removed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Added:
+		return "Added"
+	case Updated:
+		return "Updated"
+	case Removed:
+		return "Removed"
+	}
+	return "Unknown"
+}
+
+type DiscoveryEvent struct {
+	Type     EventType
+	Instance ServiceInstance
+}
+
+type discoveryOptions struct {
+	defaultReadMode bool
+	bootstrapBatch  int
+	codec           codec.Codec
+}
+
+type DiscoveryOption func(*discoveryOptions)
+
+// WithDefaultReadMode makes the reads that load the instance set use
+// kv.DefaultReadMode instead of always being linearizable.
+func WithDefaultReadMode() DiscoveryOption {
+	return func(o *discoveryOptions) {
+		o.defaultReadMode = true
+	}
+}
+
+// WithDiscoveryCodec sets the codec instances are decoded with, JSON by
+// default. It must match the one they are registered with.
+func WithDiscoveryCodec(c codec.Codec) DiscoveryOption {
+	return func(o *discoveryOptions) {
+		o.codec = c
+	}
+}
+
+// WithKeysOnlyBootstrap loads the instance set by listing the keys alone, then
+// fetching the values batch keys at a time, all at the revision of the
+// listing. This bounds how many values are in flight at once for services
+// with many instances.
+func WithKeysOnlyBootstrap(batch int) DiscoveryOption {
+	return func(o *discoveryOptions) {
+		o.bootstrapBatch = batch
+	}
+}
+
+// Discovery keeps a live set of the instances registered for one service. It
+// seeds the set with a ranged Get and then watches from the revision right
+// after it, so no update is missed between the two.
+type Discovery struct {
+	cli    *clientv3.Client
+	prefix string
+	opts   discoveryOptions
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.RWMutex
+	instances map[string]ServiceInstance
+	rev       int64
+
+	subscribed bool
+	events     chan DiscoveryEvent
+}
+
+// NewDiscovery loads the current instances of the service and starts
+// watching for changes until Close is called.
+func NewDiscovery(ctx context.Context, cli *clientv3.Client, name string, opts ...DiscoveryOption) (*Discovery, error) {
+	o := discoveryOptions{codec: codec.JSON}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	d := &Discovery{
+		cli:       cli,
+		prefix:    ServicePrefix(name),
+		opts:      o,
+		done:      make(chan struct{}),
+		instances: make(map[string]ServiceInstance),
+		events:    make(chan DiscoveryEvent, 16),
+	}
+	if err := d.resync(ctx); err != nil {
+		return nil, err
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.run(watchCtx)
+	return d, nil
+}
+
+// Instances returns a snapshot of the current instances ordered by key. The
+// snapshot shares nothing with the live set, so callers may keep and modify
+// it while the watch carries on updating.
+func (d *Discovery) Instances() []ServiceInstance {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	keys := make([]string, 0, len(d.instances))
+	for key := range d.instances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	insts := make([]ServiceInstance, 0, len(keys))
+	for _, key := range keys {
+		insts = append(insts, d.instances[key].clone())
+	}
+	return insts
+}
+
+// Events streams changes to the instance set after the initial load. Events
+// are only produced once Events has been called, and the stream applies
+// backpressure: a subscriber that stops reading stalls updates.
+func (d *Discovery) Events() <-chan DiscoveryEvent {
+	d.mu.Lock()
+	d.subscribed = true
+	d.mu.Unlock()
+	return d.events
+}
+
+// Close stops watching and closes the event stream.
+func (d *Discovery) Close() {
+	d.cancel()
+	<-d.done
+}
+
+func (d *Discovery) run(ctx context.Context) {
+	defer close(d.done)
+	defer close(d.events)
+
+	for ctx.Err() == nil {
+		d.mu.Lock()
+		rev := d.rev
+		d.mu.Unlock()
+
+		watchChan := d.cli.Watch(clientv3.WithRequireLeader(ctx), d.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+		for watchResp := range watchChan {
+			if watchResp.Err() != nil {
+				if watchResp.Err() == rpctypes.ErrCompacted {
+					// history we need is gone, reload the whole set
+					d.resync(ctx)
+				}
+				break
+			}
+			for _, ev := range watchResp.Events {
+				d.apply(ctx, ev)
+			}
+			d.mu.Lock()
+			d.rev = watchResp.Header.Revision
+			d.mu.Unlock()
+		}
+
+		// the watch closed, re-establish it from the last seen revision
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (d *Discovery) apply(ctx context.Context, ev *clientv3.Event) {
+	key := string(ev.Kv.Key)
+
+	d.mu.Lock()
+	old, exists := d.instances[key]
+	var event DiscoveryEvent
+	switch ev.Type {
+	case mvccpb.PUT:
+		var inst ServiceInstance
+		if err := d.opts.codec.Unmarshal(ev.Kv.Value, &inst); err != nil {
+			d.mu.Unlock()
+			return
+		}
+		d.instances[key] = inst
+		event = DiscoveryEvent{Type: Added, Instance: inst}
+		if exists {
+			event.Type = Updated
+		}
+	case mvccpb.DELETE:
+		if !exists {
+			d.mu.Unlock()
+			return
+		}
+		delete(d.instances, key)
+		event = DiscoveryEvent{Type: Removed, Instance: old}
+	}
+	subscribed := d.subscribed
+	d.mu.Unlock()
+
+	d.emit(ctx, subscribed, event)
+}
+
+// resync replaces the instance set with a fresh ranged Get, emitting the
+// differences as events.
+func (d *Discovery) resync(ctx context.Context) error {
+	load := d.load
+	if d.opts.bootstrapBatch > 0 {
+		load = d.loadBatched
+	}
+	current, rev, err := load(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	var events []DiscoveryEvent
+	for key, old := range d.instances {
+		if _, ok := current[key]; !ok {
+			events = append(events, DiscoveryEvent{Type: Removed, Instance: old})
+		}
+	}
+	for key, inst := range current {
+		if old, ok := d.instances[key]; !ok {
+			events = append(events, DiscoveryEvent{Type: Added, Instance: inst})
+		} else if !equalInstance(old, inst) {
+			events = append(events, DiscoveryEvent{Type: Updated, Instance: inst})
+		}
+	}
+	d.instances = current
+	d.rev = rev
+	subscribed := d.subscribed
+	d.mu.Unlock()
+
+	for _, event := range events {
+		d.emit(ctx, subscribed, event)
+	}
+	return nil
+}
+
+func (d *Discovery) readOpts(opts ...clientv3.OpOption) []clientv3.OpOption {
+	if d.opts.defaultReadMode {
+		opts = append(opts, kv.DefaultReadMode.Options()...)
+	}
+	return opts
+}
+
+// load reads the whole instance set with a single ranged Get.
+func (d *Discovery) load(ctx context.Context) (map[string]ServiceInstance, int64, error) {
+	getRes, err := d.cli.Get(ctx, d.prefix, d.readOpts(clientv3.WithPrefix())...)
+	if err != nil {
+		return nil, 0, err
+	}
+	current := make(map[string]ServiceInstance, len(getRes.Kvs))
+	d.decodeInto(current, getRes.Kvs)
+	return current, getRes.Header.Revision, nil
+}
+
+// loadBatched lists the keys, then reads their values in batches at the
+// revision of the listing, so the set is the same as a single Get would have
+// returned and the watch can carry on from that revision.
+func (d *Discovery) loadBatched(ctx context.Context) (map[string]ServiceInstance, int64, error) {
+	keysRes, err := d.cli.Get(ctx, d.prefix, d.readOpts(clientv3.WithPrefix(), clientv3.WithKeysOnly())...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rev := keysRes.Header.Revision
+	current := make(map[string]ServiceInstance, len(keysRes.Kvs))
+	for i := 0; i < len(keysRes.Kvs); i += d.opts.bootstrapBatch {
+		batch := keysRes.Kvs[i:min(i+d.opts.bootstrapBatch, len(keysRes.Kvs))]
+		// a range from the first key of the batch to just past its last
+		end := string(batch[len(batch)-1].Key) + "\x00"
+		getRes, err := d.cli.Get(ctx, string(batch[0].Key), clientv3.WithRange(end), clientv3.WithRev(rev))
+		if err != nil {
+			return nil, 0, err
+		}
+		d.decodeInto(current, getRes.Kvs)
+	}
+	return current, rev, nil
+}
+
+func (d *Discovery) decodeInto(instances map[string]ServiceInstance, kvs []*mvccpb.KeyValue) {
+	for _, item := range kvs {
+		var inst ServiceInstance
+		if err := d.opts.codec.Unmarshal(item.Value, &inst); err != nil {
+			continue
+		}
+		instances[string(item.Key)] = inst
+	}
+}
+
+func (d *Discovery) emit(ctx context.Context, subscribed bool, event DiscoveryEvent) {
+	if !subscribed {
+		return
+	}
+	event.Instance = event.Instance.clone()
+	select {
+	case d.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+func equalInstance(a, b ServiceInstance) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
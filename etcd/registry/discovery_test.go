@@ -0,0 +1,175 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/registry"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *RegistryTestSuite) nextEvent(events <-chan registry.DiscoveryEvent) registry.DiscoveryEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		s.FailNow("no discovery event")
+	}
+	return registry.DiscoveryEvent{}
+}
+
+func (s *RegistryTestSuite) TestDiscovery() {
+	name := "test-discovery"
+	inst1 := registry.ServiceInstance{Name: name, ID: "1", Host: "10.0.0.1", Port: 80, Weight: 1}
+	inst2 := registry.ServiceInstance{Name: name, ID: "2", Host: "10.0.0.2", Port: 80, Weight: 1}
+
+	r1, r2 := registry.New(s.cli), registry.New(s.cli)
+	s.NoError(r1.Register(context.Background(), inst1))
+	defer r1.Deregister(context.Background())
+
+	d, err := registry.NewDiscovery(context.Background(), s.cli, name)
+	s.NoError(err)
+	defer d.Close()
+	s.Equal([]registry.ServiceInstance{inst1}, d.Instances())
+
+	events := d.Events()
+
+	s.NoError(r2.Register(context.Background(), inst2))
+	defer r2.Deregister(context.Background())
+	s.Equal(registry.DiscoveryEvent{Type: registry.Added, Instance: inst2}, s.nextEvent(events))
+	s.Equal([]registry.ServiceInstance{inst1, inst2}, d.Instances())
+
+	inst2.Weight = 5
+	s.NoError(r2.Register(context.Background(), inst2))
+	s.Equal(registry.DiscoveryEvent{Type: registry.Updated, Instance: inst2}, s.nextEvent(events))
+	s.Equal([]registry.ServiceInstance{inst1, inst2}, d.Instances())
+
+	s.NoError(r1.Deregister(context.Background()))
+	s.Equal(registry.DiscoveryEvent{Type: registry.Removed, Instance: inst1}, s.nextEvent(events))
+	s.Equal([]registry.ServiceInstance{inst2}, d.Instances())
+
+	d.Close()
+	_, ok := <-events
+	s.False(ok)
+}
+
+// bootstrapKV records the Gets and runs afterList once the keys have been
+// listed.
+type bootstrapKV struct {
+	clientv3.KV
+	afterList func()
+	gets      []int
+}
+
+func (kv *bootstrapKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := kv.KV.Get(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	kv.gets = append(kv.gets, len(resp.Kvs))
+	if len(kv.gets) == 1 && kv.afterList != nil {
+		kv.afterList()
+	}
+	return resp, nil
+}
+
+func (s *RegistryTestSuite) TestDiscoveryKeysOnlyBootstrap() {
+	name := "test-discovery-bootstrap"
+	prefix := registry.ServicePrefix(name)
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	var want []registry.ServiceInstance
+	for i := 0; i < 5; i++ {
+		inst := registry.ServiceInstance{Name: name, ID: fmt.Sprint(i), Host: "10.0.0.1", Port: 80 + i, Weight: 1}
+		val, err := json.Marshal(inst)
+		s.NoError(err)
+		_, err = s.cli.Put(context.Background(), inst.Key(), string(val))
+		s.NoError(err)
+		want = append(want, inst)
+	}
+
+	cli, err := clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.Require().NoError(err)
+	defer cli.Close()
+	// an instance registered and one updated between the listing and the
+	// batches are not part of the set loaded, and must come from the watch
+	late := registry.ServiceInstance{Name: name, ID: "5", Host: "10.0.0.2", Port: 80, Weight: 1}
+	updated := want[0]
+	updated.Weight = 7
+	kv := &bootstrapKV{KV: cli.KV, afterList: func() {
+		for _, inst := range []registry.ServiceInstance{late, updated} {
+			val, err := json.Marshal(inst)
+			s.NoError(err)
+			_, err = s.cli.Put(context.Background(), inst.Key(), string(val))
+			s.NoError(err)
+		}
+	}}
+	cli.KV = kv
+
+	d, err := registry.NewDiscovery(context.Background(), cli, name, registry.WithKeysOnlyBootstrap(2))
+	s.Require().NoError(err)
+	defer d.Close()
+
+	// one listing, then batches of at most 2 values
+	s.Equal([]int{5, 2, 2, 1}, kv.gets)
+	s.Equal(want, d.Instances())
+
+	want[0] = updated
+	want = append(want, late)
+	s.Eventually(func() bool { return reflect.DeepEqual(want, d.Instances()) }, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *RegistryTestSuite) TestDiscoveryConcurrentInstances() {
+	name := "test-discovery-concurrent"
+	prefix := registry.ServicePrefix(name)
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	d, err := registry.NewDiscovery(context.Background(), s.cli, name)
+	s.Require().NoError(err)
+	defer d.Close()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for _, inst := range d.Instances() {
+					// every instance is written whole, with matching fields
+					if inst.Host != "10.0.0."+inst.ID || len(inst.Tags) != 1 || inst.Tags[0] != inst.ID {
+						s.Failf("torn instance", "%+v", inst)
+						return
+					}
+					// the snapshot is the caller's to modify
+					inst.Tags[0] = "mutated"
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprint(i % 5)
+		key := prefix + id
+		if i%3 == 2 {
+			_, err = s.cli.Delete(context.Background(), key)
+		} else {
+			inst := registry.ServiceInstance{Name: name, ID: id, Host: "10.0.0." + id, Port: 80 + i, Weight: 1, Tags: []string{id}}
+			val, merr := json.Marshal(inst)
+			s.NoError(merr)
+			_, err = s.cli.Put(context.Background(), key, string(val))
+		}
+		s.NoError(err)
+	}
+	close(done)
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the version written into every encoded ServiceInstance.
+const SchemaVersion = 1
+
+var ErrUnknownSchema = errors.New("registry: unknown instance schema")
+
+type ServiceInstance struct {
+	Name   string   `json:"name" yaml:"name"`
+	ID     string   `json:"id" yaml:"id"`
+	Host   string   `json:"host" yaml:"host"`
+	Port   int      `json:"port" yaml:"port"`
+	Weight int      `json:"weight" yaml:"weight"`
+	Tags   []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// MarshalJSON puts the schema version first, followed by the fields in
+// declaration order, so the encoding is stable.
+func (inst ServiceInstance) MarshalJSON() ([]byte, error) {
+	type plain ServiceInstance
+	return json.Marshal(struct {
+		Schema int `json:"schema"`
+		plain
+	}{SchemaVersion, plain(inst)})
+}
+
+// UnmarshalJSON rejects payloads with a schema version other than SchemaVersion.
+func (inst *ServiceInstance) UnmarshalJSON(data []byte) error {
+	type plain ServiceInstance
+	var v struct {
+		Schema int `json:"schema"`
+		plain
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Schema != SchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnknownSchema, v.Schema)
+	}
+	*inst = ServiceInstance(v.plain)
+	return nil
+}
+
+// MarshalYAML is MarshalJSON for the YAML codec.
+func (inst ServiceInstance) MarshalYAML() (interface{}, error) {
+	type plain ServiceInstance
+	return struct {
+		Schema int `yaml:"schema"`
+		plain  `yaml:",inline"`
+	}{SchemaVersion, plain(inst)}, nil
+}
+
+// UnmarshalYAML is UnmarshalJSON for the YAML codec.
+func (inst *ServiceInstance) UnmarshalYAML(node *yaml.Node) error {
+	type plain ServiceInstance
+	var v struct {
+		Schema int `yaml:"schema"`
+		plain  `yaml:",inline"`
+	}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	if v.Schema != SchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnknownSchema, v.Schema)
+	}
+	*inst = ServiceInstance(v.plain)
+	return nil
+}
+
+// clone returns a copy of inst that does not share its Tags.
+func (inst ServiceInstance) clone() ServiceInstance {
+	if inst.Tags != nil {
+		inst.Tags = append([]string(nil), inst.Tags...)
+	}
+	return inst
+}
+
+// ServicePrefix returns the prefix all instances of a service register under.
+func ServicePrefix(name string) string {
+	return "/services/" + name + "/"
+}
+
+// Key returns the key the instance registers under.
+func (inst ServiceInstance) Key() string {
+	return ServicePrefix(inst.Name) + inst.ID
+}
@@ -0,0 +1,59 @@
+package registry
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrNoInstances = errors.New("registry: no instances available")
+
+// Picker spreads calls over the instances of a Discovery with smooth
+// weighted round-robin: every pick raises each instance's current weight by
+// its weight and takes the highest, which then drops by the total. Heavier
+// instances are picked more often without being picked in bursts.
+type Picker struct {
+	d *Discovery
+
+	mu      sync.Mutex
+	current map[string]int
+}
+
+func NewPicker(d *Discovery) *Picker {
+	return &Picker{d: d, current: make(map[string]int)}
+}
+
+// Pick returns the next instance. It works on the instances the Discovery
+// currently knows, so a removed instance is never picked. A weight below 1
+// counts as 1.
+func (p *Picker) Pick() (ServiceInstance, error) {
+	insts := p.d.Instances()
+	if len(insts) == 0 {
+		return ServiceInstance{}, ErrNoInstances
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	live := make(map[string]bool, len(insts))
+	var total, best int
+	for i, inst := range insts {
+		key := inst.Key()
+		live[key] = true
+		w := inst.Weight
+		if w < 1 {
+			w = 1
+		}
+		total += w
+		p.current[key] += w
+		if p.current[key] > p.current[insts[best].Key()] {
+			best = i
+		}
+	}
+	for key := range p.current {
+		if !live[key] {
+			delete(p.current, key)
+		}
+	}
+	p.current[insts[best].Key()] -= total
+	return insts[best], nil
+}
@@ -0,0 +1,50 @@
+package registry_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/registry"
+)
+
+func (s *RegistryTestSuite) TestPickerWeights() {
+	name := "test-picker"
+	d, err := registry.NewDiscovery(context.Background(), s.cli, name)
+	s.NoError(err)
+	defer d.Close()
+	p := registry.NewPicker(d)
+
+	_, err = p.Pick()
+	s.ErrorIs(err, registry.ErrNoInstances)
+
+	var regs []*registry.Registry
+	for i, w := range []int{1, 2, 3} {
+		r := registry.New(s.cli)
+		s.NoError(r.Register(context.Background(), registry.ServiceInstance{Name: name, ID: string(rune('a' + i)), Host: "10.0.0.1", Port: 80 + i, Weight: w}))
+		defer r.Deregister(context.Background())
+		regs = append(regs, r)
+	}
+	s.Eventually(func() bool { return len(d.Instances()) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+	counts := make(map[string]int)
+	var first []string
+	for i := 0; i < 600; i++ {
+		inst, err := p.Pick()
+		s.NoError(err)
+		counts[inst.ID]++
+		if i < 6 {
+			first = append(first, inst.ID)
+		}
+	}
+	// interleaved rather than c, c, c, b, b, a
+	s.Equal([]string{"c", "b", "a", "c", "b", "c"}, first)
+	s.Equal(map[string]int{"a": 100, "b": 200, "c": 300}, counts)
+
+	s.NoError(regs[2].Deregister(context.Background()))
+	s.Eventually(func() bool { return len(d.Instances()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < 30; i++ {
+		inst, err := p.Pick()
+		s.NoError(err)
+		s.NotEqual("c", inst.ID)
+	}
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+var ErrNotRegistered = errors.New("registry: not registered")
+
+type options struct {
+	ttl     int
+	session *session.Session
+	codec   codec.Codec
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the registration lease, which is how
+// long a dead instance lingers before disappearing.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithSession registers with the lease of an existing session. Deregister
+// then only deletes the key and leaves the session alive.
+func WithSession(s *session.Session) Option {
+	return func(o *options) {
+		o.session = s
+	}
+}
+
+// WithCodec sets the codec instances are encoded with, JSON by default.
+// Discoveries of the service must use the same one, see WithDiscoveryCodec.
+func WithCodec(c codec.Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// Registry registers one service instance under /services/<name>/<id>,
+// attached to a keep-alived lease so it vanishes when the process dies.
+type Registry struct {
+	cli  *clientv3.Client
+	opts options
+
+	mu      sync.Mutex
+	session *session.Session
+	key     string
+}
+
+func New(cli *clientv3.Client, opts ...Option) *Registry {
+	o := options{ttl: defaultTTL, codec: codec.JSON}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Registry{cli: cli, opts: o}
+}
+
+// Register puts the instance under its service prefix. Registering again
+// replaces the previous registration of this Registry.
+func (r *Registry) Register(ctx context.Context, inst ServiceInstance) error {
+	val, err := r.opts.codec.Marshal(inst)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session == nil {
+		r.session = r.opts.session
+		if r.session == nil {
+			if r.session, err = session.New(r.cli, session.WithTTL(r.opts.ttl), session.WithContext(ctx)); err != nil {
+				return err
+			}
+		}
+	}
+	if r.key != "" && r.key != inst.Key() {
+		if _, err := r.cli.Delete(ctx, r.key); err != nil {
+			return err
+		}
+	}
+	if _, err := r.cli.Put(ctx, inst.Key(), string(val), clientv3.WithLease(r.session.Lease())); err != nil {
+		return err
+	}
+	r.key = inst.Key()
+	return nil
+}
+
+// Deregister removes the instance right away instead of waiting for its
+// lease to expire.
+func (r *Registry) Deregister(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.key == "" {
+		return ErrNotRegistered
+	}
+	if _, err := r.cli.Delete(ctx, r.key); err != nil {
+		return err
+	}
+	r.key = ""
+	if r.session != r.opts.session {
+		r.session.Close()
+	}
+	r.session = nil
+	return nil
+}
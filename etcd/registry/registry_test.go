@@ -0,0 +1,127 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/gojustforfun/learn-by-test/etcd/registry"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var endpoints = []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"}
+
+type RegistryTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
+
+func (s *RegistryTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.NoError(err)
+}
+
+func (s *RegistryTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *RegistryTestSuite) instances(name string) []registry.ServiceInstance {
+	getRes, err := s.cli.Get(context.Background(), registry.ServicePrefix(name), clientv3.WithPrefix())
+	s.NoError(err)
+	var insts []registry.ServiceInstance
+	for _, kv := range getRes.Kvs {
+		var inst registry.ServiceInstance
+		s.NoError(json.Unmarshal(kv.Value, &inst))
+		insts = append(insts, inst)
+	}
+	return insts
+}
+
+func (s *RegistryTestSuite) TestRegisterAndExpire() {
+	name := "test-register"
+	inst1 := registry.ServiceInstance{Name: name, ID: "1", Host: "10.0.0.1", Port: 8080, Weight: 1, Tags: []string{"a"}}
+	inst2 := registry.ServiceInstance{Name: name, ID: "2", Host: "10.0.0.2", Port: 8080, Weight: 2}
+
+	r1 := registry.New(s.cli, registry.WithTTL(2))
+	s.NoError(r1.Register(context.Background(), inst1))
+	defer r1.Deregister(context.Background())
+
+	// the second instance runs on its own connection so that it can "die"
+	cli2, err := clientv3.New(clientv3.Config{Endpoints: endpoints, DialTimeout: 5 * time.Second})
+	s.NoError(err)
+	r2 := registry.New(cli2, registry.WithTTL(2))
+	s.NoError(r2.Register(context.Background(), inst2))
+
+	s.Equal([]registry.ServiceInstance{inst1, inst2}, s.instances(name))
+
+	// the process dies without deregistering, keep-alive stops
+	cli2.Close()
+	time.Sleep(4 * time.Second)
+
+	s.Equal([]registry.ServiceInstance{inst1}, s.instances(name))
+
+	s.NoError(r1.Deregister(context.Background()))
+	s.Empty(s.instances(name))
+	s.Equal(registry.ErrNotRegistered, r1.Deregister(context.Background()))
+}
+
+func (s *RegistryTestSuite) TestInstanceSchema() {
+	inst := registry.ServiceInstance{Name: "svc", ID: "1", Host: "localhost", Port: 80, Weight: 1}
+	data, err := json.Marshal(inst)
+	s.NoError(err)
+	s.Equal(`{"schema":1,"name":"svc","id":"1","host":"localhost","port":80,"weight":1}`, string(data))
+
+	var decoded registry.ServiceInstance
+	s.NoError(json.Unmarshal(data, &decoded))
+	s.Equal(inst, decoded)
+
+	err = json.Unmarshal([]byte(`{"schema":2,"name":"svc","id":"1"}`), &decoded)
+	s.ErrorIs(err, registry.ErrUnknownSchema)
+}
+
+func (s *RegistryTestSuite) TestCodecs() {
+	inst := registry.ServiceInstance{Name: "test-codecs", ID: "1", Host: "10.0.0.1", Port: 8080, Weight: 3, Tags: []string{"a", "b"}}
+	defer s.cli.Delete(context.Background(), registry.ServicePrefix(inst.Name), clientv3.WithPrefix())
+
+	for name, c := range map[string]codec.Codec{"json": codec.JSON, "yaml": codec.YAML} {
+		r := registry.New(s.cli, registry.WithCodec(c))
+		s.NoError(r.Register(context.Background(), inst), name)
+
+		d, err := registry.NewDiscovery(context.Background(), s.cli, inst.Name, registry.WithDiscoveryCodec(c))
+		s.Require().NoError(err, name)
+		s.Equal([]registry.ServiceInstance{inst}, d.Instances(), name)
+		d.Close()
+
+		// the schema version is part of every encoding
+		getResp, err := s.cli.Get(context.Background(), inst.Key())
+		s.NoError(err)
+		s.Contains(string(getResp.Kvs[0].Value), "schema", name)
+		s.NoError(r.Deregister(context.Background()))
+	}
+
+	// instances are not protobuf messages
+	err := registry.New(s.cli, registry.WithCodec(codec.Protobuf)).Register(context.Background(), inst)
+	s.ErrorIs(err, codec.ErrNotProto)
+}
+
+func (s *RegistryTestSuite) TestInstanceSchemaYAML() {
+	inst := registry.ServiceInstance{Name: "svc", ID: "1", Host: "localhost", Port: 80, Weight: 1}
+	data, err := codec.YAML.Marshal(inst)
+	s.NoError(err)
+	s.Equal("schema: 1\nname: svc\nid: \"1\"\nhost: localhost\nport: 80\nweight: 1\n", string(data))
+
+	var decoded registry.ServiceInstance
+	s.NoError(codec.YAML.Unmarshal(data, &decoded))
+	s.Equal(inst, decoded)
+
+	err = codec.YAML.Unmarshal([]byte("schema: 2\nname: svc\n"), &decoded)
+	s.ErrorIs(err, registry.ErrUnknownSchema)
+}
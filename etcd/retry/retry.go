@@ -0,0 +1,121 @@
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type options struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+type Option func(*options)
+
+// WithMaxAttempts sets how many times a call is tried in total.
+func WithMaxAttempts(n int) Option {
+	return func(o *options) {
+		o.maxAttempts = n
+	}
+}
+
+// WithBaseDelay sets the delay before the first retry; it doubles after
+// every failed attempt.
+func WithBaseDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.baseDelay = d
+	}
+}
+
+// WithMaxDelay caps the delay between two attempts.
+func WithMaxDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.maxDelay = d
+	}
+}
+
+// Client decorates a clientv3.KV, retrying Put, Get and Delete on transient
+// errors with exponential backoff and jitter. Txn, Do and Compact are passed
+// through untouched since they are not safe to blindly repeat.
+type Client struct {
+	clientv3.KV
+	opts options
+}
+
+func New(kv clientv3.KV, opts ...Option) *Client {
+	o := options{maxAttempts: 5, baseDelay: 50 * time.Millisecond, maxDelay: 2 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Client{KV: kv, opts: o}
+}
+
+func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (resp *clientv3.PutResponse, err error) {
+	err = c.do(ctx, func() error {
+		resp, err = c.KV.Put(ctx, key, val, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.GetResponse, err error) {
+	err = c.do(ctx, func() error {
+		resp, err = c.KV.Get(ctx, key, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (resp *clientv3.DeleteResponse, err error) {
+	err = c.do(ctx, func() error {
+		resp, err = c.KV.Delete(ctx, key, opts...)
+		return err
+	})
+	return resp, err
+}
+
+func (c *Client) do(ctx context.Context, call func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = call(); err == nil || !IsRetryable(err) || attempt >= c.opts.maxAttempts {
+			return err
+		}
+		select {
+		case <-time.After(c.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backoff returns a random delay in [d/2, d) where d doubles per attempt.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.opts.baseDelay << (attempt - 1)
+	if d > c.opts.maxDelay || d <= 0 {
+		d = c.opts.maxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsRetryable reports whether err is transient, such as an unavailable
+// endpoint or a leader election in progress. Context errors and errors like
+// rpctypes.ErrCompacted are not.
+func IsRetryable(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	var code codes.Code
+	if ev, ok := err.(rpctypes.EtcdError); ok {
+		code = ev.Code()
+	} else {
+		code = status.Code(err)
+	}
+	return code == codes.Unavailable || code == codes.ResourceExhausted
+}
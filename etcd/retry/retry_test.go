@@ -0,0 +1,115 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/retry"
+	"github.com/stretchr/testify/suite"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// flakyKV fails the first failures calls with err, then succeeds.
+type flakyKV struct {
+	clientv3.KV
+	failures int
+	err      error
+	attempts int
+}
+
+func (kv *flakyKV) call() error {
+	kv.attempts++
+	if kv.attempts <= kv.failures {
+		return kv.err
+	}
+	return nil
+}
+
+func (kv *flakyKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if err := kv.call(); err != nil {
+		return nil, err
+	}
+	return &clientv3.PutResponse{}, nil
+}
+
+func (kv *flakyKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if err := kv.call(); err != nil {
+		return nil, err
+	}
+	return &clientv3.GetResponse{Count: 1}, nil
+}
+
+func (kv *flakyKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	if err := kv.call(); err != nil {
+		return nil, err
+	}
+	return &clientv3.DeleteResponse{Deleted: 1}, nil
+}
+
+type RetryTestSuite struct {
+	suite.Suite
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
+
+func (s *RetryTestSuite) TestRetryUntilSuccess() {
+	kv := &flakyKV{failures: 3, err: rpctypes.ErrLeaderChanged}
+	cli := retry.New(kv, retry.WithMaxAttempts(5), retry.WithBaseDelay(time.Millisecond))
+
+	resp, err := cli.Get(context.Background(), "key")
+	s.NoError(err)
+	s.Equal(int64(1), resp.Count)
+	s.Equal(4, kv.attempts)
+
+	kv.attempts = 0
+	_, err = cli.Put(context.Background(), "key", "val")
+	s.NoError(err)
+	s.Equal(4, kv.attempts)
+
+	kv.attempts = 0
+	_, err = cli.Delete(context.Background(), "key")
+	s.NoError(err)
+	s.Equal(4, kv.attempts)
+}
+
+func (s *RetryTestSuite) TestMaxAttempts() {
+	kv := &flakyKV{failures: 10, err: rpctypes.ErrGRPCNoLeader}
+	cli := retry.New(kv, retry.WithMaxAttempts(5), retry.WithBaseDelay(time.Millisecond))
+
+	_, err := cli.Get(context.Background(), "key")
+	s.Equal(rpctypes.ErrGRPCNoLeader, err)
+	s.Equal(5, kv.attempts)
+}
+
+func (s *RetryTestSuite) TestNonRetryable() {
+	kv := &flakyKV{failures: 10, err: rpctypes.ErrCompacted}
+	cli := retry.New(kv, retry.WithMaxAttempts(5), retry.WithBaseDelay(time.Millisecond))
+
+	_, err := cli.Get(context.Background(), "key")
+	s.Equal(rpctypes.ErrCompacted, err)
+	s.Equal(1, kv.attempts)
+
+	kv = &flakyKV{failures: 10, err: errors.New("boom")}
+	_, err = retry.New(kv).Get(context.Background(), "key")
+	s.Error(err)
+	s.Equal(1, kv.attempts)
+}
+
+func (s *RetryTestSuite) TestCancelShortCircuits() {
+	kv := &flakyKV{failures: 10, err: rpctypes.ErrTimeout}
+	cli := retry.New(kv, retry.WithMaxAttempts(100), retry.WithBaseDelay(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := cli.Put(ctx, "key", "val")
+	s.Equal(context.Canceled, err)
+	s.Equal(1, kv.attempts)
+	s.Less(time.Since(start), time.Second)
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/election"
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+type options struct {
+	ttl     int
+	onError func(error)
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease backing the leadership, i.e.
+// how long a crashed leader keeps the task from running elsewhere.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithOnError is called with the errors of the task callback and of
+// campaigning. They are dropped by default.
+func WithOnError(fn func(error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// LeaderTask runs a periodic task on a single instance at a time: every
+// instance campaigns on the same prefix and only the leader runs the task.
+type LeaderTask struct {
+	cli    *clientv3.Client
+	prefix string
+	id     string
+	opts   options
+}
+
+func New(cli *clientv3.Client, prefix, id string, opts ...Option) *LeaderTask {
+	o := options{ttl: defaultTTL, onError: func(error) {}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &LeaderTask{cli: cli, prefix: prefix, id: id, opts: o}
+}
+
+// Run blocks until ctx is done, calling fn every interval while this
+// instance is leader. When leadership is lost the context passed to fn is
+// cancelled, no further call is made and the instance campaigns again. Calls
+// never overlap on one instance; a leader whose lease expired may still be
+// finishing a call when the next leader starts, which is why fn should stop
+// promptly once its context is done.
+func (t *LeaderTask) Run(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error) error {
+	for ctx.Err() == nil {
+		if err := t.lead(ctx, interval, fn); err != nil && ctx.Err() == nil {
+			t.opts.onError(err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// lead campaigns and runs fn until leadership is lost or ctx is done.
+func (t *LeaderTask) lead(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error) error {
+	e := election.New(t.cli, t.prefix, election.WithTTL(t.opts.ttl))
+	if err := e.Campaign(ctx, t.id); err != nil {
+		return err
+	}
+	defer e.Resign(context.Background())
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	getResp, err := t.cli.Get(leaderCtx, e.Key())
+	if err != nil {
+		return err
+	}
+	if len(getResp.Kvs) == 0 {
+		return nil
+	}
+	go func() {
+		// leadership ends with the candidate key
+		wait.Delete(leaderCtx, t.cli, e.Key(), getResp.Header.Revision)
+		cancel()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-leaderCtx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		// the tick may have raced with losing leadership
+		if leaderCtx.Err() != nil {
+			return nil
+		}
+		if err := fn(leaderCtx); err != nil && leaderCtx.Err() == nil {
+			t.opts.onError(err)
+		}
+	}
+}
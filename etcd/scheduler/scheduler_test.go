@@ -0,0 +1,83 @@
+package scheduler_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/scheduler"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type SchedulerTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
+
+func (s *SchedulerTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *SchedulerTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *SchedulerTestSuite) TestLeaderTask() {
+	prefix := "/test/scheduler/leader"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var running int32
+	runs := make(map[string]*int32)
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b"} {
+		runs[id] = new(int32)
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			task := scheduler.New(s.cli, prefix, id)
+			task.Run(ctx, 20*time.Millisecond, func(ctx context.Context) error {
+				s.Equal(int32(1), atomic.AddInt32(&running, 1))
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				atomic.AddInt32(runs[id], 1)
+				return nil
+			})
+		}(id)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	s.Eventually(func() bool { return atomic.LoadInt32(runs["a"])+atomic.LoadInt32(runs["b"]) >= 5 }, 5*time.Second, 10*time.Millisecond)
+	getResp, err := s.cli.Get(context.Background(), prefix+"/", clientv3.WithFirstCreate()...)
+	s.NoError(err)
+	leader, follower := string(getResp.Kvs[0].Value), "a"
+	if leader == "a" {
+		follower = "b"
+	}
+	s.Zero(atomic.LoadInt32(runs[follower]))
+
+	// killing the leader's lease promotes the other instance
+	_, err = s.cli.Revoke(context.Background(), clientv3.LeaseID(getResp.Kvs[0].Lease))
+	s.NoError(err)
+	s.Eventually(func() bool { return atomic.LoadInt32(runs[follower]) >= 5 }, 5*time.Second, 10*time.Millisecond)
+
+	// the old leader queued up again behind the new one
+	stopped := atomic.LoadInt32(runs[leader])
+	time.Sleep(100 * time.Millisecond)
+	s.Equal(stopped, atomic.LoadInt32(runs[leader]))
+}
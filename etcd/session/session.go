@@ -0,0 +1,276 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/clock"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 60
+
+var ErrKeepAliveLost = errors.New("session: keep-alive lost")
+
+type options struct {
+	ttl         int64
+	ctx         context.Context
+	recover     func(ctx context.Context, lease clientv3.LeaseID) error
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	resume      bool
+	clock       clock.Clock
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the session lease.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = int64(ttl)
+	}
+}
+
+// WithContext sets the context used to grant the lease. Cancelling it does
+// not end the session; Close does.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithAutoRecover makes the session survive losing its keep-alive: it grants
+// a fresh lease, revokes the old one so that none of its keys linger next to
+// their re-registered copies, and calls reregister to put the session's keys
+// again with the new lease. If granting or reregister fails, recovery starts
+// over a second later, or as paced by WithKeepAliveBackoff, until the session
+// is closed. Done is then only closed by Close.
+func WithAutoRecover(reregister func(ctx context.Context, lease clientv3.LeaseID) error) Option {
+	return func(o *options) {
+		o.recover = reregister
+	}
+}
+
+// WithKeepAliveBackoff retries after the keep-alive is lost, waiting base
+// after the first failed attempt and twice as long after each further one, up
+// to max. Every failed attempt is reported to the OnKeepAliveLost callbacks.
+//
+// Without WithAutoRecover the session keeps trying to resume the keep-alive
+// of its lease until the TTL has passed since the lease was last renewed, at
+// which point the lease is certainly gone and Done is closed. With
+// WithAutoRecover it paces the attempts to replace the lease, which otherwise
+// happen every second.
+func WithKeepAliveBackoff(base, max time.Duration) Option {
+	return func(o *options) {
+		o.backoffBase, o.backoffMax, o.resume = base, max, true
+	}
+}
+
+// WithClock sets the clock the backoff waits and the TTL are measured by.
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// Session is a lease kept alive in the background for as long as the
+// session lives. Keys put with its lease disappear once the session ends,
+// either by Close or because the keep-alive permanently failed.
+type Session struct {
+	cli    *clientv3.Client
+	ttl    int64
+	opts   options
+	cancel context.CancelFunc
+	donec  chan struct{}
+
+	mu      sync.Mutex
+	id      clientv3.LeaseID
+	renewed time.Time
+	onLost  []func(err error)
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func New(cli *clientv3.Client, opts ...Option) (*Session, error) {
+	o := options{ttl: defaultTTL, ctx: context.Background(), backoffBase: time.Second, backoffMax: time.Second, clock: clock.Real}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	granted := o.clock.Now()
+	resp, err := cli.Grant(o.ctx, o.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	keepChan, err := cli.KeepAlive(ctx, resp.ID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &Session{cli: cli, id: resp.ID, renewed: granted, ttl: o.ttl, opts: o, cancel: cancel, donec: make(chan struct{})}
+	go s.run(ctx, keepChan)
+	return s, nil
+}
+
+func (s *Session) run(ctx context.Context, keepChan <-chan *clientv3.LeaseKeepAliveResponse) {
+	defer close(s.donec)
+	for {
+		// drain so the keep-alive never blocks; the channel closes when the
+		// keep-alive stops for good
+		for range keepChan {
+			// sent before the renewal, so the TTL counts from no later
+			// than the server's
+			s.mu.Lock()
+			s.renewed = s.opts.clock.Now()
+			s.mu.Unlock()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		s.lost(ErrKeepAliveLost)
+
+		switch {
+		case s.opts.recover != nil:
+			keepChan = s.retry(ctx, s.regrant)
+		case s.opts.resume:
+			keepChan = s.retry(ctx, s.resume)
+		default:
+			return
+		}
+		if keepChan == nil {
+			return
+		}
+	}
+}
+
+func (s *Session) lost(err error) {
+	s.mu.Lock()
+	callbacks := append([]func(err error){}, s.onLost...)
+	s.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(err)
+	}
+}
+
+// errExpired stops retry once the lease can no longer be saved.
+var errExpired = errors.New("session: lease expired")
+
+// retry calls attempt until it succeeds, backing off between failures. It
+// returns nil once ctx is done or attempt fails with errExpired.
+func (s *Session) retry(ctx context.Context, attempt func(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error)) <-chan *clientv3.LeaseKeepAliveResponse {
+	delay := s.opts.backoffBase
+	for {
+		keepChan, err := attempt(ctx)
+		if err == nil {
+			return keepChan
+		}
+		if err == errExpired || ctx.Err() != nil {
+			return nil
+		}
+		s.lost(err)
+
+		wait := delay
+		if s.opts.recover == nil {
+			// no point waiting past the expiry of the lease being resumed
+			wait = min(wait, s.deadline().Sub(s.opts.clock.Now()))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.opts.clock.After(wait):
+		}
+		delay = min(2*delay, s.opts.backoffMax)
+	}
+}
+
+// deadline is when the lease certainly expired if not renewed since.
+func (s *Session) deadline() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.renewed.Add(time.Duration(s.ttl) * time.Second)
+}
+
+// resume restarts the keep-alive of the current lease, as long as the lease
+// may still be alive.
+func (s *Session) resume(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	if !s.opts.clock.Now().Before(s.deadline()) {
+		return nil, errExpired
+	}
+	return s.cli.KeepAlive(ctx, s.Lease())
+}
+
+func (s *Session) regrant(ctx context.Context) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	granted := s.opts.clock.Now()
+	resp, err := s.cli.Grant(ctx, s.ttl)
+	if err != nil {
+		return nil, err
+	}
+	keepChan, err := s.cli.KeepAlive(ctx, resp.ID)
+	if err != nil {
+		s.cli.Revoke(ctx, resp.ID)
+		return nil, err
+	}
+
+	s.mu.Lock()
+	old := s.id
+	s.id, s.renewed = resp.ID, granted
+	s.mu.Unlock()
+
+	// the old lease may still be alive; its keys must not outlive the
+	// re-registration
+	s.cli.Revoke(ctx, old)
+	if err := s.opts.recover(ctx, resp.ID); err != nil {
+		// let the next attempt start from scratch
+		s.cli.Revoke(ctx, resp.ID)
+		for range keepChan {
+		}
+		return nil, err
+	}
+	return keepChan, nil
+}
+
+// OnKeepAliveLost registers fn to be called when the keep-alive stops
+// without Close being called. It runs before Done is closed, or before
+// recovery starts with WithAutoRecover. While the session retries, fn is also
+// called with the error of every failed attempt.
+func (s *Session) OnKeepAliveLost(fn func(err error)) {
+	s.mu.Lock()
+	s.onLost = append(s.onLost, fn)
+	s.mu.Unlock()
+}
+
+func (s *Session) Client() *clientv3.Client { return s.cli }
+
+// Lease returns the session lease, which WithAutoRecover may replace.
+func (s *Session) Lease() clientv3.LeaseID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+func (s *Session) TTL() int64 { return s.ttl }
+
+// Done is closed when the keep-alive stops, after which the lease expires.
+// With WithKeepAliveBackoff it is closed once resuming failed for a whole TTL.
+// With WithAutoRecover it is only closed by Close.
+func (s *Session) Done() <-chan struct{} { return s.donec }
+
+// Close stops the keep-alive and revokes the lease. It is safe to call more
+// than once.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		// wait for a recovery in progress, so the lease revoked is the last
+		<-s.donec
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.ttl)*time.Second)
+		defer cancel()
+		_, s.closeErr = s.cli.Revoke(ctx, s.Lease())
+	})
+	return s.closeErr
+}
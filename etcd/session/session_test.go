@@ -0,0 +1,297 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/lock"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type SessionTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestSessionTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
+
+func (s *SessionTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *SessionTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *SessionTestSuite) TestKeepAlive() {
+	sess, err := session.New(s.cli, session.WithTTL(2))
+	s.NoError(err)
+	defer sess.Close()
+
+	key := "/test/session/keepalive"
+	_, err = s.cli.Put(context.Background(), key, "val", clientv3.WithLease(sess.Lease()))
+	s.NoError(err)
+
+	// longer than the TTL
+	time.Sleep(3 * time.Second)
+
+	getRes, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal(int64(1), getRes.Count)
+
+	s.NoError(sess.Close())
+	s.NoError(sess.Close())
+	getRes, err = s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Zero(getRes.Count)
+}
+
+func (s *SessionTestSuite) TestDoneOnRevoke() {
+	sess, err := session.New(s.cli, session.WithTTL(5))
+	s.NoError(err)
+	defer sess.Close()
+
+	select {
+	case <-sess.Done():
+		s.Fail("session done before revoke")
+	default:
+	}
+
+	_, err = s.cli.Revoke(context.Background(), sess.Lease())
+	s.NoError(err)
+
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		s.Fail("session not done after lease revoked")
+	}
+}
+
+func (s *SessionTestSuite) TestSharedWithMutex() {
+	sess, err := session.New(s.cli, session.WithTTL(5))
+	s.NoError(err)
+	defer sess.Close()
+
+	key := "/test/session/shared/registration"
+	_, err = s.cli.Put(context.Background(), key, "val", clientv3.WithLease(sess.Lease()))
+	s.NoError(err)
+
+	m := lock.NewMutex(s.cli, "/test/session/shared/lock", lock.WithSession(sess))
+	s.NoError(m.Lock(context.Background()))
+	s.NoError(m.Unlock(context.Background()))
+
+	// unlocking must not end the shared session
+	getRes, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal(int64(1), getRes.Count)
+
+	s.NoError(m.Lock(context.Background()))
+	s.NoError(sess.Close())
+	getRes, err = s.cli.Get(context.Background(), m.Key())
+	s.NoError(err)
+	s.Zero(getRes.Count)
+}
+
+func (s *SessionTestSuite) TestDoneOnExpiryFake() {
+	clock := fake.NewClock()
+	cli := fake.NewClient(clock)
+	defer cli.Close()
+
+	sess, err := session.New(cli, session.WithTTL(3))
+	s.NoError(err)
+	defer sess.Close()
+
+	key := "/test/session/fake"
+	_, err = cli.Put(context.Background(), key, "val", clientv3.WithLease(sess.Lease()))
+	s.NoError(err)
+
+	// the keep-alive cannot make up for time passing at once
+	clock.Advance(4 * time.Second)
+	getRes, err := cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Zero(getRes.Count)
+
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		s.Fail("session not done after lease expired")
+	}
+}
+
+func (s *SessionTestSuite) TestKeepAliveLost() {
+	sess, err := session.New(s.cli, session.WithTTL(5))
+	s.NoError(err)
+	defer sess.Close()
+
+	lost := make(chan error, 1)
+	sess.OnKeepAliveLost(func(err error) { lost <- err })
+
+	// a revoke from elsewhere ends the keep-alive
+	_, err = s.cli.Revoke(context.Background(), sess.Lease())
+	s.NoError(err)
+
+	select {
+	case err := <-lost:
+		s.ErrorIs(err, session.ErrKeepAliveLost)
+	case <-time.After(5 * time.Second):
+		s.FailNow("callback not called")
+	}
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		s.Fail("session not done after keep-alive lost")
+	}
+}
+
+func (s *SessionTestSuite) TestAutoRecover() {
+	key := "/test/session/recover"
+	defer s.cli.Delete(context.Background(), key)
+
+	leases := make(chan clientv3.LeaseID, 1)
+	reregister := func(ctx context.Context, lease clientv3.LeaseID) error {
+		if _, err := s.cli.Put(ctx, key, "val", clientv3.WithLease(lease)); err != nil {
+			return err
+		}
+		leases <- lease
+		return nil
+	}
+	sess, err := session.New(s.cli, session.WithTTL(5), session.WithAutoRecover(reregister))
+	s.NoError(err)
+	defer sess.Close()
+	s.NoError(reregister(context.Background(), sess.Lease()))
+	first := <-leases
+
+	lost := make(chan error, 1)
+	sess.OnKeepAliveLost(func(err error) { lost <- err })
+	_, err = s.cli.Revoke(context.Background(), first)
+	s.NoError(err)
+
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		s.FailNow("callback not called")
+	}
+	var second clientv3.LeaseID
+	select {
+	case second = <-leases:
+	case <-time.After(5 * time.Second):
+		s.FailNow("keys not re-registered")
+	}
+	s.NotEqual(first, second)
+	s.Equal(second, sess.Lease())
+
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal(int64(1), getResp.Count)
+	s.Equal(int64(second), getResp.Kvs[0].Lease)
+
+	select {
+	case <-sess.Done():
+		s.Fail("session done despite recovery")
+	default:
+	}
+
+	s.NoError(sess.Close())
+	getResp, err = s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Zero(getResp.Count)
+}
+
+// unreachableLease hands out a keep-alive that stops when lost is closed, and
+// fails every keep-alive after it.
+type unreachableLease struct {
+	clientv3.Lease
+	clock *fake.Clock
+	lost  chan struct{}
+
+	mu       sync.Mutex
+	attempts []time.Time
+}
+
+func (l *unreachableLease) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.attempts == nil {
+		l.attempts = []time.Time{}
+		keepChan := make(chan *clientv3.LeaseKeepAliveResponse)
+		go func() {
+			<-l.lost
+			close(keepChan)
+		}()
+		return keepChan, nil
+	}
+	l.attempts = append(l.attempts, l.clock.Now())
+	return nil, errUnreachable
+}
+
+func (l *unreachableLease) Attempts() []time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]time.Time(nil), l.attempts...)
+}
+
+var errUnreachable = errors.New("cluster unreachable")
+
+func (s *SessionTestSuite) TestKeepAliveBackoff() {
+	clock := fake.NewClock()
+	cli := fake.NewClient(clock)
+	defer cli.Close()
+	lease := &unreachableLease{Lease: cli.Lease, clock: clock, lost: make(chan struct{})}
+	cli.Lease = lease
+
+	start := clock.Now()
+	sess, err := session.New(cli, session.WithTTL(10), session.WithClock(clock),
+		session.WithKeepAliveBackoff(time.Second, 4*time.Second))
+	s.Require().NoError(err)
+	defer sess.Close()
+	var mu sync.Mutex
+	var errs []error
+	sess.OnKeepAliveLost(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	})
+
+	close(lease.lost)
+	// waits of 1s, 2s, then 4s, and the last one cut short by the TTL
+	for _, d := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 3 * time.Second} {
+		clock.BlockUntil(1)
+		select {
+		case <-sess.Done():
+			s.FailNow("session done before the TTL passed")
+		default:
+		}
+		clock.Advance(d)
+	}
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		s.FailNow("session not done after the TTL passed")
+	}
+
+	var offsets []time.Duration
+	for _, at := range lease.Attempts() {
+		offsets = append(offsets, at.Sub(start))
+	}
+	s.Equal([]time.Duration{0, time.Second, 3 * time.Second, 7 * time.Second}, offsets)
+	mu.Lock()
+	defer mu.Unlock()
+	s.Require().Len(errs, 5)
+	s.ErrorIs(errs[0], session.ErrKeepAliveLost)
+	for _, err := range errs[1:] {
+		s.ErrorIs(err, errUnreachable)
+	}
+}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultPageSize = 100
+
+var (
+	ErrNotFound      = errors.New("store: not found")
+	ErrUnknownSchema = errors.New("store: unknown schema")
+)
+
+type options struct {
+	codec    codec.Codec
+	schema   int
+	pageSize int64
+}
+
+type Option func(*options)
+
+// WithCodec sets the codec values are encoded with, JSON by default.
+func WithCodec(c codec.Codec) Option {
+	return func(o *options) {
+		o.codec = c
+	}
+}
+
+// WithSchema sets the schema version written with every value, 1 by
+// default. Bump it when T changes in a way older readers cannot decode.
+func WithSchema(version int) Option {
+	return func(o *options) {
+		o.schema = version
+	}
+}
+
+// WithPageSize sets how many values List reads per request, 100 by default.
+func WithPageSize(n int) Option {
+	return func(o *options) {
+		o.pageSize = int64(n)
+	}
+}
+
+// Typed keeps values of type T under a prefix, one key per id. Each value is
+// stored as a "schema=<version>" line followed by the encoded T, so a reader
+// expecting another version fails with ErrUnknownSchema instead of decoding
+// the value wrong. T is decoded through a pointer to it.
+type Typed[T any] struct {
+	cli    etcdx.KV
+	prefix string
+	opts   options
+}
+
+// New stores values under prefix, which gets a trailing slash if it has none.
+func New[T any](cli etcdx.KV, prefix string, opts ...Option) *Typed[T] {
+	o := options{codec: codec.JSON, schema: 1, pageSize: defaultPageSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Typed[T]{cli: cli, prefix: prefix, opts: o}
+}
+
+func (t *Typed[T]) Put(ctx context.Context, id string, v T) error {
+	data, err := t.encode(v)
+	if err != nil {
+		return err
+	}
+	_, err = t.cli.Put(ctx, t.prefix+id, string(data))
+	return err
+}
+
+// Get returns the value of id, or ErrNotFound.
+func (t *Typed[T]) Get(ctx context.Context, id string) (T, error) {
+	var v T
+	resp, err := t.cli.Get(ctx, t.prefix+id)
+	if err != nil {
+		return v, err
+	}
+	if len(resp.Kvs) == 0 {
+		return v, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return t.decode(resp.Kvs[0].Key, resp.Kvs[0].Value)
+}
+
+// List returns every value by id. It reads them a page at a time, all at the
+// revision of the first page.
+func (t *Typed[T]) List(ctx context.Context) (map[string]T, error) {
+	out := make(map[string]T)
+	end := clientv3.GetPrefixRangeEnd(t.prefix)
+	next := t.prefix
+	var rev int64
+	for {
+		opts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(t.opts.pageSize)}
+		if rev > 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		resp, err := t.cli.Get(ctx, next, opts...)
+		if err != nil {
+			return nil, err
+		}
+		rev = resp.Header.Revision
+		for _, kv := range resp.Kvs {
+			v, err := t.decode(kv.Key, kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			out[strings.TrimPrefix(string(kv.Key), t.prefix)] = v
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			return out, nil
+		}
+		next = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+}
+
+// Delete removes id; a missing id is not an error.
+func (t *Typed[T]) Delete(ctx context.Context, id string) error {
+	_, err := t.cli.Delete(ctx, t.prefix+id)
+	return err
+}
+
+func (t *Typed[T]) encode(v T) ([]byte, error) {
+	data, err := t.opts.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("schema="+strconv.Itoa(t.opts.schema)+"\n"), data...), nil
+}
+
+func (t *Typed[T]) decode(key, value []byte) (T, error) {
+	var v T
+	header, data, ok := bytes.Cut(value, []byte("\n"))
+	schema, found := bytes.CutPrefix(header, []byte("schema="))
+	if !ok || !found {
+		return v, fmt.Errorf("%w: %s has no schema", ErrUnknownSchema, key)
+	}
+	if string(schema) != strconv.Itoa(t.opts.schema) {
+		return v, fmt.Errorf("%w: %s has schema %s, want %d", ErrUnknownSchema, key, schema, t.opts.schema)
+	}
+	if err := t.opts.codec.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("store: decode %s: %w", key, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,100 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/codec"
+	"github.com/gojustforfun/learn-by-test/etcd/store"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type User struct {
+	Name   string            `json:"name" yaml:"name"`
+	Age    int               `json:"age" yaml:"age"`
+	Emails []string          `json:"emails" yaml:"emails"`
+	Labels map[string]string `json:"labels" yaml:"labels"`
+	Joined time.Time         `json:"joined" yaml:"joined"`
+}
+
+type StoreTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
+
+func (s *StoreTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *StoreTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *StoreTestSuite) TestRoundTrip() {
+	prefix := "/test/store/users"
+	defer s.cli.Delete(context.Background(), prefix+"/", clientv3.WithPrefix())
+
+	for name, c := range map[string]codec.Codec{"JSON": codec.JSON, "YAML": codec.YAML} {
+		s.Run(name, func() {
+			users := store.New[User](s.cli, prefix, store.WithCodec(c), store.WithPageSize(2))
+			want := make(map[string]User)
+			for i := 0; i < 5; i++ {
+				u := User{
+					Name:   fmt.Sprint("user-", i),
+					Age:    20 + i,
+					Emails: []string{fmt.Sprintf("u%d@example.com", i)},
+					Labels: map[string]string{"team": "a"},
+					Joined: time.Date(2021, 1, i+1, 0, 0, 0, 0, time.UTC),
+				}
+				id := fmt.Sprint("u", i)
+				s.Require().NoError(users.Put(context.Background(), id, u))
+				want[id] = u
+			}
+
+			u, err := users.Get(context.Background(), "u3")
+			s.Require().NoError(err)
+			s.Equal(want["u3"], u)
+
+			all, err := users.List(context.Background())
+			s.Require().NoError(err)
+			s.Equal(want, all)
+
+			s.NoError(users.Delete(context.Background(), "u3"))
+			_, err = users.Get(context.Background(), "u3")
+			s.ErrorIs(err, store.ErrNotFound)
+			all, err = users.List(context.Background())
+			s.Require().NoError(err)
+			s.Len(all, 4)
+		})
+	}
+}
+
+func (s *StoreTestSuite) TestSchema() {
+	prefix := "/test/store/schema"
+	defer s.cli.Delete(context.Background(), prefix+"/", clientv3.WithPrefix())
+
+	s.Require().NoError(store.New[User](s.cli, prefix).Put(context.Background(), "u1", User{Name: "old"}))
+	_, err := s.cli.Put(context.Background(), prefix+"/raw", `{"name":"raw"}`)
+	s.Require().NoError(err)
+
+	v2 := store.New[User](s.cli, prefix, store.WithSchema(2))
+	_, err = v2.Get(context.Background(), "u1")
+	s.ErrorIs(err, store.ErrUnknownSchema)
+	_, err = v2.List(context.Background())
+	s.ErrorIs(err, store.ErrUnknownSchema)
+
+	_, err = store.New[User](s.cli, prefix).Get(context.Background(), "raw")
+	s.ErrorIs(err, store.ErrUnknownSchema)
+}
@@ -0,0 +1,111 @@
+package barrier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+type options struct {
+	ttl int
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease backing a participant's key,
+// i.e. how long a crashed participant keeps counting as entered.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// Barrier blocks n participants until all of them have entered. Each
+// participant uses its own Barrier on the same prefix.
+//
+// Participants are counted by lease-backed keys under <prefix>/waiters/. A
+// participant that crashes before the barrier opens drops out once its lease
+// expires, so the barrier does not stall for good but waits for a
+// replacement. The participant that sees the nth key puts <prefix>/ready,
+// which opens the barrier for good; delete the prefix to reuse it.
+type Barrier struct {
+	cli    *clientv3.Client
+	prefix string
+	n      int64
+	opts   options
+}
+
+func New(cli *clientv3.Client, prefix string, n int, opts ...Option) *Barrier {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Barrier{cli: cli, prefix: prefix + "/", n: int64(n), opts: o}
+}
+
+// Enter blocks until n participants have entered or ctx is done.
+func (b *Barrier) Enter(ctx context.Context) error {
+	sess, _, err := b.enter(ctx)
+	if err != nil {
+		return err
+	}
+	return sess.Close()
+}
+
+// enter registers a waiter key and blocks until the barrier opens. The
+// returned session keeps the key, and the revision is that of the ready key;
+// on error the key has been removed.
+func (b *Barrier) enter(ctx context.Context) (*session.Session, int64, error) {
+	sess, err := session.New(b.cli, session.WithTTL(b.opts.ttl), session.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	key := fmt.Sprintf("%s%x", b.waitersPrefix(), sess.Lease())
+	if _, err := b.cli.Put(ctx, key, "", clientv3.WithLease(sess.Lease())); err != nil {
+		sess.Close()
+		return nil, 0, err
+	}
+
+	readyRev, err := b.waitReady(ctx)
+	if err != nil {
+		sess.Close()
+		return nil, 0, err
+	}
+	return sess, readyRev, nil
+}
+
+// waitReady blocks until the ready key exists and returns its revision.
+func (b *Barrier) waitReady(ctx context.Context) (int64, error) {
+	for {
+		resp, err := b.cli.Txn(ctx).Then(
+			clientv3.OpGet(b.readyKey(), clientv3.WithKeysOnly()),
+			clientv3.OpGet(b.waitersPrefix(), clientv3.WithPrefix(), clientv3.WithCountOnly()),
+		).Commit()
+		if err != nil {
+			return 0, err
+		}
+		if kvs := resp.Responses[0].GetResponseRange().Kvs; len(kvs) > 0 {
+			return kvs[0].ModRevision, nil
+		}
+		if resp.Responses[1].GetResponseRange().Count >= b.n {
+			putResp, err := b.cli.Put(ctx, b.readyKey(), "")
+			if err != nil {
+				return 0, err
+			}
+			return putResp.Header.Revision, nil
+		}
+
+		if err := wait.AnyPut(ctx, b.cli, b.prefix, resp.Header.Revision); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (b *Barrier) readyKey() string { return b.prefix + "ready" }
+
+func (b *Barrier) waitersPrefix() string { return b.prefix + "waiters/" }
@@ -0,0 +1,84 @@
+package barrier_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/sync/barrier"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type BarrierTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestBarrierTestSuite(t *testing.T) {
+	suite.Run(t, new(BarrierTestSuite))
+}
+
+func (s *BarrierTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *BarrierTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *BarrierTestSuite) TestEnter() {
+	prefix := "/test/barrier/enter"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	var entered int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * 100 * time.Millisecond)
+			atomic.AddInt32(&entered, 1)
+			s.NoError(barrier.New(s.cli, prefix, 4).Enter(context.Background()))
+			s.Equal(int32(4), atomic.LoadInt32(&entered))
+		}(i)
+	}
+	wg.Wait()
+
+	// an open barrier lets late participants through
+	s.NoError(barrier.New(s.cli, prefix, 4).Enter(context.Background()))
+}
+
+func (s *BarrierTestSuite) TestDropout() {
+	prefix := "/test/barrier/dropout"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	// a participant that gives up no longer counts
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(barrier.New(s.cli, prefix, 3).Enter(ctx), context.DeadlineExceeded)
+
+	var opened int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.NoError(barrier.New(s.cli, prefix, 3).Enter(context.Background()))
+			atomic.AddInt32(&opened, 1)
+		}()
+	}
+	time.Sleep(300 * time.Millisecond)
+	s.Zero(atomic.LoadInt32(&opened))
+
+	s.NoError(barrier.New(s.cli, prefix, 3).Enter(context.Background()))
+	wg.Wait()
+	s.Equal(int32(2), atomic.LoadInt32(&opened))
+}
@@ -0,0 +1,70 @@
+package barrier
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrNotEntered = errors.New("barrier: leave without enter")
+
+// DoubleBarrier synchronizes n participants both at the start and at the end
+// of a phase: Enter blocks until all have entered, Leave until all have left.
+// A participant whose lease expired counts as having left. Once everyone has
+// left, the barrier resets for the next phase, so the same participants can
+// go through it again and again.
+//
+// A phase is told apart by the revision of its ready key: every waiter key of
+// a phase is created before the phase opens and every key of the next one
+// after, so Leave only waits for the keys created by then. A participant
+// slow to leave never waits for those that already entered the next phase.
+type DoubleBarrier struct {
+	b *Barrier
+
+	mu       sync.Mutex
+	session  *session.Session
+	readyRev int64
+}
+
+func NewDouble(cli *clientv3.Client, prefix string, n int, opts ...Option) *DoubleBarrier {
+	return &DoubleBarrier{b: New(cli, prefix, n, opts...)}
+}
+
+// Enter blocks until n participants have entered or ctx is done.
+func (d *DoubleBarrier) Enter(ctx context.Context) error {
+	sess, readyRev, err := d.b.enter(ctx)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.session, d.readyRev = sess, readyRev
+	d.mu.Unlock()
+	return nil
+}
+
+// Leave removes this participant and blocks until all others have left too
+// or ctx is done.
+func (d *DoubleBarrier) Leave(ctx context.Context) error {
+	d.mu.Lock()
+	sess, readyRev := d.session, d.readyRev
+	d.session = nil
+	d.mu.Unlock()
+
+	if sess == nil {
+		return ErrNotEntered
+	}
+	if err := sess.Close(); err != nil {
+		return err
+	}
+
+	b := d.b
+	if err := wait.Deletes(ctx, b.cli, b.waitersPrefix(), readyRev); err != nil {
+		return err
+	}
+	_, err := b.cli.Delete(ctx, b.readyKey())
+	return err
+}
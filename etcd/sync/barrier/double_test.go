@@ -0,0 +1,100 @@
+package barrier_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/sync/barrier"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *BarrierTestSuite) TestDoubleBarrier() {
+	prefix := "/test/barrier/double"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	var started, computed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := barrier.NewDouble(s.cli, prefix, 3)
+
+			time.Sleep(time.Duration(i) * 100 * time.Millisecond)
+			atomic.AddInt32(&started, 1)
+			s.NoError(b.Enter(context.Background()))
+			s.Equal(int32(3), atomic.LoadInt32(&started))
+
+			time.Sleep(time.Duration(3-i) * 100 * time.Millisecond)
+			atomic.AddInt32(&computed, 1)
+			s.NoError(b.Leave(context.Background()))
+			s.Equal(int32(3), atomic.LoadInt32(&computed))
+		}(i)
+	}
+	wg.Wait()
+
+	s.ErrorIs(barrier.NewDouble(s.cli, prefix, 3).Leave(context.Background()), barrier.ErrNotEntered)
+
+	resp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(resp.Count)
+}
+
+func (s *BarrierTestSuite) TestDoubleBarrierCrashedParticipant() {
+	prefix := "/test/barrier/crashed"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	crashed, err := clientv3.New(clientv3.Config{
+		Endpoints:   s.cli.Endpoints(),
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := barrier.NewDouble(s.cli, prefix, 3)
+			s.NoError(b.Enter(context.Background()))
+			s.NoError(b.Leave(context.Background()))
+		}()
+	}
+	s.NoError(barrier.NewDouble(crashed, prefix, 3, barrier.WithTTL(2)).Enter(context.Background()))
+
+	// the crashed participant never leaves, its lease expires instead
+	crashed.Close()
+	wg.Wait()
+}
+
+func (s *BarrierTestSuite) TestDoubleBarrierPhases() {
+	prefix := "/test/barrier/phases"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const n, phases = 3, 30
+	var entered [phases]int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := barrier.NewDouble(s.cli, prefix, n)
+			for phase := 0; phase < phases; phase++ {
+				atomic.AddInt32(&entered[phase], 1)
+				if !s.NoError(b.Enter(ctx)) {
+					return
+				}
+				s.Equal(int32(n), atomic.LoadInt32(&entered[phase]))
+				if !s.NoError(b.Leave(ctx)) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
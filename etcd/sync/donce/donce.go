@@ -0,0 +1,140 @@
+package donce
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	defaultTTL = 10
+	prefix     = "/once/"
+	inProgress = "in-progress"
+	done       = "done"
+)
+
+var ErrLeaseExpired = errors.New("donce: lease expired while running, the action may run again")
+
+type options struct {
+	ttl int
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease the running marker is put
+// with, i.e. how long a runner that crashed holds up the others.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// Once runs each named action exactly once across every process using it.
+//
+// The caller that puts /once/<name> first runs the action, with the key
+// holding "in-progress" under a lease of its own. Once the action succeeds
+// the key is put to "done" without a lease and stays so for good. If the
+// action fails, or the runner dies and its lease expires, the key is gone
+// and the next caller runs the action instead.
+type Once struct {
+	cli  *clientv3.Client
+	opts options
+}
+
+func New(cli *clientv3.Client, opts ...Option) *Once {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Once{cli: cli, opts: o}
+}
+
+// Do runs fn unless name was already done, and reports whether it ran it. If
+// another caller is running it, Do waits for that to finish: it returns
+// false once the action is done, and takes over if it failed.
+//
+// When fn fails its error is returned and the action is left to the next
+// caller. When it succeeds but the lease expired meanwhile, another caller
+// may have started it again, and ErrLeaseExpired is returned with true.
+func (o *Once) Do(ctx context.Context, name string, fn func(ctx context.Context) error) (bool, error) {
+	key := prefix + name
+	for {
+		sess, err := session.New(o.cli, session.WithTTL(o.opts.ttl), session.WithContext(ctx))
+		if err != nil {
+			return false, err
+		}
+		resp, err := o.cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, inProgress, clientv3.WithLease(sess.Lease()))).
+			Else(clientv3.OpGet(key)).
+			Commit()
+		if err != nil {
+			sess.Close()
+			return false, err
+		}
+		if resp.Succeeded {
+			return true, o.run(ctx, sess, key, fn)
+		}
+		sess.Close()
+
+		kvs := resp.Responses[0].GetResponseRange().Kvs
+		if len(kvs) > 0 && string(kvs[0].Value) == done {
+			return false, nil
+		}
+		finished, err := o.await(ctx, key, resp.Header.Revision)
+		if err != nil || finished {
+			return false, err
+		}
+	}
+}
+
+// run runs fn as the elected runner, then marks key done or, if fn failed,
+// deletes it for the next caller. Either only happens while key is still on
+// the runner's lease.
+func (o *Once) run(ctx context.Context, sess *session.Session, key string, fn func(ctx context.Context) error) error {
+	defer sess.Close()
+
+	ours := clientv3.Compare(clientv3.LeaseValue(key), "=", sess.Lease())
+	if err := fn(ctx); err != nil {
+		o.cli.Txn(context.Background()).If(ours).Then(clientv3.OpDelete(key)).Commit()
+		return err
+	}
+	resp, err := o.cli.Txn(ctx).If(ours).Then(clientv3.OpPut(key, done)).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// await watches key from after rev until it is done, reporting true, or gone,
+// reporting false.
+func (o *Once) await(ctx context.Context, key string, rev int64) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var watchResp clientv3.WatchResponse
+	for watchResp = range o.cli.Watch(ctx, key, clientv3.WithRev(rev+1)) {
+		for _, ev := range watchResp.Events {
+			if ev.Type == mvccpb.DELETE {
+				return false, nil
+			}
+			if string(ev.Kv.Value) == done {
+				return true, nil
+			}
+		}
+	}
+	if err := watchResp.Err(); err != nil {
+		return false, err
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return false, wait.ErrWatchClosed
+}
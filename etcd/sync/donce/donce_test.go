@@ -0,0 +1,109 @@
+package donce_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/sync/donce"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type DOnceTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestDOnceTestSuite(t *testing.T) {
+	suite.Run(t, new(DOnceTestSuite))
+}
+
+func (s *DOnceTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *DOnceTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *DOnceTestSuite) TestDo() {
+	name := "test-do"
+	defer s.cli.Delete(context.Background(), "/once/"+name)
+
+	var runs, ran atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// each caller as its own process
+			ok, err := donce.New(s.cli).Do(context.Background(), name, func(ctx context.Context) error {
+				runs.Add(1)
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			})
+			s.NoError(err)
+			if ok {
+				ran.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	s.Equal(int64(1), runs.Load())
+	s.Equal(int64(1), ran.Load())
+
+	ok, err := donce.New(s.cli).Do(context.Background(), name, func(ctx context.Context) error {
+		s.Fail("ran again")
+		return nil
+	})
+	s.NoError(err)
+	s.False(ok)
+}
+
+func (s *DOnceTestSuite) TestDoFailed() {
+	name := "test-failed"
+	defer s.cli.Delete(context.Background(), "/once/"+name)
+
+	boom := errors.New("boom")
+	once := donce.New(s.cli)
+	ok, err := once.Do(context.Background(), name, func(ctx context.Context) error { return boom })
+	s.ErrorIs(err, boom)
+	s.True(ok)
+
+	// a failed action is left to the next caller
+	ok, err = once.Do(context.Background(), name, func(ctx context.Context) error { return nil })
+	s.NoError(err)
+	s.True(ok)
+}
+
+func (s *DOnceTestSuite) TestRunnerCrashed() {
+	name := "test-crashed"
+	key := "/once/" + name
+	defer s.cli.Delete(context.Background(), key)
+
+	// a runner that died mid-action, leaving its marker until the lease expires
+	lease, err := s.cli.Grant(context.Background(), 2)
+	s.Require().NoError(err)
+	_, err = s.cli.Put(context.Background(), key, "in-progress", clientv3.WithLease(lease.ID))
+	s.Require().NoError(err)
+
+	start := time.Now()
+	ok, err := donce.New(s.cli).Do(context.Background(), name, func(ctx context.Context) error { return nil })
+	s.NoError(err)
+	s.True(ok)
+	s.GreaterOrEqual(time.Since(start), time.Second)
+
+	getResp, err := s.cli.Get(context.Background(), key)
+	s.NoError(err)
+	s.Equal("done", string(getResp.Kvs[0].Value))
+	s.Zero(getResp.Kvs[0].Lease)
+}
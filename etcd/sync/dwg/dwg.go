@@ -0,0 +1,141 @@
+package dwg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrNegativeCounter = errors.New("dwg: negative counter")
+
+type options struct {
+	session *session.Session
+}
+
+type Option func(*options)
+
+// WithSession ties the Adds of this WaitGroup to the session, so that if the
+// process crashes they are done for it once the session lease expires. The
+// Dones balancing them must then be made through the same WaitGroup.
+func WithSession(s *session.Session) Option {
+	return func(o *options) {
+		o.session = s
+	}
+}
+
+// WaitGroup waits for work spread over processes. Each process uses its own
+// WaitGroup on the same prefix. The count is the sum of the counter keys
+// under the prefix: <prefix>/shared, used by plain WaitGroups, and one
+// <prefix>/<lease hex> per WaitGroup using a session.
+//
+// Without a session the count of a crashed worker is never done, and Wait
+// blocks until ctx is done or someone calls Done on its behalf.
+type WaitGroup struct {
+	cli    *clientv3.Client
+	prefix string
+	key    string
+	lease  clientv3.LeaseID
+}
+
+func New(cli *clientv3.Client, prefix string, opts ...Option) *WaitGroup {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	wg := &WaitGroup{cli: cli, prefix: prefix + "/", key: prefix + "/shared"}
+	if o.session != nil {
+		wg.lease = o.session.Lease()
+		wg.key = fmt.Sprintf("%s/%x", prefix, wg.lease)
+	}
+	return wg
+}
+
+// Add adds delta, which may be negative, to the counter of this WaitGroup. It
+// fails with ErrNegativeCounter, leaving the counter alone, if that would drop
+// below zero. The write is a txn guarded on the ModRevision that was read,
+// retried until no concurrent update gets in between.
+func (wg *WaitGroup) Add(ctx context.Context, delta int) error {
+	for {
+		getResp, err := wg.cli.Get(ctx, wg.key)
+		if err != nil {
+			return err
+		}
+		var val, modRev int64
+		if len(getResp.Kvs) > 0 {
+			if val, err = strconv.ParseInt(string(getResp.Kvs[0].Value), 10, 64); err != nil {
+				return err
+			}
+			modRev = getResp.Kvs[0].ModRevision
+		}
+		val += int64(delta)
+		if val < 0 {
+			return ErrNegativeCounter
+		}
+
+		var putOpts []clientv3.OpOption
+		if wg.lease != clientv3.NoLease {
+			putOpts = append(putOpts, clientv3.WithLease(wg.lease))
+		}
+		txnResp, err := wg.cli.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(wg.key), "=", modRev)).
+			Then(clientv3.OpPut(wg.key, strconv.FormatInt(val, 10), putOpts...)).
+			Commit()
+		if err != nil {
+			return err
+		}
+		if txnResp.Succeeded {
+			return nil
+		}
+	}
+}
+
+// Done decrements the counter of this WaitGroup by one.
+func (wg *WaitGroup) Done(ctx context.Context) error {
+	return wg.Add(ctx, -1)
+}
+
+// Wait blocks until the count across all WaitGroups on the prefix is zero or
+// ctx is done.
+func (wg *WaitGroup) Wait(ctx context.Context) error {
+	for {
+		getResp, err := wg.cli.Get(ctx, wg.prefix, clientv3.WithPrefix())
+		if err != nil {
+			return err
+		}
+		var count int64
+		for _, kv := range getResp.Kvs {
+			val, err := strconv.ParseInt(string(kv.Value), 10, 64)
+			if err != nil {
+				return err
+			}
+			count += val
+		}
+		if count == 0 {
+			return nil
+		}
+		if err := wg.waitChange(ctx, getResp.Header.Revision); err != nil {
+			return err
+		}
+	}
+}
+
+// waitChange blocks until some counter changes or goes away after rev.
+func (wg *WaitGroup) waitChange(ctx context.Context, rev int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var watchResp clientv3.WatchResponse
+	for watchResp = range wg.cli.Watch(ctx, wg.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1)) {
+		if len(watchResp.Events) > 0 {
+			return nil
+		}
+	}
+	if err := watchResp.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,93 @@
+package dwg_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	"github.com/gojustforfun/learn-by-test/etcd/sync/dwg"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type DWGTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestDWGTestSuite(t *testing.T) {
+	suite.Run(t, new(DWGTestSuite))
+}
+
+func (s *DWGTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *DWGTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *DWGTestSuite) TestWait() {
+	prefix := "/test/dwg/wait"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	coordinator := dwg.New(s.cli, prefix)
+	s.NoError(coordinator.Wait(context.Background()))
+	s.NoError(coordinator.Add(context.Background(), 3))
+
+	var done int32
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			worker := dwg.New(s.cli, prefix)
+			time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
+			atomic.AddInt32(&done, 1)
+			s.NoError(worker.Done(context.Background()))
+		}(i)
+	}
+
+	s.NoError(coordinator.Wait(context.Background()))
+	s.Equal(int32(3), atomic.LoadInt32(&done))
+	s.Equal(dwg.ErrNegativeCounter, coordinator.Done(context.Background()))
+}
+
+func (s *DWGTestSuite) TestWaitCancelled() {
+	prefix := "/test/dwg/cancelled"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	wg := dwg.New(s.cli, prefix)
+	s.NoError(wg.Add(context.Background(), 1))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(wg.Wait(ctx), context.DeadlineExceeded)
+}
+
+func (s *DWGTestSuite) TestCrashedWorker() {
+	prefix := "/test/dwg/crashed"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	crashed, err := clientv3.New(clientv3.Config{
+		Endpoints:   s.cli.Endpoints(),
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+	sess, err := session.New(crashed, session.WithTTL(2))
+	s.NoError(err)
+
+	worker := dwg.New(s.cli, prefix)
+	s.NoError(worker.Add(context.Background(), 1))
+	s.NoError(dwg.New(crashed, prefix, dwg.WithSession(sess)).Add(context.Background(), 2))
+	s.NoError(worker.Done(context.Background()))
+
+	// the crashed worker never calls Done, its lease expires instead
+	crashed.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s.NoError(worker.Wait(ctx))
+}
@@ -0,0 +1,69 @@
+package gate
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const open = "open"
+
+// Gate holds back any number of waiters until it is opened, then lets them
+// all through at once. Each process uses its own Gate on the same key.
+//
+// The gate is open while the key holds "open". Waiters watch the key, so one
+// that was waiting when Open was called is released even if Close follows
+// right after.
+type Gate struct {
+	cli *clientv3.Client
+	key string
+}
+
+func New(cli *clientv3.Client, key string) *Gate {
+	return &Gate{cli: cli, key: key}
+}
+
+// Wait blocks until the gate is open or ctx is done.
+func (g *Gate) Wait(ctx context.Context) error {
+	resp, err := g.cli.Get(ctx, g.key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) > 0 && string(resp.Kvs[0].Value) == open {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var watchResp clientv3.WatchResponse
+	for watchResp = range g.cli.Watch(ctx, g.key, clientv3.WithRev(resp.Header.Revision+1)) {
+		for _, ev := range watchResp.Events {
+			if ev.Type == mvccpb.PUT && string(ev.Kv.Value) == open {
+				return nil
+			}
+		}
+	}
+	if err := watchResp.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return wait.ErrWatchClosed
+}
+
+// Open releases every waiter, and lets later ones through until Close.
+func (g *Gate) Open(ctx context.Context) error {
+	_, err := g.cli.Put(ctx, g.key, open)
+	return err
+}
+
+// Close re-arms the gate, so that the next waiters block until it is opened
+// again.
+func (g *Gate) Close(ctx context.Context) error {
+	_, err := g.cli.Delete(ctx, g.key)
+	return err
+}
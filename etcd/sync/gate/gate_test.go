@@ -0,0 +1,74 @@
+package gate_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/sync/gate"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type GateTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestGateTestSuite(t *testing.T) {
+	suite.Run(t, new(GateTestSuite))
+}
+
+func (s *GateTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *GateTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *GateTestSuite) TestOpen() {
+	key := "/test/gate/open"
+	defer s.cli.Delete(context.Background(), key)
+
+	var released int32
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.NoError(gate.New(s.cli, key).Wait(context.Background()))
+			atomic.AddInt32(&released, 1)
+		}()
+	}
+	time.Sleep(300 * time.Millisecond)
+	s.Zero(atomic.LoadInt32(&released))
+
+	// waiters are released even when the gate closes again right away
+	g := gate.New(s.cli, key)
+	s.NoError(g.Open(context.Background()))
+	s.NoError(g.Close(context.Background()))
+	s.Eventually(func() bool { return atomic.LoadInt32(&released) == 5 }, time.Second, 10*time.Millisecond)
+	wg.Wait()
+}
+
+func (s *GateTestSuite) TestClose() {
+	key := "/test/gate/close"
+	defer s.cli.Delete(context.Background(), key)
+
+	g := gate.New(s.cli, key)
+	s.NoError(g.Open(context.Background()))
+	s.NoError(gate.New(s.cli, key).Wait(context.Background()))
+
+	s.NoError(g.Close(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(gate.New(s.cli, key).Wait(ctx), context.DeadlineExceeded)
+}
@@ -0,0 +1,119 @@
+package sema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gojustforfun/learn-by-test/etcd/internal/wait"
+	"github.com/gojustforfun/learn-by-test/etcd/session"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultTTL = 10
+
+var ErrSessionExpired = errors.New("sema: lease expired while waiting")
+
+type options struct {
+	ttl int
+}
+
+type Option func(*options)
+
+// WithTTL sets the TTL in seconds of the lease backing each acquisition,
+// i.e. how long the slot of a crashed holder stays taken.
+func WithTTL(ttl int) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// Semaphore limits concurrency across processes to n holders. Every
+// acquisition queues a lease-backed key under the prefix; the n keys with the
+// lowest CreateRevision hold the slots, so acquisition is FIFO-fair.
+type Semaphore struct {
+	cli    *clientv3.Client
+	prefix string
+	n      int64
+	opts   options
+
+	mu      sync.Mutex
+	session *session.Session
+	key     string
+}
+
+func New(cli *clientv3.Client, prefix string, n int, opts ...Option) *Semaphore {
+	o := options{ttl: defaultTTL}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Semaphore{cli: cli, prefix: prefix + "/", n: int64(n), opts: o}
+}
+
+// Acquire blocks until a slot is free or ctx is done. A cancelled Acquire
+// gives up its place in the queue.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	sess, err := session.New(s.cli, session.WithTTL(s.opts.ttl), session.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%x", s.prefix, sess.Lease())
+	putResp, err := s.cli.Put(ctx, key, "", clientv3.WithLease(sess.Lease()))
+	if err != nil {
+		sess.Close()
+		return err
+	}
+
+	if err := s.waitSlot(ctx, key, putResp.Header.Revision); err != nil {
+		sess.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.session, s.key = sess, key
+	s.mu.Unlock()
+	return nil
+}
+
+// Release frees the slot; it is a no-op if the semaphore is not held.
+func (s *Semaphore) Release(ctx context.Context) error {
+	s.mu.Lock()
+	sess := s.session
+	s.session, s.key = nil, ""
+	s.mu.Unlock()
+
+	if sess == nil {
+		return nil
+	}
+	return sess.Close()
+}
+
+// waitSlot blocks until at most n keys, including key, were created at or
+// before rev.
+func (s *Semaphore) waitSlot(ctx context.Context, key string, rev int64) error {
+	for {
+		// the server counts before applying revision filters, so count the
+		// returned keys rather than asking for a count only
+		getResp, err := s.cli.Get(ctx, s.prefix, clientv3.WithPrefix(), clientv3.WithMaxCreateRev(rev), clientv3.WithKeysOnly())
+		if err != nil {
+			return err
+		}
+		if int64(len(getResp.Kvs)) <= s.n {
+			// make sure our own key did not expire meanwhile
+			ownResp, err := s.cli.Get(ctx, key, clientv3.WithCountOnly())
+			if err != nil {
+				return err
+			}
+			if ownResp.Count == 0 {
+				return ErrSessionExpired
+			}
+			return nil
+		}
+
+		// wait for any holder ahead of us to go away
+		if err := wait.AnyDelete(ctx, s.cli, s.prefix, getResp.Header.Revision); err != nil {
+			return err
+		}
+	}
+}
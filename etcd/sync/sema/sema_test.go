@@ -0,0 +1,84 @@
+package sema_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/sync/sema"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type SemaTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestSemaTestSuite(t *testing.T) {
+	suite.Run(t, new(SemaTestSuite))
+}
+
+func (s *SemaTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *SemaTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *SemaTestSuite) TestCapacity() {
+	prefix := "/test/sema/capacity"
+	var holding, maxHolding int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem := sema.New(s.cli, prefix, 3)
+			s.NoError(sem.Acquire(context.Background()))
+			n := atomic.AddInt32(&holding, 1)
+			for {
+				max := atomic.LoadInt32(&maxHolding)
+				if n <= max || atomic.CompareAndSwapInt32(&maxHolding, max, n) {
+					break
+				}
+			}
+			time.Sleep(200 * time.Millisecond)
+			atomic.AddInt32(&holding, -1)
+			s.NoError(sem.Release(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(int32(3), maxHolding)
+	getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(getRes.Count)
+}
+
+func (s *SemaTestSuite) TestAcquireCancelled() {
+	prefix := "/test/sema/cancel"
+	holder := sema.New(s.cli, prefix, 1)
+	s.NoError(holder.Acquire(context.Background()))
+	defer holder.Release(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	s.ErrorIs(sema.New(s.cli, prefix, 1).Acquire(ctx), context.DeadlineExceeded)
+
+	getRes, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(1), getRes.Count)
+}
+
+func (s *SemaTestSuite) TestReleaseNotHeld() {
+	s.NoError(sema.New(s.cli, "/test/sema/release", 1).Release(context.Background()))
+}
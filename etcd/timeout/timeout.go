@@ -0,0 +1,132 @@
+package timeout
+
+import (
+	"context"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type options struct {
+	read  time.Duration
+	write time.Duration
+	txn   time.Duration
+}
+
+type Option func(*options)
+
+// WithReadTimeout sets the timeout of Get and of Do with a get, 5s by
+// default.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.read = d
+	}
+}
+
+// WithWriteTimeout sets the timeout of Put, Delete, Compact and of Do with a
+// put or delete, 5s by default.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.write = d
+	}
+}
+
+// WithTxnTimeout sets the timeout of a txn, from Txn to Commit, and of Do
+// with a txn, 10s by default.
+func WithTxnTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.txn = d
+	}
+}
+
+// Client decorates a clientv3.KV, bounding every call whose context has no
+// deadline by a default timeout, so nothing blocks forever against a dead
+// cluster. A deadline the caller set is always left as it is, longer or not.
+type Client struct {
+	clientv3.KV
+	opts options
+}
+
+func New(kv clientv3.KV, opts ...Option) *Client {
+	o := options{read: 5 * time.Second, write: 5 * time.Second, txn: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Client{KV: kv, opts: o}
+}
+
+// bound returns ctx with timeout d, unless ctx already has a deadline.
+func bound(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	ctx, cancel := bound(ctx, c.opts.write)
+	defer cancel()
+	return c.KV.Put(ctx, key, val, opts...)
+}
+
+func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, cancel := bound(ctx, c.opts.read)
+	defer cancel()
+	return c.KV.Get(ctx, key, opts...)
+}
+
+func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	ctx, cancel := bound(ctx, c.opts.write)
+	defer cancel()
+	return c.KV.Delete(ctx, key, opts...)
+}
+
+func (c *Client) Compact(ctx context.Context, rev int64, opts ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	ctx, cancel := bound(ctx, c.opts.write)
+	defer cancel()
+	return c.KV.Compact(ctx, rev, opts...)
+}
+
+func (c *Client) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	d := c.opts.write
+	switch {
+	case op.IsGet():
+		d = c.opts.read
+	case op.IsTxn():
+		d = c.opts.txn
+	}
+	ctx, cancel := bound(ctx, d)
+	defer cancel()
+	return c.KV.Do(ctx, op)
+}
+
+// Txn starts the txn timeout; it is released by Commit.
+func (c *Client) Txn(ctx context.Context) clientv3.Txn {
+	ctx, cancel := bound(ctx, c.opts.txn)
+	return &txn{Txn: c.KV.Txn(ctx), cancel: cancel}
+}
+
+type txn struct {
+	clientv3.Txn
+	cancel context.CancelFunc
+}
+
+func (t *txn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *txn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *txn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *txn) Commit() (*clientv3.TxnResponse, error) {
+	defer t.cancel()
+	return t.Txn.Commit()
+}
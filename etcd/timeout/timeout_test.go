@@ -0,0 +1,99 @@
+package timeout_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/timeout"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// deadlineKV records the deadline of the context of the last call.
+type deadlineKV struct {
+	*fake.KV
+	deadline time.Time
+	ok       bool
+}
+
+func (d *deadlineKV) record(ctx context.Context) {
+	d.deadline, d.ok = ctx.Deadline()
+}
+
+func (d *deadlineKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	d.record(ctx)
+	return d.KV.Get(ctx, key, opts...)
+}
+
+func (d *deadlineKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	d.record(ctx)
+	return d.KV.Put(ctx, key, val, opts...)
+}
+
+func (d *deadlineKV) Txn(ctx context.Context) clientv3.Txn {
+	d.record(ctx)
+	return d.KV.Txn(ctx)
+}
+
+type TimeoutTestSuite struct {
+	suite.Suite
+}
+
+func TestTimeoutTestSuite(t *testing.T) {
+	suite.Run(t, new(TimeoutTestSuite))
+}
+
+func (s *TimeoutTestSuite) TestInjectsDeadline() {
+	kv := &deadlineKV{KV: fake.NewKV()}
+	c := timeout.New(kv, timeout.WithReadTimeout(time.Second), timeout.WithWriteTimeout(2*time.Second), timeout.WithTxnTimeout(3*time.Second))
+
+	for _, tc := range []struct {
+		call func()
+		want time.Duration
+	}{
+		{func() { c.Get(context.Background(), "/k") }, time.Second},
+		{func() { c.Put(context.Background(), "/k", "v") }, 2 * time.Second},
+		{func() { c.Txn(context.Background()).Then(clientv3.OpPut("/k", "v")).Commit() }, 3 * time.Second},
+	} {
+		start := time.Now()
+		tc.call()
+		s.True(kv.ok)
+		s.WithinDuration(start.Add(tc.want), kv.deadline, 100*time.Millisecond)
+	}
+}
+
+func (s *TimeoutTestSuite) TestKeepsCallerDeadline() {
+	kv := &deadlineKV{KV: fake.NewKV()}
+	c := timeout.New(kv, timeout.WithReadTimeout(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	_, err := c.Get(ctx, "/k")
+	s.NoError(err)
+	s.Equal(want, kv.deadline)
+
+	// a longer deadline is not cut short either
+	c = timeout.New(kv, timeout.WithReadTimeout(time.Millisecond))
+	_, err = c.Get(ctx, "/k")
+	s.NoError(err)
+	s.Equal(want, kv.deadline)
+}
+
+func (s *TimeoutTestSuite) TestTimesOut() {
+	c := timeout.New(&blockingKV{}, timeout.WithReadTimeout(20*time.Millisecond))
+	_, err := c.Get(context.Background(), "/k")
+	s.ErrorIs(err, context.DeadlineExceeded)
+}
+
+// blockingKV answers only once the context is done, like a dead cluster.
+type blockingKV struct {
+	clientv3.KV
+}
+
+func (blockingKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
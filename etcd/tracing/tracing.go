@@ -0,0 +1,199 @@
+package tracing
+
+import (
+	"context"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/gojustforfun/learn-by-test/etcd/tracing"
+
+var (
+	keyAttr       = attribute.Key("etcd.key")
+	prefixAttr    = attribute.Key("etcd.prefix")
+	revisionAttr  = attribute.Key("etcd.revision")
+	eventsAttr    = attribute.Key("etcd.events")
+	succeededAttr = attribute.Key("etcd.succeeded")
+)
+
+type options struct {
+	tracer trace.Tracer
+}
+
+type Option func(*options)
+
+// WithTracer sets the tracer spans are started with instead of the one of
+// the global tracer provider.
+func WithTracer(tracer trace.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// Client decorates a clientv3.KV, tracing every Put, Get, Delete, Do and txn
+// commit in a span that is a child of the span in the call's context.
+type Client struct {
+	clientv3.KV
+	tracer trace.Tracer
+}
+
+func New(kv clientv3.KV, opts ...Option) *Client {
+	o := options{tracer: otel.Tracer(instrumentationName)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Client{KV: kv, tracer: o.tracer}
+}
+
+func (c *Client) start(ctx context.Context, name, key string, opts []clientv3.OpOption) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		keyAttr.String(key),
+		prefixAttr.Bool(clientv3.IsOptsWithPrefix(opts)),
+	))
+}
+
+// end records the revision of a response with header, or err, and ends span.
+func end(span trace.Span, header *pb.ResponseHeader, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if header != nil {
+		span.SetAttributes(revisionAttr.Int64(header.Revision))
+	}
+	span.End()
+}
+
+func (c *Client) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	ctx, span := c.start(ctx, "etcd.put", key, opts)
+	resp, err := c.KV.Put(ctx, key, val, opts...)
+	var header *pb.ResponseHeader
+	if resp != nil {
+		header = resp.Header
+	}
+	end(span, header, err)
+	return resp, err
+}
+
+func (c *Client) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, span := c.start(ctx, "etcd.get", key, opts)
+	resp, err := c.KV.Get(ctx, key, opts...)
+	var header *pb.ResponseHeader
+	if resp != nil {
+		header = resp.Header
+	}
+	end(span, header, err)
+	return resp, err
+}
+
+func (c *Client) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	ctx, span := c.start(ctx, "etcd.delete", key, opts)
+	resp, err := c.KV.Delete(ctx, key, opts...)
+	var header *pb.ResponseHeader
+	if resp != nil {
+		header = resp.Header
+	}
+	end(span, header, err)
+	return resp, err
+}
+
+func (c *Client) Do(ctx context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	ctx, span := c.tracer.Start(ctx, "etcd.do", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(keyAttr.String(string(op.KeyBytes()))))
+	resp, err := c.KV.Do(ctx, op)
+	var header *pb.ResponseHeader
+	switch {
+	case resp.Put() != nil:
+		header = resp.Put().Header
+	case resp.Get() != nil:
+		header = resp.Get().Header
+	case resp.Del() != nil:
+		header = resp.Del().Header
+	case resp.Txn() != nil:
+		header = resp.Txn().Header
+	}
+	end(span, header, err)
+	return resp, err
+}
+
+// Txn starts a span that ends when the txn is committed, so a txn must be
+// committed for its span to be exported.
+func (c *Client) Txn(ctx context.Context) clientv3.Txn {
+	ctx, span := c.tracer.Start(ctx, "etcd.txn", trace.WithSpanKind(trace.SpanKindClient))
+	return &txn{Txn: c.KV.Txn(ctx), span: span}
+}
+
+type txn struct {
+	clientv3.Txn
+	span trace.Span
+}
+
+func (t *txn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.Txn = t.Txn.If(cs...)
+	return t
+}
+
+func (t *txn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Then(ops...)
+	return t
+}
+
+func (t *txn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.Txn = t.Txn.Else(ops...)
+	return t
+}
+
+func (t *txn) Commit() (*clientv3.TxnResponse, error) {
+	resp, err := t.Txn.Commit()
+	var header *pb.ResponseHeader
+	if resp != nil {
+		header = resp.Header
+		t.span.SetAttributes(succeededAttr.Bool(resp.Succeeded))
+	}
+	end(t.span, header, err)
+	return resp, err
+}
+
+// Watcher decorates w, tracing every watch in a span that lasts until its
+// channel is closed.
+func (c *Client) Watcher(w clientv3.Watcher) clientv3.Watcher {
+	return &watcher{Watcher: w, c: c}
+}
+
+type watcher struct {
+	clientv3.Watcher
+	c *Client
+}
+
+func (w *watcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	ctx, span := w.c.start(ctx, "etcd.watch", key, opts)
+	in := w.Watcher.Watch(ctx, key, opts...)
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+		var events int
+		var err error
+		var header *pb.ResponseHeader
+		defer func() {
+			span.SetAttributes(eventsAttr.Int(events))
+			end(span, header, err)
+		}()
+
+		for watchResp := range in {
+			events += len(watchResp.Events)
+			header = &watchResp.Header
+			if watchResp.Err() != nil {
+				err = watchResp.Err()
+			}
+			select {
+			case out <- watchResp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
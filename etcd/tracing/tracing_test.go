@@ -0,0 +1,123 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/tracing"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errBroken = errors.New("broken")
+
+// brokenKV fails every Delete.
+type brokenKV struct {
+	clientv3.KV
+}
+
+func (kv *brokenKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	return nil, errBroken
+}
+
+type TracingTestSuite struct {
+	suite.Suite
+	exporter *tracetest.InMemoryExporter
+	tracer   trace.Tracer
+	cli      *clientv3.Client
+	c        *tracing.Client
+}
+
+func TestTracingTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (s *TracingTestSuite) SetupTest() {
+	s.exporter = tracetest.NewInMemoryExporter()
+	s.tracer = sdktrace.NewTracerProvider(sdktrace.WithSyncer(s.exporter)).Tracer("test")
+	s.cli = fake.NewClient(fake.NewClock())
+	s.c = tracing.New(&brokenKV{KV: s.cli.KV}, tracing.WithTracer(s.tracer))
+}
+
+func (s *TracingTestSuite) TearDownTest() {
+	s.cli.Close()
+}
+
+func attrs(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	m := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+func (s *TracingTestSuite) TestOps() {
+	ctx, parent := s.tracer.Start(context.Background(), "parent")
+	putResp, err := s.c.Put(ctx, "/t/a", "1")
+	s.NoError(err)
+	getResp, err := s.c.Get(ctx, "/t/", clientv3.WithPrefix())
+	s.NoError(err)
+	txnResp, err := s.c.Txn(ctx).If(clientv3.Compare(clientv3.Version("/t/a"), "=", 1)).Then(clientv3.OpPut("/t/a", "2")).Commit()
+	s.NoError(err)
+	_, err = s.c.Delete(ctx, "/t/a")
+	s.ErrorIs(err, errBroken)
+	parent.End()
+
+	spans := s.exporter.GetSpans()
+	s.Len(spans, 5)
+	names := make([]string, 0, len(spans))
+	for _, span := range spans[:4] {
+		names = append(names, span.Name)
+		s.Equal(parent.SpanContext().SpanID(), span.Parent.SpanID())
+		s.Equal(trace.SpanKindClient, span.SpanKind)
+	}
+	s.Equal([]string{"etcd.put", "etcd.get", "etcd.txn", "etcd.delete"}, names)
+
+	put := attrs(spans[0])
+	s.Equal("/t/a", put["etcd.key"].AsString())
+	s.False(put["etcd.prefix"].AsBool())
+	s.Equal(putResp.Header.Revision, put["etcd.revision"].AsInt64())
+
+	get := attrs(spans[1])
+	s.True(get["etcd.prefix"].AsBool())
+	s.Equal(getResp.Header.Revision, get["etcd.revision"].AsInt64())
+
+	txn := attrs(spans[2])
+	s.True(txn["etcd.succeeded"].AsBool())
+	s.Equal(txnResp.Header.Revision, txn["etcd.revision"].AsInt64())
+
+	del := spans[3]
+	s.Equal(codes.Error, del.Status.Code)
+	s.Len(del.Events, 1)
+	s.Equal("exception", del.Events[0].Name)
+}
+
+func (s *TracingTestSuite) TestWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchChan := s.c.Watcher(s.cli.Watcher).Watch(ctx, "/t/", clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), "/t/a", "1")
+	s.NoError(err)
+	select {
+	case <-watchChan:
+	case <-time.After(time.Second):
+		s.FailNow("no event")
+	}
+	s.Empty(s.exporter.GetSpans())
+
+	// the span ends with the watch
+	cancel()
+	for range watchChan {
+	}
+	s.Eventually(func() bool { return len(s.exporter.GetSpans()) == 1 }, time.Second, 10*time.Millisecond)
+	span := s.exporter.GetSpans()[0]
+	s.Equal("etcd.watch", span.Name)
+	s.Equal(int64(1), attrs(span)["etcd.events"].AsInt64())
+}
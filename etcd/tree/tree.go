@@ -0,0 +1,116 @@
+package tree
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type options struct {
+	depth int
+}
+
+type Option func(*options)
+
+// WithDepth builds only n levels below the listed prefix. Nodes at the last
+// level keep IsLeaf false when keys were cut off below them. By default the
+// whole tree is built.
+func WithDepth(n int) Option {
+	return func(o *options) {
+		o.depth = n
+	}
+}
+
+// Node is a key, or a directory of keys, in the tree of slash-delimited keys
+// under a prefix. A key can be both: /a and /a/b make /a a node with a value
+// and a child.
+type Node struct {
+	Name     string
+	Key      string
+	Value    []byte
+	HasValue bool
+	Children []*Node
+	// IsLeaf tells there are no keys below this one, even when WithDepth
+	// left Children empty.
+	IsLeaf bool
+}
+
+// List reads the key prefix and the keys below prefix/ at a single revision
+// and builds their tree, rooted at prefix.
+func List(ctx context.Context, cli etcdx.KV, prefix string, opts ...Option) (*Node, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	base := strings.TrimSuffix(prefix, "/")
+	resp, err := cli.Txn(ctx).Then(
+		clientv3.OpGet(base),
+		clientv3.OpGet(base+"/", clientv3.WithPrefix()),
+	).Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{Name: base[strings.LastIndex(base, "/")+1:], Key: base, IsLeaf: true}
+	if kvs := resp.Responses[0].GetResponseRange().Kvs; base != "" && len(kvs) > 0 {
+		root.Value, root.HasValue = kvs[0].Value, true
+	}
+	for _, kv := range resp.Responses[1].GetResponseRange().Kvs {
+		n := root
+		for i, name := range strings.Split(string(kv.Key)[len(base)+1:], "/") {
+			n.IsLeaf = false
+			if o.depth > 0 && i >= o.depth {
+				n = nil
+				break
+			}
+			n = n.child(name)
+		}
+		if n != nil {
+			n.Value, n.HasValue = kv.Value, true
+		}
+	}
+	root.sort()
+	return root, nil
+}
+
+func (n *Node) child(name string) *Node {
+	// keys come sorted, so a child being added to is usually the last one
+	if last := len(n.Children) - 1; last >= 0 && n.Children[last].Name == name {
+		return n.Children[last]
+	}
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	c := &Node{Name: name, Key: n.Key + "/" + name, IsLeaf: true}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// sort orders children by name: key order alone does not, as /a/b-c sorts
+// before /a/b/c.
+func (n *Node) sort() {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Name < n.Children[j].Name })
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// Walk calls fn for n and then every node below it, depth first and in name
+// order, stopping at the first error fn returns.
+func (n *Node) Walk(fn func(*Node) error) error {
+	if err := fn(n); err != nil {
+		return err
+	}
+	for _, c := range n.Children {
+		if err := c.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
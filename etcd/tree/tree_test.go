@@ -0,0 +1,117 @@
+package tree_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/tree"
+	"github.com/stretchr/testify/suite"
+)
+
+type TreeTestSuite struct {
+	suite.Suite
+	kv *fake.KV
+}
+
+func TestTreeTestSuite(t *testing.T) {
+	suite.Run(t, new(TreeTestSuite))
+}
+
+func (s *TreeTestSuite) SetupTest() {
+	s.kv = fake.NewKV()
+	for _, key := range []string{"/a", "/a/c", "/a/b", "/a/b-x/y", "/a/b/d/e", "/z"} {
+		_, err := s.kv.Put(context.Background(), key, "v"+key)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *TreeTestSuite) TestList() {
+	root, err := tree.List(context.Background(), s.kv, "/")
+	s.Require().NoError(err)
+	s.Equal("", root.Name)
+	s.False(root.HasValue)
+	s.Require().Len(root.Children, 2)
+
+	a := root.Children[0]
+	s.Equal("a", a.Name)
+	s.Equal("/a", a.Key)
+	s.Equal("v/a", string(a.Value))
+	s.True(a.HasValue)
+	s.False(a.IsLeaf)
+	s.Equal([]string{"b", "b-x", "c"}, names(a.Children))
+
+	b := a.Children[0]
+	s.Equal("v/a/b", string(b.Value))
+	s.False(b.IsLeaf)
+	s.Equal([]string{"d"}, names(b.Children))
+	d := b.Children[0]
+	s.False(d.HasValue, "a directory without a key of its own")
+	s.Equal("v/a/b/d/e", string(d.Children[0].Value))
+	s.True(d.Children[0].IsLeaf)
+
+	c := a.Children[2]
+	s.True(c.IsLeaf)
+	s.Empty(c.Children)
+	s.True(root.Children[1].IsLeaf)
+}
+
+func (s *TreeTestSuite) TestListSubtree() {
+	root, err := tree.List(context.Background(), s.kv, "/a/b")
+	s.Require().NoError(err)
+	s.Equal("b", root.Name)
+	s.Equal("v/a/b", string(root.Value))
+	s.Equal([]string{"d"}, names(root.Children), "/a/b-x is not below /a/b")
+
+	root, err = tree.List(context.Background(), s.kv, "/missing")
+	s.Require().NoError(err)
+	s.False(root.HasValue)
+	s.True(root.IsLeaf)
+	s.Empty(root.Children)
+}
+
+func (s *TreeTestSuite) TestDepth() {
+	root, err := tree.List(context.Background(), s.kv, "/a", tree.WithDepth(1))
+	s.Require().NoError(err)
+	s.Equal([]string{"b", "b-x", "c"}, names(root.Children))
+	for _, c := range root.Children {
+		s.Empty(c.Children)
+	}
+	s.False(root.Children[0].IsLeaf, "keys were cut off below /a/b")
+	s.False(root.Children[1].IsLeaf)
+	s.False(root.Children[1].HasValue)
+	s.True(root.Children[2].IsLeaf)
+}
+
+func (s *TreeTestSuite) TestWalk() {
+	root, err := tree.List(context.Background(), s.kv, "/")
+	s.Require().NoError(err)
+
+	var keys []string
+	s.NoError(root.Walk(func(n *tree.Node) error {
+		keys = append(keys, n.Key)
+		return nil
+	}))
+	s.Equal([]string{"", "/a", "/a/b", "/a/b/d", "/a/b/d/e", "/a/b-x", "/a/b-x/y", "/a/c", "/z"}, keys)
+
+	stop := errors.New("stop")
+	keys = nil
+	err = root.Walk(func(n *tree.Node) error {
+		keys = append(keys, n.Key)
+		if n.Key == "/a/b" {
+			return stop
+		}
+		return nil
+	})
+	s.ErrorIs(err, stop)
+	s.Equal([]string{"", "/a", "/a/b"}, keys)
+}
+
+func names(nodes []*tree.Node) []string {
+	var out []string
+	for _, n := range nodes {
+		out = append(out, n.Name)
+	}
+	return out
+}
@@ -0,0 +1,199 @@
+package txn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Explain describes the txn without running it: its conditions, then the ops
+// of each branch, one per line, with nested txns indented.
+//
+//	if
+//	  create_revision("/lock") = 0
+//	then
+//	  put "/lock" = "me"
+//	else
+//	  get "/lock"
+func (b *Builder) Explain() string {
+	var sb strings.Builder
+	explain(&sb, "", b.cmps, b.thens, b.elses)
+	return sb.String()
+}
+
+// DryRun predicts whether the conditions hold, so whether Commit would run
+// the Then or the Else ops, by reading the compared keys in a read-only txn
+// and evaluating the conditions the way etcd does. It is racy, since the keys
+// may change before a Commit, and meant for diagnostics only.
+func (b *Builder) DryRun(ctx context.Context) (bool, error) {
+	gets := make([]clientv3.Op, len(b.cmps))
+	for i, cmp := range b.cmps {
+		gets[i] = clientv3.OpGet(string(cmp.Key), clientv3.WithRange(string(cmp.RangeEnd)))
+	}
+	resp, err := b.kv.Txn(ctx).Then(gets...).Commit()
+	if err != nil {
+		return false, err
+	}
+	for i, cmp := range b.cmps {
+		if !holds((*pb.Compare)(&cmp), resp.Responses[i].GetResponseRange().Kvs) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// holds evaluates c against the keys it covers: all of them must satisfy it,
+// and a missing key compares as a zero key, except by value.
+func holds(c *pb.Compare, kvs []*mvccpb.KeyValue) bool {
+	if len(kvs) == 0 {
+		if c.Target == pb.Compare_VALUE {
+			return false
+		}
+		kvs = []*mvccpb.KeyValue{{}}
+	}
+	for _, kv := range kvs {
+		var result int
+		switch c.Target {
+		case pb.Compare_VALUE:
+			result = bytes.Compare(kv.Value, c.GetValue())
+		case pb.Compare_VERSION:
+			result = compareInt64(kv.Version, c.GetVersion())
+		case pb.Compare_CREATE:
+			result = compareInt64(kv.CreateRevision, c.GetCreateRevision())
+		case pb.Compare_MOD:
+			result = compareInt64(kv.ModRevision, c.GetModRevision())
+		case pb.Compare_LEASE:
+			result = compareInt64(kv.Lease, c.GetLease())
+		}
+		switch c.Result {
+		case pb.Compare_EQUAL:
+			if result != 0 {
+				return false
+			}
+		case pb.Compare_NOT_EQUAL:
+			if result == 0 {
+				return false
+			}
+		case pb.Compare_GREATER:
+			if result <= 0 {
+				return false
+			}
+		case pb.Compare_LESS:
+			if result >= 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func explain(sb *strings.Builder, indent string, cmps []clientv3.Cmp, thens, elses []clientv3.Op) {
+	sb.WriteString(indent + "if\n")
+	if len(cmps) == 0 {
+		sb.WriteString(indent + "  (always)\n")
+	}
+	for _, cmp := range cmps {
+		sb.WriteString(indent + "  " + describeCmp((*pb.Compare)(&cmp)) + "\n")
+	}
+	for _, branch := range []struct {
+		name string
+		ops  []clientv3.Op
+	}{{"then", thens}, {"else", elses}} {
+		sb.WriteString(indent + branch.name + "\n")
+		if len(branch.ops) == 0 {
+			sb.WriteString(indent + "  (nothing)\n")
+		}
+		for _, op := range branch.ops {
+			if op.IsTxn() {
+				sb.WriteString(indent + "  txn\n")
+				cmps, thens, elses := op.Txn()
+				explain(sb, indent+"    ", cmps, thens, elses)
+				continue
+			}
+			sb.WriteString(indent + "  " + describeOp(op) + "\n")
+		}
+	}
+}
+
+var targetNames = map[pb.Compare_CompareTarget]string{
+	pb.Compare_VERSION: "version",
+	pb.Compare_CREATE:  "create_revision",
+	pb.Compare_MOD:     "mod_revision",
+	pb.Compare_VALUE:   "value",
+	pb.Compare_LEASE:   "lease",
+}
+
+var resultNames = map[pb.Compare_CompareResult]string{
+	pb.Compare_EQUAL:     "=",
+	pb.Compare_NOT_EQUAL: "!=",
+	pb.Compare_GREATER:   ">",
+	pb.Compare_LESS:      "<",
+}
+
+func describeCmp(c *pb.Compare) string {
+	var target string
+	switch c.Target {
+	case pb.Compare_VALUE:
+		target = fmt.Sprintf("%q", c.GetValue())
+	case pb.Compare_VERSION:
+		target = fmt.Sprint(c.GetVersion())
+	case pb.Compare_CREATE:
+		target = fmt.Sprint(c.GetCreateRevision())
+	case pb.Compare_MOD:
+		target = fmt.Sprint(c.GetModRevision())
+	case pb.Compare_LEASE:
+		target = fmt.Sprintf("%x", c.GetLease())
+	}
+	return fmt.Sprintf("%s(%s) %s %s", targetNames[c.Target], describeRange(c.Key, c.RangeEnd), resultNames[c.Result], target)
+}
+
+func describeOp(op clientv3.Op) string {
+	keys := describeRange(op.KeyBytes(), op.RangeBytes())
+	switch {
+	case op.IsPut():
+		return fmt.Sprintf("put %s = %q", keys, op.ValueBytes())
+	case op.IsDelete():
+		return "delete " + keys
+	}
+	desc := "get " + keys
+	if op.Rev() != 0 {
+		desc += fmt.Sprintf(" at %d", op.Rev())
+	}
+	if op.IsCountOnly() {
+		desc += " count only"
+	} else if op.IsKeysOnly() {
+		desc += " keys only"
+	}
+	return desc
+}
+
+// describeRange names a key, or the range [key, end) by the option that
+// would make it.
+func describeRange(key, end []byte) string {
+	switch {
+	case len(end) == 0:
+		return fmt.Sprintf("%q", key)
+	case bytes.Equal(key, []byte{0}) && bytes.Equal(end, []byte{0}):
+		return "all keys"
+	case bytes.Equal(end, []byte{0}):
+		return fmt.Sprintf("from %q", key)
+	case string(end) == clientv3.GetPrefixRangeEnd(string(key)):
+		return fmt.Sprintf("prefix %q", key)
+	}
+	return fmt.Sprintf("[%q, %q)", key, end)
+}
@@ -0,0 +1,93 @@
+package txn_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/txn"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *TxnTestSuite) TestExplain() {
+	b := txn.New(s.cli).
+		IfValueEquals("/a", "1").
+		IfMissing("/b").
+		If(clientv3.Compare(clientv3.Version("/c/"), ">", 2).WithPrefix()).
+		ThenPut("/b", "2").
+		ThenGet("/c/", clientv3.WithPrefix(), clientv3.WithCountOnly()).
+		ThenTxn(func(b *txn.Builder) {
+			b.IfModRevEquals("/d", 7).ThenDelete("/d")
+		}).
+		ElseGet("/a", clientv3.WithRev(5))
+
+	s.Equal(`if
+  value("/a") = "1"
+  create_revision("/b") = 0
+  version(prefix "/c/") > 2
+then
+  put "/b" = "2"
+  get prefix "/c/" count only
+  txn
+    if
+      mod_revision("/d") = 7
+    then
+      delete "/d"
+    else
+      (nothing)
+else
+  get "/a" at 5
+`, b.Explain())
+
+	s.Equal("if\n  (always)\nthen\n  delete from \"/x\"\nelse\n  (nothing)\n",
+		txn.New(s.cli).ThenDelete("/x", clientv3.WithFromKey()).Explain())
+}
+
+func (s *TxnTestSuite) TestDryRun() {
+	prefix := "/test/txn/dryrun/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.Require().NoError(err)
+
+	b := txn.New(s.cli).
+		IfValueEquals(prefix+"a", "1").
+		IfMissing(prefix+"b").
+		ThenPut(prefix+"b", "2")
+	ok, err := b.DryRun(context.Background())
+	s.NoError(err)
+	s.True(ok)
+	// nothing ran
+	getResp, err := s.cli.Get(context.Background(), prefix+"b")
+	s.NoError(err)
+	s.Empty(getResp.Kvs)
+
+	res, err := b.Commit(context.Background())
+	s.NoError(err)
+	s.True(res.Succeeded)
+	// b exists now
+	ok, err = b.DryRun(context.Background())
+	s.NoError(err)
+	s.False(ok)
+	res, err = b.Commit(context.Background())
+	s.NoError(err)
+	s.False(res.Succeeded)
+
+	s.Run("Ranged and value compares", func() {
+		for _, tc := range []struct {
+			cmp  clientv3.Cmp
+			want bool
+		}{
+			{clientv3.Compare(clientv3.Version(prefix), "=", 1).WithPrefix(), true},
+			{clientv3.Compare(clientv3.CreateRevision(prefix), ">", 0).WithPrefix(), true},
+			{clientv3.Compare(clientv3.Value(prefix), "!=", "1").WithPrefix(), false},
+			{clientv3.Compare(clientv3.Value(prefix+"missing"), "=", ""), false},
+			{clientv3.Compare(clientv3.Version(prefix+"missing"), "<", 1), true},
+		} {
+			b := txn.New(s.cli).If(tc.cmp)
+			ok, err := b.DryRun(context.Background())
+			s.NoError(err)
+			s.Equal(tc.want, ok, b.Explain())
+			res, err := b.Commit(context.Background())
+			s.NoError(err)
+			s.Equal(res.Succeeded, ok, b.Explain())
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package txn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrOutsidePrefix = errors.New("txn: key outside prefix")
+
+// PrefixBuilder is a Builder confined to the keys under one prefix. Keys
+// passed to its helpers are relative to the prefix, or absolute if they
+// start with a slash; raw conditions and ops passed to If, Then and Else
+// must already be absolute. A key or range reaching outside the prefix, or
+// a key with a ".." segment, makes Commit fail with ErrOutsidePrefix
+// without running anything.
+type PrefixBuilder struct {
+	b      *Builder
+	prefix string
+	end    string
+	err    error
+}
+
+// OnPrefix starts a txn confined to prefix, which gets a trailing slash if
+// it has none.
+func OnPrefix(kv etcdx.KV, prefix string) *PrefixBuilder {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &PrefixBuilder{b: New(kv), prefix: prefix, end: clientv3.GetPrefixRangeEnd(prefix)}
+}
+
+// resolve returns key as an absolute key, recording an error if it is not
+// under the prefix.
+func (p *PrefixBuilder) resolve(key string) string {
+	abs := key
+	if !strings.HasPrefix(key, "/") {
+		abs = p.prefix + key
+	}
+	for _, seg := range strings.Split(abs, "/") {
+		if seg == ".." {
+			p.fail(abs)
+			return abs
+		}
+	}
+	p.check([]byte(abs), nil)
+	return abs
+}
+
+// check records an error unless [key, end) lies within the prefix. An empty
+// end is the single key.
+func (p *PrefixBuilder) check(key, end []byte) {
+	if !strings.HasPrefix(string(key), p.prefix) {
+		p.fail(string(key))
+		return
+	}
+	// "\x00" reaches to the end of the keyspace
+	if len(end) > 0 && (string(end) == "\x00" || string(end) > p.end) {
+		p.fail(string(key) + " to " + string(end))
+	}
+}
+
+func (p *PrefixBuilder) checkOps(ops []clientv3.Op) {
+	for _, op := range ops {
+		if op.IsTxn() {
+			cmps, thens, elses := op.Txn()
+			for _, cmp := range cmps {
+				p.check(cmp.Key, cmp.RangeEnd)
+			}
+			p.checkOps(thens)
+			p.checkOps(elses)
+			continue
+		}
+		p.check(op.KeyBytes(), op.RangeBytes())
+	}
+}
+
+func (p *PrefixBuilder) fail(key string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w %s: %s", ErrOutsidePrefix, p.prefix, key)
+	}
+}
+
+// Clone returns a copy that can be extended without affecting p, as
+// Builder.Clone does.
+func (p *PrefixBuilder) Clone() *PrefixBuilder {
+	return &PrefixBuilder{b: p.b.Clone(), prefix: p.prefix, end: p.end, err: p.err}
+}
+
+// If adds raw conditions.
+func (p *PrefixBuilder) If(cmps ...clientv3.Cmp) *PrefixBuilder {
+	for _, cmp := range cmps {
+		p.check(cmp.Key, cmp.RangeEnd)
+	}
+	p.b.If(cmps...)
+	return p
+}
+
+func (p *PrefixBuilder) IfValueEquals(key, val string) *PrefixBuilder {
+	p.b.IfValueEquals(p.resolve(key), val)
+	return p
+}
+
+// IfCreateRevEquals with rev 0 holds if the key does not exist.
+func (p *PrefixBuilder) IfCreateRevEquals(key string, rev int64) *PrefixBuilder {
+	p.b.IfCreateRevEquals(p.resolve(key), rev)
+	return p
+}
+
+func (p *PrefixBuilder) IfModRevEquals(key string, rev int64) *PrefixBuilder {
+	p.b.IfModRevEquals(p.resolve(key), rev)
+	return p
+}
+
+func (p *PrefixBuilder) IfVersionEquals(key string, v int64) *PrefixBuilder {
+	p.b.IfVersionEquals(p.resolve(key), v)
+	return p
+}
+
+func (p *PrefixBuilder) IfVersionGreater(key string, v int64) *PrefixBuilder {
+	p.b.IfVersionGreater(p.resolve(key), v)
+	return p
+}
+
+// IfMissing holds if the key does not exist.
+func (p *PrefixBuilder) IfMissing(key string) *PrefixBuilder {
+	p.b.IfMissing(p.resolve(key))
+	return p
+}
+
+// Then adds raw ops to the branch run when the conditions hold.
+func (p *PrefixBuilder) Then(ops ...clientv3.Op) *PrefixBuilder {
+	p.checkOps(ops)
+	p.b.Then(ops...)
+	return p
+}
+
+func (p *PrefixBuilder) ThenPut(key, val string, opts ...clientv3.OpOption) *PrefixBuilder {
+	return p.Then(clientv3.OpPut(p.resolve(key), val, opts...))
+}
+
+func (p *PrefixBuilder) ThenGet(key string, opts ...clientv3.OpOption) *PrefixBuilder {
+	return p.Then(clientv3.OpGet(p.resolve(key), opts...))
+}
+
+func (p *PrefixBuilder) ThenDelete(key string, opts ...clientv3.OpOption) *PrefixBuilder {
+	return p.Then(clientv3.OpDelete(p.resolve(key), opts...))
+}
+
+// Else adds raw ops to the branch run when a condition fails.
+func (p *PrefixBuilder) Else(ops ...clientv3.Op) *PrefixBuilder {
+	p.checkOps(ops)
+	p.b.Else(ops...)
+	return p
+}
+
+func (p *PrefixBuilder) ElsePut(key, val string, opts ...clientv3.OpOption) *PrefixBuilder {
+	return p.Else(clientv3.OpPut(p.resolve(key), val, opts...))
+}
+
+func (p *PrefixBuilder) ElseGet(key string, opts ...clientv3.OpOption) *PrefixBuilder {
+	return p.Else(clientv3.OpGet(p.resolve(key), opts...))
+}
+
+func (p *PrefixBuilder) ElseDelete(key string, opts ...clientv3.OpOption) *PrefixBuilder {
+	return p.Else(clientv3.OpDelete(p.resolve(key), opts...))
+}
+
+// Commit runs the txn, or returns the first key that was outside the prefix.
+func (p *PrefixBuilder) Commit(ctx context.Context) (*Result, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.b.Commit(ctx)
+}
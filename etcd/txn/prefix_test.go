@@ -0,0 +1,50 @@
+package txn_test
+
+import (
+	"context"
+
+	"github.com/gojustforfun/learn-by-test/etcd/txn"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *TxnTestSuite) TestOnPrefixGroupedWrite() {
+	prefix := "/test/txn/prefix/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	res, err := txn.OnPrefix(s.cli, "/test/txn/prefix").
+		IfMissing("a").
+		ThenPut("a", "1").
+		ThenPut(prefix+"b", "2").
+		ThenGet("", clientv3.WithPrefix()).
+		Commit(context.Background())
+	s.Require().NoError(err)
+	s.True(res.Succeeded)
+	s.Len(res.Responses[2].Get().Kvs, 2)
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Len(getResp.Kvs, 2)
+	s.Equal(getResp.Kvs[0].ModRevision, getResp.Kvs[1].ModRevision)
+}
+
+func (s *TxnTestSuite) TestOnPrefixRejectsOutsideKeys() {
+	prefix := "/test/txn/prefix/reject/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	for name, p := range map[string]*txn.PrefixBuilder{
+		"absolute":  txn.OnPrefix(s.cli, prefix).ThenPut("ok", "1").ThenPut("/test/other", "1"),
+		"sibling":   txn.OnPrefix(s.cli, prefix).ThenPut("/test/txn/prefix/rejected", "1"),
+		"dotdot":    txn.OnPrefix(s.cli, prefix).ThenPut("../escape", "1"),
+		"condition": txn.OnPrefix(s.cli, prefix).IfMissing("/test/other").ThenPut("ok", "1"),
+		"raw":       txn.OnPrefix(s.cli, prefix).Else(clientv3.OpDelete("/test/", clientv3.WithPrefix())),
+		"fromkey":   txn.OnPrefix(s.cli, prefix).ThenGet("ok", clientv3.WithFromKey()),
+	} {
+		_, err := p.Commit(context.Background())
+		s.ErrorIs(err, txn.ErrOutsidePrefix, name)
+	}
+
+	// nothing ran, not even the valid ops
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Zero(getResp.Count)
+}
@@ -0,0 +1,198 @@
+package txn
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"github.com/gojustforfun/learn-by-test/etcd/txnresult"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Builder assembles a txn one condition or op at a time. All conditions must
+// hold for the Then ops to run, otherwise the Else ops run.
+//
+// Commit leaves the builder as it is, so a builder can serve as a template
+// committed any number of times, from several goroutines too. Treat it as
+// immutable once committed: to vary a template, Clone it and extend the clone.
+type Builder struct {
+	kv    etcdx.KV
+	cmps  []clientv3.Cmp
+	thens []clientv3.Op
+	elses []clientv3.Op
+}
+
+func New(kv etcdx.KV) *Builder {
+	return &Builder{kv: kv}
+}
+
+// Clone returns a copy that can be extended without affecting b, and the other
+// way round.
+func (b *Builder) Clone() *Builder {
+	return &Builder{kv: b.kv, cmps: slices.Clip(b.cmps), thens: slices.Clip(b.thens), elses: slices.Clip(b.elses)}
+}
+
+// If adds raw conditions.
+func (b *Builder) If(cmps ...clientv3.Cmp) *Builder {
+	b.cmps = append(b.cmps, cmps...)
+	return b
+}
+
+func (b *Builder) IfValueEquals(key, val string) *Builder {
+	return b.If(clientv3.Compare(clientv3.Value(key), "=", val))
+}
+
+// IfCreateRevEquals with rev 0 holds if the key does not exist.
+func (b *Builder) IfCreateRevEquals(key string, rev int64) *Builder {
+	return b.If(clientv3.Compare(clientv3.CreateRevision(key), "=", rev))
+}
+
+func (b *Builder) IfModRevEquals(key string, rev int64) *Builder {
+	return b.If(clientv3.Compare(clientv3.ModRevision(key), "=", rev))
+}
+
+func (b *Builder) IfVersionEquals(key string, v int64) *Builder {
+	return b.If(clientv3.Compare(clientv3.Version(key), "=", v))
+}
+
+func (b *Builder) IfVersionGreater(key string, v int64) *Builder {
+	return b.If(clientv3.Compare(clientv3.Version(key), ">", v))
+}
+
+// IfMissing holds if the key does not exist.
+func (b *Builder) IfMissing(key string) *Builder {
+	return b.IfCreateRevEquals(key, 0)
+}
+
+// Then adds raw ops to the branch run when the conditions hold.
+func (b *Builder) Then(ops ...clientv3.Op) *Builder {
+	b.thens = append(b.thens, ops...)
+	return b
+}
+
+func (b *Builder) ThenPut(key, val string, opts ...clientv3.OpOption) *Builder {
+	return b.Then(clientv3.OpPut(key, val, opts...))
+}
+
+func (b *Builder) ThenGet(key string, opts ...clientv3.OpOption) *Builder {
+	return b.Then(clientv3.OpGet(key, opts...))
+}
+
+func (b *Builder) ThenDelete(key string, opts ...clientv3.OpOption) *Builder {
+	return b.Then(clientv3.OpDelete(key, opts...))
+}
+
+// ThenTxn adds a nested txn, built by fn, to the branch run when the
+// conditions hold. Its result is read back with Result.Txn.
+func (b *Builder) ThenTxn(fn func(b *Builder)) *Builder {
+	return b.Then(nested(fn))
+}
+
+// Else adds raw ops to the branch run when a condition fails.
+func (b *Builder) Else(ops ...clientv3.Op) *Builder {
+	b.elses = append(b.elses, ops...)
+	return b
+}
+
+func (b *Builder) ElsePut(key, val string, opts ...clientv3.OpOption) *Builder {
+	return b.Else(clientv3.OpPut(key, val, opts...))
+}
+
+func (b *Builder) ElseGet(key string, opts ...clientv3.OpOption) *Builder {
+	return b.Else(clientv3.OpGet(key, opts...))
+}
+
+func (b *Builder) ElseDelete(key string, opts ...clientv3.OpOption) *Builder {
+	return b.Else(clientv3.OpDelete(key, opts...))
+}
+
+// ElseTxn adds a nested txn, built by fn, to the branch run when a condition
+// fails. Its result is read back with Result.Txn.
+func (b *Builder) ElseTxn(fn func(b *Builder)) *Builder {
+	return b.Else(nested(fn))
+}
+
+func nested(fn func(b *Builder)) clientv3.Op {
+	var sub Builder
+	fn(&sub)
+	return clientv3.OpTxn(sub.cmps, sub.thens, sub.elses)
+}
+
+// Result is a committed txn.
+type Result struct {
+	Succeeded bool
+	Revision  int64
+	// Responses holds the responses of the branch that ran, in the order its
+	// ops were added.
+	Responses []clientv3.OpResponse
+
+	// ops are the ops of the branch that ran, lining up with Responses
+	ops []clientv3.Op
+}
+
+// Commit runs the txn. Each call runs it anew, with the conditions and ops
+// added so far.
+func (b *Builder) Commit(ctx context.Context) (*Result, error) {
+	resp, err := b.kv.Txn(ctx).If(b.cmps...).Then(b.thens...).Else(b.elses...).Commit()
+	if err != nil {
+		return nil, err
+	}
+	return result(resp, resp.Header.Revision, b.thens, b.elses), nil
+}
+
+// Txn returns the result of the nested txn at index i of Responses, or nil if
+// the op there is not a txn.
+func (r *Result) Txn(i int) *Result {
+	if i < 0 || i >= len(r.Responses) || r.Responses[i].Txn() == nil {
+		return nil
+	}
+	_, thens, elses := r.ops[i].Txn()
+	return result(r.Responses[i].Txn(), r.Revision, thens, elses)
+}
+
+// GetByKey finds key among the keys read by the Gets of the branch that ran,
+// wherever they are in Responses: the Get of key itself, or a ranged Get
+// covering key, such as one of a prefix. It reports false if no such Get ran
+// or key was not found. Nested txns are not searched; see Txn.
+func (r *Result) GetByKey(key string) (*mvccpb.KeyValue, bool) {
+	for i, op := range r.ops {
+		if !op.IsGet() || !covers(op, key) {
+			continue
+		}
+		for _, kv := range r.Responses[i].Get().Kvs {
+			if string(kv.Key) == key {
+				return kv, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// covers reports whether op reads key.
+func covers(op clientv3.Op, key string) bool {
+	start, end := string(op.KeyBytes()), string(op.RangeBytes())
+	if end == "" {
+		return key == start
+	}
+	return key >= start && (end == "\x00" || key < end)
+}
+
+// result decodes a txn response given the ops of its branches; nested txns
+// share the revision of the txn they are part of.
+func result(resp *clientv3.TxnResponse, rev int64, thens, elses []clientv3.Op) *Result {
+	ops := elses
+	if resp.Succeeded {
+		ops = thens
+	}
+	return &Result{Succeeded: resp.Succeeded, Revision: rev, Responses: decode(resp), ops: ops}
+}
+
+func decode(resp *clientv3.TxnResponse) []clientv3.OpResponse {
+	resps := make([]clientv3.OpResponse, len(resp.Responses))
+	for i := range resps {
+		// i is always in range
+		resps[i], _ = txnresult.At(resp, i)
+	}
+	return resps
+}
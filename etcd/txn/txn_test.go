@@ -0,0 +1,241 @@
+package txn_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/txn"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type TxnTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestTxnTestSuite(t *testing.T) {
+	suite.Run(t, new(TxnTestSuite))
+}
+
+func (s *TxnTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *TxnTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+func (s *TxnTestSuite) TestSucceeded() {
+	prefix := "/test/txn/succeeded/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+
+	res, err := txn.New(s.cli).
+		IfValueEquals(prefix+"a", "1").
+		IfMissing(prefix+"b").
+		ThenPut(prefix+"b", "2").
+		ThenGet(prefix, clientv3.WithPrefix()).
+		ThenDelete(prefix + "a").
+		ElseGet(prefix + "a").
+		Commit(context.Background())
+	s.NoError(err)
+	s.True(res.Succeeded)
+	s.Len(res.Responses, 3)
+	s.NotNil(res.Responses[0].Put())
+	s.Len(res.Responses[1].Get().Kvs, 2)
+	s.Equal(int64(1), res.Responses[2].Del().Deleted)
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+	s.Equal(res.Revision, getResp.Header.Revision)
+	s.Len(getResp.Kvs, 1)
+	s.Equal("2", string(getResp.Kvs[0].Value))
+}
+
+func (s *TxnTestSuite) TestFailed() {
+	prefix := "/test/txn/failed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+
+	// the first condition holds, the second one does not
+	res, err := txn.New(s.cli).
+		IfVersionEquals(prefix+"a", 1).
+		IfVersionGreater(prefix+"a", 1).
+		ThenDelete(prefix+"a").
+		ElseGet(prefix+"a").
+		ElsePut(prefix+"b", "1").
+		Commit(context.Background())
+	s.NoError(err)
+	s.False(res.Succeeded)
+	s.Len(res.Responses, 2)
+	s.Equal("1", string(res.Responses[0].Get().Kvs[0].Value))
+	s.NotNil(res.Responses[1].Put())
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(2), getResp.Count)
+}
+
+func (s *TxnTestSuite) TestNested() {
+	prefix := "/test/txn/nested/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	_, err := s.cli.Put(context.Background(), prefix+"role", "primary")
+	s.NoError(err)
+
+	promote := func() (*txn.Result, error) {
+		return txn.New(s.cli).
+			IfValueEquals(prefix+"role", "primary").
+			ThenTxn(func(b *txn.Builder) {
+				b.IfMissing(prefix+"epoch").
+					ThenPut(prefix+"epoch", "1").
+					ElseGet(prefix + "epoch")
+			}).
+			ElseTxn(func(b *txn.Builder) {
+				b.IfMissing(prefix+"standby").
+					ThenPut(prefix+"standby", "1")
+			}).
+			Commit(context.Background())
+	}
+
+	// outer holds, inner holds
+	res, err := promote()
+	s.NoError(err)
+	s.True(res.Succeeded)
+	s.Len(res.Responses, 1)
+	s.Nil(res.Txn(1))
+	inner := res.Txn(0)
+	s.Require().NotNil(inner)
+	s.True(inner.Succeeded)
+	s.Equal(res.Revision, inner.Revision)
+	s.NotNil(inner.Responses[0].Put())
+
+	// outer holds, inner fails
+	res, err = promote()
+	s.NoError(err)
+	s.True(res.Succeeded)
+	inner = res.Txn(0)
+	s.False(inner.Succeeded)
+	s.Equal("1", string(inner.Responses[0].Get().Kvs[0].Value))
+
+	// outer fails, the else branch runs its own txn
+	_, err = s.cli.Put(context.Background(), prefix+"role", "replica")
+	s.NoError(err)
+	res, err = promote()
+	s.NoError(err)
+	s.False(res.Succeeded)
+	inner = res.Txn(0)
+	s.True(inner.Succeeded)
+	s.NotNil(inner.Responses[0].Put())
+
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	s.Equal(int64(3), getResp.Count)
+}
+
+func (s *TxnTestSuite) TestReuse() {
+	prefix := "/test/txn/reuse/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	// a lock attempt built once and retried
+	attempt := txn.New(s.cli).IfMissing(prefix+"lock").ThenPut(prefix+"lock", "me").ElseGet(prefix + "lock")
+	first, err := attempt.Commit(context.Background())
+	s.Require().NoError(err)
+	s.True(first.Succeeded)
+
+	second, err := attempt.Commit(context.Background())
+	s.Require().NoError(err)
+	s.False(second.Succeeded)
+	s.Equal("me", string(second.Responses[0].Get().Kvs[0].Value))
+	s.Greater(second.Revision, int64(0))
+	s.True(first.Succeeded, "earlier results are left alone")
+
+	// clones grow apart from their template
+	clone := attempt.Clone().ElsePut(prefix+"waiting", "1")
+	third, err := attempt.Commit(context.Background())
+	s.Require().NoError(err)
+	s.Len(third.Responses, 1)
+	res, err := clone.Commit(context.Background())
+	s.Require().NoError(err)
+	s.Len(res.Responses, 2)
+	attempt.ElsePut(prefix+"other", "1")
+	res, err = clone.Commit(context.Background())
+	s.Require().NoError(err)
+	s.Len(res.Responses, 2)
+	s.NotNil(res.Responses[1].Put())
+
+	// concurrent commits of one template each run it once
+	_, err = s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	s.Require().NoError(err)
+	template := txn.New(s.cli).IfMissing(prefix+"lock").ThenPut(prefix+"lock", "me")
+	results := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			res, err := template.Commit(context.Background())
+			s.NoError(err)
+			results <- err == nil && res.Succeeded
+		}()
+	}
+	var won int
+	for i := 0; i < 10; i++ {
+		if <-results {
+			won++
+		}
+	}
+	s.Equal(1, won)
+}
+
+func (s *TxnTestSuite) TestGetByKey() {
+	prefix := "/test/txn/getbykey/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	for _, key := range []string{"a", "b", "dir/x"} {
+		_, err := s.cli.Put(context.Background(), prefix+key, key+"-val")
+		s.Require().NoError(err)
+	}
+
+	res, err := txn.New(s.cli).
+		IfMissing(prefix+"a").
+		ThenPut(prefix+"a", "new").
+		ElsePut(prefix+"c", "C").
+		ElseGet(prefix+"b").
+		ElseGet(prefix+"missing").
+		ElseGet(prefix+"a").
+		ElseGet(prefix+"dir/", clientv3.WithPrefix()).
+		Commit(context.Background())
+	s.Require().NoError(err)
+	s.False(res.Succeeded)
+
+	for key, want := range map[string]string{"a": "a-val", "b": "b-val", "dir/x": "dir/x-val"} {
+		kv, ok := res.GetByKey(prefix + key)
+		if s.True(ok, key) {
+			s.Equal(want, string(kv.Value))
+		}
+	}
+	// not found, or only put
+	for _, key := range []string{"missing", "c", "dir/", "dir/y"} {
+		_, ok := res.GetByKey(prefix + key)
+		s.False(ok, key)
+	}
+
+	s.Run("Nested txn", func() {
+		res, err := txn.New(s.cli).
+			ThenTxn(func(b *txn.Builder) {
+				b.IfMissing(prefix + "b").ElseGet(prefix + "b")
+			}).
+			Commit(context.Background())
+		s.Require().NoError(err)
+		_, ok := res.GetByKey(prefix + "b")
+		s.False(ok)
+		kv, ok := res.Txn(0).GetByKey(prefix + "b")
+		s.True(ok)
+		s.Equal("b-val", string(kv.Value))
+	})
+}
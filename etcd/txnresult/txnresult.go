@@ -0,0 +1,63 @@
+package txnresult
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gojustforfun/learn-by-test/etcd/op"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrIndexOutOfRange = errors.New("txnresult: no response at index")
+
+// At returns the response at index i of the branch that ran, the i-th op of
+// its Then or Else. An index past the ops is ErrIndexOutOfRange.
+func At(resp *clientv3.TxnResponse, i int) (clientv3.OpResponse, error) {
+	if i < 0 || i >= len(resp.Responses) {
+		return clientv3.OpResponse{}, fmt.Errorf("%w: %d of %d", ErrIndexOutOfRange, i, len(resp.Responses))
+	}
+	switch r := resp.Responses[i].Response.(type) {
+	case *pb.ResponseOp_ResponseRange:
+		return (*clientv3.GetResponse)(r.ResponseRange).OpResponse(), nil
+	case *pb.ResponseOp_ResponsePut:
+		return (*clientv3.PutResponse)(r.ResponsePut).OpResponse(), nil
+	case *pb.ResponseOp_ResponseDeleteRange:
+		return (*clientv3.DeleteResponse)(r.ResponseDeleteRange).OpResponse(), nil
+	case *pb.ResponseOp_ResponseTxn:
+		return (*clientv3.TxnResponse)(r.ResponseTxn).OpResponse(), nil
+	}
+	return clientv3.OpResponse{}, nil
+}
+
+// RangeAt returns the get response at index i, or op.ErrUnexpectedResponse if
+// the op there is not a get.
+func RangeAt(resp *clientv3.TxnResponse, i int) (*clientv3.GetResponse, error) {
+	return as(resp, i, op.AsGet)
+}
+
+func PutAt(resp *clientv3.TxnResponse, i int) (*clientv3.PutResponse, error) {
+	return as(resp, i, op.AsPut)
+}
+
+func DeleteAt(resp *clientv3.TxnResponse, i int) (*clientv3.DeleteResponse, error) {
+	return as(resp, i, op.AsDelete)
+}
+
+// TxnAt returns the response of the nested txn at index i.
+func TxnAt(resp *clientv3.TxnResponse, i int) (*clientv3.TxnResponse, error) {
+	return as(resp, i, op.AsTxn)
+}
+
+func as[T any](resp *clientv3.TxnResponse, i int, fn func(clientv3.OpResponse) (T, error)) (T, error) {
+	r, err := At(resp, i)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	v, err := fn(r)
+	if err != nil {
+		return v, fmt.Errorf("txnresult: response %d: %w", i, err)
+	}
+	return v, nil
+}
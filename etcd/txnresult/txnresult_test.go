@@ -0,0 +1,62 @@
+package txnresult_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/op"
+	"github.com/gojustforfun/learn-by-test/etcd/txnresult"
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type TxnresultTestSuite struct {
+	suite.Suite
+	kv *fake.KV
+}
+
+func TestTxnresultTestSuite(t *testing.T) {
+	suite.Run(t, new(TxnresultTestSuite))
+}
+
+func (s *TxnresultTestSuite) SetupTest() {
+	s.kv = fake.NewKV()
+}
+
+func (s *TxnresultTestSuite) TestAt() {
+	ctx := context.Background()
+	_, err := s.kv.Put(ctx, "k", "v")
+	s.Require().NoError(err)
+
+	resp, err := s.kv.Txn(ctx).Then(
+		clientv3.OpGet("k"),
+		clientv3.OpPut("k", "w"),
+		clientv3.OpDelete("other"),
+		clientv3.OpTxn(nil, []clientv3.Op{clientv3.OpGet("k")}, nil),
+	).Commit()
+	s.Require().NoError(err)
+
+	get, err := txnresult.RangeAt(resp, 0)
+	s.Require().NoError(err)
+	s.Equal("v", string(get.Kvs[0].Value))
+	put, err := txnresult.PutAt(resp, 1)
+	s.Require().NoError(err)
+	s.Equal(resp.Header.Revision, put.Header.Revision)
+	del, err := txnresult.DeleteAt(resp, 2)
+	s.Require().NoError(err)
+	s.Zero(del.Deleted)
+	nested, err := txnresult.TxnAt(resp, 3)
+	s.Require().NoError(err)
+	get, err = txnresult.RangeAt(nested, 0)
+	s.Require().NoError(err)
+	s.Equal("w", string(get.Kvs[0].Value))
+
+	_, err = txnresult.RangeAt(resp, 1)
+	s.ErrorIs(err, op.ErrUnexpectedResponse)
+	s.EqualError(err, "txnresult: response 1: op: unexpected response type: want get, got put")
+	_, err = txnresult.PutAt(resp, 4)
+	s.ErrorIs(err, txnresult.ErrIndexOutOfRange)
+	_, err = txnresult.RangeAt(resp, -1)
+	s.ErrorIs(err, txnresult.ErrIndexOutOfRange)
+}
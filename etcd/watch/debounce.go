@@ -0,0 +1,64 @@
+package watch
+
+import (
+	"sort"
+	"time"
+)
+
+// Debounce coalesces bursts of events per key: an event is only passed on
+// once its key has seen no newer event for window, so a burst yields just its
+// last event, e.g. the DELETE of a key put and deleted in quick succession.
+// Pending events are flushed when in is closed, and then the returned channel
+// is closed.
+func Debounce(in <-chan Event, window time.Duration) <-chan Event {
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+
+		type pending struct {
+			event Event
+			due   time.Time
+		}
+		keys := make(map[string]pending)
+		// emit sends the events due by now, oldest first
+		emit := func(now time.Time) {
+			var due []pending
+			for key, p := range keys {
+				if !p.due.After(now) {
+					due = append(due, p)
+					delete(keys, key)
+				}
+			}
+			sort.Slice(due, func(i, j int) bool { return due[i].due.Before(due[j].due) })
+			for _, p := range due {
+				out <- p.event
+			}
+		}
+
+		var timer <-chan time.Time
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					emit(time.Now().Add(window))
+					return
+				}
+				keys[string(ev.Kv.Key)] = pending{event: ev, due: time.Now().Add(window)}
+			case now := <-timer:
+				emit(now)
+			}
+
+			timer = nil
+			var next time.Time
+			for _, p := range keys {
+				if next.IsZero() || p.due.Before(next) {
+					next = p.due
+				}
+			}
+			if !next.IsZero() {
+				timer = time.After(time.Until(next))
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,47 @@
+package watch_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func event(typ mvccpb.Event_EventType, key, val string) watch.Event {
+	return watch.Event{Type: typ, Kv: &mvccpb.KeyValue{Key: []byte(key), Value: []byte(val)}}
+}
+
+func (s *WatchTestSuite) TestDebounce() {
+	in := make(chan watch.Event)
+	out := watch.Debounce(in, 100*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		in <- event(mvccpb.PUT, "a", fmt.Sprint(i))
+	}
+	select {
+	case ev := <-out:
+		s.Equal(event(mvccpb.PUT, "a", "9"), ev)
+	case <-time.After(time.Second):
+		s.FailNow("no event")
+	}
+	select {
+	case ev := <-out:
+		s.Failf("unexpected event", "%v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// keys are debounced independently, and a trailing DELETE wins
+	in <- event(mvccpb.PUT, "b", "1")
+	in <- event(mvccpb.PUT, "c", "1")
+	in <- event(mvccpb.DELETE, "b", "")
+	s.Equal(event(mvccpb.PUT, "c", "1"), <-out)
+	s.Equal(event(mvccpb.DELETE, "b", ""), <-out)
+
+	// closing the input flushes what is pending
+	in <- event(mvccpb.PUT, "d", "1")
+	close(in)
+	s.Equal(event(mvccpb.PUT, "d", "1"), <-out)
+	_, ok := <-out
+	s.False(ok)
+}
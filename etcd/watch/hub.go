@@ -0,0 +1,197 @@
+package watch
+
+import (
+	"context"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Hub shares watches among the subscribers of a process: subscriptions to
+// the same prefix with the same options are all fed by one Resumable, which
+// is closed once the last of them is.
+//
+// A subscription gets the events from when it subscribed on. WithBuffer and
+// WithOverflow apply to each subscription on its own and do not keep it from
+// sharing; the other options apply to the shared watch. A subscriber that
+// blocks upstream holds up the others of its watch, so slow ones should
+// rather drop or close on overflow.
+type Hub struct {
+	cli *clientv3.Client
+
+	mu      sync.Mutex
+	watches map[hubKey]*hubWatch
+}
+
+type hubKey struct {
+	prefix string
+	opts   options
+}
+
+type hubWatch struct {
+	key hubKey
+	r   *Resumable
+	// subs is guarded by the Hub's mu
+	subs map[*Subscription]struct{}
+}
+
+func NewHub(cli *clientv3.Client) *Hub {
+	return &Hub{cli: cli, watches: make(map[hubKey]*hubWatch)}
+}
+
+// Subscription is one subscriber's share of a watch.
+type Subscription struct {
+	h      *Hub
+	w      *hubWatch
+	policy Overflow
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	events chan Event
+	closed bool
+	err    error
+}
+
+// Subscribe starts receiving the changes under prefix, on the watch of an
+// earlier subscription if there is one with equal options.
+func (h *Hub) Subscribe(ctx context.Context, prefix string, opts ...Option) (*Subscription, error) {
+	o := options{buffer: defaultBuffer}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	subCtx, cancel := context.WithCancel(context.Background())
+	sub := &Subscription{h: h, policy: o.overflow, ctx: subCtx, cancel: cancel, events: make(chan Event, o.buffer)}
+	key := hubKey{prefix: prefix, opts: o}
+	key.opts.buffer, key.opts.overflow = 0, BlockUpstream
+
+	// the revision to start a new watch from is read without holding mu, so
+	// that a slow Get does not hold up the other subscriptions; the lookup
+	// is repeated after it, as another subscription may have started the
+	// watch meanwhile
+	var rev int64
+	for {
+		h.mu.Lock()
+		w, ok := h.watches[key]
+		if ok || rev > 0 {
+			if !ok {
+				// the shared watch waits for the hub, which applies the
+				// policy of each subscription
+				opts = append(opts, WithBuffer(defaultBuffer), WithOverflow(BlockUpstream))
+				w = &hubWatch{
+					key:  key,
+					r:    NewResumable(h.cli, prefix, rev, opts...),
+					subs: make(map[*Subscription]struct{}),
+				}
+				h.watches[key] = w
+				go h.fanOut(w)
+			}
+			w.subs[sub] = struct{}{}
+			sub.w = w
+			h.mu.Unlock()
+			return sub, nil
+		}
+		h.mu.Unlock()
+
+		getResp, err := h.cli.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithCountOnly())
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		rev = getResp.Header.Revision
+	}
+}
+
+func (h *Hub) fanOut(w *hubWatch) {
+	for event := range w.r.Events() {
+		h.mu.Lock()
+		subs := make([]*Subscription, 0, len(w.subs))
+		for sub := range w.subs {
+			subs = append(subs, sub)
+		}
+		h.mu.Unlock()
+		for _, sub := range subs {
+			if !sub.deliver(event) {
+				h.unsubscribe(sub)
+			}
+		}
+	}
+
+	// the watch stopped, either after the last subscription was closed or on
+	// its own
+	h.mu.Lock()
+	if h.watches[w.key] == w {
+		delete(h.watches, w.key)
+	}
+	subs := w.subs
+	w.subs = nil
+	h.mu.Unlock()
+	for sub := range subs {
+		sub.end(w.r.Err())
+	}
+}
+
+// unsubscribe drops sub from its watch, closing the watch if sub was the last.
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	w := sub.w
+	delete(w.subs, sub)
+	last := len(w.subs) == 0 && h.watches[w.key] == w
+	if last {
+		delete(h.watches, w.key)
+	}
+	h.mu.Unlock()
+
+	if last {
+		// the fan-out ends once the events are closed
+		go w.r.Close()
+	}
+}
+
+// Events streams the changes in revision order. It is closed by Close, or
+// when the subscription stops on its own, see Err.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns ErrOverflow once the subscription was closed for overflowing
+// its buffer, the error the shared watch stopped with, or nil.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close unsubscribes and closes the event stream.
+func (s *Subscription) Close() {
+	s.cancel()
+	s.h.unsubscribe(s)
+	s.end(nil)
+}
+
+// deliver hands event over as the policy says, and reports false if the
+// subscription ended with ErrOverflow.
+func (s *Subscription) deliver(event Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	dropped := func(ev Event) { s.w.r.dropped(s.ctx, ev) }
+	if push(s.ctx, s.events, event, s.policy, dropped) || s.policy != CloseOnOverflow {
+		return true
+	}
+	s.closed, s.err = true, ErrOverflow
+	close(s.events)
+	return false
+}
+
+func (s *Subscription) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed, s.err = true, err
+	close(s.events)
+}
@@ -0,0 +1,142 @@
+package watch_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// countingWatcher counts the watches opened and those still open.
+type countingWatcher struct {
+	clientv3.Watcher
+	opened, open atomic.Int64
+}
+
+func (w *countingWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	w.opened.Add(1)
+	w.open.Add(1)
+	go func() {
+		<-ctx.Done()
+		w.open.Add(-1)
+	}()
+	return w.Watcher.Watch(ctx, key, opts...)
+}
+
+func (s *WatchTestSuite) TestHub() {
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+	watcher := &countingWatcher{Watcher: cli.Watcher}
+	cli.Watcher = watcher
+	hub := watch.NewHub(cli)
+
+	sub1, err := hub.Subscribe(context.Background(), "/hub/")
+	s.Require().NoError(err)
+	sub2, err := hub.Subscribe(context.Background(), "/hub/", watch.WithBuffer(4))
+	s.Require().NoError(err)
+	puts, err := hub.Subscribe(context.Background(), "/hub/", watch.WithEventFilter(watch.PutOnly))
+	s.Require().NoError(err)
+	s.Eventually(func() bool { return watcher.opened.Load() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		_, err := cli.Put(context.Background(), fmt.Sprint("/hub/", i), "v")
+		s.Require().NoError(err)
+	}
+	for i := 0; i < 3; i++ {
+		s.Equal(fmt.Sprint("/hub/", i), s.next(sub1.Events()).Key)
+		s.Equal(fmt.Sprint("/hub/", i), s.next(sub2.Events()).Key)
+		s.Equal(fmt.Sprint("/hub/", i), s.next(puts.Events()).Key)
+	}
+
+	// the watch outlives all but its last subscriber
+	sub1.Close()
+	_, ok := <-sub1.Events()
+	s.False(ok)
+	_, err = cli.Put(context.Background(), "/hub/3", "v")
+	s.Require().NoError(err)
+	s.Equal("/hub/3", s.next(sub2.Events()).Key)
+	s.Equal(int64(2), watcher.open.Load())
+	s.Equal(int64(2), watcher.opened.Load(), "equal options share a watch")
+
+	sub2.Close()
+	s.Eventually(func() bool { return watcher.open.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	puts.Close()
+	s.Eventually(func() bool { return watcher.open.Load() == 0 }, 5*time.Second, 10*time.Millisecond)
+	s.NoError(puts.Err())
+}
+
+// blockingKV holds up the Gets of key until release is closed.
+type blockingKV struct {
+	clientv3.KV
+	key     string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (kv *blockingKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if key == kv.key {
+		close(kv.started)
+		<-kv.release
+	}
+	return kv.KV.Get(ctx, key, opts...)
+}
+
+func (s *WatchTestSuite) TestHubSlowGet() {
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+	kv := &blockingKV{KV: cli.KV, key: "/slow/", started: make(chan struct{}), release: make(chan struct{})}
+	cli.KV = kv
+	hub := watch.NewHub(cli)
+
+	slow := make(chan *watch.Subscription)
+	go func() {
+		sub, err := hub.Subscribe(context.Background(), "/slow/")
+		s.NoError(err)
+		slow <- sub
+	}()
+	<-kv.started
+
+	// another prefix subscribes while the first Get is pending
+	fast, err := hub.Subscribe(context.Background(), "/fast/")
+	s.Require().NoError(err)
+	defer fast.Close()
+	_, err = cli.Put(context.Background(), "/fast/a", "v")
+	s.Require().NoError(err)
+	s.Equal("/fast/a", s.next(fast.Events()).Key)
+
+	close(kv.release)
+	sub := <-slow
+	s.Require().NotNil(sub)
+	defer sub.Close()
+	_, err = cli.Put(context.Background(), "/slow/a", "v")
+	s.Require().NoError(err)
+	s.Equal("/slow/a", s.next(sub.Events()).Key)
+}
+
+func (s *WatchTestSuite) TestHubOverflow() {
+	cli := fake.NewClient(fake.NewClock())
+	defer cli.Close()
+	hub := watch.NewHub(cli)
+
+	fast, err := hub.Subscribe(context.Background(), "/hub/")
+	s.Require().NoError(err)
+	defer fast.Close()
+	slow, err := hub.Subscribe(context.Background(), "/hub/", watch.WithBuffer(1), watch.WithOverflow(watch.CloseOnOverflow))
+	s.Require().NoError(err)
+	defer slow.Close()
+
+	// the slow subscriber reads nothing and is cut off, the other goes on
+	for i := 0; i < 5; i++ {
+		_, err := cli.Put(context.Background(), fmt.Sprint("/hub/", i), "v")
+		s.Require().NoError(err)
+		s.Equal(fmt.Sprint("/hub/", i), s.next(fast.Events()).Key)
+	}
+	s.Equal("/hub/0", s.next(slow.Events()).Key)
+	_, ok := <-slow.Events()
+	s.False(ok)
+	s.ErrorIs(slow.Err(), watch.ErrOverflow)
+}
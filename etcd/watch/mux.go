@@ -0,0 +1,96 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrMuxClosed = errors.New("watch: mux closed")
+
+type MuxEvent struct {
+	// Prefix is the prefix the event was watched under, as passed to Add.
+	Prefix string
+	Event  *clientv3.Event
+	// Err is set, without an Event, when the watch of Prefix failed; no more
+	// events of Prefix follow.
+	Err error
+}
+
+// Mux merges the watches of several prefixes into one stream.
+type Mux struct {
+	cli    *clientv3.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	events chan MuxEvent
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func NewMux(cli *clientv3.Client) *Mux {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mux{cli: cli, ctx: ctx, cancel: cancel, events: make(chan MuxEvent, 16)}
+}
+
+// Add starts watching prefix; opts are passed on to the watch. It may be
+// called at any time before Close, also while Events is being consumed.
+func (m *Mux) Add(prefix string, opts ...clientv3.OpOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrMuxClosed
+	}
+
+	opts = append(opts, clientv3.WithPrefix())
+	watchChan := m.cli.Watch(clientv3.WithRequireLeader(m.ctx), prefix, opts...)
+	m.wg.Add(1)
+	go m.forward(prefix, watchChan)
+	return nil
+}
+
+// Events is closed by Close.
+func (m *Mux) Events() <-chan MuxEvent {
+	return m.events
+}
+
+// Close stops all watches and closes the event stream.
+func (m *Mux) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+	close(m.events)
+}
+
+func (m *Mux) forward(prefix string, watchChan clientv3.WatchChan) {
+	defer m.wg.Done()
+
+	for watchResp := range watchChan {
+		if err := watchResp.Err(); err != nil {
+			if m.ctx.Err() == nil {
+				m.emit(MuxEvent{Prefix: prefix, Err: err})
+			}
+			return
+		}
+		for _, ev := range watchResp.Events {
+			m.emit(MuxEvent{Prefix: prefix, Event: ev})
+		}
+	}
+}
+
+func (m *Mux) emit(event MuxEvent) {
+	select {
+	case m.events <- event:
+	case <-m.ctx.Done():
+	}
+}
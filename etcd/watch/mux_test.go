@@ -0,0 +1,56 @@
+package watch_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *WatchTestSuite) TestMux() {
+	root := "/test/watch/mux/"
+	defer s.cli.Delete(context.Background(), root, clientv3.WithPrefix())
+	prefixes := []string{root + "users/", root + "orders/", root + "items/"}
+
+	// watch from a fixed revision so no put is missed while watches start
+	getResp, err := s.cli.Get(context.Background(), root, clientv3.WithPrefix(), clientv3.WithCountOnly())
+	s.NoError(err)
+	from := clientv3.WithRev(getResp.Header.Revision + 1)
+
+	m := watch.NewMux(s.cli)
+	s.NoError(m.Add(prefixes[0], from))
+	s.NoError(m.Add(prefixes[1], from))
+
+	got := make(map[string][]string)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ev := range m.Events() {
+			s.NoError(ev.Err)
+			got[ev.Prefix] = append(got[ev.Prefix], string(ev.Event.Kv.Key))
+		}
+	}()
+
+	// added while events are being consumed
+	s.NoError(m.Add(prefixes[2], from))
+
+	for _, prefix := range prefixes {
+		for _, key := range []string{"1", "2"} {
+			_, err := s.cli.Put(context.Background(), prefix+key, "val")
+			s.NoError(err)
+		}
+	}
+	_, err = s.cli.Put(context.Background(), root+"unwatched", "val")
+	s.NoError(err)
+
+	time.Sleep(500 * time.Millisecond)
+	m.Close()
+	<-done
+
+	s.Len(got, 3)
+	for _, prefix := range prefixes {
+		s.Equal([]string{prefix + "1", prefix + "2"}, got[prefix])
+	}
+	s.ErrorIs(m.Add(root), watch.ErrMuxClosed)
+}
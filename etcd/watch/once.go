@@ -0,0 +1,49 @@
+package watch
+
+import (
+	"context"
+	"errors"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var ErrWatchClosed = errors.New("watch: closed before a matching event")
+
+// Once blocks until key is in a state predicate accepts, and returns the
+// event that got it there. The current state is tried first, as a PUT of the
+// current value or, if the key does not exist, as a DELETE whose Kv only
+// holds the key and the revision it was read at as ModRevision; when that
+// does not match, the changes after it are watched until one does or ctx is
+// done.
+func Once(ctx context.Context, cli *clientv3.Client, key string, predicate func(Event) bool) (Event, error) {
+	getResp, err := cli.Get(ctx, key)
+	if err != nil {
+		return Event{}, err
+	}
+	current := Event{Type: mvccpb.DELETE, Kv: &mvccpb.KeyValue{Key: []byte(key), ModRevision: getResp.Header.Revision}}
+	if len(getResp.Kvs) > 0 {
+		current = Event{Type: mvccpb.PUT, Kv: getResp.Kvs[0]}
+	}
+	if predicate(current) {
+		return current, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var watchResp clientv3.WatchResponse
+	for watchResp = range cli.Watch(ctx, key, clientv3.WithRev(getResp.Header.Revision+1)) {
+		for _, ev := range watchResp.Events {
+			if event := (Event{Type: ev.Type, Kv: ev.Kv}); predicate(event) {
+				return event, nil
+			}
+		}
+	}
+	if err := watchResp.Err(); err != nil {
+		return Event{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, ErrWatchClosed
+}
@@ -0,0 +1,61 @@
+package watch_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func valueIs(val string) func(watch.Event) bool {
+	return func(ev watch.Event) bool {
+		return ev.Type == mvccpb.PUT && string(ev.Kv.Value) == val
+	}
+}
+
+func (s *WatchTestSuite) TestOnceAlreadyTrue() {
+	key := "/test/watch/once/already"
+	defer s.cli.Delete(context.Background(), key)
+	putResp, err := s.cli.Put(context.Background(), key, "ready")
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ev, err := watch.Once(ctx, s.cli, key, valueIs("ready"))
+	s.Require().NoError(err)
+	s.Equal(mvccpb.PUT, ev.Type)
+	s.Equal(putResp.Header.Revision, ev.Kv.ModRevision)
+
+	// a missing key is a DELETE
+	ev, err = watch.Once(ctx, s.cli, key+"/missing", func(ev watch.Event) bool { return ev.Type == mvccpb.DELETE })
+	s.Require().NoError(err)
+	s.Equal(key+"/missing", string(ev.Kv.Key))
+}
+
+func (s *WatchTestSuite) TestOnceBecomesTrue() {
+	key := "/test/watch/once/later"
+	defer s.cli.Delete(context.Background(), key)
+	_, err := s.cli.Put(context.Background(), key, "starting")
+	s.Require().NoError(err)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		for _, val := range []string{"loading", "ready", "done"} {
+			s.cli.Put(context.Background(), key, val)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ev, err := watch.Once(ctx, s.cli, key, valueIs("ready"))
+	s.Require().NoError(err)
+	s.Equal("ready", string(ev.Kv.Value))
+	s.Equal(int64(3), ev.Kv.Version)
+
+	s.Run("Gives up with ctx", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := watch.Once(ctx, s.cli, key, valueIs("never"))
+		s.ErrorIs(err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,41 @@
+package watch_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *WatchTestSuite) TestProgressNotify() {
+	prefix := "/test/watch/progress/"
+	other := "/test/watch/progress-other"
+	defer s.cli.Delete(context.Background(), other)
+
+	putResp, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	r := watch.NewResumable(s.cli, prefix, putResp.Header.Revision, watch.WithProgressNotify())
+	defer r.Close()
+
+	// writes elsewhere advance the store revision but not the prefix
+	for i := 0; i < 3; i++ {
+		putResp, err = s.cli.Put(context.Background(), other, "x")
+		s.NoError(err)
+	}
+
+	// the server sends progress notifications every 10 minutes by default,
+	// so ask for one on the watch stream rather than idling that long
+	s.Eventually(func() bool {
+		s.NoError(s.cli.RequestProgress(clientv3.WithRequireLeader(context.Background())))
+		return r.Rev() >= putResp.Header.Revision
+	}, 5*time.Second, 100*time.Millisecond)
+
+	select {
+	case ev := <-r.Events():
+		s.Failf("unexpected event", "%v", ev)
+	default:
+	}
+}
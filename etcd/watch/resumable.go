@@ -0,0 +1,638 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+var (
+	ErrIdleTimeout = errors.New("watch: no response within the idle timeout")
+	ErrOverflow    = errors.New("watch: consumer fell behind the event buffer")
+)
+
+const defaultBuffer = 16
+
+// EventFilter selects the type of events a watch reports.
+type EventFilter int
+
+const (
+	AllEvents EventFilter = iota
+	PutOnly
+	DeleteOnly
+)
+
+// Overflow is what a watch does with an event when its buffer is full because
+// the consumer is not keeping up.
+type Overflow int
+
+const (
+	// BlockUpstream waits for the consumer, leaving further responses to
+	// pile up on the watch meanwhile.
+	BlockUpstream Overflow = iota
+	// DropOldest drops the oldest buffered event to make room, reporting
+	// the drop to the metrics and the logger.
+	DropOldest
+	// CloseOnOverflow stops the watch with ErrOverflow. Rev is then left
+	// before the revision of the event that did not fit.
+	CloseOnOverflow
+)
+
+type options struct {
+	progressNotify bool
+	logger         *slog.Logger
+	filter         EventFilter
+	idleTimeout    time.Duration
+	fragment       bool
+	resyncEvery    time.Duration
+	resyncJitter   time.Duration
+	metrics        Metrics
+	sampleEvery    time.Duration
+	buffer         int
+	overflow       Overflow
+}
+
+type Option func(*options)
+
+// WithProgressNotify asks the server for periodic progress notifications, so
+// that Rev keeps advancing while the prefix sees no changes and a resumed watch
+// does not have to replay the revisions of other keys.
+func WithProgressNotify() Option {
+	return func(o *options) {
+		o.progressNotify = true
+	}
+}
+
+// WithLogger logs every time the watch is resumed, and warns when it is
+// resumed from a fresh listing because its history was compacted.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithEventFilter reports only the events of the given type. The filtering is
+// done by the server, so the dropped events are never sent. With DeleteOnly,
+// the PUTs replaying the prefix after a compaction are dropped as well.
+func WithEventFilter(f EventFilter) Option {
+	return func(o *options) {
+		o.filter = f
+	}
+}
+
+// WithIdleTimeout stops the watch with ErrIdleTimeout when the server sends
+// nothing for d, events or progress notifications, so that a stalled watch
+// does not go unnoticed. Combine it with WithProgressNotify to watch quiet
+// prefixes. Time spent waiting on the consumer does not count.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = d
+	}
+}
+
+// WithFragment lets the server split responses too large to send at once,
+// instead of cancelling the watch over them. The client joins the fragments
+// back together, so events are only reported, and Rev only advances, once a
+// response is complete.
+func WithFragment() Option {
+	return func(o *options) {
+		o.fragment = true
+	}
+}
+
+// WithPeriodicResync re-lists the prefix every interval, plus a random part of
+// jitter so that many watchers do not list at once, and reports how it
+// differs from what the events so far described, as Reconcile events: a PUT
+// for every key added or changed, a DELETE for every key gone. It is a safety
+// net against lost events. A healthy watch reports nothing at a resync, except
+// for changes still on their way over the watch, which are then reported by
+// the resync instead; nothing is reported twice. With it, the keys deleted
+// within compacted history are reported at the next resync.
+//
+// The described state starts out as the prefix at the revision the watch
+// starts after, so that revision should not be compacted yet.
+func WithPeriodicResync(interval, jitter time.Duration) Option {
+	return func(o *options) {
+		o.resyncEvery, o.resyncJitter = interval, jitter
+	}
+}
+
+// WithBuffer buffers up to n events for the consumer, 16 by default.
+func WithBuffer(n int) Option {
+	return func(o *options) {
+		o.buffer = n
+	}
+}
+
+// WithOverflow sets what happens when the buffer is full, BlockUpstream by
+// default.
+func WithOverflow(p Overflow) Option {
+	return func(o *options) {
+		o.overflow = p
+	}
+}
+
+// Metrics receives the instrumentation of a Resumable; metrics.Client
+// provides one backed by Prometheus.
+type Metrics interface {
+	// Events is called with every event handed to the consumer.
+	Events(n int)
+	// Lag is called with how many revisions the watch is behind the cluster.
+	Lag(revisions int64)
+	// Dropped is called with every event DropOldest dropped, after it was
+	// counted by Events.
+	Dropped(n int)
+}
+
+// WithMetrics reports the events handed to the consumer to m, and samples the
+// lag every interval: the cluster revision, read from a response header,
+// minus Rev. To keep a watch that is caught up from showing the writes to
+// other prefixes as lag, each sample first asks the server for a progress
+// notification.
+func WithMetrics(m Metrics, interval time.Duration) Option {
+	return func(o *options) {
+		o.metrics, o.sampleEvery = m, interval
+	}
+}
+
+func (f EventFilter) opts() []clientv3.OpOption {
+	switch f {
+	case PutOnly:
+		return []clientv3.OpOption{clientv3.WithFilterDelete()}
+	case DeleteOnly:
+		return []clientv3.OpOption{clientv3.WithFilterPut()}
+	}
+	return nil
+}
+
+type Event struct {
+	Type mvccpb.Event_EventType
+	Kv   *mvccpb.KeyValue
+	// Resync marks the PUTs replaying the current state of the prefix after
+	// the history needed to resume was compacted away. Keys deleted within
+	// the compacted history are not reported.
+	Resync bool
+	// Reconcile marks the changes found by a periodic resync, which the
+	// watch should have reported but did not. The Kv of a DELETE only holds
+	// the key, and the revision of the listing as ModRevision.
+	Reconcile bool
+}
+
+// ResyncRequired reports that the changes after From up to To could not be
+// watched one by one, because that history was compacted. State derived from
+// the events should be rebuilt from a listing of the prefix at To.
+//
+// Compaction is the only way revisions get skipped: etcd sends every change
+// after the revision a watch starts from, in order, or cancels the watch with
+// the compact revision. The revisions of the events themselves are expected
+// to jump, since writes outside the prefix take revisions too, so there is no
+// contiguity to check them for.
+type ResyncRequired struct {
+	From, To int64
+}
+
+// Resumable watches a prefix and hides the watch failing underneath: when the
+// watch closes it is re-established from the last seen revision, and when
+// that revision has been compacted the prefix is re-listed and watched from
+// the revision of the listing.
+type Resumable struct {
+	cli     *clientv3.Client
+	prefix  string
+	opts    options
+	cancel  context.CancelFunc
+	done    chan struct{}
+	events  chan Event
+	resyncs chan ResyncRequired
+
+	mu         sync.Mutex
+	rev        int64
+	err        error
+	idle       *time.Timer
+	subscribed bool
+
+	// high is the revision up to which changes have been handed over, as
+	// events or by a listing; unlike rev, progress notifications do not
+	// move it. It is only used by the run goroutine.
+	high int64
+	// view is the state described by the events so far, kept only with
+	// WithPeriodicResync; it is only used by the run goroutine.
+	view map[string]*mvccpb.KeyValue
+}
+
+// NewResumable starts watching prefix for changes after rev until Close is
+// called.
+func NewResumable(cli *clientv3.Client, prefix string, rev int64, opts ...Option) *Resumable {
+	o := options{buffer: defaultBuffer}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Resumable{
+		cli:     cli,
+		prefix:  prefix,
+		opts:    o,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		events:  make(chan Event, o.buffer),
+		resyncs: make(chan ResyncRequired, 1),
+		rev:     rev,
+		high:    rev,
+	}
+	if o.idleTimeout > 0 {
+		r.idle = time.AfterFunc(o.idleTimeout, func() {
+			r.mu.Lock()
+			r.err = ErrIdleTimeout
+			r.mu.Unlock()
+			cancel()
+		})
+	}
+	if o.resyncEvery > 0 {
+		r.view = make(map[string]*mvccpb.KeyValue)
+	}
+	go r.run(ctx)
+	return r
+}
+
+// Events streams the changes in revision order. Across reconnects too, no
+// event is older than or a repeat of one handed over before, except for the
+// Resync and Reconcile events, which describe the state instead. It is closed
+// by Close, or when the watch stops on its own, see Err.
+func (r *Resumable) Events() <-chan Event {
+	return r.events
+}
+
+// Rev returns the revision up to which changes have been observed.
+func (r *Resumable) Rev() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rev
+}
+
+// ResyncRequired streams a signal every time revisions were skipped. Signals
+// are only sent once ResyncRequired has been called, and the watch waits for
+// them to be read. The stream is closed along with Events.
+func (r *Resumable) ResyncRequired() <-chan ResyncRequired {
+	r.mu.Lock()
+	r.subscribed = true
+	r.mu.Unlock()
+	return r.resyncs
+}
+
+// Err returns ErrIdleTimeout once the watch stopped for being idle, or
+// ErrOverflow for overflowing its buffer, and nil otherwise.
+func (r *Resumable) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close stops watching and closes the event stream.
+func (r *Resumable) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *Resumable) run(ctx context.Context) {
+	defer close(r.done)
+	defer close(r.events)
+	defer close(r.resyncs)
+	if r.idle != nil {
+		defer r.idle.Stop()
+	}
+	if r.opts.metrics != nil {
+		sampled := make(chan struct{})
+		defer func() { <-sampled }()
+		go r.sampleLag(ctx, sampled)
+	}
+
+	// a nil timer channel never fires without WithPeriodicResync
+	var resyncTimer *time.Timer
+	var resyncC <-chan time.Time
+	if r.view != nil {
+		r.seed(ctx)
+		resyncTimer = time.NewTimer(r.resyncDelay())
+		defer resyncTimer.Stop()
+		resyncC = resyncTimer.C
+	}
+
+	for ctx.Err() == nil {
+		rev := r.Rev()
+		opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithRev(rev + 1)}
+		if r.opts.progressNotify {
+			opts = append(opts, clientv3.WithProgressNotify())
+		}
+		if r.opts.fragment {
+			opts = append(opts, clientv3.WithFragment())
+		}
+		opts = append(opts, r.opts.filter.opts()...)
+		watchChan := r.cli.Watch(clientv3.WithRequireLeader(ctx), r.prefix, opts...)
+		r.log(ctx, slog.LevelDebug, "watch established", slog.Int64("revision", rev))
+	watching:
+		for {
+			var watchResp clientv3.WatchResponse
+			var ok bool
+			select {
+			case watchResp, ok = <-watchChan:
+			case <-resyncC:
+				r.reconcile(ctx)
+				resyncTimer.Reset(r.resyncDelay())
+				continue
+			}
+			if !ok {
+				break watching
+			}
+
+			r.pauseIdle()
+			if watchResp.Err() != nil {
+				if watchResp.Err() == rpctypes.ErrCompacted {
+					r.resync(ctx)
+				}
+				r.resetIdle()
+				break watching
+			}
+			// a resumed watch, replaying what it was not meant to, never goes
+			// back to the high-water mark of what was handed over or past it,
+			// and only the events of one revision may share it, which all
+			// come in one response
+			last := r.high
+			for _, ev := range watchResp.Events {
+				if ev.Kv.ModRevision <= last || ev.Kv.ModRevision < r.high {
+					continue
+				}
+				r.high = ev.Kv.ModRevision
+				r.emit(ctx, Event{Type: ev.Type, Kv: ev.Kv})
+			}
+			// the header is that of the cluster, which may be past the events
+			// of a catch-up sent in batches, so only progress notifications,
+			// which carry no events, advance the revision to it; a resync may
+			// have moved it further already
+			rev := r.high
+			if len(watchResp.Events) == 0 {
+				rev = watchResp.Header.Revision
+			}
+			if rev > r.Rev() {
+				r.setRev(rev)
+			}
+			r.resetIdle()
+		}
+
+		// the watch closed, re-establish it from the last seen revision
+		select {
+		case <-ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// resync replays the current state of the prefix and moves the revision to
+// that of the listing. On failure the revision is kept, so the next watch
+// fails as compacted again and resync is retried.
+func (r *Resumable) resync(ctx context.Context) {
+	getRes, err := r.cli.Get(ctx, r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return
+	}
+	if r.opts.filter != DeleteOnly {
+		for _, kv := range getRes.Kvs {
+			r.emit(ctx, Event{Type: mvccpb.PUT, Kv: kv, Resync: true})
+		}
+	}
+
+	from := r.Rev()
+	r.log(ctx, slog.LevelWarn, "watch resumed after compaction",
+		slog.Int64("from", from), slog.Int64("revision", getRes.Header.Revision), slog.Int("keys", len(getRes.Kvs)))
+	r.setRev(getRes.Header.Revision)
+	r.high = getRes.Header.Revision
+
+	r.mu.Lock()
+	subscribed := r.subscribed
+	r.mu.Unlock()
+	if subscribed {
+		select {
+		case r.resyncs <- ResyncRequired{From: from, To: getRes.Header.Revision}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// seed lists the prefix at the starting revision as the initial view. If that
+// fails the view starts out empty, and the first reconcile reports every key.
+func (r *Resumable) seed(ctx context.Context) {
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if rev := r.Rev(); rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+	getRes, err := r.cli.Get(ctx, r.prefix, opts...)
+	if err != nil {
+		r.log(ctx, slog.LevelWarn, "listing initial state failed", slog.String("error", err.Error()))
+		return
+	}
+	for _, kv := range getRes.Kvs {
+		r.view[string(kv.Key)] = kv
+	}
+}
+
+// reconcile lists the prefix and emits the differences to the view. Events
+// after the listing revision arrive on the watch as usual, and the ones up to
+// it are dropped as already seen.
+func (r *Resumable) reconcile(ctx context.Context) {
+	getRes, err := r.cli.Get(ctx, r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return
+	}
+	rev := getRes.Header.Revision
+	if rev < r.Rev() {
+		return
+	}
+
+	listed := make(map[string]bool, len(getRes.Kvs))
+	var added, changed, removed int
+	for _, kv := range getRes.Kvs {
+		listed[string(kv.Key)] = true
+		if old, ok := r.view[string(kv.Key)]; ok && old.ModRevision == kv.ModRevision {
+			continue
+		} else if ok {
+			changed++
+		} else {
+			added++
+		}
+		r.emitReconciled(ctx, Event{Type: mvccpb.PUT, Kv: kv, Reconcile: true})
+	}
+	for key := range r.view {
+		if !listed[key] {
+			removed++
+			r.emitReconciled(ctx, Event{Type: mvccpb.DELETE, Kv: &mvccpb.KeyValue{Key: []byte(key), ModRevision: rev}, Reconcile: true})
+		}
+	}
+	if added+changed+removed > 0 {
+		r.log(ctx, slog.LevelWarn, "periodic resync found missed changes", slog.Int64("revision", rev),
+			slog.Int("added", added), slog.Int("changed", changed), slog.Int("removed", removed))
+	}
+	r.setRev(rev)
+	r.high = max(r.high, rev)
+}
+
+// emitReconciled emits a reconcile event unless the event filter drops its
+// type; the view takes it either way.
+func (r *Resumable) emitReconciled(ctx context.Context, event Event) {
+	if (event.Type == mvccpb.PUT && r.opts.filter == DeleteOnly) || (event.Type == mvccpb.DELETE && r.opts.filter == PutOnly) {
+		r.observe(event)
+		return
+	}
+	r.emit(ctx, event)
+}
+
+func (r *Resumable) resyncDelay() time.Duration {
+	d := r.opts.resyncEvery
+	if r.opts.resyncJitter > 0 {
+		d += time.Duration(rand.Int63n(int64(r.opts.resyncJitter)))
+	}
+	return d
+}
+
+// observe applies event to the view, if one is kept.
+func (r *Resumable) observe(event Event) {
+	if r.view == nil {
+		return
+	}
+	if event.Type == mvccpb.DELETE {
+		delete(r.view, string(event.Kv.Key))
+		return
+	}
+	r.view[string(event.Kv.Key)] = event.Kv
+}
+
+func (r *Resumable) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if r.opts.logger == nil {
+		return
+	}
+	r.opts.logger.LogAttrs(ctx, level, msg, append([]slog.Attr{slog.String("prefix", r.prefix)}, attrs...)...)
+}
+
+// pauseIdle stops the idle timer while a response is handed to the consumer,
+// and resetIdle restarts it once that is done.
+func (r *Resumable) pauseIdle() {
+	if r.idle != nil {
+		r.idle.Stop()
+	}
+}
+
+func (r *Resumable) resetIdle() {
+	if r.idle != nil {
+		r.idle.Reset(r.opts.idleTimeout)
+	}
+}
+
+// setRev moves the revision, unless the watch already stopped with an error.
+func (r *Resumable) setRev(rev int64) {
+	r.mu.Lock()
+	if r.err == nil {
+		r.rev = rev
+	}
+	r.mu.Unlock()
+}
+
+func (r *Resumable) emit(ctx context.Context, event Event) {
+	r.observe(event)
+	if r.opts.overflow == CloseOnOverflow && ctx.Err() != nil {
+		return
+	}
+	handed := push(ctx, r.events, event, r.opts.overflow, func(dropped Event) { r.dropped(ctx, dropped) })
+	switch {
+	case handed:
+		r.handed()
+	case r.opts.overflow == CloseOnOverflow && ctx.Err() == nil:
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = ErrOverflow
+			r.rev = min(r.rev, event.Kv.ModRevision-1)
+		}
+		r.mu.Unlock()
+		r.cancel()
+	}
+}
+
+// push hands event to ch as policy says when ch is full, calling dropped with
+// every event DropOldest drops. It reports false if the event was not handed
+// over, because CloseOnOverflow found ch full or ctx is done.
+func push(ctx context.Context, ch chan Event, event Event, policy Overflow, dropped func(Event)) bool {
+	switch policy {
+	case DropOldest:
+		for {
+			select {
+			case ch <- event:
+				return true
+			default:
+			}
+			select {
+			case ev := <-ch:
+				dropped(ev)
+			default:
+			}
+		}
+	case CloseOnOverflow:
+		select {
+		case ch <- event:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case ch <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// dropped reports an event DropOldest dropped.
+func (r *Resumable) dropped(ctx context.Context, event Event) {
+	if r.opts.metrics != nil {
+		r.opts.metrics.Dropped(1)
+	}
+	r.log(ctx, slog.LevelWarn, "watch buffer full, dropped oldest event",
+		slog.String("key", string(event.Kv.Key)), slog.Int64("revision", event.Kv.ModRevision))
+}
+
+func (r *Resumable) handed() {
+	if r.opts.metrics != nil {
+		r.opts.metrics.Events(1)
+	}
+}
+
+// sampleLag reports the lag every sampling interval until ctx is done.
+func (r *Resumable) sampleLag(ctx context.Context, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.opts.sampleEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// the notification only moves Rev if the watch is waiting for news,
+		// so a stalled consumer keeps its lag; the request goes to the
+		// stream of the watch, which is picked by the leader requirement
+		r.cli.RequestProgress(clientv3.WithRequireLeader(ctx))
+		resp, err := r.cli.Get(ctx, probeKey, clientv3.WithCountOnly())
+		if err != nil {
+			continue
+		}
+		lag := resp.Header.Revision - r.Rev()
+		if lag < 0 {
+			lag = 0
+		}
+		r.opts.metrics.Lag(lag)
+	}
+}
@@ -0,0 +1,538 @@
+package watch_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type seen struct {
+	Type   mvccpb.Event_EventType
+	Key    string
+	Value  string
+	Resync bool
+}
+
+func (s *WatchTestSuite) next(events <-chan watch.Event) seen {
+	select {
+	case ev := <-events:
+		return seen{Type: ev.Type, Key: string(ev.Kv.Key), Value: string(ev.Kv.Value), Resync: ev.Resync}
+	case <-time.After(5 * time.Second):
+		s.FailNow("no event")
+		return seen{}
+	}
+}
+
+func (s *WatchTestSuite) TestResumableCompacted() {
+	prefix := "/test/watch/compacted/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	putResp, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"a", "2")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"b", "1")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"c", "1")
+	s.NoError(err)
+	_, err = s.cli.Delete(context.Background(), prefix+"c")
+	s.NoError(err)
+
+	cli := s.faultyClient(&clientv3.WatchResponse{CompactRevision: putResp.Header.Revision + 3})
+	var logs bytes.Buffer
+	r := watch.NewResumable(cli, prefix, putResp.Header.Revision, watch.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	defer r.Close()
+
+	// the compacted history is replaced by the current state
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "a", Value: "2", Resync: true}, s.next(r.Events()))
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "b", Value: "1", Resync: true}, s.next(r.Events()))
+
+	_, err = s.cli.Put(context.Background(), prefix+"b", "2")
+	s.NoError(err)
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "b", Value: "2"}, s.next(r.Events()))
+
+	_, err = s.cli.Delete(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(seen{Type: mvccpb.DELETE, Key: prefix + "a"}, s.next(r.Events()))
+
+	r.Close()
+	s.Contains(logs.String(), "watch resumed after compaction")
+}
+
+func (s *WatchTestSuite) TestResumableClosed() {
+	prefix := "/test/watch/closed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	putResp, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"a", "2")
+	s.NoError(err)
+
+	// closed watches resume from where they left off
+	r := watch.NewResumable(s.faultyClient(nil, nil), prefix, putResp.Header.Revision)
+	defer r.Close()
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "a", Value: "2"}, s.next(r.Events()))
+
+	putResp, err = s.cli.Put(context.Background(), prefix+"b", "1")
+	s.NoError(err)
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "b", Value: "1"}, s.next(r.Events()))
+	s.Eventually(func() bool { return r.Rev() == putResp.Header.Revision }, time.Second, 10*time.Millisecond)
+}
+
+func (s *WatchTestSuite) TestResumableEventFilter() {
+	prefix := "/test/watch/filter/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	putResp, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+	deletes := watch.NewResumable(s.cli, prefix, putResp.Header.Revision-1, watch.WithEventFilter(watch.DeleteOnly))
+	defer deletes.Close()
+	puts := watch.NewResumable(s.cli, prefix, putResp.Header.Revision-1, watch.WithEventFilter(watch.PutOnly))
+	defer puts.Close()
+
+	_, err = s.cli.Put(context.Background(), prefix+"b", "1")
+	s.NoError(err)
+	_, err = s.cli.Delete(context.Background(), prefix+"a")
+	s.NoError(err)
+	_, err = s.cli.Put(context.Background(), prefix+"a", "2")
+	s.NoError(err)
+	_, err = s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+	s.NoError(err)
+
+	s.Equal(seen{Type: mvccpb.DELETE, Key: prefix + "a"}, s.next(deletes.Events()))
+	s.Equal(seen{Type: mvccpb.DELETE, Key: prefix + "a"}, s.next(deletes.Events()))
+	s.Equal(seen{Type: mvccpb.DELETE, Key: prefix + "b"}, s.next(deletes.Events()))
+
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "a", Value: "1"}, s.next(puts.Events()))
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "b", Value: "1"}, s.next(puts.Events()))
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "a", Value: "2"}, s.next(puts.Events()))
+
+	// nothing else comes through either watch
+	select {
+	case ev := <-deletes.Events():
+		s.Fail("unexpected event", "%v", ev)
+	case ev := <-puts.Events():
+		s.Fail("unexpected event", "%v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func (s *WatchTestSuite) TestResumableIdleTimeout() {
+	prefix := "/test/watch/idle/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	getResp, err := s.cli.Get(context.Background(), prefix)
+	s.Require().NoError(err)
+	r := watch.NewResumable(s.cli, prefix, getResp.Header.Revision, watch.WithIdleTimeout(300*time.Millisecond))
+	defer r.Close()
+
+	// a steady stream keeps the watch open well past the timeout
+	for i := 0; i < 10; i++ {
+		_, err := s.cli.Put(context.Background(), prefix+"a", fmt.Sprint(i))
+		s.NoError(err)
+		s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "a", Value: fmt.Sprint(i)}, s.next(r.Events()))
+		time.Sleep(100 * time.Millisecond)
+	}
+	s.NoError(r.Err())
+
+	// then silence closes it
+	select {
+	case _, ok := <-r.Events():
+		s.False(ok)
+	case <-time.After(5 * time.Second):
+		s.FailNow("watch not closed")
+	}
+	s.ErrorIs(r.Err(), watch.ErrIdleTimeout)
+}
+
+func (s *WatchTestSuite) TestResumableResyncRequired() {
+	prefix := "/test/watch/resync-required/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	putResp, err := s.cli.Put(context.Background(), prefix+"a", "1")
+	s.NoError(err)
+	from := putResp.Header.Revision
+	for i := 0; i < 3; i++ {
+		putResp, err = s.cli.Put(context.Background(), prefix+"a", fmt.Sprint(i+2))
+		s.NoError(err)
+	}
+
+	// a replayed event at an already seen revision, then a jump over
+	// compacted history
+	replayed := &clientv3.WatchResponse{Events: []*clientv3.Event{{
+		Type: mvccpb.PUT,
+		Kv:   &mvccpb.KeyValue{Key: []byte(prefix + "a"), Value: []byte("replayed"), ModRevision: from},
+	}}}
+	replayed.Header.Revision = from
+	cli := s.faultyClient(replayed, &clientv3.WatchResponse{CompactRevision: from + 2})
+	r := watch.NewResumable(cli, prefix, from)
+	defer r.Close()
+	resyncs := r.ResyncRequired()
+
+	select {
+	case sig := <-resyncs:
+		s.Equal(watch.ResyncRequired{From: from, To: putResp.Header.Revision}, sig)
+	case <-time.After(5 * time.Second):
+		s.FailNow("no resync signal")
+	}
+	// only the state at the listing comes through, not the replayed event
+	s.Equal(seen{Type: mvccpb.PUT, Key: prefix + "a", Value: "4", Resync: true}, s.next(r.Events()))
+
+	r.Close()
+	_, ok := <-resyncs
+	s.False(ok)
+}
+
+func (s *WatchTestSuite) TestResumableFragment() {
+	cli := fake.NewClient(fake.NewClock())
+	r := watch.NewResumable(cli, "/f/", 0, watch.WithFragment())
+	defer r.Close()
+
+	ops := make([]clientv3.Op, 20)
+	for i := range ops {
+		ops[i] = clientv3.OpPut(fmt.Sprintf("/f/%02d", i), "v")
+	}
+	txnResp, err := cli.Txn(context.Background()).Then(ops...).Commit()
+	s.Require().NoError(err)
+
+	// every fragment of the response arrives, and Rev moves past it as a whole
+	for i := range ops {
+		s.Equal(seen{Type: mvccpb.PUT, Key: fmt.Sprintf("/f/%02d", i), Value: "v"}, s.next(r.Events()))
+	}
+	s.Eventually(func() bool { return r.Rev() == txnResp.Header.Revision }, time.Second, 10*time.Millisecond)
+}
+
+// droppingWatcher loses the events of one key.
+type droppingWatcher struct {
+	clientv3.Watcher
+	key string
+}
+
+func (w *droppingWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+		for watchResp := range w.Watcher.Watch(ctx, key, opts...) {
+			var kept []*clientv3.Event
+			for _, ev := range watchResp.Events {
+				if string(ev.Kv.Key) != w.key {
+					kept = append(kept, ev)
+				}
+			}
+			watchResp.Events = kept
+			select {
+			case out <- watchResp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (s *WatchTestSuite) TestResumablePeriodicResync() {
+	cli := fake.NewClient(fake.NewClock())
+	putResp, err := cli.Put(context.Background(), "/r/lost", "1")
+	s.Require().NoError(err)
+	_, err = cli.Put(context.Background(), "/r/kept", "1")
+	s.Require().NoError(err)
+	cli.Watcher = &droppingWatcher{Watcher: cli.Watcher, key: "/r/lost"}
+
+	r := watch.NewResumable(cli, "/r/", putResp.Header.Revision+1, watch.WithPeriodicResync(200*time.Millisecond, 100*time.Millisecond))
+	defer r.Close()
+	next := func() watch.Event {
+		select {
+		case ev := <-r.Events():
+			return ev
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event")
+		}
+		return watch.Event{}
+	}
+
+	// the kept key comes through the watch, the lost one eventually through a
+	// resync
+	_, err = cli.Put(context.Background(), "/r/lost", "2")
+	s.Require().NoError(err)
+	_, err = cli.Put(context.Background(), "/r/kept", "2")
+	s.Require().NoError(err)
+	ev := next()
+	s.Equal(seen{Type: mvccpb.PUT, Key: "/r/kept", Value: "2"}, seen{Type: ev.Type, Key: string(ev.Kv.Key), Value: string(ev.Kv.Value)})
+	s.False(ev.Reconcile)
+	ev = next()
+	s.Equal(seen{Type: mvccpb.PUT, Key: "/r/lost", Value: "2"}, seen{Type: ev.Type, Key: string(ev.Kv.Key), Value: string(ev.Kv.Value)})
+	s.True(ev.Reconcile)
+
+	_, err = cli.Put(context.Background(), "/r/new", "1")
+	s.Require().NoError(err)
+	s.Equal("/r/new", string(next().Kv.Key))
+	delResp, err := cli.Delete(context.Background(), "/r/lost")
+	s.Require().NoError(err)
+	ev = next()
+	s.Equal(mvccpb.DELETE, ev.Type)
+	s.Equal("/r/lost", string(ev.Kv.Key))
+	s.True(ev.Reconcile)
+	s.GreaterOrEqual(ev.Kv.ModRevision, delResp.Header.Revision)
+
+	// once in sync, resyncs have nothing to report
+	select {
+	case ev := <-r.Events():
+		s.Fail("unexpected event", "%v", ev)
+	case <-time.After(700 * time.Millisecond):
+	}
+}
+
+// countingMetrics counts the events handed over and dropped.
+type countingMetrics struct {
+	mu            sync.Mutex
+	events, drops int
+}
+
+func (m *countingMetrics) Events(n int) {
+	m.mu.Lock()
+	m.events += n
+	m.mu.Unlock()
+}
+
+func (m *countingMetrics) Lag(int64) {}
+
+func (m *countingMetrics) Dropped(n int) {
+	m.mu.Lock()
+	m.drops += n
+	m.mu.Unlock()
+}
+
+func (m *countingMetrics) dropped() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.drops
+}
+
+func (s *WatchTestSuite) TestResumableOverflow() {
+	put := func(cli *clientv3.Client, n int) []int64 {
+		var revs []int64
+		for i := 0; i < n; i++ {
+			resp, err := cli.Put(context.Background(), fmt.Sprint("/overflow/", i), "v")
+			s.Require().NoError(err)
+			revs = append(revs, resp.Header.Revision)
+		}
+		return revs
+	}
+
+	s.Run("BlockUpstream", func() {
+		cli := fake.NewClient(fake.NewClock())
+		defer cli.Close()
+		r := watch.NewResumable(cli, "/overflow/", 0, watch.WithBuffer(2))
+		defer r.Close()
+
+		// a slow consumer gets every event, only late
+		put(cli, 10)
+		for i := 0; i < 10; i++ {
+			time.Sleep(5 * time.Millisecond)
+			s.Equal(fmt.Sprint("/overflow/", i), s.next(r.Events()).Key)
+		}
+		s.NoError(r.Err())
+	})
+
+	s.Run("DropOldest", func() {
+		cli := fake.NewClient(fake.NewClock())
+		defer cli.Close()
+		m := &countingMetrics{}
+		var logs bytes.Buffer
+		r := watch.NewResumable(cli, "/overflow/", 0, watch.WithBuffer(2), watch.WithOverflow(watch.DropOldest),
+			watch.WithMetrics(m, time.Hour), watch.WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		defer r.Close()
+
+		// the consumer only reads once everything was written, and finds
+		// the newest events
+		revs := put(cli, 10)
+		s.Eventually(func() bool { return m.dropped() == 8 }, 5*time.Second, 10*time.Millisecond)
+		s.Equal("/overflow/8", s.next(r.Events()).Key)
+		s.Equal("/overflow/9", s.next(r.Events()).Key)
+		s.Eventually(func() bool { return r.Rev() == revs[9] }, 5*time.Second, 10*time.Millisecond)
+		s.NoError(r.Err())
+		r.Close()
+		s.Contains(logs.String(), "dropped oldest event")
+	})
+
+	s.Run("CloseOnOverflow", func() {
+		cli := fake.NewClient(fake.NewClock())
+		defer cli.Close()
+		r := watch.NewResumable(cli, "/overflow/", 0, watch.WithBuffer(2), watch.WithOverflow(watch.CloseOnOverflow))
+		defer r.Close()
+
+		revs := put(cli, 10)
+		s.Eventually(func() bool { return r.Err() != nil }, 5*time.Second, 10*time.Millisecond)
+		s.ErrorIs(r.Err(), watch.ErrOverflow)
+
+		// the buffered events are still delivered, then the stream closes
+		s.Equal("/overflow/0", s.next(r.Events()).Key)
+		s.Equal("/overflow/1", s.next(r.Events()).Key)
+		_, ok := <-r.Events()
+		s.False(ok)
+		s.Less(r.Rev(), revs[2], "a new watch from Rev gets the event that did not fit")
+	})
+}
+
+// replayingWatcher plays one script of responses per watch, closing the
+// watch after each but the last, whatever revision the watch asks for.
+type replayingWatcher struct {
+	clientv3.Watcher
+	scripts [][]clientv3.WatchResponse
+
+	mu     sync.Mutex
+	starts []int64
+}
+
+func (w *replayingWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	w.mu.Lock()
+	w.starts = append(w.starts, clientv3.OpGet(key, opts...).Rev())
+	w.mu.Unlock()
+	script := w.scripts[0]
+	last := len(w.scripts) == 1
+	if !last {
+		w.scripts = w.scripts[1:]
+	}
+	out := make(chan clientv3.WatchResponse)
+	go func() {
+		defer close(out)
+		for _, watchResp := range script {
+			select {
+			case out <- watchResp:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if last {
+			<-ctx.Done()
+		}
+	}()
+	return out
+}
+
+func replayed(header int64, revs ...int64) clientv3.WatchResponse {
+	watchResp := clientv3.WatchResponse{Header: pb.ResponseHeader{Revision: header}}
+	for _, rev := range revs {
+		kv := &mvccpb.KeyValue{Key: []byte(fmt.Sprint("/replay/", rev)), ModRevision: rev}
+		watchResp.Events = append(watchResp.Events, &clientv3.Event{Type: mvccpb.PUT, Kv: kv})
+	}
+	return watchResp
+}
+
+func (s *WatchTestSuite) TestResumableReplayedEvents() {
+	cli := fake.NewClient(fake.NewClock())
+	cli.Watcher = &replayingWatcher{scripts: [][]clientv3.WatchResponse{
+		{replayed(4, 2, 3, 4)},
+		// the resumed watch replays what was seen, then a member lagging
+		// behind answers with an older header and an event out of order
+		{replayed(6, 3, 4, 5, 6), replayed(5, 6, 7, 6)},
+		{replayed(8, 7, 8), replayed(9, 9)},
+	}}
+	r := watch.NewResumable(cli, "/replay/", 1)
+	defer r.Close()
+
+	var revs []int64
+	for len(revs) < 8 {
+		select {
+		case ev := <-r.Events():
+			revs = append(revs, ev.Kv.ModRevision)
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event", "got %v", revs)
+		}
+	}
+	s.Equal([]int64{2, 3, 4, 5, 6, 7, 8, 9}, revs)
+	s.Eventually(func() bool { return r.Rev() == 9 }, time.Second, 10*time.Millisecond)
+	select {
+	case ev := <-r.Events():
+		s.Failf("unexpected event", "%v", ev.Kv)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *WatchTestSuite) TestResumableHeaderAhead() {
+	cli := fake.NewClient(fake.NewClock())
+	// a catch-up in batches, each with the header of the current revision
+	cli.Watcher = &replayingWatcher{scripts: [][]clientv3.WatchResponse{
+		{replayed(10, 2, 3), replayed(10, 4, 5)},
+	}}
+	r := watch.NewResumable(cli, "/replay/", 1)
+	defer r.Close()
+
+	var revs []int64
+	for len(revs) < 4 {
+		select {
+		case ev := <-r.Events():
+			revs = append(revs, ev.Kv.ModRevision)
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event", "got %v", revs)
+		}
+	}
+	s.Equal([]int64{2, 3, 4, 5}, revs)
+}
+
+func (s *WatchTestSuite) TestResumableDisconnectMidCatchUp() {
+	cli := fake.NewClient(fake.NewClock())
+	replaying := &replayingWatcher{scripts: [][]clientv3.WatchResponse{
+		// the watch breaks after the first batch of a catch-up up to 10
+		{replayed(10, 2, 3)},
+		{replayed(10, 4, 5)},
+	}}
+	cli.Watcher = replaying
+	r := watch.NewResumable(cli, "/replay/", 1)
+	defer r.Close()
+
+	var revs []int64
+	for len(revs) < 4 {
+		select {
+		case ev := <-r.Events():
+			revs = append(revs, ev.Kv.ModRevision)
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event", "got %v", revs)
+		}
+	}
+	s.Equal([]int64{2, 3, 4, 5}, revs)
+	s.Eventually(func() bool { return r.Rev() == 5 }, time.Second, 10*time.Millisecond)
+
+	// resumed right after the last event, not after the header
+	replaying.mu.Lock()
+	defer replaying.mu.Unlock()
+	s.Equal([]int64{2, 4}, replaying.starts)
+}
+
+func (s *WatchTestSuite) TestResumableLongCatchUp() {
+	prefix := "/test/watch/catch-up/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	// more revisions than etcd sends in one response
+	const n = 2500
+	getResp, err := s.cli.Get(context.Background(), prefix, clientv3.WithCountOnly())
+	s.Require().NoError(err)
+	from := getResp.Header.Revision
+	var last int64
+	for i := 0; i < n; i++ {
+		putResp, err := s.cli.Put(context.Background(), fmt.Sprintf("%s%04d", prefix, i), "")
+		s.Require().NoError(err)
+		last = putResp.Header.Revision
+	}
+
+	r := watch.NewResumable(s.cli, prefix, from)
+	defer r.Close()
+	for i := 0; i < n; i++ {
+		select {
+		case ev := <-r.Events():
+			s.Require().Equal(fmt.Sprintf("%s%04d", prefix, i), string(ev.Kv.Key))
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event", "got %d of %d", i, n)
+		}
+	}
+	s.Eventually(func() bool { return r.Rev() >= last }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,53 @@
+package watch
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gojustforfun/learn-by-test/etcd/etcdx"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// probeKey is read at past revisions to find how far back history goes; it
+// does not need to exist.
+const probeKey = "\x00watch-start-probe"
+
+// SafeStartRevision returns the earliest revision a watch can start at with
+// WithRev without failing as compacted: the compaction revision, or 1 if
+// nothing was compacted. etcd does not report the compaction revision
+// directly, so it is found by a binary search of reads at past revisions,
+// about log2 of the current revision of them.
+//
+// A compaction that runs after the call can still make the watch fail as
+// compacted; Resumable recovers from that.
+func SafeStartRevision(ctx context.Context, cli etcdx.KV) (int64, error) {
+	resp, err := cli.Get(ctx, probeKey, clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+
+	// readable(lo-1) is false or lo is 1, readable(hi) is true
+	lo, hi := int64(1), resp.Header.Revision
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		ok, err := readable(ctx, cli, mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, nil
+}
+
+func readable(ctx context.Context, cli etcdx.KV, rev int64) (bool, error) {
+	_, err := cli.Get(ctx, probeKey, clientv3.WithRev(rev), clientv3.WithCountOnly())
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		return false, nil
+	}
+	return err == nil, err
+}
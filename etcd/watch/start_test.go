@@ -0,0 +1,57 @@
+package watch_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func (s *WatchTestSuite) TestSafeStartRevision() {
+	cli := fake.NewClient(fake.NewClock())
+	rev, err := watch.SafeStartRevision(context.Background(), cli)
+	s.Require().NoError(err)
+	s.Equal(int64(1), rev)
+
+	var floor int64
+	for i := 0; i < 50; i++ {
+		putResp, err := cli.Put(context.Background(), "/start/a", "v")
+		s.Require().NoError(err)
+		if i == 30 {
+			floor = putResp.Header.Revision
+		}
+	}
+	_, err = cli.Compact(context.Background(), floor)
+	s.Require().NoError(err)
+
+	rev, err = watch.SafeStartRevision(context.Background(), cli)
+	s.Require().NoError(err)
+	s.Equal(floor, rev)
+
+	// a watch from there starts without having been compacted, and replays
+	// the history left
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	select {
+	case watchResp := <-cli.Watch(ctx, "/start/", clientv3.WithPrefix(), clientv3.WithRev(rev)):
+		s.NoError(watchResp.Err())
+		s.Equal(floor, watchResp.Events[0].Kv.ModRevision)
+	case <-time.After(5 * time.Second):
+		s.FailNow("no event")
+	}
+}
+
+func (s *WatchTestSuite) TestSafeStartRevisionCluster() {
+	rev, err := watch.SafeStartRevision(context.Background(), s.cli)
+	s.Require().NoError(err)
+	s.GreaterOrEqual(rev, int64(1))
+
+	_, err = s.cli.Get(context.Background(), "/test/watch/start", clientv3.WithRev(rev))
+	s.NoError(err)
+	if rev > 1 {
+		_, err = s.cli.Get(context.Background(), "/test/watch/start", clientv3.WithRev(rev-1))
+		s.Error(err)
+	}
+}
@@ -0,0 +1,125 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("watch: decode %s: %v", e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+type TypedEvent[T any] struct {
+	Type mvccpb.Event_EventType
+	Key  string
+	// Value is the zero T for a DELETE.
+	Value T
+	// PrevValue is only set when watching WithPrevKV and the key existed
+	// before.
+	PrevValue *T
+}
+
+// Typed watches a prefix of JSON-encoded values, decoding them into T.
+// Events and errors are delivered on separate channels, and both must be
+// drained for the watch to make progress.
+type Typed[T any] struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	events chan TypedEvent[T]
+	errors chan error
+}
+
+// NewTyped watches prefix until Close is called or the watch fails; opts are
+// passed on to the watch, e.g. WithRev, WithPrevKV or WithFragment, whose
+// fragments the client joins before they get here.
+func NewTyped[T any](cli *clientv3.Client, prefix string, opts ...clientv3.OpOption) *Typed[T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &Typed[T]{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		events: make(chan TypedEvent[T], 16),
+		errors: make(chan error, 16),
+	}
+	opts = append(opts, clientv3.WithPrefix())
+	go t.run(ctx, cli.Watch(clientv3.WithRequireLeader(ctx), prefix, opts...))
+	return t
+}
+
+// Events is closed once the watch ends.
+func (t *Typed[T]) Events() <-chan TypedEvent[T] {
+	return t.events
+}
+
+// Errors receives a *DecodeError for every value that does not decode, and
+// the error that ended the watch, if any. It is closed once the watch ends.
+func (t *Typed[T]) Errors() <-chan error {
+	return t.errors
+}
+
+// Close stops watching and closes both channels.
+func (t *Typed[T]) Close() {
+	t.cancel()
+	<-t.done
+}
+
+func (t *Typed[T]) run(ctx context.Context, watchChan clientv3.WatchChan) {
+	defer close(t.done)
+	defer close(t.errors)
+	defer close(t.events)
+
+	for watchResp := range watchChan {
+		if err := watchResp.Err(); err != nil {
+			if ctx.Err() == nil {
+				t.sendErr(ctx, err)
+			}
+			return
+		}
+		for _, ev := range watchResp.Events {
+			event, err := decode[T](ev)
+			if err != nil {
+				t.sendErr(ctx, err)
+				continue
+			}
+			select {
+			case t.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (t *Typed[T]) sendErr(ctx context.Context, err error) {
+	select {
+	case t.errors <- err:
+	case <-ctx.Done():
+	}
+}
+
+func decode[T any](ev *clientv3.Event) (TypedEvent[T], error) {
+	event := TypedEvent[T]{Type: ev.Type, Key: string(ev.Kv.Key)}
+	if ev.Type == mvccpb.PUT {
+		if err := json.Unmarshal(ev.Kv.Value, &event.Value); err != nil {
+			return event, &DecodeError{Key: event.Key, Err: err}
+		}
+	}
+	if ev.PrevKv != nil {
+		var prev T
+		if err := json.Unmarshal(ev.PrevKv.Value, &prev); err != nil {
+			return event, &DecodeError{Key: event.Key, Err: err}
+		}
+		event.PrevValue = &prev
+	}
+	return event, nil
+}
@@ -0,0 +1,97 @@
+package watch_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/gojustforfun/learn-by-test/etcd/fake"
+	"github.com/gojustforfun/learn-by-test/etcd/watch"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type Endpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func (s *WatchTestSuite) TestTyped() {
+	prefix := "/test/watch/typed/"
+	defer s.cli.Delete(context.Background(), prefix, clientv3.WithPrefix())
+
+	put := func(key string, val interface{}) {
+		b, err := json.Marshal(val)
+		s.NoError(err)
+		_, err = s.cli.Put(context.Background(), prefix+key, string(b))
+		s.NoError(err)
+	}
+	next := func(t *watch.Typed[Endpoint]) watch.TypedEvent[Endpoint] {
+		select {
+		case ev := <-t.Events():
+			return ev
+		case err := <-t.Errors():
+			s.FailNow("unexpected error", "%v", err)
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event")
+		}
+		return watch.TypedEvent[Endpoint]{}
+	}
+
+	t := watch.NewTyped[Endpoint](s.cli, prefix, clientv3.WithPrevKV())
+	defer t.Close()
+
+	put("a", Endpoint{Host: "10.0.0.1", Port: 80})
+	s.Equal(watch.TypedEvent[Endpoint]{Type: mvccpb.PUT, Key: prefix + "a", Value: Endpoint{Host: "10.0.0.1", Port: 80}}, next(t))
+
+	put("a", Endpoint{Host: "10.0.0.1", Port: 8080})
+	s.Equal(watch.TypedEvent[Endpoint]{
+		Type:      mvccpb.PUT,
+		Key:       prefix + "a",
+		Value:     Endpoint{Host: "10.0.0.1", Port: 8080},
+		PrevValue: &Endpoint{Host: "10.0.0.1", Port: 80},
+	}, next(t))
+
+	_, err := s.cli.Delete(context.Background(), prefix+"a")
+	s.NoError(err)
+	s.Equal(watch.TypedEvent[Endpoint]{Type: mvccpb.DELETE, Key: prefix + "a", PrevValue: &Endpoint{Host: "10.0.0.1", Port: 8080}}, next(t))
+
+	// values that do not decode are reported, and later ones still arrive
+	_, err = s.cli.Put(context.Background(), prefix+"bad", "not json")
+	s.NoError(err)
+	select {
+	case err := <-t.Errors():
+		var decodeErr *watch.DecodeError
+		s.ErrorAs(err, &decodeErr)
+		s.Equal(prefix+"bad", decodeErr.Key)
+	case <-time.After(5 * time.Second):
+		s.FailNow("no error")
+	}
+
+	put("b", Endpoint{Host: "10.0.0.2", Port: 80})
+	s.Equal(prefix+"b", next(t).Key)
+}
+
+func (s *WatchTestSuite) TestTypedFragment() {
+	cli := fake.NewClient(fake.NewClock())
+	t := watch.NewTyped[Endpoint](cli, "/f/", clientv3.WithFragment())
+	defer t.Close()
+
+	_, err := cli.Txn(context.Background()).Then(
+		clientv3.OpPut("/f/a", `{"host":"a","port":1}`),
+		clientv3.OpPut("/f/b", `{"host":"b","port":2}`),
+		clientv3.OpPut("/f/c", `{"host":"c","port":3}`),
+	).Commit()
+	s.Require().NoError(err)
+
+	var got []Endpoint
+	for len(got) < 3 {
+		select {
+		case ev := <-t.Events():
+			got = append(got, ev.Value)
+		case <-time.After(5 * time.Second):
+			s.FailNow("no event")
+		}
+	}
+	s.Equal([]Endpoint{{"a", 1}, {"b", 2}, {"c", 3}}, got)
+}
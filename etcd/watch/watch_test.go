@@ -0,0 +1,66 @@
+package watch_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type WatchTestSuite struct {
+	suite.Suite
+	cli *clientv3.Client
+}
+
+func TestWatchTestSuite(t *testing.T) {
+	suite.Run(t, new(WatchTestSuite))
+}
+
+func (s *WatchTestSuite) SetupSuite() {
+	var err error
+	s.cli, err = clientv3.New(clientv3.Config{
+		Endpoints:   []string{"http://localhost:12379", "http://localhost:22379", "http://localhost:32379"},
+		DialTimeout: 5 * time.Second,
+	})
+	s.NoError(err)
+}
+
+func (s *WatchTestSuite) TearDownSuite() {
+	s.cli.Close()
+}
+
+// faultyClient returns a client whose first watches fail with faults in turn,
+// a nil fault being a watch that closes without any response.
+func (s *WatchTestSuite) faultyClient(faults ...*clientv3.WatchResponse) *clientv3.Client {
+	cli := clientv3.NewCtxClient(context.Background())
+	cli.KV = s.cli.KV
+	cli.Watcher = &faultyWatcher{Watcher: s.cli.Watcher, faults: faults}
+	return cli
+}
+
+type faultyWatcher struct {
+	clientv3.Watcher
+
+	mu     sync.Mutex
+	faults []*clientv3.WatchResponse
+}
+
+func (w *faultyWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.faults) == 0 {
+		return w.Watcher.Watch(ctx, key, opts...)
+	}
+
+	fault := w.faults[0]
+	w.faults = w.faults[1:]
+	watchChan := make(chan clientv3.WatchResponse, 1)
+	if fault != nil {
+		watchChan <- *fault
+	}
+	close(watchChan)
+	return watchChan
+}